@@ -0,0 +1,46 @@
+package eapaka
+
+// Find returns the first attribute of type T in p, e.g. Find[*AtRand](p).
+func Find[T Attribute](p *Packet) (T, bool) {
+	for _, attr := range p.Attributes {
+		if v, ok := attr.(T); ok {
+			return v, true
+		}
+	}
+	var zero T
+	return zero, false
+}
+
+// FindAll returns all attributes of type T in p, in order (e.g., every AT_KDF
+// offered in an EAP-AKA' challenge).
+func FindAll[T Attribute](p *Packet) []T {
+	var all []T
+	for _, attr := range p.Attributes {
+		if v, ok := attr.(T); ok {
+			all = append(all, v)
+		}
+	}
+	return all
+}
+
+// Attribute returns the first attribute of type t.
+func (p *Packet) Attribute(t AttributeType) (Attribute, bool) {
+	for _, attr := range p.Attributes {
+		if attr.Type() == t {
+			return attr, true
+		}
+	}
+	return nil, false
+}
+
+// Has reports whether p contains an attribute of type t.
+func (p *Packet) Has(t AttributeType) bool {
+	_, ok := p.Attribute(t)
+	return ok
+}
+
+// HasResultInd reports whether p contains AT_RESULT_IND, i.e. whether the
+// sender wants to use protected result indications (RFC 4187 Section 6.2).
+func (p *Packet) HasResultInd() bool {
+	return p.Has(AT_RESULT_IND)
+}
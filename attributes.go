@@ -3,6 +3,8 @@ package eapaka
 import (
 	"encoding/binary"
 	"errors"
+	"fmt"
+	"slices"
 )
 
 // Attribute is the interface implemented by all EAP-AKA attributes.
@@ -17,28 +19,28 @@ type Attribute interface {
 	Unmarshal(data []byte) error
 }
 
-// marshalAttribute is a helper to marshal header and data with padding.
-func marshalAttribute(t AttributeType, data []byte) ([]byte, error) {
-	// Length is in multiples of 4 bytes.
-	// Header is 2 bytes (Type + Length).
-	// Total length = 2 + len(data) + padding
-	totalLen := 2 + len(data)
-	padding := 0
-	if totalLen%4 != 0 {
-		padding = 4 - (totalLen % 4)
-		totalLen += padding
-	}
+// attributeLen returns the length of an attribute with an n-byte value, including
+// the 2-byte header and padding to a multiple of 4 bytes.
+func attributeLen(n int) int {
+	return (2 + n + 3) &^ 3
+}
 
+// appendAttribute appends the header of an attribute of type t, followed by an
+// n-byte zeroed value and zero padding, to b. It returns the extended slice and
+// the value part for the caller to fill in.
+func appendAttribute(b []byte, t AttributeType, n int) ([]byte, []byte, error) {
+	// Length is in multiples of 4 bytes.
+	totalLen := attributeLen(n)
 	if totalLen > 255*4 {
-		return nil, errors.New("attribute too long")
+		return b, nil, fmt.Errorf("%w: %s too long", ErrInvalidAttribute, t)
 	}
-
-	b := make([]byte, totalLen)
-	b[0] = uint8(t)
-	b[1] = uint8(totalLen / 4)
-	copy(b[2:], data)
-	// Padding is zero-initialized by make
-	return b, nil
+	b = slices.Grow(b, totalLen)
+	start := len(b)
+	b = b[:start+totalLen]
+	clear(b[start:])
+	b[start] = uint8(t)
+	b[start+1] = uint8(totalLen / 4)
+	return b, b[start+2 : start+2+n], nil
 }
 
 // AT_RAND (RFC 4187 Section 10.6)
@@ -46,19 +48,27 @@ type AtRand struct {
 	Rand []byte // 16 bytes
 }
 
-func (a *AtRand) Type() AttributeType { return AT_RAND }
-func (a *AtRand) Marshal() ([]byte, error) {
+func (a *AtRand) Type() AttributeType      { return AT_RAND }
+func (a *AtRand) Len() int                 { return attributeLen(18) }
+func (a *AtRand) Marshal() ([]byte, error) { return a.AppendBinary(make([]byte, 0, a.Len())) }
+func (a *AtRand) AppendBinary(b []byte) ([]byte, error) {
+	// RFC 4187: 2 bytes reserved + 16 bytes RAND
 	if len(a.Rand) != 16 {
-		return nil, errors.New("AT_RAND must be 16 bytes")
+		return b, fmt.Errorf("%w: AT_RAND must be 16 bytes", ErrInvalidAttribute)
+	}
+	b, v, err := appendAttribute(b, AT_RAND, 18)
+	if err != nil {
+		return b, err
 	}
-	return marshalAttribute(AT_RAND, a.Rand)
+	copy(v[2:], a.Rand)
+	return b, nil
 }
 func (a *AtRand) Unmarshal(data []byte) error {
-	if len(data) < 16 {
+	if len(data) < 18 {
 		return errors.New("invalid AT_RAND length")
 	}
 	a.Rand = make([]byte, 16)
-	copy(a.Rand, data[:16])
+	copy(a.Rand, data[2:18])
 	return nil
 }
 
@@ -67,19 +77,27 @@ type AtAutn struct {
 	Autn []byte // 16 bytes
 }
 
-func (a *AtAutn) Type() AttributeType { return AT_AUTN }
-func (a *AtAutn) Marshal() ([]byte, error) {
+func (a *AtAutn) Type() AttributeType      { return AT_AUTN }
+func (a *AtAutn) Len() int                 { return attributeLen(18) }
+func (a *AtAutn) Marshal() ([]byte, error) { return a.AppendBinary(make([]byte, 0, a.Len())) }
+func (a *AtAutn) AppendBinary(b []byte) ([]byte, error) {
+	// RFC 4187: 2 bytes reserved + 16 bytes AUTN
 	if len(a.Autn) != 16 {
-		return nil, errors.New("AT_AUTN must be 16 bytes")
+		return b, fmt.Errorf("%w: AT_AUTN must be 16 bytes", ErrInvalidAttribute)
 	}
-	return marshalAttribute(AT_AUTN, a.Autn)
+	b, v, err := appendAttribute(b, AT_AUTN, 18)
+	if err != nil {
+		return b, err
+	}
+	copy(v[2:], a.Autn)
+	return b, nil
 }
 func (a *AtAutn) Unmarshal(data []byte) error {
-	if len(data) < 16 {
+	if len(data) < 18 {
 		return errors.New("invalid AT_AUTN length")
 	}
 	a.Autn = make([]byte, 16)
-	copy(a.Autn, data[:16])
+	copy(a.Autn, data[2:18])
 	return nil
 }
 
@@ -88,14 +106,18 @@ type AtRes struct {
 	Res []byte // Variable length
 }
 
-func (a *AtRes) Type() AttributeType { return AT_RES }
-func (a *AtRes) Marshal() ([]byte, error) {
-	// RFC 4187: 2 bytes reserved + 2 bytes res length (bits) + res value
-	resLenBits := len(a.Res) * 8
-	buf := make([]byte, 2+len(a.Res))
-	binary.BigEndian.PutUint16(buf[0:2], uint16(resLenBits))
-	copy(buf[2:], a.Res)
-	return marshalAttribute(AT_RES, buf)
+func (a *AtRes) Type() AttributeType      { return AT_RES }
+func (a *AtRes) Len() int                 { return attributeLen(2 + len(a.Res)) }
+func (a *AtRes) Marshal() ([]byte, error) { return a.AppendBinary(make([]byte, 0, a.Len())) }
+func (a *AtRes) AppendBinary(b []byte) ([]byte, error) {
+	// RFC 4187: 2 bytes RES length (bits) + RES value
+	b, v, err := appendAttribute(b, AT_RES, 2+len(a.Res))
+	if err != nil {
+		return b, err
+	}
+	binary.BigEndian.PutUint16(v[0:2], uint16(len(a.Res)*8))
+	copy(v[2:], a.Res)
+	return b, nil
 }
 func (a *AtRes) Unmarshal(data []byte) error {
 	if len(data) < 2 {
@@ -116,12 +138,19 @@ type AtAuts struct {
 	Auts []byte // 14 bytes
 }
 
-func (a *AtAuts) Type() AttributeType { return AT_AUTS }
-func (a *AtAuts) Marshal() ([]byte, error) {
+func (a *AtAuts) Type() AttributeType      { return AT_AUTS }
+func (a *AtAuts) Len() int                 { return attributeLen(14) }
+func (a *AtAuts) Marshal() ([]byte, error) { return a.AppendBinary(make([]byte, 0, a.Len())) }
+func (a *AtAuts) AppendBinary(b []byte) ([]byte, error) {
 	if len(a.Auts) != 14 {
-		return nil, errors.New("AT_AUTS must be 14 bytes")
+		return b, fmt.Errorf("%w: AT_AUTS must be 14 bytes", ErrInvalidAttribute)
+	}
+	b, v, err := appendAttribute(b, AT_AUTS, 14)
+	if err != nil {
+		return b, err
 	}
-	return marshalAttribute(AT_AUTS, a.Auts)
+	copy(v, a.Auts)
+	return b, nil
 }
 func (a *AtAuts) Unmarshal(data []byte) error {
 	if len(data) < 14 {
@@ -137,18 +166,20 @@ type AtMac struct {
 	MAC []byte // 16 bytes
 }
 
-func (a *AtMac) Type() AttributeType { return AT_MAC }
-func (a *AtMac) Marshal() ([]byte, error) {
-	// RFC 4187: 2 bytes reserved + 16 bytes MAC
-	buf := make([]byte, 2+16)
-	// Reserved bytes are 0
-	if len(a.MAC) > 0 {
-		if len(a.MAC) != 16 {
-			return nil, errors.New("AT_MAC must be 16 bytes")
-		}
-		copy(buf[2:], a.MAC)
+func (a *AtMac) Type() AttributeType      { return AT_MAC }
+func (a *AtMac) Len() int                 { return attributeLen(18) }
+func (a *AtMac) Marshal() ([]byte, error) { return a.AppendBinary(make([]byte, 0, a.Len())) }
+func (a *AtMac) AppendBinary(b []byte) ([]byte, error) {
+	// RFC 4187: 2 bytes reserved + 16 bytes MAC. An empty MAC is encoded as zeros.
+	if len(a.MAC) != 0 && len(a.MAC) != 16 {
+		return b, fmt.Errorf("%w: AT_MAC must be 16 bytes", ErrInvalidAttribute)
+	}
+	b, v, err := appendAttribute(b, AT_MAC, 18)
+	if err != nil {
+		return b, err
 	}
-	return marshalAttribute(AT_MAC, buf)
+	copy(v[2:], a.MAC)
+	return b, nil
 }
 func (a *AtMac) Unmarshal(data []byte) error {
 	if len(data) < 18 {
@@ -164,14 +195,18 @@ type AtIdentity struct {
 	Identity string
 }
 
-func (a *AtIdentity) Type() AttributeType { return AT_IDENTITY }
-func (a *AtIdentity) Marshal() ([]byte, error) {
+func (a *AtIdentity) Type() AttributeType      { return AT_IDENTITY }
+func (a *AtIdentity) Len() int                 { return attributeLen(2 + len(a.Identity)) }
+func (a *AtIdentity) Marshal() ([]byte, error) { return a.AppendBinary(make([]byte, 0, a.Len())) }
+func (a *AtIdentity) AppendBinary(b []byte) ([]byte, error) {
 	// RFC 4187: 2 bytes actual length + identity
-	idBytes := []byte(a.Identity)
-	buf := make([]byte, 2+len(idBytes))
-	binary.BigEndian.PutUint16(buf[0:2], uint16(len(idBytes)))
-	copy(buf[2:], idBytes)
-	return marshalAttribute(AT_IDENTITY, buf)
+	b, v, err := appendAttribute(b, AT_IDENTITY, 2+len(a.Identity))
+	if err != nil {
+		return b, err
+	}
+	binary.BigEndian.PutUint16(v[0:2], uint16(len(a.Identity)))
+	copy(v[2:], a.Identity)
+	return b, nil
 }
 func (a *AtIdentity) Unmarshal(data []byte) error {
 	if len(data) < 2 {
@@ -190,10 +225,13 @@ type AtPermanentIdReq struct {
 	// Contains two reserved bytes, effectively empty for users
 }
 
-func (a *AtPermanentIdReq) Type() AttributeType { return AT_PERMANENT_ID_REQ }
-func (a *AtPermanentIdReq) Marshal() ([]byte, error) {
+func (a *AtPermanentIdReq) Type() AttributeType      { return AT_PERMANENT_ID_REQ }
+func (a *AtPermanentIdReq) Len() int                 { return attributeLen(2) }
+func (a *AtPermanentIdReq) Marshal() ([]byte, error) { return a.AppendBinary(make([]byte, 0, a.Len())) }
+func (a *AtPermanentIdReq) AppendBinary(b []byte) ([]byte, error) {
 	// Value is 2 bytes of reserved (0)
-	return marshalAttribute(AT_PERMANENT_ID_REQ, make([]byte, 2))
+	b, _, err := appendAttribute(b, AT_PERMANENT_ID_REQ, 2)
+	return b, err
 }
 func (a *AtPermanentIdReq) Unmarshal(data []byte) error {
 	// Just check length, data is ignored (reserved)
@@ -208,9 +246,12 @@ type AtAnyIdReq struct {
 	// Contains two reserved bytes
 }
 
-func (a *AtAnyIdReq) Type() AttributeType { return AT_ANY_ID_REQ }
-func (a *AtAnyIdReq) Marshal() ([]byte, error) {
-	return marshalAttribute(AT_ANY_ID_REQ, make([]byte, 2))
+func (a *AtAnyIdReq) Type() AttributeType      { return AT_ANY_ID_REQ }
+func (a *AtAnyIdReq) Len() int                 { return attributeLen(2) }
+func (a *AtAnyIdReq) Marshal() ([]byte, error) { return a.AppendBinary(make([]byte, 0, a.Len())) }
+func (a *AtAnyIdReq) AppendBinary(b []byte) ([]byte, error) {
+	b, _, err := appendAttribute(b, AT_ANY_ID_REQ, 2)
+	return b, err
 }
 func (a *AtAnyIdReq) Unmarshal(data []byte) error {
 	if len(data) < 2 {
@@ -224,9 +265,12 @@ type AtFullauthIdReq struct {
 	// Contains two reserved bytes
 }
 
-func (a *AtFullauthIdReq) Type() AttributeType { return AT_FULLAUTH_ID_REQ }
-func (a *AtFullauthIdReq) Marshal() ([]byte, error) {
-	return marshalAttribute(AT_FULLAUTH_ID_REQ, make([]byte, 2))
+func (a *AtFullauthIdReq) Type() AttributeType      { return AT_FULLAUTH_ID_REQ }
+func (a *AtFullauthIdReq) Len() int                 { return attributeLen(2) }
+func (a *AtFullauthIdReq) Marshal() ([]byte, error) { return a.AppendBinary(make([]byte, 0, a.Len())) }
+func (a *AtFullauthIdReq) AppendBinary(b []byte) ([]byte, error) {
+	b, _, err := appendAttribute(b, AT_FULLAUTH_ID_REQ, 2)
+	return b, err
 }
 func (a *AtFullauthIdReq) Unmarshal(data []byte) error {
 	if len(data) < 2 {
@@ -240,9 +284,12 @@ type AtResultInd struct {
 	// Contains two reserved bytes
 }
 
-func (a *AtResultInd) Type() AttributeType { return AT_RESULT_IND }
-func (a *AtResultInd) Marshal() ([]byte, error) {
-	return marshalAttribute(AT_RESULT_IND, make([]byte, 2))
+func (a *AtResultInd) Type() AttributeType      { return AT_RESULT_IND }
+func (a *AtResultInd) Len() int                 { return attributeLen(2) }
+func (a *AtResultInd) Marshal() ([]byte, error) { return a.AppendBinary(make([]byte, 0, a.Len())) }
+func (a *AtResultInd) AppendBinary(b []byte) ([]byte, error) {
+	b, _, err := appendAttribute(b, AT_RESULT_IND, 2)
+	return b, err
 }
 func (a *AtResultInd) Unmarshal(data []byte) error {
 	if len(data) < 2 {
@@ -253,17 +300,27 @@ func (a *AtResultInd) Unmarshal(data []byte) error {
 
 // AT_BIDDING (RFC 5448 Section 4)
 type AtBidding struct {
-	// Contains two reserved bytes
+	// D is set by a server that supports EAP-AKA' in an EAP-AKA Challenge.
+	// A peer that supports EAP-AKA' then rejects the authentication, as it
+	// has been bid down.
+	D bool
 }
 
-func (a *AtBidding) Type() AttributeType { return AT_BIDDING }
-func (a *AtBidding) Marshal() ([]byte, error) {
-	return marshalAttribute(AT_BIDDING, make([]byte, 2))
+func (a *AtBidding) Type() AttributeType      { return AT_BIDDING }
+func (a *AtBidding) Len() int                 { return attributeLen(2) }
+func (a *AtBidding) Marshal() ([]byte, error) { return a.AppendBinary(make([]byte, 0, a.Len())) }
+func (a *AtBidding) AppendBinary(b []byte) ([]byte, error) {
+	b, v, err := appendAttribute(b, AT_BIDDING, 2)
+	if err == nil && a.D {
+		v[0] = 0x80
+	}
+	return b, err
 }
 func (a *AtBidding) Unmarshal(data []byte) error {
 	if len(data) < 2 {
 		return errors.New("invalid AT_BIDDING length")
 	}
+	a.D = data[0]&0x80 != 0
 	return nil
 }
 
@@ -272,12 +329,17 @@ type AtCheckcode struct {
 	Checkcode []byte
 }
 
-func (a *AtCheckcode) Type() AttributeType { return AT_CHECKCODE }
-func (a *AtCheckcode) Marshal() ([]byte, error) {
+func (a *AtCheckcode) Type() AttributeType      { return AT_CHECKCODE }
+func (a *AtCheckcode) Len() int                 { return attributeLen(2 + len(a.Checkcode)) }
+func (a *AtCheckcode) Marshal() ([]byte, error) { return a.AppendBinary(make([]byte, 0, a.Len())) }
+func (a *AtCheckcode) AppendBinary(b []byte) ([]byte, error) {
 	// RFC 5448: 2 bytes reserved + checkcode
-	buf := make([]byte, 2+len(a.Checkcode))
-	copy(buf[2:], a.Checkcode)
-	return marshalAttribute(AT_CHECKCODE, buf)
+	b, v, err := appendAttribute(b, AT_CHECKCODE, 2+len(a.Checkcode))
+	if err != nil {
+		return b, err
+	}
+	copy(v[2:], a.Checkcode)
+	return b, nil
 }
 func (a *AtCheckcode) Unmarshal(data []byte) error {
 	if len(data) < 2 {
@@ -293,9 +355,12 @@ type AtPadding struct {
 	Length int // Number of zero bytes
 }
 
-func (a *AtPadding) Type() AttributeType { return AT_PADDING }
-func (a *AtPadding) Marshal() ([]byte, error) {
-	return marshalAttribute(AT_PADDING, make([]byte, a.Length))
+func (a *AtPadding) Type() AttributeType      { return AT_PADDING }
+func (a *AtPadding) Len() int                 { return attributeLen(a.Length) }
+func (a *AtPadding) Marshal() ([]byte, error) { return a.AppendBinary(make([]byte, 0, a.Len())) }
+func (a *AtPadding) AppendBinary(b []byte) ([]byte, error) {
+	b, _, err := appendAttribute(b, AT_PADDING, a.Length)
+	return b, err
 }
 func (a *AtPadding) Unmarshal(data []byte) error {
 	a.Length = len(data)
@@ -307,14 +372,18 @@ type AtKdfInput struct {
 	NetworkName string
 }
 
-func (a *AtKdfInput) Type() AttributeType { return AT_KDF_INPUT }
-func (a *AtKdfInput) Marshal() ([]byte, error) {
+func (a *AtKdfInput) Type() AttributeType      { return AT_KDF_INPUT }
+func (a *AtKdfInput) Len() int                 { return attributeLen(2 + len(a.NetworkName)) }
+func (a *AtKdfInput) Marshal() ([]byte, error) { return a.AppendBinary(make([]byte, 0, a.Len())) }
+func (a *AtKdfInput) AppendBinary(b []byte) ([]byte, error) {
 	// RFC 5448: Actual Network Name Length (2 bytes) + Network Name
-	nameBytes := []byte(a.NetworkName)
-	buf := make([]byte, 2+len(nameBytes))
-	binary.BigEndian.PutUint16(buf[0:2], uint16(len(nameBytes)))
-	copy(buf[2:], nameBytes)
-	return marshalAttribute(AT_KDF_INPUT, buf)
+	b, v, err := appendAttribute(b, AT_KDF_INPUT, 2+len(a.NetworkName))
+	if err != nil {
+		return b, err
+	}
+	binary.BigEndian.PutUint16(v[0:2], uint16(len(a.NetworkName)))
+	copy(v[2:], a.NetworkName)
+	return b, nil
 }
 func (a *AtKdfInput) Unmarshal(data []byte) error {
 	if len(data) < 2 {
@@ -333,11 +402,16 @@ type AtKdf struct {
 	KDF uint16
 }
 
-func (a *AtKdf) Type() AttributeType { return AT_KDF }
-func (a *AtKdf) Marshal() ([]byte, error) {
-	buf := make([]byte, 2)
-	binary.BigEndian.PutUint16(buf, a.KDF)
-	return marshalAttribute(AT_KDF, buf)
+func (a *AtKdf) Type() AttributeType      { return AT_KDF }
+func (a *AtKdf) Len() int                 { return attributeLen(2) }
+func (a *AtKdf) Marshal() ([]byte, error) { return a.AppendBinary(make([]byte, 0, a.Len())) }
+func (a *AtKdf) AppendBinary(b []byte) ([]byte, error) {
+	b, v, err := appendAttribute(b, AT_KDF, 2)
+	if err != nil {
+		return b, err
+	}
+	binary.BigEndian.PutUint16(v, a.KDF)
+	return b, nil
 }
 func (a *AtKdf) Unmarshal(data []byte) error {
 	if len(data) < 2 {
@@ -352,15 +426,20 @@ type AtNonceMt struct {
 	NonceMt []byte // 16 bytes
 }
 
-func (a *AtNonceMt) Type() AttributeType { return AT_NONCE_MT }
-func (a *AtNonceMt) Marshal() ([]byte, error) {
+func (a *AtNonceMt) Type() AttributeType      { return AT_NONCE_MT }
+func (a *AtNonceMt) Len() int                 { return attributeLen(18) }
+func (a *AtNonceMt) Marshal() ([]byte, error) { return a.AppendBinary(make([]byte, 0, a.Len())) }
+func (a *AtNonceMt) AppendBinary(b []byte) ([]byte, error) {
+	// RFC 4186: 2 bytes reserved + 16 bytes Nonce_MT
 	if len(a.NonceMt) != 16 {
-		return nil, errors.New("AT_NONCE_MT must be 16 bytes")
+		return b, fmt.Errorf("%w: AT_NONCE_MT must be 16 bytes", ErrInvalidAttribute)
 	}
-	// RFC 4186: 2 bytes reserved + 16 bytes Nonce_MT
-	buf := make([]byte, 2+16)
-	copy(buf[2:], a.NonceMt)
-	return marshalAttribute(AT_NONCE_MT, buf)
+	b, v, err := appendAttribute(b, AT_NONCE_MT, 18)
+	if err != nil {
+		return b, err
+	}
+	copy(v[2:], a.NonceMt)
+	return b, nil
 }
 func (a *AtNonceMt) Unmarshal(data []byte) error {
 	if len(data) < 18 {
@@ -379,8 +458,10 @@ type AtNotification struct {
 }
 
 func (a *AtNotification) Type() AttributeType { return AT_NOTIFICATION }
-func (a *AtNotification) Marshal() ([]byte, error) {
-	// RFC 4187: 2 bytes code. S bit is MSB (0x8000), P bit is 2nd MSB (0x4000)
+
+// Value returns the 16-bit notification value including the S and P bits,
+// comparable with the Notification* constants.
+func (a *AtNotification) Value() uint16 {
 	val := a.Code & 0x3FFF
 	if a.S {
 		val |= 0x8000
@@ -388,9 +469,24 @@ func (a *AtNotification) Marshal() ([]byte, error) {
 	if a.P {
 		val |= 0x4000
 	}
-	buf := make([]byte, 2)
-	binary.BigEndian.PutUint16(buf, val)
-	return marshalAttribute(AT_NOTIFICATION, buf)
+	return val
+}
+
+// NewAtNotification creates an AT_NOTIFICATION from a 16-bit notification
+// value (e.g., NotificationSuccess).
+func NewAtNotification(value uint16) *AtNotification {
+	return &AtNotification{S: value&0x8000 != 0, P: value&0x4000 != 0, Code: value & 0x3FFF}
+}
+func (a *AtNotification) Len() int                 { return attributeLen(2) }
+func (a *AtNotification) Marshal() ([]byte, error) { return a.AppendBinary(make([]byte, 0, a.Len())) }
+func (a *AtNotification) AppendBinary(b []byte) ([]byte, error) {
+	// RFC 4187: 2 bytes code. S bit is MSB (0x8000), P bit is 2nd MSB (0x4000)
+	b, v, err := appendAttribute(b, AT_NOTIFICATION, 2)
+	if err != nil {
+		return b, err
+	}
+	binary.BigEndian.PutUint16(v, a.Value())
+	return b, nil
 }
 func (a *AtNotification) Unmarshal(data []byte) error {
 	if len(data) < 2 {
@@ -408,16 +504,20 @@ type AtVersionList struct {
 	Versions []uint16
 }
 
-func (a *AtVersionList) Type() AttributeType { return AT_VERSION_LIST }
-func (a *AtVersionList) Marshal() ([]byte, error) {
+func (a *AtVersionList) Type() AttributeType      { return AT_VERSION_LIST }
+func (a *AtVersionList) Len() int                 { return attributeLen(2 + 2*len(a.Versions)) }
+func (a *AtVersionList) Marshal() ([]byte, error) { return a.AppendBinary(make([]byte, 0, a.Len())) }
+func (a *AtVersionList) AppendBinary(b []byte) ([]byte, error) {
 	// RFC 4186: 2 bytes actual length + versions
-	actualLen := len(a.Versions) * 2
-	buf := make([]byte, 2+actualLen)
-	binary.BigEndian.PutUint16(buf[0:2], uint16(actualLen))
-	for i, v := range a.Versions {
-		binary.BigEndian.PutUint16(buf[2+i*2:], v)
+	b, v, err := appendAttribute(b, AT_VERSION_LIST, 2+2*len(a.Versions))
+	if err != nil {
+		return b, err
+	}
+	binary.BigEndian.PutUint16(v[0:2], uint16(2*len(a.Versions)))
+	for i, ver := range a.Versions {
+		binary.BigEndian.PutUint16(v[2+i*2:], ver)
 	}
-	return marshalAttribute(AT_VERSION_LIST, buf)
+	return b, nil
 }
 func (a *AtVersionList) Unmarshal(data []byte) error {
 	if len(data) < 2 {
@@ -441,10 +541,17 @@ type AtSelectedVersion struct {
 }
 
 func (a *AtSelectedVersion) Type() AttributeType { return AT_SELECTED_VERSION }
+func (a *AtSelectedVersion) Len() int            { return attributeLen(2) }
 func (a *AtSelectedVersion) Marshal() ([]byte, error) {
-	buf := make([]byte, 2)
-	binary.BigEndian.PutUint16(buf, a.Version)
-	return marshalAttribute(AT_SELECTED_VERSION, buf)
+	return a.AppendBinary(make([]byte, 0, a.Len()))
+}
+func (a *AtSelectedVersion) AppendBinary(b []byte) ([]byte, error) {
+	b, v, err := appendAttribute(b, AT_SELECTED_VERSION, 2)
+	if err != nil {
+		return b, err
+	}
+	binary.BigEndian.PutUint16(v, a.Version)
+	return b, nil
 }
 func (a *AtSelectedVersion) Unmarshal(data []byte) error {
 	if len(data) < 2 {
@@ -459,11 +566,16 @@ type AtCounter struct {
 	Counter uint16
 }
 
-func (a *AtCounter) Type() AttributeType { return AT_COUNTER }
-func (a *AtCounter) Marshal() ([]byte, error) {
-	buf := make([]byte, 2)
-	binary.BigEndian.PutUint16(buf, a.Counter)
-	return marshalAttribute(AT_COUNTER, buf)
+func (a *AtCounter) Type() AttributeType      { return AT_COUNTER }
+func (a *AtCounter) Len() int                 { return attributeLen(2) }
+func (a *AtCounter) Marshal() ([]byte, error) { return a.AppendBinary(make([]byte, 0, a.Len())) }
+func (a *AtCounter) AppendBinary(b []byte) ([]byte, error) {
+	b, v, err := appendAttribute(b, AT_COUNTER, 2)
+	if err != nil {
+		return b, err
+	}
+	binary.BigEndian.PutUint16(v, a.Counter)
+	return b, nil
 }
 func (a *AtCounter) Unmarshal(data []byte) error {
 	if len(data) < 2 {
@@ -479,8 +591,13 @@ type AtCounterTooSmall struct {
 }
 
 func (a *AtCounterTooSmall) Type() AttributeType { return AT_COUNTER_TOO_SMALL }
+func (a *AtCounterTooSmall) Len() int            { return attributeLen(2) }
 func (a *AtCounterTooSmall) Marshal() ([]byte, error) {
-	return marshalAttribute(AT_COUNTER_TOO_SMALL, make([]byte, 2))
+	return a.AppendBinary(make([]byte, 0, a.Len()))
+}
+func (a *AtCounterTooSmall) AppendBinary(b []byte) ([]byte, error) {
+	b, _, err := appendAttribute(b, AT_COUNTER_TOO_SMALL, 2)
+	return b, err
 }
 func (a *AtCounterTooSmall) Unmarshal(data []byte) error {
 	if len(data) < 2 {
@@ -494,15 +611,20 @@ type AtNonceS struct {
 	NonceS []byte // 16 bytes
 }
 
-func (a *AtNonceS) Type() AttributeType { return AT_NONCE_S }
-func (a *AtNonceS) Marshal() ([]byte, error) {
+func (a *AtNonceS) Type() AttributeType      { return AT_NONCE_S }
+func (a *AtNonceS) Len() int                 { return attributeLen(18) }
+func (a *AtNonceS) Marshal() ([]byte, error) { return a.AppendBinary(make([]byte, 0, a.Len())) }
+func (a *AtNonceS) AppendBinary(b []byte) ([]byte, error) {
+	// RFC 4187: 2 bytes reserved + 16 bytes Nonce_S
 	if len(a.NonceS) != 16 {
-		return nil, errors.New("AT_NONCE_S must be 16 bytes")
+		return b, fmt.Errorf("%w: AT_NONCE_S must be 16 bytes", ErrInvalidAttribute)
 	}
-	// RFC 4187: 2 bytes reserved + 16 bytes Nonce_S
-	buf := make([]byte, 2+16)
-	copy(buf[2:], a.NonceS)
-	return marshalAttribute(AT_NONCE_S, buf)
+	b, v, err := appendAttribute(b, AT_NONCE_S, 18)
+	if err != nil {
+		return b, err
+	}
+	copy(v[2:], a.NonceS)
+	return b, nil
 }
 func (a *AtNonceS) Unmarshal(data []byte) error {
 	if len(data) < 18 {
@@ -519,10 +641,17 @@ type AtClientErrorCode struct {
 }
 
 func (a *AtClientErrorCode) Type() AttributeType { return AT_CLIENT_ERROR_CODE }
+func (a *AtClientErrorCode) Len() int            { return attributeLen(2) }
 func (a *AtClientErrorCode) Marshal() ([]byte, error) {
-	buf := make([]byte, 2)
-	binary.BigEndian.PutUint16(buf, a.Code)
-	return marshalAttribute(AT_CLIENT_ERROR_CODE, buf)
+	return a.AppendBinary(make([]byte, 0, a.Len()))
+}
+func (a *AtClientErrorCode) AppendBinary(b []byte) ([]byte, error) {
+	b, v, err := appendAttribute(b, AT_CLIENT_ERROR_CODE, 2)
+	if err != nil {
+		return b, err
+	}
+	binary.BigEndian.PutUint16(v, a.Code)
+	return b, nil
 }
 func (a *AtClientErrorCode) Unmarshal(data []byte) error {
 	if len(data) < 2 {
@@ -537,15 +666,20 @@ type AtIv struct {
 	IV []byte // 16 bytes
 }
 
-func (a *AtIv) Type() AttributeType { return AT_IV }
-func (a *AtIv) Marshal() ([]byte, error) {
+func (a *AtIv) Type() AttributeType      { return AT_IV }
+func (a *AtIv) Len() int                 { return attributeLen(18) }
+func (a *AtIv) Marshal() ([]byte, error) { return a.AppendBinary(make([]byte, 0, a.Len())) }
+func (a *AtIv) AppendBinary(b []byte) ([]byte, error) {
+	// RFC 4187: 2 bytes reserved + 16 bytes IV
 	if len(a.IV) != 16 {
-		return nil, errors.New("AT_IV must be 16 bytes")
+		return b, fmt.Errorf("%w: AT_IV must be 16 bytes", ErrInvalidAttribute)
 	}
-	// RFC 4187: 2 bytes reserved + 16 bytes IV
-	buf := make([]byte, 2+16)
-	copy(buf[2:], a.IV)
-	return marshalAttribute(AT_IV, buf)
+	b, v, err := appendAttribute(b, AT_IV, 18)
+	if err != nil {
+		return b, err
+	}
+	copy(v[2:], a.IV)
+	return b, nil
 }
 func (a *AtIv) Unmarshal(data []byte) error {
 	if len(data) < 18 {
@@ -561,12 +695,17 @@ type AtEncrData struct {
 	EncryptedData []byte
 }
 
-func (a *AtEncrData) Type() AttributeType { return AT_ENCR_DATA }
-func (a *AtEncrData) Marshal() ([]byte, error) {
+func (a *AtEncrData) Type() AttributeType      { return AT_ENCR_DATA }
+func (a *AtEncrData) Len() int                 { return attributeLen(2 + len(a.EncryptedData)) }
+func (a *AtEncrData) Marshal() ([]byte, error) { return a.AppendBinary(make([]byte, 0, a.Len())) }
+func (a *AtEncrData) AppendBinary(b []byte) ([]byte, error) {
 	// RFC 4187: 2 bytes reserved + Encrypted Data
-	buf := make([]byte, 2+len(a.EncryptedData))
-	copy(buf[2:], a.EncryptedData)
-	return marshalAttribute(AT_ENCR_DATA, buf)
+	b, v, err := appendAttribute(b, AT_ENCR_DATA, 2+len(a.EncryptedData))
+	if err != nil {
+		return b, err
+	}
+	copy(v[2:], a.EncryptedData)
+	return b, nil
 }
 func (a *AtEncrData) Unmarshal(data []byte) error {
 	if len(data) < 2 {
@@ -582,15 +721,18 @@ type AtNextPseudonym struct {
 	Pseudonym string
 }
 
-func (a *AtNextPseudonym) Type() AttributeType { return AT_NEXT_PSEUDONYM }
-func (a *AtNextPseudonym) Marshal() ([]byte, error) {
+func (a *AtNextPseudonym) Type() AttributeType      { return AT_NEXT_PSEUDONYM }
+func (a *AtNextPseudonym) Len() int                 { return attributeLen(2 + len(a.Pseudonym)) }
+func (a *AtNextPseudonym) Marshal() ([]byte, error) { return a.AppendBinary(make([]byte, 0, a.Len())) }
+func (a *AtNextPseudonym) AppendBinary(b []byte) ([]byte, error) {
 	// RFC 4187: 2 bytes actual length + pseudonym
-	// Encrypted inside AT_ENCR_DATA usually, but this struct just handles the attribute itself.
-	idBytes := []byte(a.Pseudonym)
-	buf := make([]byte, 2+len(idBytes))
-	binary.BigEndian.PutUint16(buf[0:2], uint16(len(idBytes)))
-	copy(buf[2:], idBytes)
-	return marshalAttribute(AT_NEXT_PSEUDONYM, buf)
+	b, v, err := appendAttribute(b, AT_NEXT_PSEUDONYM, 2+len(a.Pseudonym))
+	if err != nil {
+		return b, err
+	}
+	binary.BigEndian.PutUint16(v[0:2], uint16(len(a.Pseudonym)))
+	copy(v[2:], a.Pseudonym)
+	return b, nil
 }
 func (a *AtNextPseudonym) Unmarshal(data []byte) error {
 	if len(data) < 2 {
@@ -609,14 +751,18 @@ type AtNextReauthId struct {
 	Identity string
 }
 
-func (a *AtNextReauthId) Type() AttributeType { return AT_NEXT_REAUTH_ID }
-func (a *AtNextReauthId) Marshal() ([]byte, error) {
+func (a *AtNextReauthId) Type() AttributeType      { return AT_NEXT_REAUTH_ID }
+func (a *AtNextReauthId) Len() int                 { return attributeLen(2 + len(a.Identity)) }
+func (a *AtNextReauthId) Marshal() ([]byte, error) { return a.AppendBinary(make([]byte, 0, a.Len())) }
+func (a *AtNextReauthId) AppendBinary(b []byte) ([]byte, error) {
 	// RFC 4187: 2 bytes actual length + identity
-	idBytes := []byte(a.Identity)
-	buf := make([]byte, 2+len(idBytes))
-	binary.BigEndian.PutUint16(buf[0:2], uint16(len(idBytes)))
-	copy(buf[2:], idBytes)
-	return marshalAttribute(AT_NEXT_REAUTH_ID, buf)
+	b, v, err := appendAttribute(b, AT_NEXT_REAUTH_ID, 2+len(a.Identity))
+	if err != nil {
+		return b, err
+	}
+	binary.BigEndian.PutUint16(v[0:2], uint16(len(a.Identity)))
+	copy(v[2:], a.Identity)
+	return b, nil
 }
 func (a *AtNextReauthId) Unmarshal(data []byte) error {
 	if len(data) < 2 {
@@ -636,9 +782,16 @@ type GenericAttribute struct {
 	Data     []byte
 }
 
-func (a *GenericAttribute) Type() AttributeType { return a.AttrType }
-func (a *GenericAttribute) Marshal() ([]byte, error) {
-	return marshalAttribute(a.AttrType, a.Data)
+func (a *GenericAttribute) Type() AttributeType      { return a.AttrType }
+func (a *GenericAttribute) Len() int                 { return attributeLen(len(a.Data)) }
+func (a *GenericAttribute) Marshal() ([]byte, error) { return a.AppendBinary(make([]byte, 0, a.Len())) }
+func (a *GenericAttribute) AppendBinary(b []byte) ([]byte, error) {
+	b, v, err := appendAttribute(b, a.AttrType, len(a.Data))
+	if err != nil {
+		return b, err
+	}
+	copy(v, a.Data)
+	return b, nil
 }
 func (a *GenericAttribute) Unmarshal(data []byte) error {
 	a.Data = make([]byte, len(data))
@@ -1,10 +1,7 @@
 package eapaka
 
-import (
-	"fmt"
-)
-
 // decodeAttribute creates a specific Attribute struct based on the type and unmarshals the data.
+// The caller wraps errors in a *ParseError.
 func decodeAttribute(t AttributeType, data []byte) (Attribute, error) {
 	var attr Attribute
 
@@ -69,7 +66,7 @@ func decodeAttribute(t AttributeType, data []byte) (Attribute, error) {
 	}
 
 	if err := attr.Unmarshal(data); err != nil {
-		return nil, fmt.Errorf("failed to unmarshal attribute type %d: %w", t, err)
+		return nil, err
 	}
 
 	return attr, nil
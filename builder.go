@@ -0,0 +1,186 @@
+package eapaka
+
+import "fmt"
+
+// Builder assembles an EAP-AKA/AKA' packet. It is returned by the New*Request
+// and New*Response functions, which add the attributes a message requires;
+// further attributes are added with the chained methods, and the packet is
+// finished with [Builder.Build] or, for messages protected by AT_MAC,
+// [Builder.Sign]:
+//
+//	pkt, err := eapaka.NewChallengeRequest(eapaka.TypeAKA, 1, rand, autn).
+//		ResultInd().
+//		Encrypt(keys.K_encr, iv, &eapaka.AtNextReauthId{Identity: next}).
+//		Sign(keys.K_aut)
+//
+// Errors are reported by Build and Sign. A Builder must not be reused after
+// Build or Sign.
+type Builder struct {
+	p     *Packet
+	err   error
+	kEncr []byte
+	iv    []byte
+	encr  []Attribute // attributes for AT_ENCR_DATA
+}
+
+// NewBuilder returns a Builder for a packet with the given header and no attributes.
+func NewBuilder(code, identifier, eapType, subtype uint8) *Builder {
+	return &Builder{p: &Packet{Code: code, Identifier: identifier, Type: eapType, Subtype: subtype}}
+}
+
+// NewIdentityRequest returns a Builder for EAP-Request/AKA-Identity. idReq is
+// AT_PERMANENT_ID_REQ, AT_FULLAUTH_ID_REQ or AT_ANY_ID_REQ.
+func NewIdentityRequest(eapType, identifier uint8, idReq AttributeType) *Builder {
+	b := NewBuilder(CodeRequest, identifier, eapType, SubtypeIdentity)
+	switch idReq {
+	case AT_PERMANENT_ID_REQ:
+		return b.Add(&AtPermanentIdReq{})
+	case AT_FULLAUTH_ID_REQ:
+		return b.Add(&AtFullauthIdReq{})
+	case AT_ANY_ID_REQ:
+		return b.Add(&AtAnyIdReq{})
+	}
+	b.err = fmt.Errorf("%w: %s is not an identity request", ErrInvalidAttribute, idReq)
+	return b
+}
+
+// NewIdentityResponse returns a Builder for EAP-Response/AKA-Identity with AT_IDENTITY.
+func NewIdentityResponse(eapType, identifier uint8, identity string) *Builder {
+	return NewBuilder(CodeResponse, identifier, eapType, SubtypeIdentity).
+		Add(&AtIdentity{Identity: identity})
+}
+
+// NewChallengeRequest returns a Builder for EAP-Request/AKA-Challenge with
+// AT_RAND and AT_AUTN. For EAP-AKA', use [NewChallengeRequestAKAPrime].
+func NewChallengeRequest(eapType, identifier uint8, rand, autn []byte) *Builder {
+	return NewBuilder(CodeRequest, identifier, eapType, SubtypeChallenge).
+		Add(&AtRand{Rand: rand}, &AtAutn{Autn: autn})
+}
+
+// NewChallengeRequestAKAPrime returns a Builder for EAP-Request/AKA'-Challenge
+// with AT_RAND, AT_AUTN, AT_KDF_INPUT and AT_KDF for [KDFAKAPrimeSHA256].
+// See RFC 5448 Section 3.
+func NewChallengeRequestAKAPrime(identifier uint8, rand, autn []byte, networkName string) *Builder {
+	return NewChallengeRequest(TypeAKAPrime, identifier, rand, autn).
+		Add(&AtKdfInput{NetworkName: networkName}, &AtKdf{KDF: KDFAKAPrimeSHA256})
+}
+
+// NewChallengeResponse returns a Builder for EAP-Response/AKA-Challenge with AT_RES.
+func NewChallengeResponse(eapType, identifier uint8, res []byte) *Builder {
+	return NewBuilder(CodeResponse, identifier, eapType, SubtypeChallenge).
+		Add(&AtRes{Res: res})
+}
+
+// NewReauthRequest returns a Builder for EAP-Request/AKA-Reauthentication with
+// AT_COUNTER and AT_NONCE_S to be encrypted. The encryption key must be given
+// with [Builder.Encrypt] before signing.
+func NewReauthRequest(eapType, identifier uint8, counter uint16, nonceS []byte) *Builder {
+	b := NewBuilder(CodeRequest, identifier, eapType, SubtypeReauthentication)
+	b.encr = []Attribute{&AtCounter{Counter: counter}, &AtNonceS{NonceS: nonceS}}
+	return b
+}
+
+// NewReauthResponse returns a Builder for EAP-Response/AKA-Reauthentication
+// with AT_COUNTER to be encrypted. The encryption key must be given with
+// [Builder.Encrypt], and the packet is signed with NONCE_S as extra data:
+//
+//	pkt, err := eapaka.NewReauthResponse(eapaka.TypeAKA, id, counter).
+//		Encrypt(kEncr, iv).
+//		SignWithExtra(kAut, nonceS)
+func NewReauthResponse(eapType, identifier uint8, counter uint16) *Builder {
+	b := NewBuilder(CodeResponse, identifier, eapType, SubtypeReauthentication)
+	b.encr = []Attribute{&AtCounter{Counter: counter}}
+	return b
+}
+
+// NewNotificationRequest returns a Builder for EAP-Request/AKA-Notification
+// with AT_NOTIFICATION set to value (e.g., [NotificationGeneralFailure]).
+// Notifications with the P bit clear are sent after the challenge round and
+// must be finished with [Builder.Sign].
+func NewNotificationRequest(eapType, identifier uint8, value uint16) *Builder {
+	return NewBuilder(CodeRequest, identifier, eapType, SubtypeNotification).
+		Add(NewAtNotification(value))
+}
+
+// NewNotificationResponse returns a Builder for EAP-Response/AKA-Notification.
+// It must be finished with [Builder.Sign] if the request had the P bit clear.
+func NewNotificationResponse(eapType, identifier uint8) *Builder {
+	return NewBuilder(CodeResponse, identifier, eapType, SubtypeNotification)
+}
+
+// NewClientError returns a Builder for EAP-Response/AKA-Client-Error with
+// AT_CLIENT_ERROR_CODE (e.g., [ClientErrorUnableToProcess]).
+func NewClientError(eapType, identifier uint8, code uint16) *Builder {
+	return NewBuilder(CodeResponse, identifier, eapType, SubtypeClientError).
+		Add(&AtClientErrorCode{Code: code})
+}
+
+// NewAuthenticationReject returns a Builder for EAP-Response/AKA-Authentication-Reject.
+func NewAuthenticationReject(eapType, identifier uint8) *Builder {
+	return NewBuilder(CodeResponse, identifier, eapType, SubtypeAuthenticationReject)
+}
+
+// NewSyncFailure returns a Builder for EAP-Response/AKA-Synchronization-Failure with AT_AUTS.
+func NewSyncFailure(eapType, identifier uint8, auts []byte) *Builder {
+	return NewBuilder(CodeResponse, identifier, eapType, SubtypeSynchronizationFailure).
+		Add(&AtAuts{Auts: auts})
+}
+
+// Add appends attributes to the packet.
+func (b *Builder) Add(attrs ...Attribute) *Builder {
+	b.p.Attributes = append(b.p.Attributes, attrs...)
+	return b
+}
+
+// ResultInd adds AT_RESULT_IND.
+func (b *Builder) ResultInd() *Builder {
+	return b.Add(&AtResultInd{})
+}
+
+// Encrypt adds attrs to the attributes carried in AT_ENCR_DATA, which is
+// encrypted under kEncr with the AT_IV value iv (see [EncryptAttributes]) when
+// the packet is built. AT_IV and AT_ENCR_DATA follow the other attributes.
+func (b *Builder) Encrypt(kEncr Key, iv []byte, attrs ...Attribute) *Builder {
+	b.kEncr, b.iv = kEncr, iv
+	b.encr = append(b.encr, attrs...)
+	return b
+}
+
+// Build returns the packet without AT_MAC.
+func (b *Builder) Build() (*Packet, error) {
+	if b.err != nil {
+		return nil, b.err
+	}
+	if len(b.encr) > 0 {
+		if b.kEncr == nil {
+			return nil, fmt.Errorf("%w: K_encr for AT_ENCR_DATA", ErrMissingKey)
+		}
+		encr, err := EncryptAttributes(b.kEncr, b.iv, b.encr...)
+		if err != nil {
+			return nil, err
+		}
+		b.p.Attributes = append(b.p.Attributes, &AtIv{IV: b.iv}, encr)
+		b.encr = nil
+	}
+	return b.p, nil
+}
+
+// Sign returns the packet with AT_MAC appended and computed with K_aut
+// (see [Packet.CalculateAndSetMac]).
+func (b *Builder) Sign(kAut Key) (*Packet, error) {
+	return b.SignWithExtra(kAut, nil)
+}
+
+// SignWithExtra is like [Builder.Sign], but the MAC is calculated over the
+// packet concatenated with extra (see [Packet.CalculateAndSetMacWithExtra]).
+func (b *Builder) SignWithExtra(kAut Key, extra []byte) (*Packet, error) {
+	p, err := b.Build()
+	if err != nil {
+		return nil, err
+	}
+	p.Attributes = append(p.Attributes, &AtMac{})
+	if err := p.CalculateAndSetMacWithExtra(kAut, extra); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
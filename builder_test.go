@@ -0,0 +1,204 @@
+package eapaka_test
+
+import (
+	"bytes"
+	"slices"
+	"testing"
+
+	"github.com/oyaguma3/go-eapaka"
+)
+
+func TestBuilder_Messages(t *testing.T) {
+	kEncr := bytes.Repeat([]byte{0x11}, 16)
+	kAut := bytes.Repeat([]byte{0x22}, 16)
+	kAutPrime := bytes.Repeat([]byte{0x22}, 32)
+	iv := bytes.Repeat([]byte{0x33}, 16)
+	nonceS := bytes.Repeat([]byte{0x44}, 16)
+	rand16 := bytes.Repeat([]byte{0x55}, 16)
+
+	tests := []struct {
+		name   string
+		b      *eapaka.Builder
+		kAut   []byte // nil if the message is not signed
+		extra  []byte
+		inner  []eapaka.AttributeType // encrypted attributes
+		prefix []eapaka.AttributeType // attributes before AT_IV/AT_ENCR_DATA and AT_MAC
+	}{
+		{
+			name:   "identity request",
+			b:      eapaka.NewIdentityRequest(eapaka.TypeAKA, 1, eapaka.AT_FULLAUTH_ID_REQ),
+			prefix: []eapaka.AttributeType{eapaka.AT_FULLAUTH_ID_REQ},
+		},
+		{
+			name:   "identity response",
+			b:      eapaka.NewIdentityResponse(eapaka.TypeAKA, 1, "0001010123456789@example.org"),
+			prefix: []eapaka.AttributeType{eapaka.AT_IDENTITY},
+		},
+		{
+			name: "challenge request",
+			b: eapaka.NewChallengeRequest(eapaka.TypeAKA, 2, rand16, rand16).
+				ResultInd().
+				Encrypt(kEncr, iv, &eapaka.AtNextReauthId{Identity: "4reauth"}),
+			kAut:   kAut,
+			inner:  []eapaka.AttributeType{eapaka.AT_NEXT_REAUTH_ID},
+			prefix: []eapaka.AttributeType{eapaka.AT_RAND, eapaka.AT_AUTN, eapaka.AT_RESULT_IND},
+		},
+		{
+			name:   "AKA' challenge request",
+			b:      eapaka.NewChallengeRequestAKAPrime(2, rand16, rand16, "WLAN"),
+			kAut:   kAutPrime,
+			prefix: []eapaka.AttributeType{eapaka.AT_RAND, eapaka.AT_AUTN, eapaka.AT_KDF_INPUT, eapaka.AT_KDF},
+		},
+		{
+			name:   "challenge response",
+			b:      eapaka.NewChallengeResponse(eapaka.TypeAKA, 2, rand16[:8]),
+			kAut:   kAut,
+			prefix: []eapaka.AttributeType{eapaka.AT_RES},
+		},
+		{
+			name:  "reauth request",
+			b:     eapaka.NewReauthRequest(eapaka.TypeAKA, 3, 2, nonceS).Encrypt(kEncr, iv),
+			kAut:  kAut,
+			inner: []eapaka.AttributeType{eapaka.AT_COUNTER, eapaka.AT_NONCE_S},
+		},
+		{
+			name: "reauth response",
+			b: eapaka.NewReauthResponse(eapaka.TypeAKA, 3, 2).
+				Encrypt(kEncr, iv, &eapaka.AtCounterTooSmall{}),
+			kAut:  kAut,
+			extra: nonceS,
+			inner: []eapaka.AttributeType{eapaka.AT_COUNTER, eapaka.AT_COUNTER_TOO_SMALL},
+		},
+		{
+			name:   "notification request",
+			b:      eapaka.NewNotificationRequest(eapaka.TypeAKA, 4, eapaka.NotificationGeneralFailureAfterAuth),
+			kAut:   kAut,
+			prefix: []eapaka.AttributeType{eapaka.AT_NOTIFICATION},
+		},
+		{
+			name:   "notification request before authentication",
+			b:      eapaka.NewNotificationRequest(eapaka.TypeAKA, 4, eapaka.NotificationGeneralFailure),
+			prefix: []eapaka.AttributeType{eapaka.AT_NOTIFICATION},
+		},
+		{
+			name: "notification response",
+			b:    eapaka.NewNotificationResponse(eapaka.TypeAKA, 4),
+			kAut: kAut,
+		},
+		{
+			name:   "client error",
+			b:      eapaka.NewClientError(eapaka.TypeAKA, 5, eapaka.ClientErrorUnableToProcess),
+			prefix: []eapaka.AttributeType{eapaka.AT_CLIENT_ERROR_CODE},
+		},
+		{
+			name: "authentication reject",
+			b:    eapaka.NewAuthenticationReject(eapaka.TypeAKA, 5),
+		},
+		{
+			name:   "sync failure",
+			b:      eapaka.NewSyncFailure(eapaka.TypeAKA, 6, bytes.Repeat([]byte{0x66}, 14)),
+			prefix: []eapaka.AttributeType{eapaka.AT_AUTS},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var (
+				pkt *eapaka.Packet
+				err error
+			)
+			if tt.kAut != nil {
+				pkt, err = tt.b.SignWithExtra(tt.kAut, tt.extra)
+			} else {
+				pkt, err = tt.b.Build()
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := pkt.Validate(); err != nil {
+				t.Errorf("Validate: %v", err)
+			}
+
+			want := tt.prefix
+			if tt.inner != nil {
+				want = append(want, eapaka.AT_IV, eapaka.AT_ENCR_DATA)
+			}
+			if tt.kAut != nil {
+				want = append(want, eapaka.AT_MAC)
+			}
+			var got []eapaka.AttributeType
+			for _, attr := range pkt.Attributes {
+				got = append(got, attr.Type())
+			}
+			if !slices.Equal(got, want) {
+				t.Errorf("attributes = %v, want %v", got, want)
+			}
+
+			if tt.kAut != nil {
+				raw, err := pkt.Marshal()
+				if err != nil {
+					t.Fatal(err)
+				}
+				if ok, err := eapaka.VerifyMacBytes(raw, tt.kAut, tt.extra); err != nil || !ok {
+					t.Errorf("VerifyMacBytes = %v, %v; want true", ok, err)
+				}
+			}
+			if tt.inner != nil {
+				ivAttr, _ := eapaka.Find[*eapaka.AtIv](pkt)
+				encr, _ := eapaka.Find[*eapaka.AtEncrData](pkt)
+				inner, err := eapaka.DecryptAttributes(kEncr, ivAttr.IV, encr)
+				if err != nil {
+					t.Fatal(err)
+				}
+				var got []eapaka.AttributeType
+				for _, attr := range inner {
+					got = append(got, attr.Type())
+				}
+				if !slices.Equal(got, tt.inner) {
+					t.Errorf("encrypted attributes = %v, want %v", got, tt.inner)
+				}
+			}
+		})
+	}
+}
+
+func TestBuilder_Errors(t *testing.T) {
+	if _, err := eapaka.NewIdentityRequest(eapaka.TypeAKA, 1, eapaka.AT_RAND).Build(); err == nil {
+		t.Error("NewIdentityRequest accepted AT_RAND")
+	}
+	if _, err := eapaka.NewReauthRequest(eapaka.TypeAKA, 1, 1, make([]byte, 16)).Sign(make([]byte, 16)); err == nil {
+		t.Error("Sign succeeded without K_encr")
+	}
+	if _, err := eapaka.NewChallengeResponse(eapaka.TypeAKA, 1, make([]byte, 8)).
+		Encrypt(make([]byte, 16), make([]byte, 8), &eapaka.AtCounter{}).
+		Sign(make([]byte, 16)); err == nil {
+		t.Error("Sign succeeded with a short IV")
+	}
+}
+
+func TestFind(t *testing.T) {
+	pkt := &eapaka.Packet{
+		Code:    eapaka.CodeResponse,
+		Type:    eapaka.TypeAKAPrime,
+		Subtype: eapaka.SubtypeChallenge,
+		Attributes: []eapaka.Attribute{
+			&eapaka.AtKdf{KDF: 2},
+			&eapaka.AtResultInd{},
+			&eapaka.AtKdf{KDF: 1},
+		},
+	}
+	if kdf, ok := eapaka.Find[*eapaka.AtKdf](pkt); !ok || kdf.KDF != 2 {
+		t.Errorf("Find[*AtKdf] = %v, %v; want KDF 2", kdf, ok)
+	}
+	if _, ok := eapaka.Find[*eapaka.AtMac](pkt); ok {
+		t.Error("Find[*AtMac] found an attribute")
+	}
+	if kdfs := eapaka.FindAll[*eapaka.AtKdf](pkt); len(kdfs) != 2 || kdfs[1].KDF != 1 {
+		t.Errorf("FindAll[*AtKdf] = %v", kdfs)
+	}
+	if attr, ok := pkt.Attribute(eapaka.AT_RESULT_IND); !ok || attr != pkt.Attributes[1] {
+		t.Errorf("Attribute(AT_RESULT_IND) = %v, %v", attr, ok)
+	}
+	if !pkt.HasResultInd() || pkt.Has(eapaka.AT_MAC) {
+		t.Error("Has reported the wrong attributes")
+	}
+}
@@ -0,0 +1,294 @@
+// Command eapaka-client acts as a NAS and an EAP-AKA/AKA' peer with a software
+// USIM, in the spirit of wpa_supplicant's eapol_test. It authenticates against a
+// RADIUS server, prints every exchanged EAP packet, and reports the MSK and the
+// MS-MPPE keys. It exits with a non-zero status if authentication fails.
+//
+// Packets are printed as JSON with RES, AUTS and other secrets masked; -reveal
+// prints them in clear for debugging.
+//
+// Usage:
+//
+//	eapaka-client -server 127.0.0.1:1812 -secret testing123 \
+//		-imsi 001010123456789 -k 465b5ce8b199b49faa5f0a2ee238a6bc \
+//		-opc cd63cb71954a9f4e48a5994e37a02baf -sqn 0 -reauth 1
+//
+// Subscriber data can also be read from a JSON file given with -subscriber:
+//
+//	{"imsi": "001010123456789", "k": "...", "opc": "...", "sqn": 0}
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/oyaguma3/go-eapaka"
+	"github.com/oyaguma3/go-eapaka/milenage"
+	"github.com/oyaguma3/go-eapaka/peer"
+	"github.com/oyaguma3/go-eapaka/radius"
+)
+
+type subscriberFile struct {
+	IMSI string `json:"imsi"`
+	K    string `json:"k"`
+	OPc  string `json:"opc"`
+	OP   string `json:"op"`
+	SQN  uint64 `json:"sqn"`
+}
+
+type options struct {
+	server      string
+	secret      string
+	subscriber  string
+	imsi        string
+	realm       string
+	method      string
+	k, opc, op  string
+	sqn         uint64
+	networkName string
+	reauth      int
+	timeout     time.Duration
+	retries     int
+	nasID       string
+	quiet       bool
+	reveal      bool
+}
+
+func main() {
+	var o options
+	flag.StringVar(&o.server, "server", "127.0.0.1:1812", "RADIUS server address")
+	flag.StringVar(&o.secret, "secret", "testing123", "RADIUS shared secret")
+	flag.StringVar(&o.subscriber, "subscriber", "", "JSON file with imsi, k, opc (or op) and sqn")
+	flag.StringVar(&o.imsi, "imsi", "", "subscriber IMSI")
+	flag.StringVar(&o.realm, "realm", "", "NAI realm appended to the permanent identity")
+	flag.StringVar(&o.method, "method", "aka-prime", "EAP method: aka or aka-prime")
+	flag.StringVar(&o.k, "k", "", "subscriber key K (hex)")
+	flag.StringVar(&o.opc, "opc", "", "operator variant OPc (hex)")
+	flag.StringVar(&o.op, "op", "", "operator variant OP (hex), used if -opc is not given")
+	flag.Uint64Var(&o.sqn, "sqn", 0, "highest SQN accepted by the USIM")
+	flag.StringVar(&o.networkName, "network-name", "", "expected AT_KDF_INPUT network name (EAP-AKA')")
+	flag.IntVar(&o.reauth, "reauth", 0, "number of fast re-authentications after the full authentication")
+	flag.DurationVar(&o.timeout, "timeout", 10*time.Second, "overall timeout per authentication")
+	flag.IntVar(&o.retries, "retries", 2, "RADIUS retransmissions per request")
+	flag.StringVar(&o.nasID, "nas-identifier", "eapaka-client", "NAS-Identifier attribute")
+	flag.BoolVar(&o.quiet, "q", false, "do not print exchanged packets")
+	flag.BoolVar(&o.reveal, "reveal", false, "print RES, AUTS and other secrets of exchanged packets in clear")
+	flag.Parse()
+	if o.reveal {
+		eapaka.SetLogOptions(eapaka.LogOptions{RevealSecrets: true})
+	}
+
+	if err := run(o, os.Stdout); err != nil {
+		fmt.Fprintln(os.Stderr, "FAILURE:", err)
+		os.Exit(1)
+	}
+	fmt.Println("SUCCESS")
+}
+
+func run(o options, out io.Writer) error {
+	usim, identity, err := setup(&o)
+	if err != nil {
+		return err
+	}
+	p, err := peer.New(peer.Config{Identity: identity, SIM: usim, NetworkName: o.networkName})
+	if err != nil {
+		return err
+	}
+	client := &radius.Client{Addr: o.server, Secret: []byte(o.secret), Retries: o.retries}
+
+	for i := 0; i <= o.reauth; i++ {
+		if err := authenticate(o, out, client, p); err != nil {
+			return err
+		}
+		if i < o.reauth && p.ReauthID() == "" {
+			return errors.New("server did not offer a re-authentication identity")
+		}
+	}
+	fmt.Fprintf(out, "SQN: %d\n", usim.SQN())
+	return nil
+}
+
+func setup(o *options) (*milenage.USIM, string, error) {
+	if o.subscriber != "" {
+		data, err := os.ReadFile(o.subscriber)
+		if err != nil {
+			return nil, "", err
+		}
+		var sf subscriberFile
+		if err := json.Unmarshal(data, &sf); err != nil {
+			return nil, "", fmt.Errorf("%s: %w", o.subscriber, err)
+		}
+		// Flags given explicitly take precedence over the file.
+		set := map[string]bool{}
+		flag.Visit(func(f *flag.Flag) { set[f.Name] = true })
+		if !set["imsi"] {
+			o.imsi = sf.IMSI
+		}
+		if !set["k"] {
+			o.k = sf.K
+		}
+		if !set["opc"] {
+			o.opc = sf.OPc
+		}
+		if !set["op"] {
+			o.op = sf.OP
+		}
+		if !set["sqn"] {
+			o.sqn = sf.SQN
+		}
+	}
+
+	var eapType uint8
+	switch o.method {
+	case "aka":
+		eapType = eapaka.TypeAKA
+	case "aka-prime", "akaprime", "aka'":
+		eapType = eapaka.TypeAKAPrime
+	default:
+		return nil, "", fmt.Errorf("unknown method %q", o.method)
+	}
+	if o.imsi == "" {
+		return nil, "", errors.New("IMSI is required")
+	}
+	k, err := hex.DecodeString(o.k)
+	if err != nil {
+		return nil, "", fmt.Errorf("invalid K: %w", err)
+	}
+	var opc []byte
+	switch {
+	case o.opc != "":
+		if opc, err = hex.DecodeString(o.opc); err != nil {
+			return nil, "", fmt.Errorf("invalid OPc: %w", err)
+		}
+	case o.op != "":
+		op, err := hex.DecodeString(o.op)
+		if err != nil {
+			return nil, "", fmt.Errorf("invalid OP: %w", err)
+		}
+		if opc, err = milenage.OPc(k, op); err != nil {
+			return nil, "", err
+		}
+	default:
+		return nil, "", errors.New("OPc or OP is required")
+	}
+	usim, err := milenage.NewUSIM(k, opc, o.sqn)
+	if err != nil {
+		return nil, "", err
+	}
+	return usim, eapaka.PermanentIdentity(eapType, o.imsi, o.realm), nil
+}
+
+// authenticate runs one EAP conversation through the RADIUS server.
+func authenticate(o options, out io.Writer, client *radius.Client, p *peer.Peer) error {
+	ctx, cancel := context.WithTimeout(context.Background(), o.timeout)
+	defer cancel()
+
+	eap := p.IdentityResponse(0)
+	var state []byte
+	for id := uint8(0); ; id++ {
+		if !o.quiet {
+			dumpEAP(out, "->", eap)
+		}
+		req := &radius.Packet{Code: radius.CodeAccessRequest, Identifier: id}
+		req.Add(radius.AttrUserName, identityOf(eap))
+		req.Add(radius.AttrNASIdentifier, []byte(o.nasID))
+		req.Add(radius.AttrCallingStationID, []byte("02-00-00-00-00-01"))
+		if state != nil {
+			req.Add(radius.AttrState, state)
+		}
+		req.SetEAPMessage(eap)
+
+		resp, err := client.Exchange(ctx, req)
+		if err != nil {
+			return err
+		}
+		msg := resp.EAPMessage()
+		if !o.quiet {
+			dumpEAP(out, "<-", msg)
+		}
+		next, perr := p.Handle(msg)
+
+		switch resp.Code {
+		case radius.CodeAccessChallenge:
+			if next == nil {
+				return fmt.Errorf("peer: %v", perr)
+			}
+			state = resp.Get(radius.AttrState)
+			eap = next
+			continue
+		case radius.CodeAccessAccept:
+			if p.Status() != peer.StatusSuccess {
+				return fmt.Errorf("Access-Accept but peer failed: %v", perr)
+			}
+			return reportKeys(out, resp, req.Authenticator, []byte(o.secret), p)
+		default:
+			if perr != nil {
+				return fmt.Errorf("Access-Reject: %w", perr)
+			}
+			return errors.New("Access-Reject")
+		}
+	}
+}
+
+func reportKeys(out io.Writer, resp *radius.Packet, reqAuth [16]byte, secret []byte, p *peer.Peer) error {
+	kind := "full authentication"
+	if p.Reauthenticated() {
+		kind = "fast re-authentication"
+	}
+	fmt.Fprintf(out, "EAP authentication completed successfully (%s)\n", kind)
+	fmt.Fprintf(out, "MSK: %x\n", p.MSK().Export())
+	fmt.Fprintf(out, "EMSK: %x\n", p.EMSK().Export())
+
+	recvEnc := resp.GetVendor(radius.VendorMicrosoft, radius.VendorMSMPPERecvKey)
+	sendEnc := resp.GetVendor(radius.VendorMicrosoft, radius.VendorMSMPPESendKey)
+	if recvEnc == nil || sendEnc == nil {
+		return errors.New("MS-MPPE keys missing from Access-Accept")
+	}
+	recv, err := eapaka.DecryptMPPEKey(recvEnc, secret, reqAuth[:])
+	if err != nil {
+		return err
+	}
+	send, err := eapaka.DecryptMPPEKey(sendEnc, secret, reqAuth[:])
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(out, "MS-MPPE-Recv-Key: %x\n", recv)
+	fmt.Fprintf(out, "MS-MPPE-Send-Key: %x\n", send)
+	if !bytes.Equal(recv, p.MSK()[0:32]) || !bytes.Equal(send, p.MSK()[32:64]) {
+		return errors.New("MS-MPPE keys do not match the MSK")
+	}
+	return nil
+}
+
+// identityOf returns the identity of an EAP-Response/Identity for User-Name.
+func identityOf(eap []byte) []byte {
+	if v, err := eapaka.NewView(eap); err == nil && v.Type() == eapaka.TypeIdentity {
+		if id, ok := v.Identity(); ok {
+			return id
+		}
+	}
+	return []byte("anonymous")
+}
+
+// dumpEAP prints an EAP packet as JSON; secrets are masked unless -reveal
+// is given.
+func dumpEAP(out io.Writer, dir string, raw []byte) {
+	pkt, err := eapaka.Parse(raw)
+	if err != nil {
+		fmt.Fprintf(out, "%s undecodable EAP (%v), %d bytes\n", dir, err, len(raw))
+		return
+	}
+	b, err := json.Marshal(pkt)
+	if err != nil {
+		fmt.Fprintf(out, "%s EAP (%v)\n", dir, err)
+		return
+	}
+	fmt.Fprintf(out, "%s EAP %s\n", dir, b)
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"bytes"
+	"encoding/hex"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/oyaguma3/go-eapaka/milenage"
+	"github.com/oyaguma3/go-eapaka/radius"
+	"github.com/oyaguma3/go-eapaka/server"
+)
+
+const (
+	testIMSI = "001010123456789"
+	testK    = "465b5ce8b199b49faa5f0a2ee238a6bc"
+	testOPc  = "cd63cb71954a9f4e48a5994e37a02baf"
+)
+
+func startLoopback(t *testing.T, eapType uint8) string {
+	t.Helper()
+	k, _ := hex.DecodeString(testK)
+	opc, _ := hex.DecodeString(testOPc)
+	auth, err := server.New(server.Config{
+		Type:         eapType,
+		Vectors:      milenage.NewProvider(&milenage.Subscriber{IMSI: testIMSI, K: k, OPc: opc, SQN: 32}),
+		EnableReauth: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := &radius.Server{Authenticator: auth, Secrets: radius.StaticSecrets{"*": []byte("testing123")}}
+	go srv.Serve(conn)
+	t.Cleanup(func() { srv.Close() })
+	return conn.LocalAddr().String()
+}
+
+func TestRun_Loopback(t *testing.T) {
+	for _, method := range []string{"aka", "aka-prime"} {
+		var eapType uint8 = 23
+		if method == "aka-prime" {
+			eapType = 50
+		}
+		addr := startLoopback(t, eapType)
+		var out bytes.Buffer
+		err := run(options{
+			server:  addr,
+			secret:  "testing123",
+			imsi:    testIMSI,
+			method:  method,
+			k:       testK,
+			opc:     testOPc,
+			reauth:  2,
+			timeout: 5 * time.Second,
+		}, &out)
+		if err != nil {
+			t.Fatalf("%s: run failed: %v\n%s", method, err, out.String())
+		}
+		if n := strings.Count(out.String(), "completed successfully"); n != 3 {
+			t.Errorf("%s: %d successful authentications, want 3\n%s", method, n, out.String())
+		}
+		if !strings.Contains(out.String(), "fast re-authentication") {
+			t.Errorf("%s: no fast re-authentication reported", method)
+		}
+		if !strings.Contains(out.String(), `"res":"REDACTED"`) {
+			t.Errorf("%s: RES not masked\n%s", method, out.String())
+		}
+	}
+}
+
+func TestRun_WrongSecret(t *testing.T) {
+	addr := startLoopback(t, 50)
+	err := run(options{
+		server:  addr,
+		secret:  "wrong",
+		imsi:    testIMSI,
+		method:  "aka-prime",
+		k:       testK,
+		opc:     testOPc,
+		timeout: 500 * time.Millisecond,
+		quiet:   true,
+	}, &bytes.Buffer{})
+	if err == nil {
+		t.Fatal("run succeeded with a wrong shared secret")
+	}
+}
+
+func TestSetup_SubscriberFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sub.json")
+	data := `{"imsi": "` + testIMSI + `", "k": "` + testK + `", "op": "cdc202d5123e20f62b6d676ac72cb318", "sqn": 7}`
+	if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	o := options{subscriber: path, method: "aka"}
+	usim, identity, err := setup(&o)
+	if err != nil {
+		t.Fatalf("setup failed: %v", err)
+	}
+	if identity != "0"+testIMSI {
+		t.Errorf("identity: got %q", identity)
+	}
+	if usim.SQN() != 7 || hex.EncodeToString(usim.OPc) != testOPc {
+		t.Errorf("unexpected USIM state: SQN=%d OPc=%x", usim.SQN(), usim.OPc)
+	}
+}
@@ -0,0 +1,431 @@
+package main
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+
+	"github.com/oyaguma3/go-eapaka"
+)
+
+var codeNames = map[uint8]string{
+	eapaka.CodeRequest:  "Request",
+	eapaka.CodeResponse: "Response",
+	eapaka.CodeSuccess:  "Success",
+	eapaka.CodeFailure:  "Failure",
+}
+
+// EAP method types (RFC 3748 Section 5, RFC 4187, RFC 5448).
+var typeNames = map[uint8]string{
+	1:                   "Identity",
+	2:                   "Notification",
+	3:                   "Legacy Nak",
+	254:                 "Expanded Type",
+	eapaka.TypeAKA:      "EAP-AKA",
+	eapaka.TypeAKAPrime: "EAP-AKA'",
+}
+
+var subtypeNames = map[uint8]string{
+	eapaka.SubtypeChallenge:              "AKA-Challenge",
+	eapaka.SubtypeAuthenticationReject:   "AKA-Authentication-Reject",
+	eapaka.SubtypeSynchronizationFailure: "AKA-Synchronization-Failure",
+	eapaka.SubtypeIdentity:               "AKA-Identity",
+	eapaka.SubtypeNotification:           "AKA-Notification",
+	eapaka.SubtypeReauthentication:       "AKA-Reauthentication",
+	eapaka.SubtypeClientError:            "AKA-Client-Error",
+}
+
+// RFC 4187 Section 10.19
+var notificationNames = map[uint16]string{
+	eapaka.NotificationGeneralFailureAfterAuth: "General failure after authentication",
+	eapaka.NotificationTemporarilyDenied:       "User has been temporarily denied access",
+	eapaka.NotificationNotSubscribed:           "User has not subscribed to the requested service",
+	eapaka.NotificationGeneralFailure:          "General failure",
+	eapaka.NotificationSuccess:                 "Success",
+}
+
+// RFC 4187 Section 10.20
+var clientErrorNames = map[uint16]string{
+	eapaka.ClientErrorUnableToProcess:        "unable to process packet",
+	eapaka.ClientErrorUnsupportedVersion:     "unsupported version",
+	eapaka.ClientErrorInsufficientChallenges: "insufficient number of challenges",
+	eapaka.ClientErrorRandsNotFresh:          "RANDs are not fresh",
+}
+
+// RFC 5448 Section 3.2
+var kdfNames = map[uint16]string{
+	eapaka.KDFAKAPrimeSHA256: "EAP-AKA' with CK'/IK' (PRF' based on HMAC-SHA-256)",
+}
+
+func name[K comparable](m map[K]string, k K) string {
+	if s, ok := m[k]; ok {
+		return s
+	}
+	return "unknown"
+}
+
+// dumper writes one line per field: offset, length, then the annotation.
+type dumper struct {
+	out io.Writer
+	ok  bool
+}
+
+func (d *dumper) field(depth, off, n int, format string, args ...any) {
+	fmt.Fprintf(d.out, "%5d %4d  %*s%s\n", off, n, depth*2, "", fmt.Sprintf(format, args...))
+}
+
+func (d *dumper) problem(format string, args ...any) {
+	d.ok = false
+	fmt.Fprintf(d.out, "%11s  !! %s\n", "", fmt.Sprintf(format, args...))
+}
+
+// dissect prints raw field by field. It reports false if the packet is malformed
+// or AT_MAC does not verify.
+func dissect(out io.Writer, raw []byte, keys *macKeys) bool {
+	d := &dumper{out: out, ok: true}
+	pkt, perr := eapaka.Parse(raw)
+
+	fmt.Fprintf(out, "%s (%d bytes)\n", summary(raw), len(raw))
+	fmt.Fprintf(out, "%5s %4s  %s\n", "off", "len", "field")
+	if len(raw) < 4 {
+		d.problem("packet too short for the EAP header")
+		return false
+	}
+	code := raw[0]
+	length := int(binary.BigEndian.Uint16(raw[2:4]))
+	d.field(0, 0, 1, "Code: %d (%s)", code, name(codeNames, code))
+	d.field(0, 1, 1, "Identifier: %d", raw[1])
+	d.field(0, 2, 2, "Length: %d", length)
+	switch {
+	case length > len(raw):
+		d.problem("Length %d exceeds the %d bytes available", length, len(raw))
+		length = len(raw)
+	case length < len(raw):
+		d.problem("%d bytes follow the packet", len(raw)-length)
+	}
+	body := raw[:length]
+
+	if code == eapaka.CodeSuccess || code == eapaka.CodeFailure || len(body) == 4 {
+		if len(body) > 4 {
+			d.problem("%d unexpected bytes after the header", len(body)-4)
+		}
+		return d.ok
+	}
+
+	eapType := body[4]
+	d.field(0, 4, 1, "Type: %d (%s)", eapType, name(typeNames, eapType))
+	switch eapType {
+	case eapaka.TypeAKA, eapaka.TypeAKAPrime:
+	case 1, 2:
+		d.field(0, 5, len(body)-5, "Type-Data: %q", body[5:])
+		return d.ok
+	case 3:
+		for i, t := range body[5:] {
+			d.field(0, 5+i, 1, "Desired Auth Type: %d (%s)", t, name(typeNames, t))
+		}
+		return d.ok
+	default:
+		d.field(0, 5, len(body)-5, "Type-Data: %x", body[5:])
+		return d.ok
+	}
+
+	if len(body) < 8 {
+		d.problem("EAP-AKA header truncated")
+		return false
+	}
+	d.field(0, 5, 1, "Subtype: %d (%s)", body[5], name(subtypeNames, body[5]))
+	d.reserved(0, 6, body[6:8])
+
+	for off := 8; off < len(body); {
+		if off+2 > len(body) {
+			d.problem("attribute header truncated at offset %d", off)
+			break
+		}
+		t := eapaka.AttributeType(body[off])
+		n := int(body[off+1]) * 4
+		d.field(0, off, max(n, 2), "%s (%d), Length: %d (%d bytes)", t, uint8(t), body[off+1], n)
+		if n == 0 {
+			d.problem("attribute length zero")
+			break
+		}
+		if off+n > len(body) {
+			d.problem("attribute overflows the packet by %d bytes", off+n-len(body))
+			break
+		}
+		d.attribute(t, off+2, body[off+2:off+n])
+		off += n
+	}
+
+	if perr != nil {
+		d.problem("Parse: %v", perr)
+		return false
+	}
+	var verr *eapaka.ValidationError
+	if err := pkt.Validate(); errors.As(err, &verr) {
+		for _, v := range verr.Violations {
+			d.problem("RFC 4187 Section 9: %s", v)
+		}
+	}
+	if keys != nil {
+		d.verifyMAC(pkt, keys)
+	}
+	return d.ok
+}
+
+// summary returns a one-line description such as "EAP-AKA' Request/AKA-Challenge, Identifier 1".
+func summary(raw []byte) string {
+	if len(raw) < 4 {
+		return "EAP (truncated)"
+	}
+	s := "EAP " + name(codeNames, raw[0])
+	if len(raw) > 4 && (raw[0] == eapaka.CodeRequest || raw[0] == eapaka.CodeResponse) {
+		switch t := raw[4]; t {
+		case eapaka.TypeAKA, eapaka.TypeAKAPrime:
+			s = name(typeNames, t) + " " + name(codeNames, raw[0])
+			if len(raw) > 5 {
+				s += "/" + name(subtypeNames, raw[5])
+			}
+		default:
+			s += "/" + name(typeNames, t)
+		}
+	}
+	return s + ", Identifier " + strconv.Itoa(int(raw[1]))
+}
+
+func (d *dumper) reserved(depth, off int, b []byte) {
+	d.field(depth, off, len(b), "Reserved: %#04x", binary.BigEndian.Uint16(b))
+	if b[0] != 0 || b[1] != 0 {
+		d.problem("reserved field is not zero")
+	}
+}
+
+// fixed prints Reserved followed by a value of exactly size bytes.
+func (d *dumper) fixed(off int, v []byte, label string, size int) {
+	if len(v) != 2+size {
+		d.problem("value must be %d bytes, got %d", 2+size, len(v))
+		return
+	}
+	d.reserved(1, off, v[:2])
+	d.field(1, off+2, size, "%s: %x", label, v[2:])
+}
+
+// actualLength prints a 2-byte Actual Length followed by the data and padding.
+func (d *dumper) actualLength(off int, v []byte, label string, text bool) {
+	if len(v) < 2 {
+		d.problem("value too short")
+		return
+	}
+	n := int(binary.BigEndian.Uint16(v))
+	d.field(1, off, 2, "Actual Length: %d", n)
+	if 2+n > len(v) {
+		d.problem("actual length %d exceeds the %d bytes available", n, len(v)-2)
+		return
+	}
+	if text {
+		d.field(1, off+2, n, "%s: %q", label, v[2:2+n])
+	} else {
+		d.field(1, off+2, n, "%s: %x", label, v[2:2+n])
+	}
+	if pad := v[2+n:]; len(pad) > 0 {
+		d.field(1, off+2+n, len(pad), "Padding: %x", pad)
+	}
+}
+
+func (d *dumper) uint16Field(off int, v []byte, label string) (uint16, bool) {
+	if len(v) != 2 {
+		d.problem("value must be 2 bytes, got %d", len(v))
+		return 0, false
+	}
+	x := binary.BigEndian.Uint16(v)
+	d.field(1, off, 2, "%s: %d", label, x)
+	return x, true
+}
+
+// attribute prints the value of one attribute. off is the offset of v in the packet.
+func (d *dumper) attribute(t eapaka.AttributeType, off int, v []byte) {
+	switch t {
+	case eapaka.AT_RAND:
+		d.fixed(off, v, "RAND", 16)
+	case eapaka.AT_AUTN:
+		d.fixed(off, v, "AUTN", 16)
+	case eapaka.AT_MAC:
+		d.fixed(off, v, "MAC", 16)
+	case eapaka.AT_NONCE_MT:
+		d.fixed(off, v, "NONCE_MT", 16)
+	case eapaka.AT_NONCE_S:
+		d.fixed(off, v, "NONCE_S", 16)
+	case eapaka.AT_IV:
+		d.fixed(off, v, "Initialization Vector", 16)
+	case eapaka.AT_RES:
+		bits, ok := d.uint16Field(off, v[:min(2, len(v))], "RES Length (bits)")
+		if !ok {
+			return
+		}
+		n := int(bits+7) / 8
+		if bits < 32 || bits > 128 {
+			d.problem("RES length %d bits is outside 32..128 (RFC 4187 Section 10.8)", bits)
+		}
+		if 2+n > len(v) {
+			d.problem("RES length %d bits exceeds the %d bytes available", bits, len(v)-2)
+			return
+		}
+		d.field(1, off+2, n, "RES: %x", v[2:2+n])
+		if pad := v[2+n:]; len(pad) > 0 {
+			d.field(1, off+2+n, len(pad), "Padding: %x", pad)
+		}
+	case eapaka.AT_AUTS:
+		if len(v) != 14 {
+			d.problem("value must be 14 bytes, got %d", len(v))
+			return
+		}
+		d.field(1, off, 6, "SQN_MS xor AK*: %x", v[:6])
+		d.field(1, off+6, 8, "MAC-S: %x", v[6:])
+	case eapaka.AT_PADDING:
+		d.field(1, off, len(v), "Padding: %x", v)
+		for _, c := range v {
+			if c != 0 {
+				d.problem("padding is not zero")
+				break
+			}
+		}
+	case eapaka.AT_PERMANENT_ID_REQ, eapaka.AT_ANY_ID_REQ, eapaka.AT_FULLAUTH_ID_REQ,
+		eapaka.AT_COUNTER_TOO_SMALL, eapaka.AT_RESULT_IND:
+		if len(v) != 2 {
+			d.problem("value must be 2 bytes, got %d", len(v))
+			return
+		}
+		d.reserved(1, off, v)
+	case eapaka.AT_NOTIFICATION:
+		x, ok := d.uint16Field(off, v, "Notification")
+		if !ok {
+			return
+		}
+		s, p := x&0x8000 != 0, x&0x4000 != 0
+		d.field(2, off, 2, "S bit: %t (%s)", s, map[bool]string{true: "success", false: "failure"}[s])
+		d.field(2, off, 2, "P bit: %t (%s)", p, map[bool]string{true: "before the challenge round", false: "after the challenge round"}[p])
+		d.field(2, off, 2, "Code: %d (%s)", x&0x3fff, name(notificationNames, x))
+		if s && p {
+			d.problem("S and P bits must not both be set (RFC 4187 Section 10.19)")
+		}
+	case eapaka.AT_IDENTITY:
+		d.actualLength(off, v, "Identity", true)
+	case eapaka.AT_NEXT_PSEUDONYM:
+		d.actualLength(off, v, "Next Pseudonym", true)
+	case eapaka.AT_NEXT_REAUTH_ID:
+		d.actualLength(off, v, "Next Fast Re-authentication Username", true)
+	case eapaka.AT_KDF_INPUT:
+		d.actualLength(off, v, "Network Name", true)
+	case eapaka.AT_VERSION_LIST:
+		d.actualLength(off, v, "Supported Versions", false)
+	case eapaka.AT_SELECTED_VERSION:
+		d.uint16Field(off, v, "Selected Version")
+	case eapaka.AT_COUNTER:
+		d.uint16Field(off, v, "Counter")
+	case eapaka.AT_CLIENT_ERROR_CODE:
+		if x, ok := d.uint16Field(off, v, "Client Error Code"); ok {
+			d.field(2, off, 2, "%s", name(clientErrorNames, x))
+		}
+	case eapaka.AT_KDF:
+		if x, ok := d.uint16Field(off, v, "Key Derivation Function"); ok {
+			d.field(2, off, 2, "%s", name(kdfNames, x))
+		}
+	case eapaka.AT_BIDDING:
+		if len(v) != 2 {
+			d.problem("value must be 2 bytes, got %d", len(v))
+			return
+		}
+		d.field(1, off, 2, "D bit: %t (EAP-AKA' supported)", v[0]&0x80 != 0)
+	case eapaka.AT_ENCR_DATA:
+		if len(v) < 2 {
+			d.problem("value too short")
+			return
+		}
+		d.reserved(1, off, v[:2])
+		d.field(1, off+2, len(v)-2, "Encrypted Data: %x", v[2:])
+		if (len(v)-2)%16 != 0 {
+			d.problem("encrypted data is not a multiple of 16 bytes")
+		}
+	case eapaka.AT_CHECKCODE:
+		if len(v) < 2 {
+			d.problem("value too short")
+			return
+		}
+		d.reserved(1, off, v[:2])
+		if len(v) > 2 {
+			d.field(1, off+2, len(v)-2, "Checkcode: %x", v[2:])
+		}
+	default:
+		kind := "non-skippable"
+		if t >= 128 {
+			kind = "skippable"
+		}
+		d.field(1, off, len(v), "Value (%s): %x", kind, v)
+	}
+}
+
+// verifyMAC checks AT_MAC with the keys from the command line.
+func (d *dumper) verifyMAC(pkt *eapaka.Packet, k *macKeys) {
+	hasMAC := false
+	netName, autn := k.networkName, k.autn
+	for _, attr := range pkt.Attributes {
+		switch a := attr.(type) {
+		case *eapaka.AtMac:
+			hasMAC = true
+		case *eapaka.AtKdfInput:
+			if netName == "" {
+				netName = a.NetworkName
+			}
+		case *eapaka.AtAutn:
+			if autn == nil {
+				autn = a.Autn
+			}
+		}
+	}
+	if !hasMAC {
+		return
+	}
+
+	kAut := k.kAut
+	if kAut == nil {
+		switch pkt.Type {
+		case eapaka.TypeAKA:
+			kAut = eapaka.DeriveKeysAKA(k.identity, k.ck, k.ik).K_aut
+		case eapaka.TypeAKAPrime:
+			if netName == "" {
+				d.problem("MAC: -network-name is required (no AT_KDF_INPUT in this packet)")
+				return
+			}
+			if len(autn) == 0 {
+				d.problem("MAC: -autn is required (no AT_AUTN in this packet)")
+				return
+			}
+			ckPrime, ikPrime, err := eapaka.DeriveCKPrimeIKPrime(k.ck, k.ik, netName, autn)
+			if err != nil {
+				d.problem("MAC: %v", err)
+				return
+			}
+			kAut = eapaka.DeriveKeysAKAPrime(k.identity, ckPrime, ikPrime).K_aut
+		}
+		fmt.Fprintf(d.out, "%11s  K_aut: %x\n", "", kAut)
+	}
+
+	var extra []byte
+	if pkt.Code == eapaka.CodeResponse && pkt.Subtype == eapaka.SubtypeReauthentication {
+		if k.nonceS == nil {
+			d.problem("MAC: -nonce-s is required for AKA-Reauthentication responses")
+			return
+		}
+		extra = k.nonceS
+	}
+	ok, err := pkt.VerifyMacWithExtra(kAut, extra)
+	switch {
+	case err != nil:
+		d.problem("MAC: %v", err)
+	case !ok:
+		d.problem("MAC: verification FAILED")
+	default:
+		fmt.Fprintf(d.out, "%11s  MAC: verified\n", "")
+	}
+}
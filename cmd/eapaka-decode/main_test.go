@@ -0,0 +1,137 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/oyaguma3/go-eapaka"
+)
+
+var (
+	testCK = bytes.Repeat([]byte{0x11}, 16)
+	testIK = bytes.Repeat([]byte{0x22}, 16)
+)
+
+const testIdentity = "6001010123456789@wlan.mnc001.mcc001.3gppnetwork.org"
+
+// challenge returns a signed EAP-Request/AKA'-Challenge.
+func challenge(t *testing.T) []byte {
+	t.Helper()
+	ckPrime, ikPrime, err := eapaka.DeriveCKPrimeIKPrime(testCK, testIK, "WLAN", bytes.Repeat([]byte{0xbb}, 16))
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys := eapaka.DeriveKeysAKAPrime(testIdentity, ckPrime, ikPrime)
+	pkt := &eapaka.Packet{
+		Code:       eapaka.CodeRequest,
+		Identifier: 7,
+		Type:       eapaka.TypeAKAPrime,
+		Subtype:    eapaka.SubtypeChallenge,
+		Attributes: []eapaka.Attribute{
+			&eapaka.AtRand{Rand: bytes.Repeat([]byte{0xaa}, 16)},
+			&eapaka.AtAutn{Autn: bytes.Repeat([]byte{0xbb}, 16)},
+			&eapaka.AtKdfInput{NetworkName: "WLAN"},
+			&eapaka.AtKdf{KDF: eapaka.KDFAKAPrimeSHA256},
+			&eapaka.AtResultInd{},
+			&eapaka.AtMac{MAC: make([]byte, 16)},
+		},
+	}
+	if err := pkt.CalculateAndSetMac(keys.K_aut); err != nil {
+		t.Fatal(err)
+	}
+	b, err := pkt.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func TestRun_Annotations(t *testing.T) {
+	raw := challenge(t)
+	notification, err := (&eapaka.Packet{
+		Code:       eapaka.CodeRequest,
+		Identifier: 8,
+		Type:       eapaka.TypeAKA,
+		Subtype:    eapaka.SubtypeNotification,
+		Attributes: []eapaka.Attribute{eapaka.NewAtNotification(eapaka.NotificationGeneralFailure)},
+	}).Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out bytes.Buffer
+	o := options{format: "auto", ck: hex.EncodeToString(testCK), ik: hex.EncodeToString(testIK), identity: testIdentity}
+	if err := run(o, []string{hex.EncodeToString(raw), hex.EncodeToString(notification)}, nil, &out); err != nil {
+		t.Fatalf("run failed: %v\n%s", err, out.String())
+	}
+	for _, want := range []string{
+		"EAP-AKA' Request/AKA-Challenge, Identifier 7",
+		"    8   20  AT_RAND (1), Length: 5 (20 bytes)",
+		"Network Name: \"WLAN\"",
+		"Key Derivation Function: 1",
+		"AT_RESULT_IND (135)",
+		"S bit: false (failure)",
+		"P bit: true (before the challenge round)",
+		"Code: 0 (General failure)",
+		"MAC: verified",
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("output lacks %q:\n%s", want, out.String())
+		}
+	}
+}
+
+func TestRun_BadMAC(t *testing.T) {
+	raw := challenge(t)
+	raw[len(raw)-1] ^= 1
+	var out bytes.Buffer
+	o := options{format: "auto", kAut: strings.Repeat("00", 32)}
+	if err := run(o, []string{hex.EncodeToString(raw)}, nil, &out); err == nil {
+		t.Fatalf("run succeeded with a wrong MAC:\n%s", out.String())
+	}
+	if !strings.Contains(out.String(), "MAC: verification FAILED") {
+		t.Errorf("failure not reported:\n%s", out.String())
+	}
+}
+
+func TestRun_Inputs(t *testing.T) {
+	raw := challenge(t)
+	res := []byte{2, 3, 0, 40, eapaka.TypeAKA, eapaka.SubtypeChallenge, 0, 0,
+		byte(eapaka.AT_RES), 3, 0, 64, 1, 2, 3, 4, 5, 6, 7, 8,
+		byte(eapaka.AT_MAC), 5, 0, 0}
+	res = append(res, make([]byte, 16)...)
+
+	dir := t.TempDir()
+	bin := filepath.Join(dir, "pkts.bin")
+	if err := os.WriteFile(bin, append(append([]byte{}, raw...), res...), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	stdin := strings.NewReader("# from a log\n" + base64.StdEncoding.EncodeToString(res) + "\n\n")
+	colons := strings.ToUpper(hex.EncodeToString(res[:4])) + ":" + hex.EncodeToString(res[4:])
+
+	var out bytes.Buffer
+	if err := run(options{format: "auto"}, []string{"@" + bin, "-", "0x" + colons}, stdin, &out); err != nil {
+		t.Fatalf("run failed: %v\n%s", err, out.String())
+	}
+	if n := strings.Count(out.String(), "EAP-AKA Response/AKA-Challenge"); n != 3 {
+		t.Errorf("decoded %d AT_RES packets, want 3:\n%s", n, out.String())
+	}
+	if !strings.Contains(out.String(), "RES Length (bits): 64") {
+		t.Errorf("AT_RES bit length not shown:\n%s", out.String())
+	}
+}
+
+func TestRun_Malformed(t *testing.T) {
+	var out bytes.Buffer
+	// AT_RAND claims 5 words but only 2 are present.
+	if err := run(options{format: "hex"}, []string{"0101000c17010000010500000000"}, nil, &out); err == nil {
+		t.Fatalf("run succeeded on a malformed packet:\n%s", out.String())
+	}
+	if !strings.Contains(out.String(), "!!") {
+		t.Errorf("problem not flagged:\n%s", out.String())
+	}
+}
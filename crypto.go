@@ -5,100 +5,126 @@ import (
 	"crypto/sha1"
 	"crypto/sha256"
 	"crypto/subtle"
+	"encoding/binary"
 	"errors"
+	"fmt"
 	"hash"
 )
 
 // CalculateAndSetMac calculates the MAC for the packet and updates the AT_MAC attribute.
 // It requires the K_aut key.
-func (p *Packet) CalculateAndSetMac(kAut []byte) error {
-	// 1. Find AT_MAC and zero it out
-	var macAttr *AtMac
-	found := false
-	for i := range p.Attributes {
-		if m, ok := p.Attributes[i].(*AtMac); ok {
-			macAttr = m
-			found = true
-			break
+func (p *Packet) CalculateAndSetMac(kAut Key) error {
+	return p.CalculateAndSetMacWithExtra(kAut, nil)
+}
+
+// CalculateAndSetMacWithExtra is like [Packet.CalculateAndSetMac], but the MAC is
+// calculated over the packet concatenated with extra.
+// EAP-Response/AKA-Reauthentication uses NONCE_S as extra. See RFC 4187 Section 10.15.
+func (p *Packet) CalculateAndSetMacWithExtra(kAut Key, extra []byte) error {
+	_, err := p.AppendSigned(nil, kAut, extra)
+	return err
+}
+
+// VerifyMac verifies the MAC in the packet against the provided K_aut.
+func (p *Packet) VerifyMac(kAut Key) (bool, error) {
+	return p.VerifyMacWithExtra(kAut, nil)
+}
+
+// VerifyMacWithExtra is like [Packet.VerifyMac], but the MAC is calculated over
+// the packet concatenated with extra (NONCE_S for EAP-Response/AKA-Reauthentication).
+//
+// For a packet returned by [Parse], the MAC is verified over the received bytes
+// (see [VerifyMacBytes]), so fields that parsing normalises do not matter.
+// Otherwise the packet is marshalled. The packet is never modified, so
+// concurrent verification of a shared packet is safe.
+func (p *Packet) VerifyMacWithExtra(kAut Key, extra []byte) (bool, error) {
+	return reportMAC(p.verifyMac(kAut, extra))
+}
+
+func (p *Packet) verifyMac(kAut Key, extra []byte) (bool, error) {
+	if p.raw != nil {
+		if p.macOff < 0 {
+			return false, ErrMACNotFound
 		}
+		return verifyMacAt(p.raw, p.macOff, kAut, extra)
 	}
 
-	if !found {
-		return errors.New("AT_MAC attribute not found")
+	// Marshal a copy with AT_MAC zeroed.
+	c := *p
+	c.Attributes = make([]Attribute, len(p.Attributes))
+	var receivedMac []byte
+	for i, attr := range p.Attributes {
+		if m, ok := attr.(*AtMac); ok && receivedMac == nil {
+			receivedMac = m.MAC
+			attr = &AtMac{MAC: make([]byte, 16)}
+		}
+		c.Attributes[i] = attr
 	}
-
-	// Save original MAC just in case (though we are overwriting it)
-	// We need to zero it out for calculation
-	originalMac := make([]byte, 16)
-	if len(macAttr.MAC) == 16 {
-		copy(originalMac, macAttr.MAC)
+	if receivedMac == nil {
+		return false, ErrMACNotFound
 	}
-	macAttr.MAC = make([]byte, 16) // Zeroed
-
-	// 2. Marshal packet
-	data, err := p.Marshal()
+	data, err := c.Marshal()
 	if err != nil {
-		// Restore?
-		return err
+		return false, err
 	}
-
-	// 3. Calculate MAC
-	mac, err := p.calculateMac(kAut, data)
+	expectedMac, err := p.calculateMac(kAut, data, extra)
 	if err != nil {
-		return err
+		return false, err
 	}
-
-	// 4. Set MAC
-	copy(macAttr.MAC, mac)
-	return nil
+	return subtle.ConstantTimeCompare(receivedMac, expectedMac) == 1, nil
 }
 
-// VerifyMac verifies the MAC in the packet against the provided K_aut.
-func (p *Packet) VerifyMac(kAut []byte) (bool, error) {
-	// 1. Find AT_MAC
-	var macAttr *AtMac
-	found := false
-	for i := range p.Attributes {
-		if m, ok := p.Attributes[i].(*AtMac); ok {
-			macAttr = m
-			found = true
-			break
-		}
-	}
-
-	if !found {
-		return false, errors.New("AT_MAC attribute not found")
+// VerifyMacBytes verifies AT_MAC of the EAP-AKA/AKA' packet in data, exactly as
+// received, against K_aut. The MAC is calculated over a copy of data with the MAC
+// value zeroed, concatenated with extra. data is not modified.
+func VerifyMacBytes(data, kAut, extra []byte) (bool, error) {
+	off := macOffset(data)
+	if off < 0 {
+		return reportMAC(false, ErrMACNotFound)
 	}
+	return reportMAC(verifyMacAt(data, off, kAut, extra))
+}
 
-	receivedMac := make([]byte, 16)
-	copy(receivedMac, macAttr.MAC)
-
-	// 2. Zero out AT_MAC for calculation
-	macAttr.MAC = make([]byte, 16)
+// verifyMacAt verifies the 16-byte MAC at data[off:] as described for [VerifyMacBytes].
+func verifyMacAt(data []byte, off int, kAut, extra []byte) (bool, error) {
+	length := int(binary.BigEndian.Uint16(data[2:4]))
+	buf := append([]byte(nil), data[:length]...)
+	clear(buf[off : off+16])
 
-	// 3. Marshal
-	data, err := p.Marshal()
+	expectedMac, err := (&Packet{Type: data[4]}).calculateMac(kAut, buf, extra)
 	if err != nil {
-		// Restore
-		copy(macAttr.MAC, receivedMac)
 		return false, err
 	}
+	return subtle.ConstantTimeCompare(data[off:off+16], expectedMac) == 1, nil
+}
 
-	// 4. Calculate expected MAC
-	expectedMac, err := p.calculateMac(kAut, data)
-	if err != nil {
-		copy(macAttr.MAC, receivedMac)
-		return false, err
+// macOffset returns the offset of the MAC value of the first AT_MAC in an
+// EAP-AKA/AKA' packet, or -1 if there is none or the packet is malformed.
+func macOffset(data []byte) int {
+	if len(data) < 8 {
+		return -1
 	}
-
-	// 5. Restore AT_MAC
-	copy(macAttr.MAC, receivedMac)
-
-	// 6. Compare
-	return subtle.ConstantTimeCompare(receivedMac, expectedMac) == 1, nil
+	length := int(binary.BigEndian.Uint16(data[2:4]))
+	if length > len(data) || length < 8 {
+		return -1
+	}
+	for off := 8; off+2 <= length; {
+		n := int(data[off+1]) * 4
+		if n == 0 || off+n > length {
+			return -1
+		}
+		if AttributeType(data[off]) == AT_MAC {
+			if n != 20 {
+				return -1
+			}
+			return off + 4
+		}
+		off += n
+	}
+	return -1
 }
 
-func (p *Packet) calculateMac(kAut []byte, data []byte) ([]byte, error) {
+func (p *Packet) calculateMac(kAut, data, extra []byte) ([]byte, error) {
 	var h hash.Hash
 
 	switch p.Type {
@@ -107,10 +133,11 @@ func (p *Packet) calculateMac(kAut []byte, data []byte) ([]byte, error) {
 	case TypeAKAPrime:
 		h = hmac.New(sha256.New, kAut)
 	default:
-		return nil, errors.New("unsupported EAP type for MAC calculation")
+		return nil, fmt.Errorf("%w %d for MAC calculation", ErrUnsupportedType, p.Type)
 	}
 
 	h.Write(data)
+	h.Write(extra)
 	fullMac := h.Sum(nil)
 
 	// EAP-AKA and EAP-AKA' use the first 16 bytes of the HMAC output
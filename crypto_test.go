@@ -0,0 +1,97 @@
+package eapaka_test
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha1"
+	"sync"
+	"testing"
+
+	"github.com/oyaguma3/go-eapaka"
+)
+
+func TestVerifyMac_WireBytes(t *testing.T) {
+	kAut := bytes.Repeat([]byte{0x42}, 16)
+	pkt := &eapaka.Packet{
+		Code:       eapaka.CodeRequest,
+		Identifier: 3,
+		Type:       eapaka.TypeAKA,
+		Subtype:    eapaka.SubtypeChallenge,
+		Attributes: []eapaka.Attribute{
+			&eapaka.AtRand{Rand: bytes.Repeat([]byte{1}, 16)},
+			&eapaka.AtAutn{Autn: bytes.Repeat([]byte{2}, 16)},
+			&eapaka.AtResultInd{},
+			&eapaka.AtMac{MAC: make([]byte, 16)},
+		},
+	}
+	if err := pkt.CalculateAndSetMac(kAut); err != nil {
+		t.Fatal(err)
+	}
+	raw, err := pkt.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ok, err := eapaka.VerifyMacBytes(raw, kAut, nil); err != nil || !ok {
+		t.Fatalf("VerifyMacBytes = %v, %v; want true", ok, err)
+	}
+
+	// A non-zero reserved field is dropped by Parse, but covered by the MAC.
+	raw[46] = 0x5a // AT_RESULT_IND reserved field
+	if ok, _ := eapaka.VerifyMacBytes(raw, kAut, nil); ok {
+		t.Fatal("VerifyMacBytes accepted a modified packet")
+	}
+
+	// Re-sign the wire bytes as a peer that sets the reserved field would.
+	parsed, err := eapaka.Parse(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	off, ok := parsed.MacOffset()
+	if !ok {
+		t.Fatal("MacOffset: no AT_MAC")
+	}
+	clear(raw[off : off+16])
+	h := hmac.New(sha1.New, kAut)
+	h.Write(raw)
+	copy(raw[off:], h.Sum(nil)[:16])
+
+	parsed, err = eapaka.Parse(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var wg sync.WaitGroup
+	for range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if ok, err := parsed.VerifyMac(kAut); err != nil || !ok {
+				t.Errorf("VerifyMac = %v, %v; want true", ok, err)
+			}
+		}()
+	}
+	wg.Wait()
+	if !bytes.Equal(parsed.Raw(), raw) {
+		t.Error("verification modified the packet bytes")
+	}
+}
+
+func TestVerifyMac_Constructed(t *testing.T) {
+	kAut := bytes.Repeat([]byte{0x42}, 32)
+	pkt := &eapaka.Packet{
+		Code:       eapaka.CodeResponse,
+		Identifier: 3,
+		Type:       eapaka.TypeAKAPrime,
+		Subtype:    eapaka.SubtypeChallenge,
+		Attributes: []eapaka.Attribute{&eapaka.AtRes{Res: make([]byte, 8)}, &eapaka.AtMac{}},
+	}
+	if err := pkt.CalculateAndSetMac(kAut); err != nil {
+		t.Fatal(err)
+	}
+	mac := append([]byte(nil), pkt.Attributes[1].(*eapaka.AtMac).MAC...)
+	if ok, err := pkt.VerifyMac(kAut); err != nil || !ok {
+		t.Fatalf("VerifyMac = %v, %v; want true", ok, err)
+	}
+	if !bytes.Equal(pkt.Attributes[1].(*eapaka.AtMac).MAC, mac) {
+		t.Error("VerifyMac modified AT_MAC")
+	}
+}
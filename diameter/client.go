@@ -0,0 +1,353 @@
+package diameter
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/oyaguma3/go-eapaka"
+)
+
+// Errors returned by [Client.GetVector].
+var (
+	ErrNoAnswer     = errors.New("diameter: no answer from peer")
+	ErrRejected     = errors.New("diameter: request rejected")
+	ErrInvalidMAA   = errors.New("diameter: invalid Multimedia-Auth-Answer")
+	ErrCapabilities = errors.New("diameter: capabilities exchange failed")
+)
+
+// Client is an [eapaka.VectorProvider] that fetches vectors from an HSS with
+// SWx Multimedia-Auth-Requests (TS 29.273 Section 8.1.2.1). It opens one
+// connection on first use, performs the Capabilities-Exchange, answers
+// Device-Watchdog-Requests and reconnects on the next request after the
+// connection fails. It is safe for concurrent use.
+//
+// A synchronization failure reported in the [eapaka.VectorRequest] is sent
+// as RAND || AUTS in SIP-Authorization. For EAP-AKA' the request carries
+// the network name in ANID and the HSS returns CK' and IK'.
+type Client struct {
+	// Addr is the HSS address, e.g. "127.0.0.1:3868".
+	Addr string
+
+	// Dial opens the connection, e.g. over TLS or SCTP. Default: TCP to Addr.
+	Dial func(ctx context.Context) (net.Conn, error)
+
+	// OriginHost and OriginRealm identify the AAA server. Required.
+	OriginHost  string
+	OriginRealm string
+
+	// DestinationRealm is the realm of the HSS. Required.
+	DestinationRealm string
+
+	// DestinationHost, if set, is sent in every MAR.
+	DestinationHost string
+
+	// Timeout is the time to wait for each answer. Default: 5s.
+	Timeout time.Duration
+
+	// Random is the source of the Session-Id and identifier seeds.
+	// Default: [eapaka.Random].
+	Random io.Reader
+
+	// Logger receives diagnostics. Default: discard.
+	Logger *slog.Logger
+
+	mu   sync.Mutex
+	conn *clientConn
+}
+
+// clientConn is one connection to the HSS.
+type clientConn struct {
+	conn     net.Conn
+	wmu      sync.Mutex
+	mu       sync.Mutex
+	pending  map[uint32]chan *Message
+	hopByHop atomic.Uint32
+	endToEnd atomic.Uint32
+	session  atomic.Uint32
+	sidHigh  uint32
+	done     chan struct{}
+	err      error
+}
+
+// GetVector implements [eapaka.VectorProvider].
+func (c *Client) GetVector(ctx context.Context, req *eapaka.VectorRequest) (*eapaka.AuthVector, error) {
+	cc, err := c.connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	ans, err := cc.exchange(ctx, c.newMAR(cc, req), c.timeout())
+	if err != nil {
+		return nil, err
+	}
+
+	code, experimental, ok := ans.ResultCode()
+	switch {
+	case !ok:
+		return nil, fmt.Errorf("%w: no Result-Code", ErrInvalidMAA)
+	case experimental && code == ResultErrorUserUnknown:
+		return nil, fmt.Errorf("%w: %s", eapaka.ErrUnknownSubscriber, req.IMSI)
+	case code != ResultSuccess:
+		return nil, fmt.Errorf("%w: result code %d", ErrRejected, code)
+	}
+	a, ok := ans.Find(AVPSIPAuthDataItem, Vendor3GPP)
+	if !ok {
+		return nil, fmt.Errorf("%w: no SIP-Auth-Data-Item", ErrInvalidMAA)
+	}
+	d, err := ParseAuthDataItem(a)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidMAA, err)
+	}
+	if len(d.Authenticate) != 32 || len(d.Authorization) < 4 || len(d.Authorization) > 16 ||
+		len(d.CK) != 16 || len(d.IK) != 16 {
+		return nil, fmt.Errorf("%w: malformed authentication data", ErrInvalidMAA)
+	}
+	return &eapaka.AuthVector{
+		RAND:  d.Authenticate[:16],
+		AUTN:  d.Authenticate[16:],
+		XRES:  d.Authorization,
+		CK:    d.CK,
+		IK:    d.IK,
+		Prime: req.Type == eapaka.TypeAKAPrime,
+	}, nil
+}
+
+// Close closes the connection, if any. A later request opens a new one.
+func (c *Client) Close() error {
+	c.mu.Lock()
+	cc := c.conn
+	c.conn = nil
+	c.mu.Unlock()
+	if cc == nil {
+		return nil
+	}
+	return cc.conn.Close()
+}
+
+func (c *Client) newMAR(cc *clientConn, req *eapaka.VectorRequest) *Message {
+	scheme := SchemeEAPAKA
+	if req.Type == eapaka.TypeAKAPrime {
+		scheme = SchemeEAPAKAPrime
+	}
+	item := &AuthDataItem{Scheme: scheme}
+	if req.AUTS != nil {
+		item.Authorization = append(append([]byte(nil), req.RAND...), req.AUTS...)
+	}
+
+	m := cc.newRequest(CmdMultimediaAuth, AppSWx)
+	m.Add(
+		NewString(AVPSessionID, 0, fmt.Sprintf("%s;%d;%d", c.OriginHost, cc.sidHigh, cc.session.Add(1))),
+		vendorSpecificApplicationID(),
+		NewUint32(AVPAuthSessionState, 0, authSessionNoState),
+		NewString(AVPOriginHost, 0, c.OriginHost),
+		NewString(AVPOriginRealm, 0, c.OriginRealm),
+		NewString(AVPDestinationRealm, 0, c.DestinationRealm),
+	)
+	if c.DestinationHost != "" {
+		m.Add(NewString(AVPDestinationHost, 0, c.DestinationHost))
+	}
+	m.Add(
+		NewString(AVPUserName, 0, req.IMSI),
+		item.AVP(),
+		NewUint32(AVPSIPNumberAuthItems, Vendor3GPP, 1),
+		NewUint32(AVPRATType, Vendor3GPP, RATTypeWLAN),
+	)
+	if req.Type == eapaka.TypeAKAPrime {
+		m.Add(NewString(AVPANID, Vendor3GPP, req.NetworkName))
+	}
+	return m
+}
+
+func (c *Client) timeout() time.Duration {
+	if c.Timeout > 0 {
+		return c.Timeout
+	}
+	return 5 * time.Second
+}
+
+func (c *Client) logger() *slog.Logger {
+	if c.Logger != nil {
+		return c.Logger
+	}
+	return slog.New(slog.DiscardHandler)
+}
+
+// connect returns the open connection, or dials and performs the
+// Capabilities-Exchange.
+func (c *Client) connect(ctx context.Context) (*clientConn, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.conn != nil {
+		select {
+		case <-c.conn.done:
+			c.conn = nil
+		default:
+			return c.conn, nil
+		}
+	}
+	if c.OriginHost == "" || c.OriginRealm == "" || c.DestinationRealm == "" {
+		return nil, errors.New("diameter: Client.OriginHost, OriginRealm and DestinationRealm are required")
+	}
+
+	seed, err := eapaka.ReadRandom(c.Random, 12)
+	if err != nil {
+		return nil, err
+	}
+	dial := c.Dial
+	if dial == nil {
+		dial = func(ctx context.Context) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, "tcp", c.Addr)
+		}
+	}
+	conn, err := dial(ctx)
+	if err != nil {
+		return nil, err
+	}
+	cc := &clientConn{
+		conn:    conn,
+		pending: make(map[uint32]chan *Message),
+		sidHigh: binary.BigEndian.Uint32(seed[0:4]),
+		done:    make(chan struct{}),
+	}
+	cc.hopByHop.Store(binary.BigEndian.Uint32(seed[4:8]))
+	cc.endToEnd.Store(binary.BigEndian.Uint32(seed[8:12]))
+	go cc.readLoop(c)
+
+	cer := cc.newRequest(CmdCapabilitiesExchange, AppCommon)
+	cer.Add(capabilities(c.OriginHost, c.OriginRealm, localIP(conn))...)
+	cea, err := cc.exchange(ctx, cer, c.timeout())
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if code, _, _ := cea.ResultCode(); code != ResultSuccess || !supportsSWx(cea) {
+		conn.Close()
+		return nil, fmt.Errorf("%w: result code %d", ErrCapabilities, code)
+	}
+	c.conn = cc
+	return cc, nil
+}
+
+func (cc *clientConn) newRequest(code, appID uint32) *Message {
+	flags := FlagRequest
+	if appID != AppCommon {
+		flags |= FlagProxiable
+	}
+	return &Message{
+		Flags:         flags,
+		Code:          code,
+		ApplicationID: appID,
+		HopByHop:      cc.hopByHop.Add(1),
+		EndToEnd:      cc.endToEnd.Add(1),
+	}
+}
+
+// exchange sends req and waits for the answer with the same Hop-by-Hop
+// Identifier.
+func (cc *clientConn) exchange(ctx context.Context, req *Message, timeout time.Duration) (*Message, error) {
+	ch := make(chan *Message, 1)
+	cc.mu.Lock()
+	if cc.err != nil {
+		cc.mu.Unlock()
+		return nil, cc.err
+	}
+	cc.pending[req.HopByHop] = ch
+	cc.mu.Unlock()
+	defer func() {
+		cc.mu.Lock()
+		delete(cc.pending, req.HopByHop)
+		cc.mu.Unlock()
+	}()
+
+	if err := cc.write(req); err != nil {
+		cc.conn.Close()
+		return nil, err
+	}
+	t := time.NewTimer(timeout)
+	defer t.Stop()
+	select {
+	case ans := <-ch:
+		return ans, nil
+	case <-cc.done:
+		return nil, cc.err
+	case <-t.C:
+		return nil, ErrNoAnswer
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (cc *clientConn) write(m *Message) error {
+	b, err := m.Marshal()
+	if err != nil {
+		return err
+	}
+	cc.wmu.Lock()
+	defer cc.wmu.Unlock()
+	_, err = cc.conn.Write(b)
+	return err
+}
+
+// readLoop delivers answers to their requests and answers watchdog and
+// disconnect requests from the HSS until the connection fails.
+func (cc *clientConn) readLoop(c *Client) {
+	var err error
+	defer func() {
+		cc.mu.Lock()
+		cc.err = fmt.Errorf("%w: %w", ErrNoAnswer, err)
+		cc.mu.Unlock()
+		close(cc.done)
+		cc.conn.Close()
+	}()
+	for {
+		var m *Message
+		if m, err = ReadMessage(cc.conn); err != nil {
+			return
+		}
+		if !m.IsRequest() {
+			cc.mu.Lock()
+			ch := cc.pending[m.HopByHop]
+			cc.mu.Unlock()
+			if ch != nil {
+				select {
+				case ch <- m:
+				default: // duplicate answer
+				}
+			}
+			continue
+		}
+
+		ans := m.Answer()
+		switch m.Code {
+		case CmdDeviceWatchdog, CmdDisconnectPeer:
+			ans.Add(
+				NewUint32(AVPResultCode, 0, ResultSuccess),
+				NewString(AVPOriginHost, 0, c.OriginHost),
+				NewString(AVPOriginRealm, 0, c.OriginRealm),
+			)
+		default:
+			ans.Flags |= FlagError
+			ans.Add(
+				NewUint32(AVPResultCode, 0, ResultCommandUnsupported),
+				NewString(AVPOriginHost, 0, c.OriginHost),
+				NewString(AVPOriginRealm, 0, c.OriginRealm),
+			)
+		}
+		if err = cc.write(ans); err != nil {
+			return
+		}
+		if m.Code == CmdDisconnectPeer {
+			c.logger().Debug("diameter: peer disconnected", "addr", cc.conn.RemoteAddr().String())
+			err = io.EOF
+			return
+		}
+	}
+}
@@ -0,0 +1,203 @@
+package diameter_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/oyaguma3/go-eapaka"
+	"github.com/oyaguma3/go-eapaka/diameter"
+	"github.com/oyaguma3/go-eapaka/eapakatest"
+	"github.com/oyaguma3/go-eapaka/milenage"
+	"github.com/oyaguma3/go-eapaka/peer"
+	"github.com/oyaguma3/go-eapaka/server"
+)
+
+// startHSS serves sub from an HSS and returns a client connected to it.
+func startHSS(t *testing.T, sub *milenage.Subscriber) *diameter.Client {
+	t.Helper()
+	hss := diameter.NewHSS(sub)
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go hss.Serve(l)
+	c := &diameter.Client{
+		Addr:             l.Addr().String(),
+		OriginHost:       "aaa.example.org",
+		OriginRealm:      "example.org",
+		DestinationRealm: "localdomain",
+	}
+	t.Cleanup(func() {
+		c.Close()
+		hss.Close()
+	})
+	return c
+}
+
+func TestMessage_RoundTrip(t *testing.T) {
+	item := &diameter.AuthDataItem{Scheme: diameter.SchemeEAPAKAPrime, Authorization: bytes.Repeat([]byte{1}, 30)}
+	m := &diameter.Message{
+		Flags:         diameter.FlagRequest | diameter.FlagProxiable,
+		Code:          diameter.CmdMultimediaAuth,
+		ApplicationID: diameter.AppSWx,
+		HopByHop:      0x01020304,
+		EndToEnd:      0x05060708,
+	}
+	m.Add(
+		diameter.NewString(diameter.AVPSessionID, 0, "aaa;1;2"), // padded
+		diameter.NewString(diameter.AVPUserName, 0, eapakatest.Subscribers[0].IMSI),
+		item.AVP(),
+		diameter.NewUint32(diameter.AVPRATType, diameter.Vendor3GPP, diameter.RATTypeWLAN),
+	)
+	b, err := m.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(b)%4 != 0 {
+		t.Errorf("length %d is not padded", len(b))
+	}
+	got, err := diameter.ReadMessage(bytes.NewReader(b))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(m, got); diff != "" {
+		t.Errorf("ReadMessage (-want +got):\n%s", diff)
+	}
+	a, _ := got.Find(diameter.AVPSIPAuthDataItem, diameter.Vendor3GPP)
+	parsed, err := diameter.ParseAuthDataItem(a)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(item, parsed); diff != "" {
+		t.Errorf("ParseAuthDataItem (-want +got):\n%s", diff)
+	}
+
+	if _, err := diameter.Parse(b[:len(b)-4]); err == nil {
+		t.Error("Parse accepted a truncated message")
+	}
+}
+
+func TestClient_GetVector(t *testing.T) {
+	s := &eapakatest.Subscribers[0]
+	c := startHSS(t, s.Milenage())
+	usim, err := s.USIM()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	for _, eapType := range []uint8{eapaka.TypeAKA, eapaka.TypeAKAPrime} {
+		v, err := c.GetVector(ctx, &eapaka.VectorRequest{IMSI: s.IMSI, Type: eapType, NetworkName: "WLAN"})
+		if err != nil {
+			t.Fatalf("type %d: %v", eapType, err)
+		}
+		res, ck, ik, _, err := usim.Authenticate(v.RAND, v.AUTN)
+		if err != nil {
+			t.Fatalf("type %d: USIM rejected the vector: %v", eapType, err)
+		}
+		if eapType == eapaka.TypeAKAPrime {
+			if ck, ik, err = eapaka.DeriveCKPrimeIKPrime(ck, ik, "WLAN", v.AUTN); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if !bytes.Equal(res, v.XRES) || !bytes.Equal(ck, v.CK) || !bytes.Equal(ik, v.IK) || v.Prime != (eapType == eapaka.TypeAKAPrime) {
+			t.Errorf("type %d: vector does not match the USIM", eapType)
+		}
+	}
+
+	if _, err := c.GetVector(ctx, &eapaka.VectorRequest{IMSI: eapakatest.Subscribers[1].IMSI, Type: eapaka.TypeAKA}); !errors.Is(err, eapaka.ErrUnknownSubscriber) {
+		t.Errorf("unknown subscriber: %v", err)
+	}
+}
+
+// TestClient_AgainstServer runs the AAA-to-HSS leg under a full
+// conversation; the HSS starts behind the USIM and is brought forward by a
+// re-synchronisation through SIP-Authorization.
+func TestClient_AgainstServer(t *testing.T) {
+	s := &eapakatest.Subscribers[0]
+	sub := s.Milenage()
+	sub.SQN -= 500
+	c := startHSS(t, sub)
+	a, err := server.New(server.Config{Vectors: c})
+	if err != nil {
+		t.Fatal(err)
+	}
+	usim, err := s.USIM()
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := peer.New(peer.Config{Identity: s.Identity(eapaka.TypeAKAPrime), SIM: usim})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	msg, sessionID := p.IdentityResponse(0), ""
+	for range 10 {
+		res, err := a.Handle(context.Background(), sessionID, msg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		reply, _ := res.Reply.Marshal()
+		next, _ := p.Handle(reply)
+		if res.Status == server.StatusSuccess && p.Status() == peer.StatusSuccess {
+			return
+		}
+		if res.Status != server.StatusContinue {
+			t.Fatalf("status %v", res.Status)
+		}
+		msg, sessionID = next, res.SessionID
+	}
+	t.Fatal("conversation did not finish")
+}
+
+// TestClient_Watchdog checks that the client answers a Device-Watchdog-
+// Request from the HSS between its own requests.
+func TestClient_Watchdog(t *testing.T) {
+	s := &eapakatest.Subscribers[0]
+	hss := diameter.NewHSS(s.Milenage())
+	clientSide, hssSide := net.Pipe()
+	c := &diameter.Client{
+		Dial:             func(context.Context) (net.Conn, error) { return clientSide, nil },
+		OriginHost:       "aaa.example.org",
+		OriginRealm:      "example.org",
+		DestinationRealm: "localdomain",
+	}
+	defer c.Close()
+
+	dwa := make(chan *diameter.Message, 1)
+	go func() {
+		defer hssSide.Close()
+		for {
+			m, err := diameter.ReadMessage(hssSide)
+			if err != nil {
+				return
+			}
+			if !m.IsRequest() {
+				dwa <- m
+				continue
+			}
+			// net.Pipe is unbuffered: write without blocking the reads, as
+			// a socket buffer would.
+			b, _ := hss.HandleMessage(context.Background(), m).Marshal()
+			go hssSide.Write(b)
+			if m.Code == diameter.CmdCapabilitiesExchange {
+				dwr := &diameter.Message{Flags: diameter.FlagRequest, Code: diameter.CmdDeviceWatchdog, HopByHop: 7, EndToEnd: 7}
+				dwr.Add(diameter.NewString(diameter.AVPOriginHost, 0, "hss.localdomain"), diameter.NewString(diameter.AVPOriginRealm, 0, "localdomain"))
+				b, _ := dwr.Marshal()
+				go hssSide.Write(b)
+			}
+		}
+	}()
+
+	if _, err := c.GetVector(context.Background(), &eapaka.VectorRequest{IMSI: s.IMSI, Type: eapaka.TypeAKA}); err != nil {
+		t.Fatal(err)
+	}
+	m := <-dwa
+	if code, _, _ := m.ResultCode(); m.Code != diameter.CmdDeviceWatchdog || m.HopByHop != 7 || code != diameter.ResultSuccess {
+		t.Errorf("DWA = code %d, hop-by-hop %d, result %d", m.Code, m.HopByHop, code)
+	}
+}
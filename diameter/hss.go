@@ -0,0 +1,282 @@
+package diameter
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net"
+	"strings"
+	"sync"
+
+	"github.com/oyaguma3/go-eapaka"
+	"github.com/oyaguma3/go-eapaka/milenage"
+)
+
+// HSS is a Diameter server that stands in for an HSS on SWx. It answers
+// Multimedia-Auth-Requests with vectors from an [eapaka.VectorProvider],
+// typically a [milenage.Provider], re-synchronising SQN when the request
+// carries RAND || AUTS. It also answers Capabilities-Exchange,
+// Device-Watchdog and Disconnect-Peer requests. It is meant for tests and
+// lab setups.
+type HSS struct {
+	// Vectors generates the vectors. Required.
+	Vectors eapaka.VectorProvider
+
+	// OriginHost and OriginRealm identify the HSS.
+	// Default: "hss.localdomain" and "localdomain".
+	OriginHost  string
+	OriginRealm string
+
+	// Logger receives diagnostics. Default: discard.
+	Logger *slog.Logger
+
+	mu       sync.Mutex
+	listener net.Listener
+	conns    map[net.Conn]struct{}
+	closed   bool
+}
+
+// NewHSS creates an [HSS] that generates vectors with MILENAGE for the
+// given subscribers.
+func NewHSS(subs ...*milenage.Subscriber) *HSS {
+	return &HSS{Vectors: milenage.NewProvider(subs...)}
+}
+
+// ListenAndServe listens on the TCP address addr and calls [HSS.Serve].
+func (h *HSS) ListenAndServe(addr string) error {
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	return h.Serve(l)
+}
+
+// Serve accepts connections on l until [HSS.Close] is called.
+// Each connection is served in its own goroutine.
+func (h *HSS) Serve(l net.Listener) error {
+	if h.Vectors == nil {
+		return errors.New("diameter: HSS.Vectors is required")
+	}
+	h.mu.Lock()
+	if h.closed {
+		h.mu.Unlock()
+		return net.ErrClosed
+	}
+	h.listener = l
+	h.mu.Unlock()
+
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			h.mu.Lock()
+			closed := h.closed
+			h.mu.Unlock()
+			if closed {
+				return nil
+			}
+			return err
+		}
+		go func() {
+			if err := h.ServeConn(conn); err != nil {
+				h.logger().Debug("diameter: connection closed", "peer", conn.RemoteAddr().String(), "error", err)
+			}
+		}()
+	}
+}
+
+// ServeConn answers the requests read from conn until the peer disconnects
+// or the connection fails, then closes conn.
+func (h *HSS) ServeConn(conn net.Conn) error {
+	h.mu.Lock()
+	if h.closed {
+		h.mu.Unlock()
+		conn.Close()
+		return net.ErrClosed
+	}
+	if h.conns == nil {
+		h.conns = make(map[net.Conn]struct{})
+	}
+	h.conns[conn] = struct{}{}
+	h.mu.Unlock()
+	defer func() {
+		h.mu.Lock()
+		delete(h.conns, conn)
+		h.mu.Unlock()
+		conn.Close()
+	}()
+
+	for {
+		req, err := ReadMessage(conn)
+		if err != nil {
+			return err
+		}
+		if !req.IsRequest() {
+			continue
+		}
+		ans := h.HandleMessage(context.Background(), req)
+		b, err := ans.Marshal()
+		if err != nil {
+			return err
+		}
+		if _, err := conn.Write(b); err != nil {
+			return err
+		}
+		if req.Code == CmdDisconnectPeer {
+			return nil
+		}
+	}
+}
+
+// Close stops [HSS.Serve] and closes all connections.
+func (h *HSS) Close() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.closed = true
+	for conn := range h.conns {
+		conn.Close()
+	}
+	if h.listener != nil {
+		return h.listener.Close()
+	}
+	return nil
+}
+
+// HandleMessage returns the answer to the request m.
+func (h *HSS) HandleMessage(ctx context.Context, m *Message) *Message {
+	host, realm := h.origin()
+	ans := m.Answer()
+	switch {
+	case m.Code == CmdCapabilitiesExchange:
+		code := ResultSuccess
+		if !supportsSWx(m) {
+			code = ResultNoCommonApplication
+		}
+		ans.Add(NewUint32(AVPResultCode, 0, code))
+		ans.Add(capabilities(host, realm, nil)...)
+		return ans
+	case m.Code == CmdDeviceWatchdog || m.Code == CmdDisconnectPeer:
+		ans.Add(
+			NewUint32(AVPResultCode, 0, ResultSuccess),
+			NewString(AVPOriginHost, 0, host),
+			NewString(AVPOriginRealm, 0, realm),
+		)
+		return ans
+	case m.Code == CmdMultimediaAuth && m.ApplicationID == AppSWx:
+		return h.handleMAR(ctx, m, ans)
+	}
+	ans.Flags |= FlagError
+	ans.Add(
+		NewUint32(AVPResultCode, 0, ResultCommandUnsupported),
+		NewString(AVPOriginHost, 0, host),
+		NewString(AVPOriginRealm, 0, realm),
+	)
+	return ans
+}
+
+// handleMAR answers a Multimedia-Auth-Request (TS 29.273 Section 8.1.2.1).
+func (h *HSS) handleMAR(ctx context.Context, m, ans *Message) *Message {
+	host, realm := h.origin()
+	ans.Add(
+		vendorSpecificApplicationID(),
+		NewUint32(AVPAuthSessionState, 0, authSessionNoState),
+		NewString(AVPOriginHost, 0, host),
+		NewString(AVPOriginRealm, 0, realm),
+	)
+
+	user, okUser := m.Find(AVPUserName, 0)
+	a, okItem := m.Find(AVPSIPAuthDataItem, Vendor3GPP)
+	if !okUser || !okItem {
+		return result(ans, ResultMissingAVP, false)
+	}
+	item, err := ParseAuthDataItem(a)
+	if err != nil {
+		return result(ans, ResultUnableToComply, false)
+	}
+	// User-Name is the IMSI, or a permanent identity NAI.
+	imsi := string(user.Data)
+	if strings.Contains(imsi, "@") {
+		if id, ok := eapaka.IMSIFromIdentity(imsi); ok {
+			imsi = id
+		}
+	}
+	req := &eapaka.VectorRequest{IMSI: imsi}
+	switch item.Scheme {
+	case SchemeEAPAKA:
+		req.Type = eapaka.TypeAKA
+	case SchemeEAPAKAPrime:
+		anid, ok := m.Find(AVPANID, Vendor3GPP)
+		if !ok {
+			return result(ans, ResultMissingAVP, false)
+		}
+		req.Type, req.NetworkName = eapaka.TypeAKAPrime, string(anid.Data)
+	default:
+		return result(ans, ResultAuthSchemeNotSupported, true)
+	}
+	if len(item.Authorization) > 0 {
+		// RAND || AUTS from a synchronization failure.
+		if len(item.Authorization) != 16+14 {
+			return result(ans, ResultUnableToComply, false)
+		}
+		req.RAND, req.AUTS = item.Authorization[:16], item.Authorization[16:]
+	}
+
+	v, err := h.Vectors.GetVector(ctx, req)
+	if errors.Is(err, eapaka.ErrUnknownSubscriber) {
+		return result(ans, ResultErrorUserUnknown, true)
+	}
+	if err != nil {
+		h.logger().Debug("diameter: no vector", "imsi", eapaka.LogIdentity(imsi), "error", err)
+		return result(ans, ResultAuthDataUnavailable, true)
+	}
+	ck, ik := v.CK, v.IK
+	if req.Type == eapaka.TypeAKAPrime {
+		if ck, ik, err = v.CKIKPrime(req.NetworkName); err != nil {
+			h.logger().Debug("diameter: invalid vector", "imsi", eapaka.LogIdentity(imsi), "error", err)
+			return result(ans, ResultUnableToComply, false)
+		}
+	}
+	out := &AuthDataItem{
+		Scheme:        item.Scheme,
+		Authenticate:  append(append([]byte(nil), v.RAND...), v.AUTN...),
+		Authorization: v.XRES,
+		CK:            ck,
+		IK:            ik,
+	}
+	ans.Add(
+		user,
+		NewUint32(AVPSIPNumberAuthItems, Vendor3GPP, 1),
+		out.AVP(),
+	)
+	return result(ans, ResultSuccess, false)
+}
+
+// result adds a Result-Code, or an Experimental-Result from 3GPP.
+func result(ans *Message, code uint32, experimental bool) *Message {
+	if experimental {
+		ans.Add(NewGrouped(AVPExperimentalResult, 0,
+			NewUint32(AVPVendorID, 0, Vendor3GPP),
+			NewUint32(AVPExperimentalResultCode, 0, code),
+		))
+		return ans
+	}
+	ans.Add(NewUint32(AVPResultCode, 0, code))
+	return ans
+}
+
+func (h *HSS) origin() (host, realm string) {
+	host, realm = h.OriginHost, h.OriginRealm
+	if host == "" {
+		host = "hss.localdomain"
+	}
+	if realm == "" {
+		realm = "localdomain"
+	}
+	return host, realm
+}
+
+func (h *HSS) logger() *slog.Logger {
+	if h.Logger != nil {
+		return h.Logger
+	}
+	return slog.New(slog.DiscardHandler)
+}
@@ -0,0 +1,294 @@
+// Package diameter implements the subset of the Diameter base protocol
+// (RFC 6733) and of the SWx application (3GPP TS 29.273) that a 3GPP AAA
+// server needs to fetch EAP-AKA/AKA' vectors from an HSS: a [Client] that is
+// an [eapaka.VectorProvider], and an [HSS] that answers from MILENAGE or any
+// other provider, so that the AAA-to-HSS leg can run offline.
+package diameter
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+)
+
+// Command flags (RFC 6733 Section 3)
+const (
+	FlagRequest       uint8 = 0x80
+	FlagProxiable     uint8 = 0x40
+	FlagError         uint8 = 0x20
+	FlagRetransmitted uint8 = 0x10
+)
+
+// AVP flags (RFC 6733 Section 4.1)
+const (
+	FlagVendor    uint8 = 0x80
+	FlagMandatory uint8 = 0x40
+)
+
+const (
+	version       = 1
+	headerLen     = 20
+	maxMessageLen = 65535
+)
+
+// AVP is a single Diameter attribute-value pair. Grouped AVPs keep their
+// encoded members in Data; see [AVP.Grouped].
+type AVP struct {
+	Code     uint32
+	Flags    uint8
+	VendorID uint32 // 0 for the IETF; the V bit is set on marshal otherwise
+	Data     []byte
+}
+
+// Message represents a Diameter message.
+type Message struct {
+	Flags         uint8
+	Code          uint32 // 24 bits
+	ApplicationID uint32
+	HopByHop      uint32
+	EndToEnd      uint32
+	AVPs          []AVP
+}
+
+// Parse parses a Diameter message from a byte slice.
+func Parse(data []byte) (*Message, error) {
+	if len(data) < headerLen {
+		return nil, errors.New("diameter: message too short")
+	}
+	if data[0] != version {
+		return nil, fmt.Errorf("diameter: unsupported version %d", data[0])
+	}
+	length := int(uint24(data[1:4]))
+	if length < headerLen || length > len(data) {
+		return nil, errors.New("diameter: message length mismatch")
+	}
+	m := &Message{
+		Flags:         data[4],
+		Code:          uint24(data[5:8]),
+		ApplicationID: binary.BigEndian.Uint32(data[8:12]),
+		HopByHop:      binary.BigEndian.Uint32(data[12:16]),
+		EndToEnd:      binary.BigEndian.Uint32(data[16:20]),
+	}
+	avps, err := parseAVPs(data[headerLen:length])
+	if err != nil {
+		return nil, err
+	}
+	m.AVPs = avps
+	return m, nil
+}
+
+// ReadMessage reads one message from r, such as a stream connection.
+func ReadMessage(r io.Reader) (*Message, error) {
+	var hdr [4]byte
+	if _, err := io.ReadFull(r, hdr[:]); err != nil {
+		return nil, err
+	}
+	length := int(uint24(hdr[1:4]))
+	if hdr[0] != version || length < headerLen || length > maxMessageLen {
+		return nil, errors.New("diameter: invalid message header")
+	}
+	b := make([]byte, length)
+	copy(b, hdr[:])
+	if _, err := io.ReadFull(r, b[4:]); err != nil {
+		return nil, err
+	}
+	return Parse(b)
+}
+
+// Marshal serializes the message.
+func (m *Message) Marshal() ([]byte, error) {
+	b := make([]byte, headerLen, headerLen+64*len(m.AVPs))
+	b = appendAVPs(b, m.AVPs)
+	if len(b) > maxMessageLen {
+		return nil, errors.New("diameter: message too long")
+	}
+	b[0] = version
+	putUint24(b[1:4], uint32(len(b)))
+	b[4] = m.Flags
+	putUint24(b[5:8], m.Code)
+	binary.BigEndian.PutUint32(b[8:12], m.ApplicationID)
+	binary.BigEndian.PutUint32(b[12:16], m.HopByHop)
+	binary.BigEndian.PutUint32(b[16:20], m.EndToEnd)
+	return b, nil
+}
+
+// IsRequest reports whether the R bit is set.
+func (m *Message) IsRequest() bool { return m.Flags&FlagRequest != 0 }
+
+// Answer creates an answer to m. Command Code, Application-Id, Hop-by-Hop
+// and End-to-End Identifiers and the P bit are copied from m, and Session-Id
+// if present.
+func (m *Message) Answer() *Message {
+	a := &Message{
+		Flags:         m.Flags & FlagProxiable,
+		Code:          m.Code,
+		ApplicationID: m.ApplicationID,
+		HopByHop:      m.HopByHop,
+		EndToEnd:      m.EndToEnd,
+	}
+	if sid, ok := m.Find(AVPSessionID, 0); ok {
+		a.Add(sid)
+	}
+	return a
+}
+
+// Add appends AVPs.
+func (m *Message) Add(avps ...AVP) {
+	m.AVPs = append(m.AVPs, avps...)
+}
+
+// Find returns the first AVP with the given code and vendor (0 for the IETF).
+func (m *Message) Find(code, vendorID uint32) (AVP, bool) {
+	return findAVP(m.AVPs, code, vendorID)
+}
+
+// FindAll returns all AVPs with the given code and vendor.
+func (m *Message) FindAll(code, vendorID uint32) []AVP {
+	var found []AVP
+	for _, a := range m.AVPs {
+		if a.Code == code && a.VendorID == vendorID {
+			found = append(found, a)
+		}
+	}
+	return found
+}
+
+// ResultCode returns the Result-Code of an answer, or the
+// Experimental-Result-Code with experimental set. ok is false if there is
+// neither.
+func (m *Message) ResultCode() (code uint32, experimental, ok bool) {
+	if a, found := m.Find(AVPResultCode, 0); found {
+		code, err := a.Uint32()
+		return code, false, err == nil
+	}
+	if a, found := m.Find(AVPExperimentalResult, 0); found {
+		members, err := a.Grouped()
+		if err != nil {
+			return 0, true, false
+		}
+		if c, found := findAVP(members, AVPExperimentalResultCode, 0); found {
+			code, err := c.Uint32()
+			return code, true, err == nil
+		}
+	}
+	return 0, false, false
+}
+
+// NewAVP returns an AVP with the M bit set, and the V bit if vendorID is
+// not 0.
+func NewAVP(code, vendorID uint32, data []byte) AVP {
+	a := AVP{Code: code, Flags: FlagMandatory, VendorID: vendorID, Data: data}
+	if vendorID != 0 {
+		a.Flags |= FlagVendor
+	}
+	return a
+}
+
+// NewUint32 returns an Unsigned32 or Enumerated AVP; see [NewAVP].
+func NewUint32(code, vendorID, v uint32) AVP {
+	return NewAVP(code, vendorID, binary.BigEndian.AppendUint32(nil, v))
+}
+
+// NewString returns a UTF8String, DiameterIdentity or OctetString AVP; see
+// [NewAVP].
+func NewString(code, vendorID uint32, s string) AVP {
+	return NewAVP(code, vendorID, []byte(s))
+}
+
+// NewAddress returns an Address AVP for ip; see [NewAVP].
+func NewAddress(code, vendorID uint32, ip net.IP) AVP {
+	if ip4 := ip.To4(); ip4 != nil {
+		return NewAVP(code, vendorID, append([]byte{0, 1}, ip4...))
+	}
+	return NewAVP(code, vendorID, append([]byte{0, 2}, ip.To16()...))
+}
+
+// NewGrouped returns a Grouped AVP holding members; see [NewAVP].
+func NewGrouped(code, vendorID uint32, members ...AVP) AVP {
+	return NewAVP(code, vendorID, appendAVPs(nil, members))
+}
+
+// Uint32 returns the value of an Unsigned32 or Enumerated AVP.
+func (a AVP) Uint32() (uint32, error) {
+	if len(a.Data) != 4 {
+		return 0, fmt.Errorf("diameter: AVP %d is not a 32-bit value", a.Code)
+	}
+	return binary.BigEndian.Uint32(a.Data), nil
+}
+
+// Grouped returns the members of a Grouped AVP.
+func (a AVP) Grouped() ([]AVP, error) {
+	return parseAVPs(a.Data)
+}
+
+func avpHeaderLen(flags uint8) int {
+	if flags&FlagVendor != 0 {
+		return 12
+	}
+	return 8
+}
+
+func findAVP(avps []AVP, code, vendorID uint32) (AVP, bool) {
+	for _, a := range avps {
+		if a.Code == code && a.VendorID == vendorID {
+			return a, true
+		}
+	}
+	return AVP{}, false
+}
+
+func parseAVPs(b []byte) ([]AVP, error) {
+	var avps []AVP
+	for len(b) > 0 {
+		if len(b) < 8 {
+			return nil, errors.New("diameter: AVP header truncated")
+		}
+		a := AVP{Code: binary.BigEndian.Uint32(b[0:4]), Flags: b[4]}
+		length, hdrLen := int(uint24(b[5:8])), avpHeaderLen(a.Flags)
+		if length < hdrLen || length > len(b) {
+			return nil, fmt.Errorf("diameter: AVP %d length invalid", a.Code)
+		}
+		if a.Flags&FlagVendor != 0 {
+			a.VendorID = binary.BigEndian.Uint32(b[8:12])
+		}
+		a.Data = append([]byte(nil), b[hdrLen:length]...)
+		avps = append(avps, a)
+
+		// AVPs are padded to a multiple of four bytes; the padding of the
+		// last AVP may be missing.
+		length = min((length+3)&^3, len(b))
+		b = b[length:]
+	}
+	return avps, nil
+}
+
+func appendAVPs(b []byte, avps []AVP) []byte {
+	for _, a := range avps {
+		a.Flags &^= FlagVendor
+		if a.VendorID != 0 {
+			a.Flags |= FlagVendor
+		}
+		length := avpHeaderLen(a.Flags) + len(a.Data)
+		b = binary.BigEndian.AppendUint32(b, a.Code)
+		b = append(b, a.Flags, byte(length>>16), byte(length>>8), byte(length))
+		if a.VendorID != 0 {
+			b = binary.BigEndian.AppendUint32(b, a.VendorID)
+		}
+		b = append(b, a.Data...)
+		for length%4 != 0 {
+			b = append(b, 0)
+			length++
+		}
+	}
+	return b
+}
+
+func uint24(b []byte) uint32 {
+	return uint32(b[0])<<16 | uint32(b[1])<<8 | uint32(b[2])
+}
+
+func putUint24(b []byte, v uint32) {
+	b[0], b[1], b[2] = byte(v>>16), byte(v>>8), byte(v)
+}
@@ -0,0 +1,206 @@
+package diameter
+
+import (
+	"errors"
+	"net"
+)
+
+// Application Ids
+const (
+	AppCommon uint32 = 0        // Diameter common messages (RFC 6733)
+	AppSWx    uint32 = 16777265 // SWx (TS 29.273 Section 8.1)
+)
+
+// Vendor3GPP is the 3GPP vendor id used by SWx AVPs.
+const Vendor3GPP uint32 = 10415
+
+// Command Codes (RFC 6733 Section 3.1, TS 29.273 Section 8.1.2)
+const (
+	CmdCapabilitiesExchange uint32 = 257
+	CmdDeviceWatchdog       uint32 = 280
+	CmdDisconnectPeer       uint32 = 282
+	CmdMultimediaAuth       uint32 = 303
+)
+
+// Base protocol AVP codes (RFC 6733 Section 4.5)
+const (
+	AVPUserName                    uint32 = 1
+	AVPHostIPAddress               uint32 = 257
+	AVPAuthApplicationID           uint32 = 258
+	AVPVendorSpecificApplicationID uint32 = 260
+	AVPSessionID                   uint32 = 263
+	AVPOriginHost                  uint32 = 264
+	AVPSupportedVendorID           uint32 = 265
+	AVPVendorID                    uint32 = 266
+	AVPResultCode                  uint32 = 268
+	AVPProductName                 uint32 = 269
+	AVPAuthSessionState            uint32 = 277
+	AVPDestinationRealm            uint32 = 283
+	AVPDestinationHost             uint32 = 293
+	AVPOriginRealm                 uint32 = 296
+	AVPExperimentalResult          uint32 = 297
+	AVPExperimentalResultCode      uint32 = 298
+)
+
+// 3GPP AVP codes used by SWx (TS 29.229 Section 6.3, TS 29.273 Section 8.2.3,
+// TS 29.212 Section 5.3.31)
+const (
+	AVPSIPNumberAuthItems      uint32 = 607
+	AVPSIPAuthenticationScheme uint32 = 608
+	AVPSIPAuthenticate         uint32 = 609
+	AVPSIPAuthorization        uint32 = 610
+	AVPSIPAuthDataItem         uint32 = 612
+	AVPConfidentialityKey      uint32 = 625
+	AVPIntegrityKey            uint32 = 626
+	AVPRATType                 uint32 = 1032
+	AVPANID                    uint32 = 1504
+)
+
+// Result codes (RFC 6733 Section 7.1, TS 29.229 Section 6.2, TS 29.273
+// Section 8.2.4)
+const (
+	ResultSuccess                uint32 = 2001
+	ResultCommandUnsupported     uint32 = 3001
+	ResultApplicationUnsupported uint32 = 3007
+	ResultAuthenticationRejected uint32 = 4001
+	ResultMissingAVP             uint32 = 5005
+	ResultNoCommonApplication    uint32 = 5010
+	ResultUnableToComply         uint32 = 5012
+	ResultErrorUserUnknown       uint32 = 5001 // experimental, 3GPP
+	ResultAuthDataUnavailable    uint32 = 4181 // experimental, 3GPP
+	ResultAuthSchemeNotSupported uint32 = 5006 // experimental, 3GPP
+)
+
+// SIP-Authentication-Scheme values of SWx (TS 29.273 Section 8.2.3.2)
+const (
+	SchemeEAPAKA      = "EAP-AKA"
+	SchemeEAPAKAPrime = "EAP-AKA'"
+)
+
+// RATTypeWLAN is the RAT-Type of untrusted and trusted WLAN access.
+const RATTypeWLAN uint32 = 0
+
+// authSessionNoState is NO_STATE_MAINTAINED (RFC 6733 Section 8.11).
+const authSessionNoState uint32 = 1
+
+// AuthDataItem is the content of a SIP-Auth-Data-Item AVP (TS 29.273
+// Section 8.2.3.9).
+type AuthDataItem struct {
+	// Scheme is the SIP-Authentication-Scheme: SchemeEAPAKA or SchemeEAPAKAPrime.
+	Scheme string
+
+	// Authenticate is SIP-Authenticate in an MAA: RAND || AUTN.
+	Authenticate []byte
+
+	// Authorization is SIP-Authorization: XRES in an MAA, and RAND || AUTS
+	// in an MAR that asks the HSS to re-synchronise.
+	Authorization []byte
+
+	// CK and IK are Confidentiality-Key and Integrity-Key in an MAA; for
+	// EAP-AKA' they hold CK' and IK'.
+	CK, IK []byte
+}
+
+// AVP encodes d as a SIP-Auth-Data-Item AVP. Empty fields are left out.
+func (d *AuthDataItem) AVP() AVP {
+	var members []AVP
+	for _, f := range []struct {
+		code  uint32
+		value []byte
+	}{
+		{AVPSIPAuthenticationScheme, []byte(d.Scheme)},
+		{AVPSIPAuthenticate, d.Authenticate},
+		{AVPSIPAuthorization, d.Authorization},
+		{AVPConfidentialityKey, d.CK},
+		{AVPIntegrityKey, d.IK},
+	} {
+		if len(f.value) > 0 {
+			members = append(members, NewAVP(f.code, Vendor3GPP, f.value))
+		}
+	}
+	return NewGrouped(AVPSIPAuthDataItem, Vendor3GPP, members...)
+}
+
+// ParseAuthDataItem decodes a SIP-Auth-Data-Item AVP.
+func ParseAuthDataItem(a AVP) (*AuthDataItem, error) {
+	if a.Code != AVPSIPAuthDataItem || a.VendorID != Vendor3GPP {
+		return nil, errors.New("diameter: not a SIP-Auth-Data-Item")
+	}
+	members, err := a.Grouped()
+	if err != nil {
+		return nil, err
+	}
+	d := &AuthDataItem{}
+	for _, m := range members {
+		if m.VendorID != Vendor3GPP {
+			continue
+		}
+		switch m.Code {
+		case AVPSIPAuthenticationScheme:
+			d.Scheme = string(m.Data)
+		case AVPSIPAuthenticate:
+			d.Authenticate = m.Data
+		case AVPSIPAuthorization:
+			d.Authorization = m.Data
+		case AVPConfidentialityKey:
+			d.CK = m.Data
+		case AVPIntegrityKey:
+			d.IK = m.Data
+		}
+	}
+	return d, nil
+}
+
+// vendorSpecificApplicationID advertises or selects SWx.
+func vendorSpecificApplicationID() AVP {
+	return NewGrouped(AVPVendorSpecificApplicationID, 0,
+		NewUint32(AVPVendorID, 0, Vendor3GPP),
+		NewUint32(AVPAuthApplicationID, 0, AppSWx),
+	)
+}
+
+// capabilities returns the AVPs shared by CER and CEA (RFC 6733 Section 5.3).
+func capabilities(host, realm string, ip net.IP) []AVP {
+	if ip == nil {
+		ip = net.IPv4(127, 0, 0, 1)
+	}
+	return []AVP{
+		NewString(AVPOriginHost, 0, host),
+		NewString(AVPOriginRealm, 0, realm),
+		NewAddress(AVPHostIPAddress, 0, ip),
+		NewUint32(AVPVendorID, 0, 0),
+		// Product-Name is a UTF8String without the M bit.
+		{Code: AVPProductName, Data: []byte("go-eapaka")},
+		NewUint32(AVPSupportedVendorID, 0, Vendor3GPP),
+		vendorSpecificApplicationID(),
+	}
+}
+
+// supportsSWx reports whether a CER or CEA advertises SWx.
+func supportsSWx(m *Message) bool {
+	for _, a := range m.FindAll(AVPAuthApplicationID, 0) {
+		if id, _ := a.Uint32(); id == AppSWx {
+			return true
+		}
+	}
+	for _, a := range m.FindAll(AVPVendorSpecificApplicationID, 0) {
+		members, err := a.Grouped()
+		if err != nil {
+			continue
+		}
+		if id, ok := findAVP(members, AVPAuthApplicationID, 0); ok {
+			if v, _ := id.Uint32(); v == AppSWx {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// localIP returns the local address of conn for Host-IP-Address.
+func localIP(conn net.Conn) net.IP {
+	if addr, ok := conn.LocalAddr().(*net.TCPAddr); ok {
+		return addr.IP
+	}
+	return nil
+}
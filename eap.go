@@ -0,0 +1,169 @@
+package eapaka
+
+import (
+	"encoding/binary"
+	"slices"
+)
+
+// expandedHeaderLen is the length of the Vendor-Id and Vendor-Type of an
+// Expanded Type (RFC 3748 Section 5.7).
+const expandedHeaderLen = 7
+
+// ExpandedType identifies a method by Vendor-Id and Vendor-Type in an
+// Expanded Type packet or an Expanded Nak (RFC 3748 Sections 5.3.2 and 5.7).
+type ExpandedType struct {
+	VendorID   uint32 // 24 bits; 0 is the IETF
+	VendorType uint32
+}
+
+// ExpandedNak is the Expanded Type of an Expanded Nak: IETF Vendor-Id and
+// Vendor-Type 3.
+var ExpandedNak = ExpandedType{VendorID: 0, VendorType: uint32(TypeNak)}
+
+func (t ExpandedType) append(b []byte) []byte {
+	b = append(b, byte(t.VendorID>>16), byte(t.VendorID>>8), byte(t.VendorID))
+	return binary.BigEndian.AppendUint32(b, t.VendorType)
+}
+
+func readExpandedType(b []byte) ExpandedType {
+	return ExpandedType{
+		VendorID:   uint32(b[0])<<16 | uint32(b[1])<<8 | uint32(b[2]),
+		VendorType: binary.BigEndian.Uint32(b[3:7]),
+	}
+}
+
+// NewEAPIdentity returns an EAP-Request or EAP-Response/Identity (Type 1)
+// carrying identity; a request usually carries an empty identity or a
+// displayable prompt. Not to be confused with EAP-AKA's own AKA-Identity
+// round ([NewIdentityRequest]).
+func NewEAPIdentity(code, identifier uint8, identity string) *Packet {
+	return &Packet{Code: code, Identifier: identifier, Type: TypeIdentity, TypeData: []byte(identity)}
+}
+
+// EAPIdentity returns the identity of an EAP-Request or EAP-Response/Identity.
+func (p *Packet) EAPIdentity() (string, bool) {
+	if p.Type != TypeIdentity {
+		return "", false
+	}
+	return string(p.TypeData), true
+}
+
+// NewEAPNotification returns an EAP-Request or EAP-Response/Notification
+// (Type 2) with a displayable message; a response carries none.
+func NewEAPNotification(code, identifier uint8, message string) *Packet {
+	return &Packet{Code: code, Identifier: identifier, Type: TypeNotification, TypeData: []byte(message)}
+}
+
+// EAPNotification returns the message of an EAP-Request/Notification.
+func (p *Packet) EAPNotification() (string, bool) {
+	if p.Type != TypeNotification {
+		return "", false
+	}
+	return string(p.TypeData), true
+}
+
+// NewNak returns an EAP-Response/Nak (Type 3) proposing the desired
+// methods in order of preference. Without desired, the Nak carries 0: no
+// alternative is available. Include [TypeExpanded] to ask for an Expanded
+// Nak (RFC 3748 Section 5.3.1).
+func NewNak(identifier uint8, desired ...uint8) *Packet {
+	if len(desired) == 0 {
+		desired = []uint8{0}
+	}
+	return &Packet{Code: CodeResponse, Identifier: identifier, Type: TypeNak, TypeData: slices.Clone(desired)}
+}
+
+// NakTypes returns the desired methods of an EAP-Response/Nak, without the
+// 0 that means none.
+func (p *Packet) NakTypes() ([]uint8, bool) {
+	if p.Code != CodeResponse || p.Type != TypeNak {
+		return nil, false
+	}
+	var types []uint8
+	for _, t := range p.TypeData {
+		if t != 0 {
+			types = append(types, t)
+		}
+	}
+	return types, true
+}
+
+// NewExpanded returns an Expanded Type packet (Type 254) for the method t
+// with the method's data.
+func NewExpanded(code, identifier uint8, t ExpandedType, data []byte) *Packet {
+	b := make([]byte, 0, expandedHeaderLen+len(data))
+	b = t.append(b)
+	return &Packet{Code: code, Identifier: identifier, Type: TypeExpanded, TypeData: append(b, data...)}
+}
+
+// Expanded returns the method and data of an Expanded Type packet.
+func (p *Packet) Expanded() (t ExpandedType, data []byte, ok bool) {
+	if p.Type != TypeExpanded || len(p.TypeData) < expandedHeaderLen {
+		return ExpandedType{}, nil, false
+	}
+	return readExpandedType(p.TypeData), p.TypeData[expandedHeaderLen:], true
+}
+
+// NewExpandedNak returns an Expanded Nak (RFC 3748 Section 5.3.2) proposing
+// the desired methods in order of preference. Without desired, it carries
+// the IETF Vendor-Type 0: no alternative is available.
+func NewExpandedNak(identifier uint8, desired ...ExpandedType) *Packet {
+	if len(desired) == 0 {
+		desired = []ExpandedType{{}}
+	}
+	data := make([]byte, 0, len(desired)*(1+expandedHeaderLen))
+	for _, t := range desired {
+		data = append(data, TypeExpanded)
+		data = t.append(data)
+	}
+	return NewExpanded(CodeResponse, identifier, ExpandedNak, data)
+}
+
+// ExpandedNakTypes returns the desired methods of an Expanded Nak, without
+// the IETF Vendor-Type 0 that means none. Methods with a one-byte Type
+// appear with the IETF Vendor-Id.
+func (p *Packet) ExpandedNakTypes() ([]ExpandedType, bool) {
+	t, data, ok := p.Expanded()
+	if !ok || p.Code != CodeResponse || t != ExpandedNak {
+		return nil, false
+	}
+	var types []ExpandedType
+	for len(data) >= 1+expandedHeaderLen {
+		if d := readExpandedType(data[1:]); d != (ExpandedType{}) {
+			types = append(types, d)
+		}
+		data = data[1+expandedHeaderLen:]
+	}
+	return types, true
+}
+
+// DesiredTypes returns the methods proposed by a Nak or an Expanded Nak as
+// one-byte Types, in order of preference; vendor-specific methods are
+// skipped. ok is false if p is neither.
+func (p *Packet) DesiredTypes() (types []uint8, ok bool) {
+	if types, ok := p.NakTypes(); ok {
+		return types, true
+	}
+	expanded, ok := p.ExpandedNakTypes()
+	if !ok {
+		return nil, false
+	}
+	for _, t := range expanded {
+		if t.VendorID == 0 && t.VendorType < uint32(TypeExpanded) {
+			types = append(types, uint8(t.VendorType))
+		}
+	}
+	return types, true
+}
+
+// NegotiateType returns the first method of supported, in the caller's order
+// of preference, that is among desired, such as the methods of
+// [Packet.DesiredTypes]. ok is false if there is none.
+func NegotiateType(supported, desired []uint8) (_ uint8, ok bool) {
+	for _, t := range supported {
+		if slices.Contains(desired, t) {
+			return t, true
+		}
+	}
+	return 0, false
+}
@@ -0,0 +1,127 @@
+package eapaka_test
+
+import (
+	"bytes"
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/oyaguma3/go-eapaka"
+)
+
+func TestGenericTypes_RoundTrip(t *testing.T) {
+	vendor := eapaka.ExpandedType{VendorID: 0x0028af, VendorType: 7}
+	tests := []struct {
+		name string
+		pkt  *eapaka.Packet
+		want []byte
+	}{
+		{"identity", eapaka.NewEAPIdentity(eapaka.CodeResponse, 1, "user@example.org"),
+			append([]byte{2, 1, 0, 21, 1}, "user@example.org"...)},
+		{"identity request", eapaka.NewEAPIdentity(eapaka.CodeRequest, 1, ""), []byte{1, 1, 0, 5, 1}},
+		{"notification", eapaka.NewEAPNotification(eapaka.CodeRequest, 2, "hi"), []byte{1, 2, 0, 7, 2, 'h', 'i'}},
+		{"nak", eapaka.NewNak(3, eapaka.TypeAKA, eapaka.TypeAKAPrime), []byte{2, 3, 0, 7, 3, 23, 50}},
+		{"nak none", eapaka.NewNak(3), []byte{2, 3, 0, 6, 3, 0}},
+		{"expanded", eapaka.NewExpanded(eapaka.CodeRequest, 4, vendor, []byte{0xaa}),
+			[]byte{1, 4, 0, 13, 254, 0x00, 0x28, 0xaf, 0, 0, 0, 7, 0xaa}},
+		{"expanded nak", eapaka.NewExpandedNak(5, vendor),
+			[]byte{2, 5, 0, 20, 254, 0, 0, 0, 0, 0, 0, 3, 254, 0x00, 0x28, 0xaf, 0, 0, 0, 7}},
+		{"other method", &eapaka.Packet{Code: eapaka.CodeRequest, Identifier: 6, Type: 13, TypeData: []byte{0x20}},
+			[]byte{1, 6, 0, 6, 13, 0x20}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, err := tt.pkt.Marshal()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(b, tt.want) || tt.pkt.Len() != len(b) {
+				t.Fatalf("Marshal = %x (Len %d), want %x", b, tt.pkt.Len(), tt.want)
+			}
+			got, err := eapaka.ParseWithOptions(b, eapaka.StrictParseOptions)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(tt.pkt, got, cmp.AllowUnexported(eapaka.Packet{}), cmp.FilterPath(func(p cmp.Path) bool {
+				return p.Last().String() == ".raw" || p.Last().String() == ".macOff"
+			}, cmp.Ignore())); diff != "" {
+				t.Errorf("Parse (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestGenericTypes_Accessors(t *testing.T) {
+	if id, ok := eapaka.NewEAPIdentity(eapaka.CodeResponse, 1, "0001@x").EAPIdentity(); !ok || id != "0001@x" {
+		t.Errorf("EAPIdentity = %q, %v", id, ok)
+	}
+	if msg, ok := eapaka.NewEAPNotification(eapaka.CodeRequest, 1, "hello").EAPNotification(); !ok || msg != "hello" {
+		t.Errorf("EAPNotification = %q, %v", msg, ok)
+	}
+	if _, ok := eapaka.NewNak(1, 23).EAPIdentity(); ok {
+		t.Error("EAPIdentity of a Nak")
+	}
+
+	vendor := eapaka.ExpandedType{VendorID: 0x0028af, VendorType: 7}
+	expanded := eapaka.NewExpanded(eapaka.CodeRequest, 1, vendor, []byte("data"))
+	if typ, data, ok := expanded.Expanded(); !ok || typ != vendor || string(data) != "data" {
+		t.Errorf("Expanded = %v, %q, %v", typ, data, ok)
+	}
+	if _, ok := expanded.ExpandedNakTypes(); ok {
+		t.Error("ExpandedNakTypes of an Expanded request")
+	}
+
+	tests := []struct {
+		name string
+		pkt  *eapaka.Packet
+		want []uint8
+	}{
+		{"nak", eapaka.NewNak(1, eapaka.TypeAKA, eapaka.TypeExpanded), []uint8{eapaka.TypeAKA, eapaka.TypeExpanded}},
+		{"nak none", eapaka.NewNak(1), nil},
+		{"expanded nak", eapaka.NewExpandedNak(1, vendor, eapaka.ExpandedType{VendorType: uint32(eapaka.TypeAKA)}), []uint8{eapaka.TypeAKA}},
+		{"expanded nak none", eapaka.NewExpandedNak(1), nil},
+	}
+	for _, tt := range tests {
+		got, ok := tt.pkt.DesiredTypes()
+		if !ok || !slices.Equal(got, tt.want) {
+			t.Errorf("%s: DesiredTypes = %v, %v; want %v", tt.name, got, ok, tt.want)
+		}
+	}
+	if _, ok := expanded.DesiredTypes(); ok {
+		t.Error("DesiredTypes of an Expanded request")
+	}
+
+	supported := []uint8{eapaka.TypeAKAPrime, eapaka.TypeAKA}
+	if got, ok := eapaka.NegotiateType(supported, []uint8{4, eapaka.TypeAKA, eapaka.TypeAKAPrime}); !ok || got != eapaka.TypeAKAPrime {
+		t.Errorf("NegotiateType = %d, %v", got, ok)
+	}
+	if _, ok := eapaka.NegotiateType(supported, []uint8{4}); ok {
+		t.Error("NegotiateType without a common method")
+	}
+}
+
+func TestParse_ExpandedTruncated(t *testing.T) {
+	_, err := eapaka.Parse([]byte{1, 1, 0, 8, 254, 0, 0, 0})
+	if !errors.Is(err, eapaka.ErrTruncated) {
+		t.Errorf("Parse = %v, want ErrTruncated", err)
+	}
+}
+
+func TestAtBidding(t *testing.T) {
+	for _, d := range []bool{false, true} {
+		pkt, err := eapaka.NewChallengeRequest(eapaka.TypeAKA, 1, make([]byte, 16), make([]byte, 16)).
+			Add(&eapaka.AtBidding{D: d}).Sign(make([]byte, 16))
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, _ := pkt.Marshal()
+		got, err := eapaka.Parse(b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if bid, ok := eapaka.Find[*eapaka.AtBidding](got); !ok || bid.D != d {
+			t.Errorf("AT_BIDDING D = %v, want %v", bid, d)
+		}
+	}
+}
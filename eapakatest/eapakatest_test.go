@@ -0,0 +1,133 @@
+package eapakatest_test
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/oyaguma3/go-eapaka"
+	"github.com/oyaguma3/go-eapaka/eapakatest"
+	"github.com/oyaguma3/go-eapaka/milenage"
+	"github.com/oyaguma3/go-eapaka/peer"
+	"github.com/oyaguma3/go-eapaka/server"
+)
+
+func TestRun(t *testing.T) {
+	eapakatest.Run(t)
+}
+
+func TestReplay_Tampered(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(f *eapakatest.Flow)
+		want   string
+	}{
+		{"MAC", func(f *eapakatest.Flow) { f.Messages[2][len(f.Messages[2])-1] ^= 1 }, "AT_MAC does not verify"},
+		{"NONCE_S", func(f *eapakatest.Flow) { f.NonceS = make([]byte, 16) }, "AT_NONCE_S"},
+		{"MSK", func(f *eapakatest.Flow) { f.MSK = f.EMSK }, "MSK ="},
+		{"identity", func(f *eapakatest.Flow) { f.Identity = "0001010000000002@" + eapakatest.Realm }, "AT_IDENTITY"},
+		{"subscriber", func(f *eapakatest.Flow) { f.Subscriber = &eapakatest.Subscribers[1] }, "USIM"},
+	}
+	for _, tt := range tests {
+		for _, orig := range eapakatest.Flows {
+			t.Run(tt.name+"/"+orig.Name, func(t *testing.T) {
+				f := orig
+				f.Messages = make([][]byte, len(orig.Messages))
+				for i, m := range orig.Messages {
+					f.Messages[i] = bytes.Clone(m)
+				}
+				tt.modify(&f)
+				err := eapakatest.Replay(&f)
+				if err == nil || !strings.Contains(err.Error(), tt.want) {
+					t.Errorf("Replay = %v, want error containing %q", err, tt.want)
+				}
+			})
+		}
+	}
+}
+
+func TestSubscribers(t *testing.T) {
+	if len(eapakatest.Subscribers) != len(eapakatest.Milenage) {
+		t.Fatalf("%d subscribers for %d test sets", len(eapakatest.Subscribers), len(eapakatest.Milenage))
+	}
+	for i := range eapakatest.Subscribers {
+		s := &eapakatest.Subscribers[i]
+		if _, ok := eapaka.IMSIFromIdentity(s.Identity(eapaka.TypeAKAPrime)); !ok {
+			t.Errorf("Identity(%s) is not a permanent identity", s.IMSI)
+		}
+
+		// A vector from the provider is accepted by the USIM.
+		provider := milenage.NewProvider(s.Milenage())
+		v, err := provider.GetVector(context.Background(), &eapaka.VectorRequest{IMSI: s.IMSI, Type: eapaka.TypeAKAPrime})
+		if err != nil {
+			t.Fatal(err)
+		}
+		usim, err := s.USIM()
+		if err != nil {
+			t.Fatal(err)
+		}
+		res, _, _, _, err := usim.Authenticate(v.RAND, v.AUTN)
+		if err != nil || !bytes.Equal(res, v.XRES) {
+			t.Errorf("%s: Authenticate = %x, %v; want %x", s.IMSI, res, err, v.XRES)
+		}
+	}
+}
+
+// transcript runs a full authentication and a fast re-authentication of the
+// first subscriber with every component reading from NewRandom(seed).
+func transcript(t *testing.T, seed string) [][]byte {
+	t.Helper()
+	s := &eapakatest.Subscribers[0]
+	provider := milenage.NewProvider(s.Milenage())
+	provider.Random = eapakatest.NewRandom(seed + "/provider")
+	a, err := server.New(server.Config{Vectors: provider, EnableReauth: true, Realm: eapakatest.Realm, Random: eapakatest.NewRandom(seed + "/server")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	usim, err := s.USIM()
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := peer.New(peer.Config{Identity: s.Identity(eapaka.TypeAKAPrime), SIM: usim, Random: eapakatest.NewRandom(seed + "/peer")})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var msgs [][]byte
+	for range 2 {
+		msg, sessionID := p.IdentityResponse(0), ""
+		for {
+			msgs = append(msgs, msg)
+			res, err := a.Handle(context.Background(), sessionID, msg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			reply, _ := res.Reply.Marshal()
+			msgs = append(msgs, reply)
+			next, err := p.Handle(reply)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if res.Status != server.StatusContinue {
+				break
+			}
+			msg, sessionID = next, res.SessionID
+		}
+	}
+	if !p.Reauthenticated() {
+		t.Fatal("second conversation was not a fast re-authentication")
+	}
+	return msgs
+}
+
+func TestNewRandom(t *testing.T) {
+	first := transcript(t, "seed")
+	if diff := cmp.Diff(first, transcript(t, "seed")); diff != "" {
+		t.Errorf("same seed, different transcript (-first +second):\n%s", diff)
+	}
+	if cmp.Equal(first, transcript(t, "other")) {
+		t.Error("different seeds, same transcript")
+	}
+}
@@ -0,0 +1,59 @@
+package eapakatest
+
+import (
+	"github.com/oyaguma3/go-eapaka"
+	"github.com/oyaguma3/go-eapaka/milenage"
+)
+
+// Realm is the realm of the fixture identities (test PLMN 001/01).
+const Realm = "wlan.mnc001.mcc001.3gppnetwork.org"
+
+// Subscriber is a canned subscription for tests.
+type Subscriber struct {
+	IMSI string
+	K    []byte // 16 bytes
+	OP   []byte // 16 bytes
+	OPc  []byte // 16 bytes
+	AMF  []byte // 2 bytes
+	SQN  uint64 // SQN of the matching TS 35.208 test set
+}
+
+// Subscribers holds one subscriber per TS 35.208 test set in [Milenage],
+// with IMSIs 001010000000001 to 001010000000006.
+var Subscribers = func() []Subscriber {
+	subs := make([]Subscriber, len(Milenage))
+	for i, v := range Milenage {
+		subs[i] = Subscriber{
+			IMSI: "00101000000000" + string(rune('1'+i)),
+			K:    v.K,
+			OP:   v.OP,
+			OPc:  v.OPc,
+			AMF:  v.AMF,
+			SQN:  milenage.SQNFromBytes(v.SQN),
+		}
+	}
+	return subs
+}()
+
+// Identity returns the permanent identity of s for eapType in [Realm].
+func (s *Subscriber) Identity(eapType uint8) string {
+	return eapaka.PermanentIdentity(eapType, s.IMSI, Realm)
+}
+
+// Milenage returns s as a [milenage.Subscriber] for a [milenage.Provider].
+// The provider's next vector uses SQN + 1.
+func (s *Subscriber) Milenage() *milenage.Subscriber {
+	return &milenage.Subscriber{
+		IMSI: s.IMSI,
+		K:    append([]byte(nil), s.K...),
+		OPc:  append([]byte(nil), s.OPc...),
+		AMF:  append([]byte(nil), s.AMF...),
+		SQN:  s.SQN,
+	}
+}
+
+// USIM returns a software USIM for s that accepts SQN, so that it answers
+// the AUTN of the matching test set.
+func (s *Subscriber) USIM() (*milenage.USIM, error) {
+	return milenage.NewUSIM(append([]byte(nil), s.K...), append([]byte(nil), s.OPc...), s.SQN-1)
+}
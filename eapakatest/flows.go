@@ -0,0 +1,130 @@
+package eapakatest
+
+import "github.com/oyaguma3/go-eapaka"
+
+// Flow is a complete conversation between a peer and a server: an
+// AKA-Identity round trip, full authentication with the vector of
+// [Flow.Subscriber], EAP-Success, and one fast re-authentication.
+type Flow struct {
+	Name       string
+	Type       uint8 // eapaka.TypeAKA or eapaka.TypeAKAPrime
+	Subscriber *Subscriber
+	Identity   string
+
+	// Authentication vector and, for EAP-AKA', the network name.
+	RAND        []byte
+	AUTN        []byte
+	RES         []byte
+	CK          []byte
+	IK          []byte
+	NetworkName string
+
+	// Keys of the full authentication. MK is only set for EAP-AKA,
+	// CKPrime, IKPrime and KRe only for EAP-AKA'.
+	CKPrime []byte
+	IKPrime []byte
+	MK      []byte
+	KEncr   []byte
+	KAut    []byte
+	KRe     []byte
+	MSK     []byte
+	EMSK    []byte
+
+	// Fast re-authentication. XKEY is only set for EAP-AKA.
+	ReauthIdentity string
+	Counter        uint16
+	NonceS         []byte
+	XKEY           []byte
+	ReauthMSK      []byte
+	ReauthEMSK     []byte
+
+	// Messages holds the EAP packets in the order they are sent.
+	Messages [][]byte
+}
+
+// Flows holds an EAP-AKA and an EAP-AKA' conversation built from
+// TS 35.208 Test Set 1 (the first entry of [Subscribers]).
+var Flows = []Flow{
+	{
+		Name:           "EAP-AKA",
+		Type:           eapaka.TypeAKA,
+		Subscriber:     &Subscribers[0],
+		Identity:       "0001010000000001@" + Realm,
+		RAND:           unhex("23553cbe9637a89d218ae64dae47bf35"),
+		AUTN:           unhex("55f328b43577b9b94a9ffac354dfafb3"),
+		RES:            unhex("a54211d5e3ba50bf"),
+		CK:             unhex("b40ba9a3c58b2a05bbf0d987b21bf8cb"),
+		IK:             unhex("f769bcd751044604127672711c6d3441"),
+		MK:             unhex("bc1081922cc7d06130c5ac3c9b09bf32c8a98d65"),
+		KEncr:          unhex("1351232efed72ea28448ee65a6fcc517"),
+		KAut:           unhex("cdac79fa94174ad8f6646ccbf880d9cc"),
+		MSK:            unhex("4b460c927fc983717a3654713481fc54e4bc4c48b7a869321661af6b5b2d94fbf0c4d7e51fcc4f90123e0b93fa072778ae33ed7f497a9617d9256b52f683aad7"),
+		EMSK:           unhex("d74d5e5ee6feba81dcdf65d5c37f9e38c93d0d48138965aa183ae018d2e0446c66c7ca36f2d790527a70be9abb965e1169ad8df09b51ac6fddb52bffc6a9fda6"),
+		ReauthIdentity: "4e2d4b0c6a1f3@" + Realm,
+		Counter:        1,
+		NonceS:         unhex("101112131415161718191a1b1c1d1e1f"),
+		XKEY:           unhex("8683bdbd15c1ead39e299ee52b05fa5adeab71a2"),
+		ReauthMSK:      unhex("e68e8e1853726d9d7fe0573fb5d71c4f908f511d9866097eb87a5e22cd51f34c672888bc4f8d47d43168a0396f553b816270000a44e8af33900851c03d5ea6f3"),
+		ReauthEMSK:     unhex("763129f4e93676979efe3324c40c7fd826faa43fd7e72298629b5e66f1a3151bfdf12865d9b6cd6ea17dd2d767b47781f68ee9d617704b916845f2332d541b01"),
+		Messages: [][]byte{
+			// EAP-Request/AKA-Identity (AT_PERMANENT_ID_REQ)
+			unhex("0101000c170500000a010000"),
+			// EAP-Response/AKA-Identity (AT_IDENTITY)
+			unhex("02010040170500000e0e00333030303130313030303030303030303140776c616e2e6d6e633030312e6d63633030312e336770706e6574776f726b2e6f726700"),
+			// EAP-Request/AKA-Challenge (AT_RAND, AT_AUTN, AT_MAC)
+			unhex("01020044170100000105000023553cbe9637a89d218ae64dae47bf350205000055f328b43577b9b94a9ffac354dfafb30b0500002109bd7cec06968619259420ba4671a2"),
+			// EAP-Response/AKA-Challenge (AT_RES, AT_MAC)
+			unhex("020200281701000003030040a54211d5e3ba50bf0b05000087900de21c2cdf1a551dde78a988f428"),
+			// EAP-Success
+			unhex("03020004"),
+			// EAP-Request/AKA-Reauthentication (AT_IV, AT_ENCR_DATA, AT_MAC)
+			unhex("01030054170d000081050000202122232425262728292a2b2c2d2e2f82090000f8cf5d0a376dd6cea97e5ed8f81da3cd172ba37c549dcc87878216fca8f9d0d50b0500003fd28502f9ffdee3a55634b41d389333"),
+			// EAP-Response/AKA-Reauthentication (AT_IV, AT_ENCR_DATA, AT_MAC)
+			unhex("02030044170d000081050000303132333435363738393a3b3c3d3e3f82050000b76be53a186d06f676adab50f2f93abb0b0500001472f3a7ebdfcdf6b95ef35228c6ccad"),
+			// EAP-Success
+			unhex("03030004"),
+		},
+	},
+	{
+		Name:           "EAP-AKA'",
+		Type:           eapaka.TypeAKAPrime,
+		Subscriber:     &Subscribers[0],
+		Identity:       "6001010000000001@" + Realm,
+		RAND:           unhex("23553cbe9637a89d218ae64dae47bf35"),
+		AUTN:           unhex("55f328b43577b9b94a9ffac354dfafb3"),
+		RES:            unhex("a54211d5e3ba50bf"),
+		CK:             unhex("b40ba9a3c58b2a05bbf0d987b21bf8cb"),
+		IK:             unhex("f769bcd751044604127672711c6d3441"),
+		NetworkName:    "WLAN",
+		CKPrime:        unhex("f3b667d53efe3370358f5d13b3241856"),
+		IKPrime:        unhex("1043a90c77fdac888b4be721dbff247f"),
+		KEncr:          unhex("84c4b5226fe5db0cc355f73c7510bf13"),
+		KAut:           unhex("73ecec5a5a07fee9130c39e5b0ad6f6da7bfd7b1a13eadb6bf67bc27974d68be"),
+		KRe:            unhex("a62f9d395bad3ff45fab551b2e9aae19a2b4af3b8eafef832050eb3d3941b024"),
+		MSK:            unhex("6936fb98e0a768f5463d73401fe033a22764aa0b36ce6378067ba37e8800f20cc7c68929488af36e707a0a70b44d180a4c68381bd89336ecbc7e9179a5c011fd"),
+		EMSK:           unhex("ae1c7d64e2b9b4f4584f5a247accff11ef1cc6d72fdf029b424b810192852c5adcc7256bd76767e018dfce22467d7fa72ec94477fef369ac3c64bb8da45f0a9e"),
+		ReauthIdentity: "8e2d4b0c6a1f3@" + Realm,
+		Counter:        1,
+		NonceS:         unhex("101112131415161718191a1b1c1d1e1f"),
+		ReauthMSK:      unhex("1f0d4ad9130b9391f5107e397bca33ab0d2db7f0db77de070aceb34428c4c7794df9e31bc565cb6e28fa29c51caa464c2b98a3bca1db3ea2139b9fca1953ad45"),
+		ReauthEMSK:     unhex("63b4f6f42f9f7ffc77af5024f311703562774cb3122d8519dc0b135e1cea75cbb8f8fe28c6bd6636ebbf16ee4e2c3b8c2bdb5cc5ee86fa163ac8663955b20f13"),
+		Messages: [][]byte{
+			// EAP-Request/AKA-Identity (AT_PERMANENT_ID_REQ)
+			unhex("0101000c320500000a010000"),
+			// EAP-Response/AKA-Identity (AT_IDENTITY)
+			unhex("02010040320500000e0e00333630303130313030303030303030303140776c616e2e6d6e633030312e6d63633030312e336770706e6574776f726b2e6f726700"),
+			// EAP-Request/AKA'-Challenge (AT_RAND, AT_AUTN, AT_KDF_INPUT, AT_KDF, AT_MAC)
+			unhex("01020050320100000105000023553cbe9637a89d218ae64dae47bf350205000055f328b43577b9b94a9ffac354dfafb317020004574c414e180100010b0500003116d7f290b057bd6754a5c7bb4d13cc"),
+			// EAP-Response/AKA'-Challenge (AT_RES, AT_MAC)
+			unhex("020200283201000003030040a54211d5e3ba50bf0b050000b7cd47e97b982c6fe473d1f4e13fe00b"),
+			// EAP-Success
+			unhex("03020004"),
+			// EAP-Request/AKA'-Reauthentication (AT_IV, AT_ENCR_DATA, AT_MAC)
+			unhex("01030054320d000081050000202122232425262728292a2b2c2d2e2f82090000e476ea4e4761ea1f1f7e98bf9387f86900d6726f206df98713bfe474e16a81cf0b0500006cc9ce7368e7c8e0095b046d0b10a74a"),
+			// EAP-Response/AKA'-Reauthentication (AT_IV, AT_ENCR_DATA, AT_MAC)
+			unhex("02030044320d000081050000303132333435363738393a3b3c3d3e3f82050000128fd1fc8bd6da460735df07d8ab88f50b05000064d2b1f37f42e5f4ac83a99a9439d53c"),
+			// EAP-Success
+			unhex("03030004"),
+		},
+	},
+}
@@ -0,0 +1,28 @@
+package eapakatest
+
+import (
+	"crypto/sha256"
+	"io"
+	"math/rand/v2"
+	"sync"
+)
+
+// NewRandom returns a deterministic source of random values seeded from
+// seed, for [eapaka.SetRandom] or the Random field of a component's
+// configuration. The same seed always gives the same stream, so
+// conversations run with it produce byte-exact output. It is safe for
+// concurrent use and must never be used outside tests.
+func NewRandom(seed string) io.Reader {
+	return &seededRandom{c: rand.NewChaCha8(sha256.Sum256([]byte(seed)))}
+}
+
+type seededRandom struct {
+	mu sync.Mutex
+	c  *rand.ChaCha8
+}
+
+func (r *seededRandom) Read(b []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.c.Read(b)
+}
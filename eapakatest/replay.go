@@ -0,0 +1,291 @@
+package eapakatest
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/oyaguma3/go-eapaka"
+	"github.com/oyaguma3/go-eapaka/milenage"
+)
+
+// Run runs [CheckMilenage], [CheckAKAPrimeKeys] and [Replay] for all vectors
+// as subtests of t.
+func Run(t *testing.T) {
+	t.Run("Milenage", func(t *testing.T) {
+		for i := range Milenage {
+			t.Run(Milenage[i].Name, func(t *testing.T) {
+				if err := CheckMilenage(&Milenage[i]); err != nil {
+					t.Error(err)
+				}
+			})
+		}
+	})
+	t.Run("AKAPrimeKeys", func(t *testing.T) {
+		for i := range AKAPrimeKeys {
+			t.Run(AKAPrimeKeys[i].Name, func(t *testing.T) {
+				if err := CheckAKAPrimeKeys(&AKAPrimeKeys[i]); err != nil {
+					t.Error(err)
+				}
+			})
+		}
+	})
+	t.Run("Flows", func(t *testing.T) {
+		for i := range Flows {
+			t.Run(Flows[i].Name, func(t *testing.T) {
+				if err := Replay(&Flows[i]); err != nil {
+					t.Error(err)
+				}
+			})
+		}
+	})
+}
+
+// CheckMilenage computes v with [milenage.OPc] (if v.OP is set) and
+// [milenage.Compute] and reports the first mismatch.
+func CheckMilenage(v *MilenageVector) error {
+	if v.OP != nil {
+		opc, err := milenage.OPc(v.K, v.OP)
+		if err != nil {
+			return fmt.Errorf("eapakatest: %s: %w", v.Name, err)
+		}
+		if err := compare("eapakatest: "+v.Name, field{"OPc", opc, v.OPc}); err != nil {
+			return err
+		}
+	}
+	out, err := milenage.Compute(v.K, v.OPc, v.RAND, v.SQN, v.AMF)
+	if err != nil {
+		return fmt.Errorf("eapakatest: %s: %w", v.Name, err)
+	}
+	return compare("eapakatest: "+v.Name,
+		field{"f1", out.MACA, v.MACA},
+		field{"f1*", out.MACS, v.MACS},
+		field{"f2", out.RES, v.RES},
+		field{"f3", out.CK, v.CK},
+		field{"f4", out.IK, v.IK},
+		field{"f5", out.AK, v.AK},
+		field{"f5*", out.AKS, v.AKS},
+	)
+}
+
+// CheckAKAPrimeKeys derives the keys of v with [eapaka.DeriveCKPrimeIKPrime]
+// and [eapaka.DeriveKeysAKAPrime] and reports the first mismatch.
+func CheckAKAPrimeKeys(v *AKAPrimeKeyVector) error {
+	ckPrime, ikPrime, err := eapaka.DeriveCKPrimeIKPrime(v.CK, v.IK, v.NetworkName, v.AUTN)
+	if err != nil {
+		return fmt.Errorf("eapakatest: %s: %w", v.Name, err)
+	}
+	keys := eapaka.DeriveKeysAKAPrime(v.Identity, ckPrime, ikPrime)
+	return compare("eapakatest: "+v.Name,
+		field{"CK'", ckPrime, v.CKPrime},
+		field{"IK'", ikPrime, v.IKPrime},
+		field{"K_encr", keys.K_encr, v.KEncr},
+		field{"K_aut", keys.K_aut, v.KAut},
+		field{"K_re", keys.K_re, v.KRe},
+		field{"MSK", keys.MSK, v.MSK},
+		field{"EMSK", keys.EMSK, v.EMSK},
+	)
+}
+
+// Replay replays f with [ReplayWithOptions] and [eapaka.StrictParseOptions].
+func Replay(f *Flow) error {
+	return ReplayWithOptions(f, eapaka.StrictParseOptions)
+}
+
+// ReplayWithOptions parses every message of f with [eapaka.ParseWithOptions]
+// and checks it as the receiving side would: the vector against the USIM of
+// f.Subscriber, the keys derived from it, the MACs with
+// [eapaka.Packet.VerifyMac] and the encrypted attributes of
+// re-authentication. It reports the first mismatch.
+func ReplayWithOptions(f *Flow, opts eapaka.ParseOptions) error {
+	r := &replay{f: f}
+	if err := r.checkVector(); err != nil {
+		return fmt.Errorf("eapakatest: %s: %w", f.Name, err)
+	}
+	for i, msg := range f.Messages {
+		p, err := eapaka.ParseWithOptions(msg, opts)
+		if err != nil {
+			return fmt.Errorf("eapakatest: %s: message %d: %w", f.Name, i, err)
+		}
+		if err := r.check(p); err != nil {
+			return fmt.Errorf("eapakatest: %s: message %d: %w", f.Name, i, err)
+		}
+	}
+	if r.kAut == nil || r.reauthMSK == nil {
+		return fmt.Errorf("eapakatest: %s: flow has no full and fast re-authentication", f.Name)
+	}
+	return nil
+}
+
+// replay holds the state of one [ReplayWithOptions] run.
+type replay struct {
+	f         *Flow
+	kEncr     []byte
+	kAut      []byte
+	reauthMSK []byte
+}
+
+func (r *replay) checkVector() error {
+	if r.f.Subscriber == nil {
+		return nil
+	}
+	usim, err := r.f.Subscriber.USIM()
+	if err != nil {
+		return err
+	}
+	res, ck, ik, _, err := usim.Authenticate(r.f.RAND, r.f.AUTN)
+	if err != nil {
+		return fmt.Errorf("USIM: %w", err)
+	}
+	return compare("vector", field{"RES", res, r.f.RES}, field{"CK", ck, r.f.CK}, field{"IK", ik, r.f.IK})
+}
+
+func (r *replay) check(p *eapaka.Packet) error {
+	if p.Code == eapaka.CodeSuccess || p.Code == eapaka.CodeFailure {
+		return nil
+	}
+	if p.Type != r.f.Type {
+		return fmt.Errorf("type %d, want %d", p.Type, r.f.Type)
+	}
+	switch p.Subtype {
+	case eapaka.SubtypeIdentity:
+		if p.Code != eapaka.CodeResponse {
+			return nil
+		}
+		id, ok := eapaka.Find[*eapaka.AtIdentity](p)
+		if !ok || id.Identity != r.f.Identity {
+			return fmt.Errorf("AT_IDENTITY missing or not %q", r.f.Identity)
+		}
+		return nil
+	case eapaka.SubtypeChallenge:
+		if p.Code == eapaka.CodeRequest {
+			if err := r.challengeRequest(p); err != nil {
+				return err
+			}
+		} else if res, ok := eapaka.Find[*eapaka.AtRes](p); !ok || !bytes.Equal(res.Res, r.f.RES) {
+			return errors.New("AT_RES missing or wrong")
+		}
+		return r.verifyMac(p, nil)
+	case eapaka.SubtypeReauthentication:
+		return r.reauthentication(p)
+	}
+	return fmt.Errorf("unexpected subtype %d", p.Subtype)
+}
+
+func (r *replay) challengeRequest(p *eapaka.Packet) error {
+	rand, ok := eapaka.Find[*eapaka.AtRand](p)
+	if !ok || !bytes.Equal(rand.Rand, r.f.RAND) {
+		return errors.New("AT_RAND missing or wrong")
+	}
+	autn, ok := eapaka.Find[*eapaka.AtAutn](p)
+	if !ok || !bytes.Equal(autn.Autn, r.f.AUTN) {
+		return errors.New("AT_AUTN missing or wrong")
+	}
+	if r.f.Type == eapaka.TypeAKA {
+		keys := eapaka.DeriveKeysAKA(r.f.Identity, r.f.CK, r.f.IK)
+		r.kEncr, r.kAut = keys.K_encr, keys.K_aut
+		return compare("keys",
+			field{"MK", keys.MK, r.f.MK},
+			field{"K_encr", keys.K_encr, r.f.KEncr},
+			field{"K_aut", keys.K_aut, r.f.KAut},
+			field{"MSK", keys.MSK, r.f.MSK},
+			field{"EMSK", keys.EMSK, r.f.EMSK},
+		)
+	}
+	netName, err := eapaka.CheckKDFInput(p, r.f.NetworkName)
+	if err != nil {
+		return err
+	}
+	ckPrime, ikPrime, err := eapaka.DeriveCKPrimeIKPrime(r.f.CK, r.f.IK, netName, autn.Autn)
+	if err != nil {
+		return err
+	}
+	keys := eapaka.DeriveKeysAKAPrime(r.f.Identity, ckPrime, ikPrime)
+	r.kEncr, r.kAut = keys.K_encr, keys.K_aut
+	return compare("keys",
+		field{"CK'", ckPrime, r.f.CKPrime},
+		field{"IK'", ikPrime, r.f.IKPrime},
+		field{"K_encr", keys.K_encr, r.f.KEncr},
+		field{"K_aut", keys.K_aut, r.f.KAut},
+		field{"K_re", keys.K_re, r.f.KRe},
+		field{"MSK", keys.MSK, r.f.MSK},
+		field{"EMSK", keys.EMSK, r.f.EMSK},
+	)
+}
+
+func (r *replay) reauthentication(p *eapaka.Packet) error {
+	if r.kAut == nil {
+		return errors.New("re-authentication before full authentication")
+	}
+	iv, ok := eapaka.Find[*eapaka.AtIv](p)
+	if !ok {
+		return errors.New("AT_IV missing")
+	}
+	encr, ok := eapaka.Find[*eapaka.AtEncrData](p)
+	if !ok {
+		return errors.New("AT_ENCR_DATA missing")
+	}
+	attrs, err := eapaka.DecryptAttributes(r.kEncr, iv.IV, encr)
+	if err != nil {
+		return err
+	}
+	inner := &eapaka.Packet{Attributes: attrs}
+	counter, ok := eapaka.Find[*eapaka.AtCounter](inner)
+	if !ok || counter.Counter != r.f.Counter {
+		return fmt.Errorf("AT_COUNTER missing or not %d", r.f.Counter)
+	}
+	if p.Code == eapaka.CodeResponse {
+		// The peer's MAC also covers NONCE_S (RFC 4187 Section 9.8).
+		return r.verifyMac(p, r.f.NonceS)
+	}
+
+	nonceS, ok := eapaka.Find[*eapaka.AtNonceS](inner)
+	if !ok || !bytes.Equal(nonceS.NonceS, r.f.NonceS) {
+		return errors.New("AT_NONCE_S missing or wrong")
+	}
+	var keys eapaka.ReauthKeys
+	if r.f.Type == eapaka.TypeAKA {
+		keys = eapaka.DeriveReauthKeysAKA(r.f.ReauthIdentity, r.f.Counter, r.f.NonceS, r.f.MK)
+		if err := compare("reauth keys", field{"XKEY'", keys.XKEY, r.f.XKEY}); err != nil {
+			return err
+		}
+	} else {
+		keys = eapaka.DeriveReauthKeysAKAPrime(r.f.ReauthIdentity, r.f.Counter, r.f.NonceS, r.f.KRe)
+	}
+	if err := compare("reauth keys", field{"MSK", keys.MSK, r.f.ReauthMSK}, field{"EMSK", keys.EMSK, r.f.ReauthEMSK}); err != nil {
+		return err
+	}
+	r.reauthMSK = keys.MSK
+	return r.verifyMac(p, nil)
+}
+
+func (r *replay) verifyMac(p *eapaka.Packet, extra []byte) error {
+	if r.kAut == nil {
+		return errors.New("MAC before full authentication")
+	}
+	ok, err := p.VerifyMacWithExtra(r.kAut, extra)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return errors.New("AT_MAC does not verify")
+	}
+	return nil
+}
+
+// field is a named value and its expected value.
+type field struct {
+	name      string
+	got, want []byte
+}
+
+// compare reports the first field whose value is not the expected one.
+func compare(prefix string, fields ...field) error {
+	for _, f := range fields {
+		if !bytes.Equal(f.got, f.want) {
+			return fmt.Errorf("%s: %s = %x, want %x", prefix, f.name, f.got, f.want)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,238 @@
+// Package eapakatest provides machine-readable test vectors, subscriber
+// fixtures and replay helpers for conformance testing of EAP-AKA (RFC 4187)
+// and EAP-AKA' (RFC 5448, RFC 9048) implementations built on package eapaka.
+//
+// The vectors are:
+//
+//   - [Milenage]: the MILENAGE test sets of 3GPP TS 35.208 Section 4.3.
+//   - [AKAPrimeKeys]: the EAP-AKA' key derivation cases of RFC 5448
+//     Appendix C, which RFC 9048 Appendix C carries over unchanged.
+//   - [Flows]: complete EAP-AKA and EAP-AKA' conversations, including fast
+//     re-authentication, with the keys of every step.
+//
+// RFC 4187 publishes no test vectors of its own. The flows are built from
+// TS 35.208 Test Set 1 and were computed independently of this module,
+// using the FIPS 186-2 pseudo-random function of RFC 4187 Appendix A for
+// EAP-AKA.
+//
+// [Run] replays everything through [eapaka.Parse], [eapaka.Packet.VerifyMac]
+// and the key derivation functions, so integrations can run the same suite:
+//
+//	func TestConformance(t *testing.T) {
+//		eapakatest.Run(t)
+//	}
+//
+// The vectors are shared package variables; callers must not modify them.
+package eapakatest
+
+import "encoding/hex"
+
+// MilenageVector is a MILENAGE test set (TS 35.208 Section 4.3).
+type MilenageVector struct {
+	Name string
+	K    []byte // 16 bytes
+	RAND []byte // 16 bytes
+	SQN  []byte // 6 bytes
+	AMF  []byte // 2 bytes
+	OP   []byte // 16 bytes, nil if omitted
+	OPc  []byte // 16 bytes
+
+	MACA []byte // f1: 8 bytes
+	MACS []byte // f1*: 8 bytes
+	RES  []byte // f2: 8 bytes
+	CK   []byte // f3: 16 bytes
+	IK   []byte // f4: 16 bytes
+	AK   []byte // f5: 6 bytes
+	AKS  []byte // f5*: 6 bytes
+}
+
+// Milenage holds TS 35.208 Test Sets 1 to 6.
+var Milenage = []MilenageVector{
+	{
+		Name: "TS 35.208 Test Set 1",
+		K:    unhex("465b5ce8b199b49faa5f0a2ee238a6bc"),
+		RAND: unhex("23553cbe9637a89d218ae64dae47bf35"),
+		SQN:  unhex("ff9bb4d0b607"),
+		AMF:  unhex("b9b9"),
+		OP:   unhex("cdc202d5123e20f62b6d676ac72cb318"),
+		OPc:  unhex("cd63cb71954a9f4e48a5994e37a02baf"),
+		MACA: unhex("4a9ffac354dfafb3"),
+		MACS: unhex("01cfaf9ec4e871e9"),
+		RES:  unhex("a54211d5e3ba50bf"),
+		CK:   unhex("b40ba9a3c58b2a05bbf0d987b21bf8cb"),
+		IK:   unhex("f769bcd751044604127672711c6d3441"),
+		AK:   unhex("aa689c648370"),
+		AKS:  unhex("451e8beca43b"),
+	},
+	{
+		Name: "TS 35.208 Test Set 2",
+		K:    unhex("0396eb317b6d1c36f19c1c84cd6ffd16"),
+		RAND: unhex("c00d603103dcee52c4478119494202e8"),
+		SQN:  unhex("fd8eef40df7d"),
+		AMF:  unhex("af17"),
+		// OP is omitted; the set is checked from OPc.
+		OPc:  unhex("53c15671c60a4b731c55b4a441c0bde2"),
+		MACA: unhex("5df5b31807e258b0"),
+		MACS: unhex("a8c016e51ef4a343"),
+		RES:  unhex("d3a628ed988620f0"),
+		CK:   unhex("58c433ff7a7082acd424220f2b67c556"),
+		IK:   unhex("21a8c1f929702adb3e738488b9f5c5da"),
+		AK:   unhex("c47783995f72"),
+		AKS:  unhex("30f1197061c1"),
+	},
+	{
+		Name: "TS 35.208 Test Set 3",
+		K:    unhex("fec86ba6eb707ed08905757b1bb44b8f"),
+		RAND: unhex("9f7c8d021accf4db213ccff0c7f71a6a"),
+		SQN:  unhex("9d0277595ffc"),
+		AMF:  unhex("725c"),
+		OP:   unhex("dbc59adcb6f9a0ef735477b7fadf8374"),
+		OPc:  unhex("1006020f0a478bf6b699f15c062e42b3"),
+		MACA: unhex("9cabc3e99baf7281"),
+		MACS: unhex("95814ba2b3044324"),
+		RES:  unhex("8011c48c0c214ed2"),
+		CK:   unhex("5dbdbb2954e8f3cde665b046179a5098"),
+		IK:   unhex("59a92d3b476a0443487055cf88b2307b"),
+		AK:   unhex("33484dc2136b"),
+		AKS:  unhex("deacdd848cc6"),
+	},
+	{
+		Name: "TS 35.208 Test Set 4",
+		K:    unhex("9e5944aea94b81165c82fbf9f32db751"),
+		RAND: unhex("ce83dbc54ac0274a157c17f80d017bd6"),
+		SQN:  unhex("0b604a81eca8"),
+		AMF:  unhex("9e09"),
+		OP:   unhex("223014c5806694c007ca1eeef57f004f"),
+		OPc:  unhex("a64a507ae1a2a98bb88eb4210135dc87"),
+		MACA: unhex("74a58220cba84c49"),
+		MACS: unhex("ac2cc74a96871837"),
+		RES:  unhex("f365cd683cd92e96"),
+		CK:   unhex("e203edb3971574f5a94b0d61b816345d"),
+		IK:   unhex("0c4524adeac041c4dd830d20854fc46b"),
+		AK:   unhex("f0b9c08ad02e"),
+		AKS:  unhex("6085a86c6f63"),
+	},
+	{
+		Name: "TS 35.208 Test Set 5",
+		K:    unhex("4ab1deb05ca6ceb051fc98e77d026a84"),
+		RAND: unhex("74b0cd6031a1c8339b2b6ce2b8c4a186"),
+		SQN:  unhex("e880a1b580b6"),
+		AMF:  unhex("9f07"),
+		OP:   unhex("2d16c5cd1fdf6b22383584e3bef2a8d8"),
+		OPc:  unhex("dcf07cbd51855290b92a07a9891e523e"),
+		MACA: unhex("49e785dd12626ef2"),
+		MACS: unhex("9e85790336bb3fa2"),
+		RES:  unhex("5860fc1bce351e7e"),
+		CK:   unhex("7657766b373d1c2138f307e3de9242f9"),
+		IK:   unhex("1c42e960d89b8fa99f2744e0708ccb53"),
+		AK:   unhex("31e11a609118"),
+		AKS:  unhex("fe2555e54aa9"),
+	},
+	{
+		Name: "TS 35.208 Test Set 6",
+		K:    unhex("6c38a116ac280c454f59332ee35c8c4f"),
+		RAND: unhex("ee6466bc96202c5a557abbeff8babf63"),
+		SQN:  unhex("414b98222181"),
+		AMF:  unhex("4464"),
+		OP:   unhex("1ba00a1a7c6700ac8c3ff3e96ad08725"),
+		OPc:  unhex("3803ef5363b947c6aaa225e58fae3934"),
+		MACA: unhex("078adfb488241a57"),
+		MACS: unhex("80246b8d0186bcf1"),
+		RES:  unhex("16c8233f05a0ac28"),
+		CK:   unhex("3f8c7587fe8e4b233af676aede30ba3b"),
+		IK:   unhex("a7466cc1e6b2a1337d49d3b66e95d7b4"),
+		AK:   unhex("45b0f69ab06c"),
+		AKS:  unhex("1f53cd2b1113"),
+	},
+}
+
+// AKAPrimeKeyVector is an EAP-AKA' key derivation case: CK' and IK' from CK,
+// IK, the network name and AUTN, and the keys derived from them.
+type AKAPrimeKeyVector struct {
+	Name        string
+	Identity    string
+	NetworkName string
+	AUTN        []byte // 16 bytes; only SQN⊕AK is used
+	CK          []byte // 16 bytes
+	IK          []byte // 16 bytes
+
+	CKPrime []byte // 16 bytes
+	IKPrime []byte // 16 bytes
+	KEncr   []byte // 16 bytes
+	KAut    []byte // 32 bytes
+	KRe     []byte // 32 bytes
+	MSK     []byte // 64 bytes
+	EMSK    []byte // 64 bytes
+}
+
+// AKAPrimeKeys holds RFC 5448 Appendix C Cases 1 to 4.
+var AKAPrimeKeys = []AKAPrimeKeyVector{
+	{
+		Name:        "RFC 5448 Case 1",
+		Identity:    "0555444333222111",
+		NetworkName: "WLAN",
+		AUTN:        unhex("bb52e91c747ac3ab2a5c23d15ee351d5"),
+		CK:          unhex("5349fbe098649f948f5d2e973a81c00f"),
+		IK:          unhex("9744871ad32bf9bbd1dd5ce54e3e2e5a"),
+		CKPrime:     unhex("0093962d0dd84aa5684b045c9edffa04"),
+		IKPrime:     unhex("ccfc230ca74fcc96c0a5d61164f5a76c"),
+		KEncr:       unhex("766fa0a6c317174b812d52fbcd11a179"),
+		KAut:        unhex("0842ea722ff6835bfa2032499fc3ec23c2f0e388b4f07543ffc677f1696d71ea"),
+		KRe:         unhex("cf83aa8bc7e0aced892acc98e76a9b2095b558c7795c7094715cb3393aa7d17a"),
+		MSK:         unhex("67c42d9aa56c1b79e295e3459fc3d187d42be0bf818d3070e362c5e967a4d544e8ecfe19358ab3039aff03b7c930588c055babee58a02650b067ec4e9347c75a"),
+		EMSK:        unhex("f861703cd775590e16c7679ea3874ada866311de290764d760cf76df647ea01c313f69924bdd7650ca9bac141ea075c4ef9e8029c0e290cdbad5638b63bc23fb"),
+	},
+	{
+		Name:        "RFC 5448 Case 2",
+		Identity:    "0555444333222111",
+		NetworkName: "HRPD",
+		AUTN:        unhex("bb52e91c747ac3ab2a5c23d15ee351d5"),
+		CK:          unhex("5349fbe098649f948f5d2e973a81c00f"),
+		IK:          unhex("9744871ad32bf9bbd1dd5ce54e3e2e5a"),
+		CKPrime:     unhex("3820f0277fa5f77732b1fb1d90c1a0da"),
+		IKPrime:     unhex("db94a0ab557ef6c9ab48619ca05b9a9f"),
+		KEncr:       unhex("05ad73ac915fce89ac77e1520d82187b"),
+		KAut:        unhex("5b4acaef62c6ebb8882b2f3d534c4b35277337a00184f20ff25d224c04be2afd"),
+		KRe:         unhex("3f90bf5c6e5ef325ff04eb5ef6539fa8cca8398194fbd00be425b3f40dba10ac"),
+		MSK:         unhex("87b321570117cd6c95ab6c436fb5073ff15cf85505d2bc5bb7355fc21ea8a75757e8f86a2b138002e05752913bb43b82f868a96117e91a2d95f526677d572900"),
+		EMSK:        unhex("c891d5f20f148a1007553e2dea555c9cb672e9675f4a66b4bafa027379f93aee539a5979d0a0042b9d2ae28bed3b17a31dc8ab75072b80bd0c1da612466e402c"),
+	},
+	{
+		Name:        "RFC 5448 Case 3",
+		Identity:    "0555444333222111",
+		NetworkName: "WLAN",
+		AUTN:        unhex("a0a0a0a0a0a0a0a0a0a0a0a0a0a0a0a0"),
+		CK:          unhex("c0c0c0c0c0c0c0c0c0c0c0c0c0c0c0c0"),
+		IK:          unhex("b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0"),
+		CKPrime:     unhex("cd4c8e5c68f57dd1d7d7dfd0c538e577"),
+		IKPrime:     unhex("3ece6b705dbbf7dfc459a11280c65524"),
+		KEncr:       unhex("897d302fa2847416488c28e20dcb7be4"),
+		KAut:        unhex("c40700e7722483ae3dc7139eb0b88bb558cb3081eccd057f9207d1286ee7dd53"),
+		KRe:         unhex("0a591a22dd8b5b1cf29e3d508c91dbbdb4aee23051892c42b6a2de66ea504473"),
+		MSK:         unhex("9f7dca9e37bb22029ed986e7cd09d4a70d1ac76d95535c5cac40a7504699bb8961a29ef6f3e90f183de5861ad1bedc81ce9916391b401aa006c98785a5756df7"),
+		EMSK:        unhex("724de00bdb9e568187be3fe746114557d5018779537ee37f4d3c6c738cb97b9dc651bc19bfadc344ffe2b52ca78bd8316b51dacc5f2b1440cb9515521cc7ba23"),
+	},
+	{
+		Name:        "RFC 5448 Case 4",
+		Identity:    "0555444333222111",
+		NetworkName: "HRPD",
+		AUTN:        unhex("a0a0a0a0a0a0a0a0a0a0a0a0a0a0a0a0"),
+		CK:          unhex("c0c0c0c0c0c0c0c0c0c0c0c0c0c0c0c0"),
+		IK:          unhex("b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0"),
+		CKPrime:     unhex("8310a71ce6f754889613da8f64d5fb46"),
+		IKPrime:     unhex("5adf14360ae838192db23f6fcb7f8c76"),
+		KEncr:       unhex("745e7439ba238f50fcac4d15d47cd1d9"),
+		KAut:        unhex("3e1d2aa4e677025cfd862a4be18361a13a645765571463df833a9759e8099879"),
+		KRe:         unhex("99da835e2ae82462576fe6516fad1f802f0fa1191655dd0a273da96d04e0fcd3"),
+		MSK:         unhex("c6d3a6e0ceea951eb20d74f32c3061d0680a04b0b086ee8700ace3e0b95fa02683c287beee44432294ff98af26d2cc783bace75c4b0af7fdfeb5511ba8e4cbd0"),
+		EMSK:        unhex("7fb56813838adafa99d140c2f198f6dacebfb6afee444961105402b508c7f363352cb2919644b50463e6a69354150147ae09cbc54b8a651d8787a6893ed8536d"),
+	},
+}
+
+func unhex(s string) []byte {
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		panic("eapakatest: " + err.Error())
+	}
+	return b
+}
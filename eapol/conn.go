@@ -0,0 +1,201 @@
+package eapol
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/oyaguma3/go-eapaka"
+	"github.com/oyaguma3/go-eapaka/peer"
+	"github.com/oyaguma3/go-eapaka/server"
+)
+
+// ErrLogoff is returned by [RunAuthenticator] when the supplicant sends EAPOL-Logoff.
+var ErrLogoff = errors.New("eapol: supplicant logged off")
+
+// maxFrameLen is the largest EAPOL frame: the header and a 16-bit body length.
+const maxFrameLen = HeaderLen + 0xffff
+
+// Conn sends and receives EAPOL frames over an [io.ReadWriter]. Each frame is
+// written with a single Write call.
+//
+// A Conn is not safe for concurrent use.
+type Conn struct {
+	// Version is the protocol version of the frames written. Default: [Version2004].
+	Version uint8
+
+	rw  io.ReadWriter
+	r   *bufio.Reader // nil for a datagram transport
+	buf []byte        // datagram buffer
+}
+
+// NewConn returns a Conn that reads and writes frames on rw, a stream such as
+// a pipe or a SOCK_STREAM socket.
+func NewConn(rw io.ReadWriter) *Conn {
+	return &Conn{Version: Version2004, rw: rw, r: bufio.NewReaderSize(rw, maxFrameLen)}
+}
+
+// NewDatagramConn returns a Conn that reads and writes frames on rw, a
+// datagram transport carrying one frame per datagram, such as a SOCK_DGRAM
+// or SOCK_SEQPACKET socket. Bytes after the EAPOL body, e.g. the padding of
+// a minimum-size Ethernet frame, are discarded.
+func NewDatagramConn(rw io.ReadWriter) *Conn {
+	return &Conn{Version: Version2004, rw: rw, buf: make([]byte, maxFrameLen)}
+}
+
+// ReadFrame reads the next frame.
+func (c *Conn) ReadFrame() (*Frame, error) {
+	if c.r == nil {
+		n, err := c.rw.Read(c.buf)
+		if err != nil {
+			return nil, err
+		}
+		return Parse(c.buf[:n])
+	}
+	var hdr [HeaderLen]byte
+	if _, err := io.ReadFull(c.r, hdr[:]); err != nil {
+		return nil, err
+	}
+	b := make([]byte, HeaderLen+int(binary.BigEndian.Uint16(hdr[2:4])))
+	copy(b, hdr[:])
+	if _, err := io.ReadFull(c.r, b[HeaderLen:]); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	return Parse(b)
+}
+
+// WriteFrame writes f.
+func (c *Conn) WriteFrame(f *Frame) error {
+	b, err := f.Marshal()
+	if err != nil {
+		return err
+	}
+	_, err = c.rw.Write(b)
+	return err
+}
+
+// WriteEAP writes an EAP-Packet frame carrying eap.
+func (c *Conn) WriteEAP(eap []byte) error {
+	return c.WriteFrame(NewEAPFrame(c.Version, eap))
+}
+
+// WriteStart writes EAPOL-Start.
+func (c *Conn) WriteStart() error {
+	return c.WriteFrame(&Frame{Version: c.Version, Type: TypeStart})
+}
+
+// WriteLogoff writes EAPOL-Logoff.
+func (c *Conn) WriteLogoff() error {
+	return c.WriteFrame(&Frame{Version: c.Version, Type: TypeLogoff})
+}
+
+// identityRequestID is the EAP Identifier of the EAP-Request/Identity sent by
+// [RunAuthenticator].
+const identityRequestID = 1
+
+// RunAuthenticator acts as the authenticator side of the port: it sends
+// EAP-Request/Identity, passes the supplicant's EAP-Responses to a and
+// writes the replies until the conversation ends, and returns the final
+// [server.Result] together with the error reported by a, if any.
+//
+// EAPOL-Start restarts the conversation, EAPOL-Logoff ends it with
+// [ErrLogoff], and EAPOL-Key frames and the responses a discards are
+// ignored. ctx is checked between frames; to interrupt a blocked read, close
+// the underlying transport.
+func RunAuthenticator(ctx context.Context, c *Conn, a *server.Authenticator) (*server.Result, error) {
+	identityReq, err := eapaka.NewEAPIdentity(eapaka.CodeRequest, identityRequestID, "").Marshal()
+	if err != nil {
+		return nil, err
+	}
+	if err := c.WriteEAP(identityReq); err != nil {
+		return nil, err
+	}
+	sessionID := ""
+	for {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		f, err := c.ReadFrame()
+		if err != nil {
+			return nil, err
+		}
+		switch f.Type {
+		case TypeStart:
+			sessionID = ""
+			if err := c.WriteEAP(identityReq); err != nil {
+				return nil, err
+			}
+			continue
+		case TypeLogoff:
+			return nil, ErrLogoff
+		case TypeEAPPacket:
+		default:
+			continue
+		}
+
+		res, authErr := a.Handle(ctx, sessionID, f.Body)
+		if res.Status == server.StatusDiscard {
+			continue
+		}
+		reply, err := res.Reply.Marshal()
+		if err != nil {
+			return nil, err
+		}
+		if err := c.WriteEAP(reply); err != nil {
+			return nil, err
+		}
+		if res.Status != server.StatusContinue {
+			return res, authErr
+		}
+		sessionID = res.SessionID
+	}
+}
+
+// RunSupplicant acts as the supplicant side of the port: it answers the
+// authenticator's EAP-Requests with p until EAP-Success or EAP-Failure is
+// received. It returns nil if p authenticated successfully; the keys are then
+// available from [peer.Peer.MSK]. To solicit EAP-Request/Identity from an
+// authenticator that waits for the supplicant, call [Conn.WriteStart] first.
+//
+// If p rejected the network (e.g., with Authentication-Reject), the returned
+// error reports that cause. Frames other than EAP-Packet are ignored.
+func RunSupplicant(c *Conn, p *peer.Peer) error {
+	var cause error
+	for {
+		f, err := c.ReadFrame()
+		if err != nil {
+			return err
+		}
+		if f.Type != TypeEAPPacket {
+			continue
+		}
+		resp, err := p.Handle(f.Body)
+		if resp != nil {
+			if werr := c.WriteEAP(resp); werr != nil {
+				return werr
+			}
+			if err != nil {
+				cause = err
+			}
+			continue
+		}
+		switch p.Status() {
+		case peer.StatusSuccess:
+			return nil
+		case peer.StatusFailure:
+			if cause != nil {
+				return fmt.Errorf("%w: %w", err, cause)
+			}
+			return err
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
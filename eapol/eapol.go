@@ -0,0 +1,178 @@
+// Package eapol implements EAPOL (EAP over LANs, IEEE 802.1X) framing for
+// protocol versions 1 to 3, and a [Conn] that carries EAP packets between a
+// supplicant and an authenticator over any [io.ReadWriter], such as a pipe or
+// a Unix socket pair.
+package eapol
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// EtherType is the Ethernet type of EAPOL frames (IEEE 802.1X-2020 Section 11.1.4).
+const EtherType = 0x888e
+
+// Protocol versions (IEEE 802.1X-2020 Section 11.3.1)
+const (
+	Version2001 uint8 = 1 // IEEE 802.1X-2001
+	Version2004 uint8 = 2 // IEEE 802.1X-2004
+	Version2010 uint8 = 3 // IEEE 802.1X-2010 and later
+)
+
+// Packet types (IEEE 802.1X-2020 Section 11.3.2)
+const (
+	TypeEAPPacket uint8 = 0
+	TypeStart     uint8 = 1
+	TypeLogoff    uint8 = 2
+	TypeKey       uint8 = 3
+)
+
+// Key descriptor types (IEEE 802.1X-2020 Section 11.9, IEEE 802.11-2020 Section 12.7.2)
+const (
+	KeyDescriptorRC4 uint8 = 1
+	KeyDescriptorRSN uint8 = 2
+	KeyDescriptorWPA uint8 = 254
+)
+
+// Key Information bits of an RSN or WPA EAPOL-Key frame (IEEE 802.11-2020 Figure 12-33).
+const (
+	KeyInfoVersionMask uint16 = 0x0007
+	KeyInfoPairwise    uint16 = 0x0008
+	KeyInfoInstall     uint16 = 0x0040
+	KeyInfoAck         uint16 = 0x0080
+	KeyInfoMIC         uint16 = 0x0100
+	KeyInfoSecure      uint16 = 0x0200
+	KeyInfoError       uint16 = 0x0400
+	KeyInfoRequest     uint16 = 0x0800
+	KeyInfoEncrypted   uint16 = 0x1000
+)
+
+const (
+	// HeaderLen is the length of the EAPOL header.
+	HeaderLen = 4
+
+	keyFixedLen = 77 // RSN/WPA EAPOL-Key fields before the MIC
+)
+
+// Frame is an EAPOL PDU. Ethernet addressing is not part of the frame.
+type Frame struct {
+	Version uint8
+	Type    uint8
+
+	// Body is the packet body: the EAP packet for [TypeEAPPacket], the key
+	// descriptor for [TypeKey], and empty for [TypeStart] and [TypeLogoff].
+	Body []byte
+}
+
+// NewEAPFrame returns an EAP-Packet frame carrying eap.
+func NewEAPFrame(version uint8, eap []byte) *Frame {
+	return &Frame{Version: version, Type: TypeEAPPacket, Body: eap}
+}
+
+// Parse parses an EAPOL frame. Bytes after the body, such as Ethernet
+// padding, are ignored.
+func Parse(data []byte) (*Frame, error) {
+	if len(data) < HeaderLen {
+		return nil, errors.New("eapol: frame too short")
+	}
+	if data[0] == 0 {
+		return nil, errors.New("eapol: invalid protocol version 0")
+	}
+	n := int(binary.BigEndian.Uint16(data[2:4]))
+	if HeaderLen+n > len(data) {
+		return nil, fmt.Errorf("eapol: body length %d exceeds frame", n)
+	}
+	return &Frame{
+		Version: data[0],
+		Type:    data[1],
+		Body:    append([]byte(nil), data[HeaderLen:HeaderLen+n]...),
+	}, nil
+}
+
+// Marshal serializes the frame.
+func (f *Frame) Marshal() ([]byte, error) {
+	return f.AppendMarshal(make([]byte, 0, HeaderLen+len(f.Body)))
+}
+
+// AppendMarshal appends the serialized frame to b.
+func (f *Frame) AppendMarshal(b []byte) ([]byte, error) {
+	if f.Version == 0 {
+		return nil, errors.New("eapol: invalid protocol version 0")
+	}
+	if len(f.Body) > 0xffff {
+		return nil, errors.New("eapol: body too long")
+	}
+	b = append(b, f.Version, f.Type)
+	b = binary.BigEndian.AppendUint16(b, uint16(len(f.Body)))
+	return append(b, f.Body...), nil
+}
+
+// Key is the body of an RSN or WPA EAPOL-Key frame (IEEE 802.11-2020
+// Section 12.7.2). The Key Data field is kept opaque.
+type Key struct {
+	DescriptorType uint8
+	Info           uint16 // Key Information, see the KeyInfo constants
+	Length         uint16 // Key Length
+	ReplayCounter  uint64
+	Nonce          [32]byte
+	IV             [16]byte
+	RSC            [8]byte
+	Reserved       [8]byte
+
+	// MIC is the Key MIC. Its length depends on the AKM suite (16 bytes for
+	// most, 24 for Suite B 192-bit) and is given to [ParseKey].
+	MIC  []byte
+	Data []byte
+}
+
+// ParseKey parses the body of an EAPOL-Key frame with an RSN or WPA key
+// descriptor and a MIC of micLen bytes.
+func ParseKey(body []byte, micLen int) (*Key, error) {
+	if len(body) < 1 {
+		return nil, errors.New("eapol: empty key descriptor")
+	}
+	if body[0] != KeyDescriptorRSN && body[0] != KeyDescriptorWPA {
+		return nil, fmt.Errorf("eapol: unsupported key descriptor type %d", body[0])
+	}
+	if micLen < 0 || len(body) < keyFixedLen+micLen+2 {
+		return nil, errors.New("eapol: key descriptor too short")
+	}
+	k := &Key{
+		DescriptorType: body[0],
+		Info:           binary.BigEndian.Uint16(body[1:3]),
+		Length:         binary.BigEndian.Uint16(body[3:5]),
+		ReplayCounter:  binary.BigEndian.Uint64(body[5:13]),
+	}
+	copy(k.Nonce[:], body[13:45])
+	copy(k.IV[:], body[45:61])
+	copy(k.RSC[:], body[61:69])
+	copy(k.Reserved[:], body[69:77])
+	k.MIC = append([]byte(nil), body[77:77+micLen]...)
+	rest := body[77+micLen:]
+	n := int(binary.BigEndian.Uint16(rest[0:2]))
+	if 2+n > len(rest) {
+		return nil, fmt.Errorf("eapol: key data length %d exceeds descriptor", n)
+	}
+	k.Data = append([]byte(nil), rest[2:2+n]...)
+	return k, nil
+}
+
+// Marshal serializes the key descriptor, for use as the body of a [TypeKey] frame.
+func (k *Key) Marshal() ([]byte, error) {
+	if len(k.Data) > 0xffff {
+		return nil, errors.New("eapol: key data too long")
+	}
+	b := make([]byte, 0, keyFixedLen+len(k.MIC)+2+len(k.Data))
+	b = append(b, k.DescriptorType)
+	b = binary.BigEndian.AppendUint16(b, k.Info)
+	b = binary.BigEndian.AppendUint16(b, k.Length)
+	b = binary.BigEndian.AppendUint64(b, k.ReplayCounter)
+	b = append(b, k.Nonce[:]...)
+	b = append(b, k.IV[:]...)
+	b = append(b, k.RSC[:]...)
+	b = append(b, k.Reserved[:]...)
+	b = append(b, k.MIC...)
+	b = binary.BigEndian.AppendUint16(b, uint16(len(k.Data)))
+	return append(b, k.Data...), nil
+}
@@ -0,0 +1,245 @@
+package eapol_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/oyaguma3/go-eapaka"
+	"github.com/oyaguma3/go-eapaka/eapol"
+	"github.com/oyaguma3/go-eapaka/milenage"
+	"github.com/oyaguma3/go-eapaka/peer"
+	"github.com/oyaguma3/go-eapaka/server"
+)
+
+var (
+	testIMSI = "001010000000001"
+	testK    = bytes.Repeat([]byte{0x46}, 16)
+	testOPc  = bytes.Repeat([]byte{0xcd}, 16)
+)
+
+func TestFrame(t *testing.T) {
+	eap := []byte{eapaka.CodeRequest, 1, 0, 5, 1}
+	tests := []struct {
+		name string
+		f    *eapol.Frame
+		want []byte
+	}{
+		{"EAP-Packet", eapol.NewEAPFrame(eapol.Version2010, eap), []byte{3, 0, 0, 5, 1, 1, 0, 5, 1}},
+		{"Start", &eapol.Frame{Version: eapol.Version2001, Type: eapol.TypeStart}, []byte{1, 1, 0, 0}},
+		{"Logoff", &eapol.Frame{Version: eapol.Version2004, Type: eapol.TypeLogoff}, []byte{2, 2, 0, 0}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, err := tt.f.Marshal()
+			if err != nil || !bytes.Equal(b, tt.want) {
+				t.Fatalf("Marshal = %x, %v; want %x", b, err, tt.want)
+			}
+			// Ethernet padding after the body is ignored.
+			f, err := eapol.Parse(append(b, 0, 0, 0))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(tt.f, f, cmp.Comparer(func(a, b []byte) bool { return bytes.Equal(a, b) })); diff != "" {
+				t.Errorf("Parse mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+
+	for _, data := range [][]byte{{2, 0, 0}, {0, 0, 0, 0}, {2, 0, 0, 5, 1}} {
+		if _, err := eapol.Parse(data); err == nil {
+			t.Errorf("Parse(%x) succeeded", data)
+		}
+	}
+}
+
+func TestKey(t *testing.T) {
+	k := &eapol.Key{
+		DescriptorType: eapol.KeyDescriptorRSN,
+		Info:           2 | eapol.KeyInfoPairwise | eapol.KeyInfoAck,
+		Length:         16,
+		ReplayCounter:  1,
+		MIC:            make([]byte, 16),
+		Data:           []byte{0xdd, 0x14, 0x00, 0x0f, 0xac, 0x04},
+	}
+	k.Nonce[0] = 0xaa
+	body, err := k.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(body) != 95+len(k.Data) {
+		t.Fatalf("key descriptor length = %d", len(body))
+	}
+	got, err := eapol.ParseKey(body, 16)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(k, got); diff != "" {
+		t.Errorf("ParseKey mismatch (-want +got):\n%s", diff)
+	}
+	if _, err := eapol.ParseKey(body[:len(body)-1], 16); err == nil {
+		t.Error("ParseKey accepted truncated key data")
+	}
+	if _, err := eapol.ParseKey([]byte{eapol.KeyDescriptorRC4}, 16); err == nil {
+		t.Error("ParseKey accepted an RC4 descriptor")
+	}
+}
+
+func newPair(t *testing.T) (*server.Authenticator, *peer.Peer) {
+	t.Helper()
+	provider := milenage.NewProvider(&milenage.Subscriber{IMSI: testIMSI, K: testK, OPc: testOPc, SQN: 10})
+	a, err := server.New(server.Config{Type: eapaka.TypeAKAPrime, Vectors: provider, EnableReauth: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	usim, err := milenage.NewUSIM(testK, testOPc, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := peer.New(peer.Config{Identity: eapaka.PermanentIdentity(eapaka.TypeAKAPrime, testIMSI, "example.org"), SIM: usim})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return a, p
+}
+
+// authenticate runs one conversation between a and p over the connected pair.
+func authenticate(t *testing.T, a *server.Authenticator, p *peer.Peer, authSide, suppSide net.Conn) *server.Result {
+	t.Helper()
+	errc := make(chan error, 1)
+	go func() { errc <- eapol.RunSupplicant(eapol.NewConn(suppSide), p) }()
+	res, err := eapol.RunAuthenticator(context.Background(), eapol.NewConn(authSide), a)
+	if err != nil {
+		t.Fatalf("RunAuthenticator: %v", err)
+	}
+	if err := <-errc; err != nil {
+		t.Fatalf("RunSupplicant: %v", err)
+	}
+	if res.Status != server.StatusSuccess || !bytes.Equal(res.MSK, p.MSK()) {
+		t.Fatalf("status %v, MSK match %v", res.Status, bytes.Equal(res.MSK, p.MSK()))
+	}
+	return res
+}
+
+func TestRun(t *testing.T) {
+	a, p := newPair(t)
+
+	// Full authentication over a pipe.
+	authSide, suppSide := net.Pipe()
+	defer authSide.Close()
+	defer suppSide.Close()
+	if res := authenticate(t, a, p, authSide, suppSide); res.Reauth {
+		t.Error("first conversation was a re-authentication")
+	}
+
+	// Fast re-authentication over a Unix socket.
+	ln, err := net.Listen("unix", filepath.Join(t.TempDir(), "eapol.sock"))
+	if err != nil {
+		t.Skipf("unix sockets unavailable: %v", err)
+	}
+	defer ln.Close()
+	accepted := make(chan net.Conn, 1)
+	go func() {
+		conn, _ := ln.Accept()
+		accepted <- conn
+	}()
+	suppSide, err = net.Dial("unix", ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer suppSide.Close()
+	authSide = <-accepted
+	if authSide == nil {
+		t.Fatal("accept failed")
+	}
+	defer authSide.Close()
+	if res := authenticate(t, a, p, authSide, suppSide); !res.Reauth || !p.Reauthenticated() {
+		t.Error("second conversation was not a re-authentication")
+	}
+}
+
+func TestRunAuthenticator_StartAndLogoff(t *testing.T) {
+	a, _ := newPair(t)
+	authSide, suppSide := net.Pipe()
+	defer authSide.Close()
+	defer suppSide.Close()
+
+	errc := make(chan error, 1)
+	go func() {
+		_, err := eapol.RunAuthenticator(context.Background(), eapol.NewConn(authSide), a)
+		errc <- err
+	}()
+
+	supp := eapol.NewConn(suppSide)
+	supp.Version = eapol.Version2010
+	for range 2 {
+		// The identity request is repeated after EAPOL-Start.
+		f, err := supp.ReadFrame()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if f.Type != eapol.TypeEAPPacket || f.Version != eapol.Version2004 || !bytes.Equal(f.Body, []byte{eapaka.CodeRequest, 1, 0, 5, 1}) {
+			t.Fatalf("frame = %+v, want EAP-Request/Identity", f)
+		}
+		if err := supp.WriteStart(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := supp.ReadFrame(); err != nil {
+		t.Fatal(err)
+	}
+	if err := supp.WriteLogoff(); err != nil {
+		t.Fatal(err)
+	}
+	if err := <-errc; !errors.Is(err, eapol.ErrLogoff) {
+		t.Errorf("RunAuthenticator = %v, want ErrLogoff", err)
+	}
+}
+
+// TestDatagramConn reads frames padded to the minimum Ethernet payload, one
+// per datagram.
+func TestDatagramConn(t *testing.T) {
+	raw, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer raw.Close()
+	conn, err := net.DialUDP("udp", nil, raw.LocalAddr().(*net.UDPAddr))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	eap := []byte{eapaka.CodeRequest, 1, 0, 5, 1}
+	want := []*eapol.Frame{
+		eapol.NewEAPFrame(eapol.Version2004, eap),
+		{Version: eapol.Version2004, Type: eapol.TypeStart},
+	}
+	for _, f := range want {
+		b, err := f.Marshal()
+		if err != nil {
+			t.Fatal(err)
+		}
+		padded := make([]byte, 46)
+		copy(padded, b)
+		if _, err := raw.WriteTo(padded, conn.LocalAddr()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	c := eapol.NewDatagramConn(conn)
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	for _, w := range want {
+		f, err := c.ReadFrame()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if diff := cmp.Diff(w, f); diff != "" {
+			t.Errorf("frame (-want +got):\n%s", diff)
+		}
+	}
+}
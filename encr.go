@@ -0,0 +1,72 @@
+package eapaka
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"fmt"
+)
+
+// EncryptAttributes encrypts attrs with AES-128-CBC under K_encr and returns
+// the resulting AT_ENCR_DATA attribute. AT_PADDING is appended as needed so that
+// the plaintext is a multiple of 16 bytes. iv is the value sent in AT_IV.
+// See RFC 4187 Section 10.12.
+func EncryptAttributes(kEncr Key, iv []byte, attrs ...Attribute) (*AtEncrData, error) {
+	if len(iv) != aes.BlockSize {
+		return nil, fmt.Errorf("%w: AT_IV must be 16 bytes", ErrInvalidAttribute)
+	}
+	var plain []byte
+	for _, attr := range attrs {
+		b, err := attr.Marshal()
+		if err != nil {
+			return nil, err
+		}
+		plain = append(plain, b...)
+	}
+	if r := len(plain) % aes.BlockSize; r != 0 {
+		// The AT_PADDING header takes 2 of the missing bytes.
+		b, err := (&AtPadding{Length: aes.BlockSize - r - 2}).Marshal()
+		if err != nil {
+			return nil, err
+		}
+		plain = append(plain, b...)
+	}
+
+	block, err := aes.NewCipher(kEncr)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]byte, len(plain))
+	cipher.NewCBCEncrypter(block, iv).CryptBlocks(out, plain)
+	clear(plain)
+	return &AtEncrData{EncryptedData: out}, nil
+}
+
+// DecryptAttributes decrypts the contents of AT_ENCR_DATA with AES-128-CBC under
+// K_encr and the AT_IV value iv, and decodes the enclosed attributes.
+// AT_PADDING is removed from the result.
+func DecryptAttributes(kEncr Key, iv []byte, encr *AtEncrData) ([]Attribute, error) {
+	if len(iv) != aes.BlockSize {
+		return nil, fmt.Errorf("%w: AT_IV must be 16 bytes", ErrInvalidAttribute)
+	}
+	if len(encr.EncryptedData) == 0 || len(encr.EncryptedData)%aes.BlockSize != 0 {
+		return nil, fmt.Errorf("%w: AT_ENCR_DATA length %d is not a positive multiple of 16", ErrMalformedAttribute, len(encr.EncryptedData))
+	}
+	block, err := aes.NewCipher(kEncr)
+	if err != nil {
+		return nil, err
+	}
+	plain := make([]byte, len(encr.EncryptedData))
+	cipher.NewCBCDecrypter(block, iv).CryptBlocks(plain, encr.EncryptedData)
+
+	attrs, err := parseAttributes(plain, 0, &LenientParseOptions)
+	if err != nil {
+		return nil, err
+	}
+	out := attrs[:0]
+	for _, attr := range attrs {
+		if attr.Type() != AT_PADDING {
+			out = append(out, attr)
+		}
+	}
+	return out, nil
+}
@@ -0,0 +1,134 @@
+package eapaka
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Sentinel errors for use with [errors.Is]. Errors returned by this package
+// wrap one of them, usually inside a *[ParseError] or one of the typed errors
+// of [ParseWithOptions].
+var (
+	// ErrTruncated reports a packet or attribute that extends beyond the data.
+	ErrTruncated = errors.New("eapaka: truncated packet")
+
+	// ErrInvalidLength reports a length field that is inconsistent with the
+	// format, such as an EAP Length below 4 or an attribute Length of zero.
+	ErrInvalidLength = errors.New("eapaka: invalid length")
+
+	// ErrMalformedAttribute reports an attribute whose value cannot be decoded.
+	ErrMalformedAttribute = errors.New("eapaka: malformed attribute")
+
+	// ErrUnknownAttribute reports an unknown non-skippable attribute.
+	// See [UnknownAttributeError].
+	ErrUnknownAttribute = errors.New("eapaka: unknown non-skippable attribute")
+
+	// ErrTrailingBytes reports data after the end of the packet.
+	// See [TrailingBytesError].
+	ErrTrailingBytes = errors.New("eapaka: trailing bytes")
+
+	// ErrReservedField reports a non-zero reserved field. See [ReservedFieldError].
+	ErrReservedField = errors.New("eapaka: non-zero reserved field")
+
+	// ErrInvalidResLength reports an invalid RES Length. See [ResLengthError].
+	ErrInvalidResLength = errors.New("eapaka: invalid AT_RES length")
+
+	// ErrLimitExceeded reports a packet beyond the limits of [ParseOptions].
+	// See [LimitError].
+	ErrLimitExceeded = errors.New("eapaka: limit exceeded")
+
+	// ErrInvalidAttribute reports an attribute that cannot be marshalled, such as
+	// an AT_RAND that is not 16 bytes, or that a builder cannot use.
+	ErrInvalidAttribute = errors.New("eapaka: invalid attribute")
+
+	// ErrPacketTooLong reports a packet longer than the EAP Length can express.
+	ErrPacketTooLong = errors.New("eapaka: packet too long")
+
+	// ErrMissingKey reports that a key needed to build a packet was not given.
+	ErrMissingKey = errors.New("eapaka: key not set")
+
+	// ErrMACNotFound reports a packet without the AT_MAC attribute needed to
+	// calculate or verify the MAC.
+	ErrMACNotFound = errors.New("eapaka: AT_MAC attribute not found")
+
+	// ErrUnsupportedType reports an EAP Type other than EAP-AKA and EAP-AKA'
+	// where one of them is required, e.g. for the MAC calculation.
+	ErrUnsupportedType = errors.New("eapaka: unsupported EAP type")
+
+	// ErrInvalidAUTN reports an AUTN that is not 16 bytes long.
+	// See [DeriveCKPrimeIKPrime].
+	ErrInvalidAUTN = errors.New("eapaka: invalid AUTN")
+
+	// ErrInvalidEMSK reports an EMSK shorter than 256 bits.
+	// See [DeriveKAUSF].
+	ErrInvalidEMSK = errors.New("eapaka: invalid EMSK")
+
+	// ErrUnsupportedKDF reports an AT_KDF negotiation without a supported KDF.
+	// See [CheckKDFInput].
+	ErrUnsupportedKDF = errors.New("eapaka: unsupported KDF")
+
+	// ErrKDFInput reports an AT_KDF_INPUT that is missing or does not match the
+	// expected network name. See [CheckKDFInput].
+	ErrKDFInput = errors.New("eapaka: invalid AT_KDF_INPUT")
+
+	// ErrProtectedNotification reports an EAP-AKA-Notification of a protected
+	// result round that does not verify. See [VerifyProtectedNotification].
+	ErrProtectedNotification = errors.New("eapaka: invalid protected notification")
+)
+
+// ParseError describes where and why a packet could not be parsed.
+// It wraps a sentinel error, or for the checks of [ParseWithOptions] one of the
+// typed errors (e.g., *[UnknownAttributeError]), which in turn wraps a sentinel.
+type ParseError struct {
+	// Offset is the offset in the packet where the problem was found. For the
+	// attributes inside AT_ENCR_DATA, it is relative to the decrypted data.
+	Offset int
+
+	// Attribute is the type of the offending attribute, or zero if the problem is
+	// in the EAP or EAP-AKA header.
+	Attribute AttributeType
+
+	// Reason describes the problem.
+	Reason string
+
+	// Err is the underlying error.
+	Err error
+}
+
+func (e *ParseError) Error() string {
+	if e.Attribute == 0 {
+		return fmt.Sprintf("eapaka: parse error at offset %d: %s", e.Offset, e.Reason)
+	}
+	return fmt.Sprintf("eapaka: parse error in %s at offset %d: %s", e.Attribute, e.Offset, e.Reason)
+}
+
+func (e *ParseError) Unwrap() error { return e.Err }
+
+// ClientErrorFor suggests the AT_CLIENT_ERROR_CODE a peer sends in
+// EAP-Response/AKA-Client-Error when it fails to process a request with err.
+// EAP-AKA only uses [ClientErrorUnableToProcess]; the other codes belong to
+// EAP-SIM.
+//
+// ok is false if err does not call for a Client-Error: if err is nil, is not an
+// error of this package, or is a KDF negotiation failure, to which the peer
+// replies with EAP-Response/AKA-Authentication-Reject (RFC 5448 Section 3.2).
+func ClientErrorFor(err error) (_ *AtClientErrorCode, ok bool) {
+	if err == nil || errors.Is(err, ErrUnsupportedKDF) || errors.Is(err, ErrKDFInput) {
+		return nil, false
+	}
+	var verr *ValidationError
+	var perr *ParseError
+	if errors.As(err, &verr) || errors.As(err, &perr) {
+		return &AtClientErrorCode{Code: ClientErrorUnableToProcess}, true
+	}
+	for _, target := range []error{
+		ErrTruncated, ErrInvalidLength, ErrMalformedAttribute, ErrUnknownAttribute,
+		ErrTrailingBytes, ErrReservedField, ErrInvalidResLength, ErrLimitExceeded,
+		ErrMACNotFound, ErrUnsupportedType,
+	} {
+		if errors.Is(err, target) {
+			return &AtClientErrorCode{Code: ClientErrorUnableToProcess}, true
+		}
+	}
+	return nil, false
+}
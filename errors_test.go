@@ -0,0 +1,121 @@
+package eapaka_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/oyaguma3/go-eapaka"
+)
+
+func TestParse_Errors(t *testing.T) {
+	tests := []struct {
+		name   string
+		data   []byte
+		opts   eapaka.ParseOptions
+		want   error
+		offset int
+		attr   eapaka.AttributeType
+	}{
+		{"short", []byte{eapaka.CodeRequest, 1}, eapaka.LenientParseOptions, eapaka.ErrTruncated, 2, 0},
+		{"length beyond data", []byte{eapaka.CodeRequest, 1, 0, 12, eapaka.TypeAKA, 1, 0, 0}, eapaka.LenientParseOptions, eapaka.ErrTruncated, 2, 0},
+		{"length below header", []byte{eapaka.CodeRequest, 1, 0, 2}, eapaka.LenientParseOptions, eapaka.ErrInvalidLength, 2, 0},
+		{"AKA header", []byte{eapaka.CodeRequest, 1, 0, 6, eapaka.TypeAKA, 1}, eapaka.LenientParseOptions, eapaka.ErrTruncated, 4, 0},
+		{"attribute length zero", []byte{eapaka.CodeRequest, 1, 0, 12, eapaka.TypeAKA, 1, 0, 0, byte(eapaka.AT_RAND), 0, 0, 0}, eapaka.LenientParseOptions, eapaka.ErrInvalidLength, 8, eapaka.AT_RAND},
+		{"attribute overflow", []byte{eapaka.CodeRequest, 1, 0, 12, eapaka.TypeAKA, 1, 0, 0, byte(eapaka.AT_RAND), 5, 0, 0}, eapaka.LenientParseOptions, eapaka.ErrTruncated, 8, eapaka.AT_RAND},
+		{"malformed attribute", []byte{eapaka.CodeRequest, 1, 0, 12, eapaka.TypeAKA, 1, 0, 0, byte(eapaka.AT_RAND), 1, 0, 0}, eapaka.LenientParseOptions, eapaka.ErrMalformedAttribute, 8, eapaka.AT_RAND},
+		{"unknown attribute", []byte{eapaka.CodeRequest, 1, 0, 12, eapaka.TypeAKA, 1, 0, 0, 99, 1, 0, 0}, eapaka.StrictParseOptions, eapaka.ErrUnknownAttribute, 8, 99},
+		{"reserved field", []byte{eapaka.CodeRequest, 1, 0, 12, eapaka.TypeAKA, 1, 0, 0, byte(eapaka.AT_RESULT_IND), 1, 1, 0}, eapaka.StrictParseOptions, eapaka.ErrReservedField, 10, eapaka.AT_RESULT_IND},
+		{"trailing bytes", []byte{eapaka.CodeSuccess, 1, 0, 4, 0}, eapaka.StrictParseOptions, eapaka.ErrTrailingBytes, 4, 0},
+		{"limit", []byte{eapaka.CodeSuccess, 1, 0, 4}, eapaka.ParseOptions{MaxPacketSize: 3}, eapaka.ErrLimitExceeded, 2, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := eapaka.ParseWithOptions(tt.data, tt.opts)
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("err = %v, want %v", err, tt.want)
+			}
+			var perr *eapaka.ParseError
+			if !errors.As(err, &perr) {
+				t.Fatalf("err = %T, want *ParseError", err)
+			}
+			if perr.Offset != tt.offset || perr.Attribute != tt.attr {
+				t.Errorf("offset = %d, attribute = %s; want %d, %s", perr.Offset, perr.Attribute, tt.offset, tt.attr)
+			}
+			if _, ok := eapaka.ClientErrorFor(err); !ok {
+				t.Error("ClientErrorFor: no Client-Error suggested")
+			}
+
+			// NewView reports framing errors the same way.
+			if tt.opts == eapaka.LenientParseOptions && tt.want != eapaka.ErrMalformedAttribute {
+				if _, err := eapaka.NewView(tt.data); !errors.Is(err, tt.want) {
+					t.Errorf("NewView: err = %v, want %v", err, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestMarshalAndMac_Errors(t *testing.T) {
+	pkt := &eapaka.Packet{Code: eapaka.CodeRequest, Type: eapaka.TypeAKA, Subtype: eapaka.SubtypeChallenge,
+		Attributes: []eapaka.Attribute{&eapaka.AtRand{Rand: make([]byte, 8)}}}
+	if _, err := pkt.Marshal(); !errors.Is(err, eapaka.ErrInvalidAttribute) {
+		t.Errorf("Marshal: err = %v, want ErrInvalidAttribute", err)
+	}
+	if err := pkt.CalculateAndSetMac(make([]byte, 16)); !errors.Is(err, eapaka.ErrMACNotFound) {
+		t.Errorf("CalculateAndSetMac: err = %v, want ErrMACNotFound", err)
+	}
+	if _, err := pkt.VerifyMac(make([]byte, 16)); !errors.Is(err, eapaka.ErrMACNotFound) {
+		t.Errorf("VerifyMac: err = %v, want ErrMACNotFound", err)
+	}
+	pkt = &eapaka.Packet{Code: eapaka.CodeRequest, Type: 1, Attributes: []eapaka.Attribute{&eapaka.AtMac{}}}
+	if err := pkt.CalculateAndSetMac(make([]byte, 16)); !errors.Is(err, eapaka.ErrUnsupportedType) {
+		t.Errorf("CalculateAndSetMac: err = %v, want ErrUnsupportedType", err)
+	}
+	if _, err := eapaka.NewReauthResponse(eapaka.TypeAKA, 1, 1).Sign(make([]byte, 16)); !errors.Is(err, eapaka.ErrMissingKey) {
+		t.Errorf("Sign: err = %v, want ErrMissingKey", err)
+	}
+}
+
+func TestCheckKDFInput(t *testing.T) {
+	challenge := func(attrs ...eapaka.Attribute) *eapaka.Packet {
+		return &eapaka.Packet{Code: eapaka.CodeRequest, Type: eapaka.TypeAKAPrime, Subtype: eapaka.SubtypeChallenge, Attributes: attrs}
+	}
+	tests := []struct {
+		name string
+		pkt  *eapaka.Packet
+		want error
+	}{
+		{"ok", challenge(&eapaka.AtKdfInput{NetworkName: "WLAN"}, &eapaka.AtKdf{KDF: 1}), nil},
+		{"no network name", challenge(&eapaka.AtKdf{KDF: 1}), eapaka.ErrKDFInput},
+		{"other network", challenge(&eapaka.AtKdfInput{NetworkName: "5G:mnc001.mcc001.3gppnetwork.org"}, &eapaka.AtKdf{KDF: 1}), eapaka.ErrKDFInput},
+		{"no AT_KDF", challenge(&eapaka.AtKdfInput{NetworkName: "WLAN"}), eapaka.ErrUnsupportedKDF},
+		{"unsupported KDF first", challenge(&eapaka.AtKdfInput{NetworkName: "WLAN"}, &eapaka.AtKdf{KDF: 2}, &eapaka.AtKdf{KDF: 1}), eapaka.ErrUnsupportedKDF},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			name, err := eapaka.CheckKDFInput(tt.pkt, "WLAN")
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("err = %v, want %v", err, tt.want)
+			}
+			if err == nil && name != "WLAN" {
+				t.Errorf("network name = %q", name)
+			}
+			if _, ok := eapaka.ClientErrorFor(err); ok {
+				t.Error("ClientErrorFor suggested a Client-Error")
+			}
+		})
+	}
+}
+
+func TestClientErrorFor(t *testing.T) {
+	verr := (&eapaka.Packet{Code: eapaka.CodeRequest, Type: eapaka.TypeAKA, Subtype: eapaka.SubtypeChallenge}).Validate()
+	if ce, ok := eapaka.ClientErrorFor(verr); !ok || ce.Code != eapaka.ClientErrorUnableToProcess {
+		t.Errorf("ClientErrorFor(validation error) = %v, %v", ce, ok)
+	}
+	if _, ok := eapaka.ClientErrorFor(errors.New("network unreachable")); ok {
+		t.Error("ClientErrorFor suggested a Client-Error for a foreign error")
+	}
+	if _, ok := eapaka.ClientErrorFor(nil); ok {
+		t.Error("ClientErrorFor(nil) suggested a Client-Error")
+	}
+}
@@ -0,0 +1,189 @@
+package hlrauc
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/oyaguma3/go-eapaka"
+)
+
+// Errors returned by [Client.GetVector].
+var (
+	ErrFailure      = errors.New("hlrauc: gateway reported FAILURE")
+	ErrNoReply      = errors.New("hlrauc: no reply from gateway")
+	ErrInvalidReply = errors.New("hlrauc: invalid reply")
+)
+
+// localSeq numbers the default local socket paths of this process.
+var localSeq atomic.Uint32
+
+// Client is an [eapaka.VectorProvider] that fetches vectors from hostapd's
+// hlr_auc_gw, or a [Server], with AKA-REQ-AUTH. A synchronization failure
+// reported in the [eapaka.VectorRequest] is first sent as AKA-AUTS. The
+// socket bound for the replies is created on first use. Requests are sent
+// one at a time; it is safe for concurrent use.
+//
+// The gateway returns CK and IK; for EAP-AKA' the EAP server derives CK'
+// and IK' itself, and the gateway's AMF must have the separation bit set.
+// A FAILURE reply is reported as [eapaka.ErrUnknownSubscriber] wrapping
+// [ErrFailure], since hlr_auc_gw answers so for an unknown IMSI.
+type Client struct {
+	// Addr is the path of the gateway's socket, e.g. "/tmp/hlr_auc_gw.sock".
+	Addr string
+
+	// LocalAddr is the path of the socket bound for the replies; it is
+	// removed by [Client.Close].
+	// Default: "eapaka_hlr_auc_<pid>-<n>" in os.TempDir().
+	LocalAddr string
+
+	// Timeout is the time to wait for each reply. Default: 5s.
+	Timeout time.Duration
+
+	// Logger receives diagnostics. Default: discard.
+	Logger *slog.Logger
+
+	mu    sync.Mutex
+	conn  *net.UnixConn
+	local string
+	buf   []byte
+}
+
+// GetVector implements [eapaka.VectorProvider].
+func (c *Client) GetVector(ctx context.Context, req *eapaka.VectorRequest) (*eapaka.AuthVector, error) {
+	if !isDigits(req.IMSI) {
+		return nil, fmt.Errorf("hlrauc: invalid IMSI %q", req.IMSI)
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	conn, err := c.open()
+	if err != nil {
+		return nil, err
+	}
+	if req.AUTS != nil {
+		msg := fmt.Sprintf("%s %s %x %x", MsgAKAAUTS, req.IMSI, req.AUTS, req.RAND)
+		if err := c.send(conn, msg); err != nil {
+			return nil, err
+		}
+	}
+	if err := c.send(conn, MsgAKAReqAuth+" "+req.IMSI); err != nil {
+		return nil, err
+	}
+
+	deadline := time.Now().Add(c.timeout())
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	conn.SetReadDeadline(deadline)
+	for {
+		n, err := conn.Read(c.buf)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			var ne net.Error
+			if errors.As(err, &ne) && ne.Timeout() {
+				return nil, ErrNoReply
+			}
+			return nil, err
+		}
+		f := strings.Fields(string(c.buf[:n]))
+		// Skip late replies to requests that timed out.
+		if len(f) < 2 || f[0] != MsgAKARespAuth || f[1] != req.IMSI {
+			if len(f) > 0 {
+				c.logger().Debug("hlrauc: unexpected reply", "message", f[0])
+			}
+			continue
+		}
+		return parseRespAuth(f)
+	}
+}
+
+// parseRespAuth parses the fields of an AKA-RESP-AUTH.
+func parseRespAuth(f []string) (*eapaka.AuthVector, error) {
+	if len(f) == 3 && f[2] == failure {
+		return nil, fmt.Errorf("%w: %w", eapaka.ErrUnknownSubscriber, ErrFailure)
+	}
+	if len(f) != 7 {
+		return nil, fmt.Errorf("%w: %d fields", ErrInvalidReply, len(f))
+	}
+	v := &eapaka.AuthVector{}
+	for _, x := range []struct {
+		name     string
+		s        string
+		dst      *[]byte
+		min, max int
+	}{
+		{"RAND", f[2], &v.RAND, 16, 16},
+		{"AUTN", f[3], &v.AUTN, 16, 16},
+		{"IK", f[4], &v.IK, 16, 16},
+		{"CK", f[5], &v.CK, 16, 16},
+		{"RES", f[6], &v.XRES, 4, 16},
+	} {
+		b, err := hex.DecodeString(x.s)
+		if err != nil || len(b) < x.min || len(b) > x.max {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidReply, x.name)
+		}
+		*x.dst = b
+	}
+	return v, nil
+}
+
+// open binds the local socket. c.mu is held.
+func (c *Client) open() (*net.UnixConn, error) {
+	if c.conn != nil {
+		return c.conn, nil
+	}
+	local := c.LocalAddr
+	if local == "" {
+		local = filepath.Join(os.TempDir(), fmt.Sprintf("eapaka_hlr_auc_%d-%d", os.Getpid(), localSeq.Add(1)))
+	}
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: local, Net: "unixgram"})
+	if err != nil {
+		return nil, err
+	}
+	c.conn, c.local, c.buf = conn, local, make([]byte, maxMessage)
+	return conn, nil
+}
+
+// send writes one message to the gateway. c.mu is held.
+func (c *Client) send(conn *net.UnixConn, msg string) error {
+	_, err := conn.WriteToUnix([]byte(msg), &net.UnixAddr{Name: c.Addr, Net: "unixgram"})
+	return err
+}
+
+// Close closes the local socket and removes its path.
+func (c *Client) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.conn == nil {
+		return nil
+	}
+	err := c.conn.Close()
+	os.Remove(c.local)
+	c.conn = nil
+	return err
+}
+
+func (c *Client) timeout() time.Duration {
+	if c.Timeout > 0 {
+		return c.Timeout
+	}
+	return 5 * time.Second
+}
+
+func (c *Client) logger() *slog.Logger {
+	if c.Logger != nil {
+		return c.Logger
+	}
+	return slog.New(slog.DiscardHandler)
+}
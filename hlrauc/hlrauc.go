@@ -0,0 +1,193 @@
+// Package hlrauc speaks the text protocol of hostapd's hlr_auc_gw, the
+// HLR/AuC gateway used by hostapd's EAP-SIM/AKA server, and reads and
+// writes its Milenage database file. [Client] is an [eapaka.VectorProvider]
+// that fetches vectors from a running hlr_auc_gw, and [Server] is a
+// compatible gateway backed by an [eapaka.VectorProvider], typically a
+// [milenage.Provider] loaded with [LoadMilenageDB], so that hostapd and a
+// Go AAA server can share one subscriber database.
+//
+// Messages are single datagrams on a Unix datagram socket, with values in
+// hex:
+//
+//	AKA-REQ-AUTH <IMSI>
+//	AKA-RESP-AUTH <IMSI> <RAND> <AUTN> <IK> <CK> <RES>
+//	AKA-RESP-AUTH <IMSI> FAILURE
+//	AKA-AUTS <IMSI> <AUTS> <RAND>
+//
+// AKA-AUTS has no reply; the next AKA-REQ-AUTH returns a vector from the
+// re-synchronised SQN. GSM triplets (SIM-REQ-AUTH) are not supported.
+package hlrauc
+
+import (
+	"bufio"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/oyaguma3/go-eapaka/milenage"
+)
+
+// Message names of the hlr_auc_gw protocol.
+const (
+	MsgAKAReqAuth  = "AKA-REQ-AUTH"
+	MsgAKARespAuth = "AKA-RESP-AUTH"
+	MsgAKAAUTS     = "AKA-AUTS"
+	MsgSIMReqAuth  = "SIM-REQ-AUTH"
+	MsgSIMRespAuth = "SIM-RESP-AUTH"
+
+	failure = "FAILURE"
+)
+
+// maxMessage is the largest datagram read, as in hlr_auc_gw.
+const maxMessage = 1000
+
+// ReadMilenageDB parses a hlr_auc_gw Milenage database. Each line holds
+//
+//	IMSI Ki OPc AMF SQN [RES_len]
+//
+// with Ki, OPc, AMF and SQN in hex; SQN is the last SQN used. Empty lines
+// and lines starting with "#" are ignored. RES_len, if present, must be 8,
+// the RES length of MILENAGE.
+func ReadMilenageDB(r io.Reader) ([]*milenage.Subscriber, error) {
+	var subs []*milenage.Subscriber
+	sc := bufio.NewScanner(r)
+	for n := 1; sc.Scan(); n++ {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		s, err := parseDBLine(line)
+		if err != nil {
+			return nil, fmt.Errorf("hlrauc: line %d: %w", n, err)
+		}
+		subs = append(subs, s)
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	return subs, nil
+}
+
+// LoadMilenageDB reads the hlr_auc_gw Milenage database at path, see
+// [ReadMilenageDB].
+func LoadMilenageDB(path string) ([]*milenage.Subscriber, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ReadMilenageDB(f)
+}
+
+func parseDBLine(line string) (*milenage.Subscriber, error) {
+	f := strings.Fields(line)
+	if len(f) != 5 && len(f) != 6 {
+		return nil, fmt.Errorf("%d fields, want IMSI Ki OPc AMF SQN [RES_len]", len(f))
+	}
+	if !isDigits(f[0]) {
+		return nil, fmt.Errorf("invalid IMSI %q", f[0])
+	}
+	s := &milenage.Subscriber{IMSI: f[0]}
+	var sqn []byte
+	for _, v := range []struct {
+		name string
+		s    string
+		dst  *[]byte
+		n    int
+	}{
+		{"Ki", f[1], &s.K, 16},
+		{"OPc", f[2], &s.OPc, 16},
+		{"AMF", f[3], &s.AMF, 2},
+		{"SQN", f[4], &sqn, 6},
+	} {
+		b, err := hex.DecodeString(v.s)
+		if err != nil || len(b) != v.n {
+			return nil, fmt.Errorf("invalid %s %q", v.name, v.s)
+		}
+		*v.dst = b
+	}
+	s.SQN = milenage.SQNFromBytes(sqn)
+	if len(f) == 6 {
+		if n, err := strconv.Atoi(f[5]); err != nil || n != 8 {
+			return nil, fmt.Errorf("RES_len %q not supported", f[5])
+		}
+	}
+	return s, nil
+}
+
+// WriteMilenageDB writes subs in the hlr_auc_gw Milenage database format.
+// A missing AMF is written as 8000.
+func WriteMilenageDB(w io.Writer, subs []*milenage.Subscriber) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintln(bw, "# IMSI Ki OPc AMF SQN")
+	for _, s := range subs {
+		amf := s.AMF
+		if len(amf) != 2 {
+			amf = []byte{0x80, 0x00}
+		}
+		fmt.Fprintf(bw, "%s %x %x %x %x\n", s.IMSI, s.K, s.OPc, amf, milenage.SQNBytes(s.SQN))
+	}
+	return bw.Flush()
+}
+
+// UpdateMilenageDB rewrites the SQN of every subscriber of the database at
+// path that p knows with its current value, as hlr_auc_gw -u does. Other
+// lines are kept as they are. The file is replaced atomically.
+func UpdateMilenageDB(path string, p *milenage.Provider) error {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	lines := strings.SplitAfter(string(b), "\n")
+	for i, line := range lines {
+		body := strings.TrimRight(line, "\r\n")
+		f := strings.Fields(body)
+		if len(f) < 5 || strings.HasPrefix(f[0], "#") {
+			continue
+		}
+		s, ok := p.Subscriber(f[0])
+		if !ok {
+			continue
+		}
+		f[4] = hex.EncodeToString(milenage.SQNBytes(s.SQN))
+		lines[i] = strings.Join(f, " ") + line[len(body):]
+	}
+
+	fi, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.WriteString(strings.Join(lines, "")); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(fi.Mode().Perm()); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+func isDigits(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,220 @@
+package hlrauc_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/oyaguma3/go-eapaka"
+	"github.com/oyaguma3/go-eapaka/eapakatest"
+	"github.com/oyaguma3/go-eapaka/hlrauc"
+	"github.com/oyaguma3/go-eapaka/milenage"
+	"github.com/oyaguma3/go-eapaka/peer"
+	"github.com/oyaguma3/go-eapaka/server"
+)
+
+var testSub = &eapakatest.Subscribers[0]
+
+var testDB = `# Parameters for Milenage
+# IMSI Ki OPc AMF SQN [RES_len]
+
+` + fmt.Sprintf("%s %x %x 8000 00000000000a\n", testSub.IMSI, testSub.K, testSub.OPc) +
+	"232010000000000 90dca4eda45b53cf0f12d7c9c3bc6a89 cb9cccc4b9258e6dca4760379fb82581 61df 000000000000 8\n"
+
+func TestReadMilenageDB(t *testing.T) {
+	subs, err := hlrauc.ReadMilenageDB(strings.NewReader(testDB))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []*milenage.Subscriber{
+		{IMSI: testSub.IMSI, K: testSub.K, OPc: testSub.OPc, AMF: []byte{0x80, 0x00}, SQN: 10},
+		{
+			IMSI: "232010000000000",
+			K:    []byte{0x90, 0xdc, 0xa4, 0xed, 0xa4, 0x5b, 0x53, 0xcf, 0x0f, 0x12, 0xd7, 0xc9, 0xc3, 0xbc, 0x6a, 0x89},
+			OPc:  []byte{0xcb, 0x9c, 0xcc, 0xc4, 0xb9, 0x25, 0x8e, 0x6d, 0xca, 0x47, 0x60, 0x37, 0x9f, 0xb8, 0x25, 0x81},
+			AMF:  []byte{0x61, 0xdf},
+		},
+	}
+	if diff := cmp.Diff(want, subs); diff != "" {
+		t.Errorf("subscribers (-want +got):\n%s", diff)
+	}
+
+	var buf bytes.Buffer
+	if err := hlrauc.WriteMilenageDB(&buf, subs); err != nil {
+		t.Fatal(err)
+	}
+	again, err := hlrauc.ReadMilenageDB(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(subs, again); diff != "" {
+		t.Errorf("round trip (-want +got):\n%s", diff)
+	}
+
+	for _, line := range []string{
+		"001010000000001 4646 cdcdcdcdcdcdcdcdcdcdcdcdcdcdcdcd 8000 000000000000",
+		"00101000000000x 46464646464646464646464646464646 cdcdcdcdcdcdcdcdcdcdcdcdcdcdcdcd 8000 000000000000",
+		"001010000000001 46464646464646464646464646464646 cdcdcdcdcdcdcdcdcdcdcdcdcdcdcdcd 8000",
+		"001010000000001 46464646464646464646464646464646 cdcdcdcdcdcdcdcdcdcdcdcdcdcdcdcd 8000 000000000000 4",
+	} {
+		if _, err := hlrauc.ReadMilenageDB(strings.NewReader("# comment\n" + line)); err == nil || !strings.Contains(err.Error(), "line 2") {
+			t.Errorf("%q: %v", line, err)
+		}
+	}
+}
+
+func TestUpdateMilenageDB(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "hlr_auc_gw.milenage_db")
+	if err := os.WriteFile(path, []byte(testDB), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	subs, err := hlrauc.LoadMilenageDB(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := milenage.NewProvider(subs[0])
+	for range 3 {
+		if _, err := p.GetVector(context.Background(), &eapaka.VectorRequest{IMSI: testSub.IMSI, Type: eapaka.TypeAKA}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := hlrauc.UpdateMilenageDB(path, p); err != nil {
+		t.Fatal(err)
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := strings.Replace(testDB, "8000 00000000000a", "8000 00000000000d", 1)
+	if diff := cmp.Diff(want, string(b)); diff != "" {
+		t.Errorf("database (-want +got):\n%s", diff)
+	}
+}
+
+// startServer serves sub from a gateway and returns a client of it.
+func startServer(t *testing.T, sub *milenage.Subscriber) *hlrauc.Client {
+	t.Helper()
+	dir := t.TempDir()
+	s := hlrauc.NewServer(sub)
+	path := filepath.Join(dir, "gw.sock")
+	done := make(chan error, 1)
+	go func() { done <- s.ListenAndServe(path) }()
+	for range 100 {
+		if _, err := os.Stat(path); err == nil {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	c := &hlrauc.Client{Addr: path, LocalAddr: filepath.Join(dir, "client.sock"), Timeout: time.Second}
+	t.Cleanup(func() {
+		c.Close()
+		s.Close()
+		if err := <-done; err != nil {
+			t.Error(err)
+		}
+	})
+	return c
+}
+
+func TestServer_Handle(t *testing.T) {
+	s := hlrauc.NewServer(testSub.Milenage())
+	ctx := context.Background()
+	unknown := eapakatest.Subscribers[1].IMSI
+	tests := []struct {
+		msg, want string
+	}{
+		{"AKA-REQ-AUTH " + unknown, "AKA-RESP-AUTH " + unknown + " FAILURE"},
+		{"SIM-REQ-AUTH " + testSub.IMSI + " 3", "SIM-RESP-AUTH " + testSub.IMSI + " FAILURE"},
+		{"AKA-AUTS " + testSub.IMSI + " 00 00", ""},
+		{"UNKNOWN " + testSub.IMSI, ""},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := s.Handle(ctx, tt.msg); got != tt.want {
+			t.Errorf("Handle(%q) = %q, want %q", tt.msg, got, tt.want)
+		}
+	}
+	if got := s.Handle(ctx, "AKA-REQ-AUTH "+testSub.IMSI); len(strings.Fields(got)) != 7 {
+		t.Errorf("AKA-REQ-AUTH: %q", got)
+	}
+}
+
+func TestClient(t *testing.T) {
+	c := startServer(t, testSub.Milenage())
+	ctx := context.Background()
+	v, err := c.GetVector(ctx, &eapaka.VectorRequest{IMSI: testSub.IMSI, Type: eapaka.TypeAKA})
+	if err != nil {
+		t.Fatal(err)
+	}
+	usim, err := testSub.USIM()
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, ck, ik, _, err := usim.Authenticate(v.RAND, v.AUTN)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v.Prime || !bytes.Equal(res, v.XRES) || !bytes.Equal(ck, v.CK) || !bytes.Equal(ik, v.IK) {
+		t.Error("vector does not match the USIM")
+	}
+
+	if _, err := c.GetVector(ctx, &eapaka.VectorRequest{IMSI: eapakatest.Subscribers[1].IMSI, Type: eapaka.TypeAKA}); !errors.Is(err, eapaka.ErrUnknownSubscriber) || !errors.Is(err, hlrauc.ErrFailure) {
+		t.Errorf("unknown subscriber: %v", err)
+	}
+
+	none := &hlrauc.Client{Addr: filepath.Join(t.TempDir(), "none.sock"), LocalAddr: filepath.Join(t.TempDir(), "c.sock"), Timeout: 50 * time.Millisecond}
+	defer none.Close()
+	if _, err := none.GetVector(ctx, &eapaka.VectorRequest{IMSI: testSub.IMSI, Type: eapaka.TypeAKA}); err == nil {
+		t.Error("no gateway: vector returned")
+	}
+}
+
+// TestClient_AgainstServer runs EAP-AKA with vectors from the gateway; the
+// gateway starts behind the USIM and is brought forward by a
+// re-synchronisation through AKA-AUTS.
+func TestClient_AgainstServer(t *testing.T) {
+	sub := testSub.Milenage()
+	sub.SQN -= 500
+	c := startServer(t, sub)
+	a, err := server.New(server.Config{Vectors: c})
+	if err != nil {
+		t.Fatal(err)
+	}
+	usim, err := testSub.USIM()
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := peer.New(peer.Config{Identity: testSub.Identity(eapaka.TypeAKA), SIM: usim})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	msg, sessionID := p.IdentityResponse(0), ""
+	for range 10 {
+		res, err := a.Handle(context.Background(), sessionID, msg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		reply, _ := res.Reply.Marshal()
+		next, _ := p.Handle(reply)
+		if res.Status == server.StatusSuccess && p.Status() == peer.StatusSuccess {
+			if usim.SQN() < testSub.SQN {
+				t.Errorf("USIM SQN %d, want a vector past the re-synchronisation", usim.SQN())
+			}
+			return
+		}
+		if res.Status != server.StatusContinue {
+			t.Fatalf("status %v", res.Status)
+		}
+		msg, sessionID = next, res.SessionID
+	}
+	t.Fatal("conversation did not finish")
+}
@@ -0,0 +1,190 @@
+package hlrauc
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"net"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/oyaguma3/go-eapaka"
+	"github.com/oyaguma3/go-eapaka/milenage"
+)
+
+// Server is a gateway compatible with hostapd's hlr_auc_gw. It answers
+// AKA-REQ-AUTH with vectors from an [eapaka.VectorProvider], typically a
+// [milenage.Provider], and re-synchronises SQN on AKA-AUTS; the vector
+// generated then is returned by the next AKA-REQ-AUTH for the IMSI.
+// SIM-REQ-AUTH is answered with FAILURE. It is safe for concurrent use.
+type Server struct {
+	// Vectors generates the vectors. Required.
+	Vectors eapaka.VectorProvider
+
+	// Type is the method the vectors are requested for. With
+	// [eapaka.TypeAKAPrime] a [milenage.Provider] sets the AMF separation
+	// bit, which EAP-AKA' peers check; the gateway cannot tell the methods
+	// apart. Default: [eapaka.TypeAKA], the AMF of the subscriber as is.
+	Type uint8
+
+	// Logger receives diagnostics. Default: discard.
+	Logger *slog.Logger
+
+	mu      sync.Mutex
+	conn    net.PacketConn
+	closed  bool
+	pending map[string]*eapaka.AuthVector // by IMSI, after AKA-AUTS
+}
+
+// NewServer creates a [Server] that generates vectors with MILENAGE for the
+// given subscribers, e.g. those of [LoadMilenageDB].
+func NewServer(subs ...*milenage.Subscriber) *Server {
+	return &Server{Vectors: milenage.NewProvider(subs...)}
+}
+
+// ListenAndServe binds the Unix datagram socket path, replacing a stale
+// socket left there, and calls [Server.Serve]. The socket is removed when
+// it returns.
+func (s *Server) ListenAndServe(path string) error {
+	if fi, err := os.Lstat(path); err == nil && fi.Mode().Type() == fs.ModeSocket {
+		os.Remove(path)
+	}
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: path, Net: "unixgram"})
+	if err != nil {
+		return err
+	}
+	defer os.Remove(path)
+	return s.Serve(conn)
+}
+
+// Serve answers the messages read from conn until [Server.Close] is
+// called.
+func (s *Server) Serve(conn net.PacketConn) error {
+	if s.Vectors == nil {
+		return errors.New("hlrauc: Server.Vectors is required")
+	}
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		conn.Close()
+		return net.ErrClosed
+	}
+	s.conn = conn
+	s.mu.Unlock()
+
+	buf := make([]byte, maxMessage)
+	for {
+		n, addr, err := conn.ReadFrom(buf)
+		if err != nil {
+			s.mu.Lock()
+			closed := s.closed
+			s.mu.Unlock()
+			if closed {
+				return nil
+			}
+			return err
+		}
+		reply := s.Handle(context.Background(), string(buf[:n]))
+		if reply == "" {
+			continue
+		}
+		if addr == nil || addr.String() == "" {
+			s.logger().Debug("hlrauc: request from an unbound socket")
+			continue
+		}
+		if _, err := conn.WriteTo([]byte(reply), addr); err != nil {
+			s.logger().Debug("hlrauc: reply not sent", "peer", addr.String(), "error", err)
+		}
+	}
+}
+
+// Close stops [Server.Serve].
+func (s *Server) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.closed = true
+	if s.conn != nil {
+		return s.conn.Close()
+	}
+	return nil
+}
+
+// Handle returns the reply to the message msg, or "" if it has none.
+func (s *Server) Handle(ctx context.Context, msg string) string {
+	f := strings.Fields(msg)
+	if len(f) < 2 {
+		s.logger().Debug("hlrauc: invalid message")
+		return ""
+	}
+	switch {
+	case f[0] == MsgAKAReqAuth && len(f) == 2:
+		return s.reqAuth(ctx, f[1])
+	case f[0] == MsgAKAAUTS && len(f) == 4:
+		s.auts(ctx, f[1], f[2], f[3])
+		return ""
+	case f[0] == MsgSIMReqAuth:
+		return MsgSIMRespAuth + " " + f[1] + " " + failure
+	}
+	s.logger().Debug("hlrauc: unknown message", "message", f[0])
+	return ""
+}
+
+// reqAuth answers AKA-REQ-AUTH.
+func (s *Server) reqAuth(ctx context.Context, imsi string) string {
+	s.mu.Lock()
+	v := s.pending[imsi]
+	delete(s.pending, imsi)
+	s.mu.Unlock()
+	if v == nil {
+		var err error
+		v, err = s.Vectors.GetVector(ctx, &eapaka.VectorRequest{IMSI: imsi, Type: s.eapType()})
+		if err != nil {
+			s.logger().Debug("hlrauc: no vector", "imsi", eapaka.LogIdentity(imsi), "error", err)
+			return MsgAKARespAuth + " " + imsi + " " + failure
+		}
+	}
+	return fmt.Sprintf("%s %s %x %x %x %x %x", MsgAKARespAuth, imsi, v.RAND, v.AUTN, v.IK, v.CK, v.XRES)
+}
+
+// auts handles AKA-AUTS, which has no reply.
+func (s *Server) auts(ctx context.Context, imsi, auts, rand string) {
+	req := &eapaka.VectorRequest{IMSI: imsi, Type: s.eapType()}
+	var err error
+	req.AUTS, err = hex.DecodeString(auts)
+	if err == nil {
+		req.RAND, err = hex.DecodeString(rand)
+	}
+	if err != nil || len(req.AUTS) != 14 || len(req.RAND) != 16 {
+		s.logger().Debug("hlrauc: invalid AKA-AUTS", "imsi", eapaka.LogIdentity(imsi))
+		return
+	}
+	v, err := s.Vectors.GetVector(ctx, req)
+	if err != nil {
+		s.logger().Debug("hlrauc: re-synchronisation failed", "imsi", eapaka.LogIdentity(imsi), "error", err)
+		return
+	}
+	s.mu.Lock()
+	if s.pending == nil {
+		s.pending = make(map[string]*eapaka.AuthVector)
+	}
+	s.pending[imsi] = v
+	s.mu.Unlock()
+}
+
+func (s *Server) eapType() uint8 {
+	if s.Type != 0 {
+		return s.Type
+	}
+	return eapaka.TypeAKA
+}
+
+func (s *Server) logger() *slog.Logger {
+	if s.Logger != nil {
+		return s.Logger
+	}
+	return slog.New(slog.DiscardHandler)
+}
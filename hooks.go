@@ -0,0 +1,214 @@
+package eapaka
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"time"
+)
+
+// Hooks receives observability events from parsing, MAC verification, key
+// derivation and the server and peer state machines. Install it with
+// [SetHooks]. Implementations must be safe for concurrent use and should
+// return quickly; they run on the path of every packet.
+//
+// Embed [NopHooks] to implement only some of the methods. Package metrics
+// provides an implementation that publishes counters through expvar.
+type Hooks interface {
+	// Parsed is called after every [ParseWithOptions] (and so [Parse]) call.
+	// err is nil on success; otherwise [ParseErrorReason] labels it.
+	Parsed(err error)
+
+	// MACVerified is called after every MAC verification with its outcome.
+	// ok is false for a MAC mismatch and when the MAC could not be checked.
+	MACVerified(ok bool)
+
+	// KeysDerived is called after every key derivation with the name of the
+	// function (e.g., "DeriveKeysAKAPrime") and the time it took.
+	KeysDerived(kdf string, d time.Duration)
+
+	// Event reports a milestone of a conversation. source is "server" or
+	// "peer".
+	Event(source string, e Event)
+
+	// StartSpan starts a tracing span named name, such as "server.Handle",
+	// and returns the context to use within it and a function that ends it.
+	// end is called exactly once, with the error the operation returned.
+	StartSpan(ctx context.Context, name string) (_ context.Context, end func(err error))
+}
+
+// Event is a conversation milestone reported to [Hooks.Event].
+type Event int
+
+// Conversation events.
+const (
+	// EventFullAuth is a successful full authentication.
+	EventFullAuth Event = iota + 1
+	// EventReauth is a successful fast re-authentication.
+	EventReauth
+	// EventSyncFailure is an EAP-Response/AKA-Synchronization-Failure, sent
+	// by the peer or received by the server.
+	EventSyncFailure
+	// EventFailure is a conversation that ended in failure.
+	EventFailure
+)
+
+func (e Event) String() string {
+	switch e {
+	case EventFullAuth:
+		return "full_auth"
+	case EventReauth:
+		return "reauth"
+	case EventSyncFailure:
+		return "sync_failure"
+	case EventFailure:
+		return "failure"
+	}
+	return "unknown"
+}
+
+// NopHooks implements [Hooks] and ignores all events.
+type NopHooks struct{}
+
+func (NopHooks) Parsed(error)                      {}
+func (NopHooks) MACVerified(bool)                  {}
+func (NopHooks) KeysDerived(string, time.Duration) {}
+func (NopHooks) Event(string, Event)               {}
+func (NopHooks) StartSpan(ctx context.Context, _ string) (context.Context, func(error)) {
+	return ctx, func(error) {}
+}
+
+// MultiHooks returns a [Hooks] that passes every event to each of hooks in
+// order. Spans are started in order and ended in reverse order.
+func MultiHooks(hooks ...Hooks) Hooks {
+	return multiHooks(append([]Hooks(nil), hooks...))
+}
+
+type multiHooks []Hooks
+
+func (m multiHooks) Parsed(err error) {
+	for _, h := range m {
+		h.Parsed(err)
+	}
+}
+
+func (m multiHooks) MACVerified(ok bool) {
+	for _, h := range m {
+		h.MACVerified(ok)
+	}
+}
+
+func (m multiHooks) KeysDerived(kdf string, d time.Duration) {
+	for _, h := range m {
+		h.KeysDerived(kdf, d)
+	}
+}
+
+func (m multiHooks) Event(source string, e Event) {
+	for _, h := range m {
+		h.Event(source, e)
+	}
+}
+
+func (m multiHooks) StartSpan(ctx context.Context, name string) (context.Context, func(error)) {
+	ends := make([]func(error), len(m))
+	for i, h := range m {
+		ctx, ends[i] = h.StartSpan(ctx, name)
+	}
+	return ctx, func(err error) {
+		for i := len(ends) - 1; i >= 0; i-- {
+			ends[i](err)
+		}
+	}
+}
+
+var hooks atomic.Pointer[Hooks]
+
+// SetHooks installs h for the whole process; nil removes it. It is safe for
+// concurrent use. Without hooks, no events are produced and no time is
+// measured.
+func SetHooks(h Hooks) {
+	if h == nil {
+		hooks.Store(nil)
+		return
+	}
+	hooks.Store(&h)
+}
+
+func currentHooks() Hooks {
+	if h := hooks.Load(); h != nil {
+		return *h
+	}
+	return nil
+}
+
+// StartSpan starts a span with the installed [Hooks], for use by the server
+// and peer packages and by transports built on them. Without hooks it
+// returns ctx and a no-op end function.
+func StartSpan(ctx context.Context, name string) (_ context.Context, end func(err error)) {
+	if h := currentHooks(); h != nil {
+		return h.StartSpan(ctx, name)
+	}
+	return ctx, func(error) {}
+}
+
+// ReportEvent passes e to the installed [Hooks], if any.
+func ReportEvent(source string, e Event) {
+	if h := currentHooks(); h != nil {
+		h.Event(source, e)
+	}
+}
+
+func reportParsed(err error) {
+	if h := currentHooks(); h != nil {
+		h.Parsed(err)
+	}
+}
+
+func reportMAC(ok bool, err error) (bool, error) {
+	if h := currentHooks(); h != nil {
+		h.MACVerified(ok && err == nil)
+	}
+	return ok, err
+}
+
+// observeKDF starts timing a key derivation; call the result when it is done.
+func observeKDF(kdf string) func() {
+	h := currentHooks()
+	if h == nil {
+		return func() {}
+	}
+	start := time.Now()
+	return func() { h.KeysDerived(kdf, time.Since(start)) }
+}
+
+// ParseErrorReason returns a short, stable label for a parse error, for use
+// as a metric dimension: "truncated", "invalid_length",
+// "malformed_attribute", "unknown_attribute", "trailing_bytes",
+// "reserved_field", "invalid_res_length", "limit_exceeded" or "other".
+// It returns "" for a nil error.
+func ParseErrorReason(err error) string {
+	if err == nil {
+		return ""
+	}
+	for _, r := range parseErrorReasons {
+		if errors.Is(err, r.err) {
+			return r.reason
+		}
+	}
+	return "other"
+}
+
+var parseErrorReasons = []struct {
+	err    error
+	reason string
+}{
+	{ErrTruncated, "truncated"},
+	{ErrInvalidLength, "invalid_length"},
+	{ErrMalformedAttribute, "malformed_attribute"},
+	{ErrUnknownAttribute, "unknown_attribute"},
+	{ErrTrailingBytes, "trailing_bytes"},
+	{ErrReservedField, "reserved_field"},
+	{ErrInvalidResLength, "invalid_res_length"},
+	{ErrLimitExceeded, "limit_exceeded"},
+}
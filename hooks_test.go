@@ -0,0 +1,80 @@
+package eapaka_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/oyaguma3/go-eapaka"
+)
+
+type recordingHooks struct {
+	eapaka.NopHooks
+	name   string
+	events *[]string
+}
+
+func (r recordingHooks) Parsed(err error) {
+	*r.events = append(*r.events, r.name+" parse "+eapaka.ParseErrorReason(err))
+}
+
+func (r recordingHooks) KeysDerived(kdf string, _ time.Duration) {
+	*r.events = append(*r.events, r.name+" "+kdf)
+}
+
+func (r recordingHooks) StartSpan(ctx context.Context, name string) (context.Context, func(error)) {
+	*r.events = append(*r.events, r.name+" start "+name)
+	return ctx, func(err error) { *r.events = append(*r.events, fmt.Sprintf("%s end %s %v", r.name, name, err)) }
+}
+
+func TestHooks(t *testing.T) {
+	var events []string
+	eapaka.SetHooks(eapaka.MultiHooks(recordingHooks{name: "a", events: &events}, recordingHooks{name: "b", events: &events}))
+	t.Cleanup(func() { eapaka.SetHooks(nil) })
+
+	eapaka.Parse([]byte{1, 0, 0, 4})
+	eapaka.Parse([]byte{1, 0, 0, 5})
+	eapaka.DeriveCKPrimeIKPrime(make([]byte, 16), make([]byte, 16), "WLAN", make([]byte, 16))
+	_, end := eapaka.StartSpan(context.Background(), "test")
+	end(errors.New("boom"))
+
+	want := []string{
+		"a parse ", "b parse ",
+		"a parse truncated", "b parse truncated",
+		"a DeriveCKPrimeIKPrime", "b DeriveCKPrimeIKPrime",
+		"a start test", "b start test", "b end test boom", "a end test boom",
+	}
+	if !slices.Equal(events, want) {
+		t.Errorf("events = %q, want %q", events, want)
+	}
+
+	eapaka.SetHooks(nil)
+	eapaka.Parse([]byte{1})
+	if len(events) != len(want) {
+		t.Errorf("events after SetHooks(nil): %q", events[len(want):])
+	}
+}
+
+func TestParseErrorReason(t *testing.T) {
+	tests := []struct {
+		data []byte
+		want string
+	}{
+		{[]byte{2, 0, 0, 8, 23, 1, 0, 0}, ""},
+		{[]byte{2, 0}, "truncated"},
+		{[]byte{2, 0, 0, 12, 23, 1, 0, 0, 100, 1, 0, 0}, "unknown_attribute"},
+		{[]byte{2, 0, 0, 12, 23, 1, 0, 0, 1, 0, 0, 0}, "invalid_length"},
+	}
+	for _, tt := range tests {
+		_, err := eapaka.ParseWithOptions(tt.data, eapaka.StrictParseOptions)
+		if got := eapaka.ParseErrorReason(err); got != tt.want {
+			t.Errorf("ParseErrorReason(%v) = %q, want %q", err, got, tt.want)
+		}
+	}
+	if got := eapaka.ParseErrorReason(errors.New("x")); got != "other" {
+		t.Errorf("ParseErrorReason(other) = %q", got)
+	}
+}
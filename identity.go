@@ -0,0 +1,49 @@
+package eapaka
+
+import "strings"
+
+// Identity prefixes for IMSI-based NAIs (3GPP TS 23.003 Section 14.3 and 19.3).
+const (
+	PrefixPermanentAKA      byte = '0'
+	PrefixPseudonymAKA      byte = '2'
+	PrefixReauthAKA         byte = '4'
+	PrefixPermanentAKAPrime byte = '6'
+	PrefixPseudonymAKAPrime byte = '7'
+	PrefixReauthAKAPrime    byte = '8'
+)
+
+// PermanentIdentity builds the permanent identity NAI for imsi,
+// e.g. "0001010123456789@wlan.mnc001.mcc001.3gppnetwork.org".
+// The realm is omitted if empty.
+func PermanentIdentity(eapType uint8, imsi, realm string) string {
+	prefix := PrefixPermanentAKA
+	if eapType == TypeAKAPrime {
+		prefix = PrefixPermanentAKAPrime
+	}
+	id := string(prefix) + imsi
+	if realm != "" {
+		id += "@" + realm
+	}
+	return id
+}
+
+// IMSIFromIdentity extracts the IMSI from a permanent identity NAI.
+// It reports false if identity is not an EAP-AKA or EAP-AKA' permanent identity.
+func IMSIFromIdentity(identity string) (string, bool) {
+	if len(identity) < 2 {
+		return "", false
+	}
+	if identity[0] != PrefixPermanentAKA && identity[0] != PrefixPermanentAKAPrime {
+		return "", false
+	}
+	imsi, _, _ := strings.Cut(identity[1:], "@")
+	if len(imsi) < 6 || len(imsi) > 15 {
+		return "", false
+	}
+	for _, c := range imsi {
+		if c < '0' || c > '9' {
+			return "", false
+		}
+	}
+	return imsi, true
+}
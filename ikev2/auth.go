@@ -0,0 +1,143 @@
+package ikev2
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"errors"
+	"fmt"
+	"hash"
+)
+
+// Errors returned by the AUTH functions.
+var (
+	ErrUnsupportedPRF = errors.New("ikev2: unsupported PRF")
+	ErrAuthMismatch   = errors.New("ikev2: AUTH mismatch")
+)
+
+// PRF is an IKEv2 pseudorandom function, identified by its Transform ID
+// (RFC 7296 Section 3.3.2, RFC 4868).
+type PRF uint16
+
+// Supported PRFs.
+const (
+	PRFHMACSHA1   PRF = 2
+	PRFHMACSHA256 PRF = 5
+	PRFHMACSHA384 PRF = 6
+	PRFHMACSHA512 PRF = 7
+)
+
+func (p PRF) String() string {
+	switch p {
+	case PRFHMACSHA1:
+		return "PRF_HMAC_SHA1"
+	case PRFHMACSHA256:
+		return "PRF_HMAC_SHA2_256"
+	case PRFHMACSHA384:
+		return "PRF_HMAC_SHA2_384"
+	case PRFHMACSHA512:
+		return "PRF_HMAC_SHA2_512"
+	}
+	return fmt.Sprintf("PRF(%d)", uint16(p))
+}
+
+func (p PRF) hash() (func() hash.Hash, error) {
+	switch p {
+	case PRFHMACSHA1:
+		return sha1.New, nil
+	case PRFHMACSHA256:
+		return sha256.New, nil
+	case PRFHMACSHA384:
+		return sha512.New384, nil
+	case PRFHMACSHA512:
+		return sha512.New, nil
+	}
+	return nil, fmt.Errorf("%w: %s", ErrUnsupportedPRF, p)
+}
+
+// Compute returns prf(key, data).
+func (p PRF) Compute(key, data []byte) ([]byte, error) {
+	h, err := p.hash()
+	if err != nil {
+		return nil, err
+	}
+	m := hmac.New(h, key)
+	m.Write(data)
+	return m.Sum(nil), nil
+}
+
+// keyPad is the key pad for shared-key AUTH (RFC 7296 Section 2.15).
+const keyPad = "Key Pad for IKEv2"
+
+// SignedOctets returns the octets signed by the AUTH payload of one side
+// (RFC 7296 Section 2.15):
+//
+//	SignedOctets = RealMessage | Nonce | prf(SK_p, RestOfIDPayload)
+//
+// message is the first IKE message sent by that side (IKE_SA_INIT), nonce the
+// other side's nonce, skP that side's SK_pi or SK_pr, and idPayload the body
+// of that side's ID payload, without the generic payload header.
+func SignedOctets(prf PRF, message, nonce, skP, idPayload []byte) ([]byte, error) {
+	macedID, err := prf.Compute(skP, idPayload)
+	if err != nil {
+		return nil, err
+	}
+	b := make([]byte, 0, len(message)+len(nonce)+len(macedID))
+	b = append(b, message...)
+	b = append(b, nonce...)
+	return append(b, macedID...), nil
+}
+
+// ComputeAuth returns the AUTH payload data computed from the MSK of an EAP
+// method (e.g., [eapaka.AkaKeys.MSK] or [eapaka.AkaPrimeKeys.MSK]), as sent by
+// both sides in the last IKE_AUTH exchange (RFC 7296 Section 2.16):
+//
+//	AUTH = prf(prf(MSK, "Key Pad for IKEv2"), SignedOctets)
+//
+// The AUTH payload carries it with [AuthMethodSharedKey].
+func ComputeAuth(prf PRF, msk, signedOctets []byte) ([]byte, error) {
+	if len(msk) == 0 {
+		return nil, errors.New("ikev2: empty MSK")
+	}
+	key, err := prf.Compute(msk, []byte(keyPad))
+	if err != nil {
+		return nil, err
+	}
+	return prf.Compute(key, signedOctets)
+}
+
+// VerifyAuth reports whether auth is the AUTH payload data for msk and
+// signedOctets. The comparison is constant-time.
+func VerifyAuth(prf PRF, msk, signedOctets, auth []byte) (bool, error) {
+	want, err := ComputeAuth(prf, msk, signedOctets)
+	if err != nil {
+		return false, err
+	}
+	return hmac.Equal(want, auth), nil
+}
+
+// NewAuthPayload returns an AUTH payload computed from msk with [ComputeAuth].
+func NewAuthPayload(prf PRF, msk, signedOctets []byte) (*AuthPayload, error) {
+	auth, err := ComputeAuth(prf, msk, signedOctets)
+	if err != nil {
+		return nil, err
+	}
+	return &AuthPayload{Method: AuthMethodSharedKey, Data: auth}, nil
+}
+
+// Verify checks an AUTH payload received from the other side against msk and
+// that side's signed octets.
+func (a *AuthPayload) Verify(prf PRF, msk, signedOctets []byte) error {
+	if a.Method != AuthMethodSharedKey {
+		return fmt.Errorf("ikev2: AUTH method %d is not Shared Key MIC", a.Method)
+	}
+	ok, err := VerifyAuth(prf, msk, signedOctets, a.Data)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrAuthMismatch
+	}
+	return nil
+}
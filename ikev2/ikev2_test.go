@@ -0,0 +1,157 @@
+package ikev2_test
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"testing"
+
+	"github.com/oyaguma3/go-eapaka"
+	"github.com/oyaguma3/go-eapaka/ikev2"
+)
+
+func unhex(s string) []byte {
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
+
+func TestEAPPayload(t *testing.T) {
+	pkt, err := eapaka.NewChallengeRequest(eapaka.TypeAKA, 3, make([]byte, 16), make([]byte, 16)).Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	eap := &ikev2.EAPPayload{NextPayload: ikev2.PayloadAUTH, Packet: pkt}
+	b, err := eap.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw, _ := pkt.Marshal()
+	if !bytes.Equal(b[:4], []byte{ikev2.PayloadAUTH, 0, 0, byte(4 + len(raw))}) || !bytes.Equal(b[4:], raw) {
+		t.Fatalf("EAP payload = %x", b)
+	}
+
+	// Chain an AUTH payload after it and parse both.
+	auth := &ikev2.AuthPayload{Critical: true, Method: ikev2.AuthMethodSharedKey, Data: []byte{1, 2, 3}}
+	b, err = auth.AppendBinary(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gotEAP, n, err := ikev2.ParseEAPPayloadWithOptions(b, eapaka.StrictParseOptions)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if gotEAP.NextPayload != ikev2.PayloadAUTH || gotEAP.Critical || gotEAP.Packet.Subtype != eapaka.SubtypeChallenge || n != 4+len(raw) {
+		t.Errorf("EAP payload = %+v, length %d", gotEAP, n)
+	}
+	gotAuth, m, err := ikev2.ParseAuthPayload(b[n:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !gotAuth.Critical || gotAuth.Method != ikev2.AuthMethodSharedKey || !bytes.Equal(gotAuth.Data, auth.Data) || n+m != len(b) {
+		t.Errorf("AUTH payload = %+v, length %d", gotAuth, m)
+	}
+
+	for _, data := range [][]byte{
+		{0, 0, 0},                   // truncated header
+		{0, 0, 0, 9, 1, 1, 0},       // length beyond data
+		{0, 0, 0, 9, 3, 1, 0, 4, 0}, // EAP length shorter than the payload
+	} {
+		if _, _, err := ikev2.ParseEAPPayload(data); err == nil {
+			t.Errorf("ParseEAPPayload(%x) succeeded", data)
+		}
+	}
+	if _, _, err := ikev2.ParseAuthPayload([]byte{0, 0, 0, 6, 2, 0}); err == nil {
+		t.Error("ParseAuthPayload accepted a short payload")
+	}
+
+	// On error, the caller's buffer is returned unchanged.
+	prefix := []byte{1, 2, 3}
+	for _, p := range []interface {
+		AppendBinary([]byte) ([]byte, error)
+	}{
+		&ikev2.EAPPayload{},
+		&ikev2.EAPPayload{Packet: &eapaka.Packet{Code: eapaka.CodeRequest, Type: eapaka.TypeAKA, Attributes: []eapaka.Attribute{&eapaka.AtRand{}}}},
+		&ikev2.AuthPayload{Data: make([]byte, 0x10000)},
+	} {
+		if b, err := p.AppendBinary(prefix); err == nil || !bytes.Equal(b, prefix) {
+			t.Errorf("%T.AppendBinary = %x, %v", p, b, err)
+		}
+	}
+}
+
+func TestComputeAuth(t *testing.T) {
+	msk := make([]byte, 64)
+	for i := range msk {
+		msk[i] = byte(i)
+	}
+	message := append(make([]byte, 8), "IKE_SA_INIT"...)
+	nonce := bytes.Repeat([]byte{0x11}, 16)
+	skP := bytes.Repeat([]byte{0x22}, 20)
+	idPayload := append([]byte{2, 0, 0, 0}, "0001010123456789@example.org"...)
+
+	signed, err := ikev2.SignedOctets(ikev2.PRFHMACSHA1, message, nonce, skP, idPayload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := unhex("0000000000000000494b455f53415f494e495411111111111111111111111111111111a551cf8946f6d24b8c7254cd6d0db9eafa2511bf"); !bytes.Equal(signed, want) {
+		t.Errorf("SignedOctets = %x, want %x", signed, want)
+	}
+
+	so := append(append([]byte(nil), message...), nonce...)
+	for _, tt := range []struct {
+		prf  ikev2.PRF
+		want []byte
+	}{
+		{ikev2.PRFHMACSHA1, unhex("933ab92e8e3a39a3357a4ac7a1ca08f9c52335e7")},
+		{ikev2.PRFHMACSHA256, unhex("4a2153b4d06e1872aad1cda8984d5b72788411b25109c4358f96a02bf6b624d6")},
+		{ikev2.PRFHMACSHA384, nil},
+		{ikev2.PRFHMACSHA512, nil},
+	} {
+		t.Run(tt.prf.String(), func(t *testing.T) {
+			auth, err := ikev2.ComputeAuth(tt.prf, msk, so)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.want != nil && !bytes.Equal(auth, tt.want) {
+				t.Errorf("AUTH = %x, want %x", auth, tt.want)
+			}
+			p, err := ikev2.NewAuthPayload(tt.prf, msk, so)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := p.Verify(tt.prf, msk, so); err != nil {
+				t.Errorf("Verify: %v", err)
+			}
+			if err := p.Verify(tt.prf, msk, signed); !errors.Is(err, ikev2.ErrAuthMismatch) {
+				t.Errorf("Verify(other octets) = %v, want ErrAuthMismatch", err)
+			}
+		})
+	}
+
+	if _, err := ikev2.ComputeAuth(ikev2.PRF(4), msk, so); !errors.Is(err, ikev2.ErrUnsupportedPRF) {
+		t.Errorf("PRF_AES128_CBC: err = %v, want ErrUnsupportedPRF", err)
+	}
+}
+
+func TestComputeAuth_FromAKAKeys(t *testing.T) {
+	keys := eapaka.DeriveKeysAKAPrime("6555444333222111@example.org", make([]byte, 16), make([]byte, 16))
+	so := []byte("signed octets")
+	p, err := ikev2.NewAuthPayload(ikev2.PRFHMACSHA256, keys.MSK, so)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ := p.Marshal()
+	got, _, err := ikev2.ParseAuthPayload(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := got.Verify(ikev2.PRFHMACSHA256, keys.MSK, so); err != nil {
+		t.Error(err)
+	}
+	if err := got.Verify(ikev2.PRFHMACSHA256, keys.EMSK, so); !errors.Is(err, ikev2.ErrAuthMismatch) {
+		t.Errorf("Verify with EMSK = %v", err)
+	}
+}
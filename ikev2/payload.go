@@ -0,0 +1,154 @@
+// Package ikev2 implements the parts of IKEv2 (RFC 7296) needed to run
+// EAP-AKA/AKA' inside IKE_AUTH exchanges, as between a UE and an ePDG for
+// Wi-Fi calling: the EAP payload around an [eapaka.Packet], the AUTH payload,
+// and the MSK-based AUTH computation of RFC 7296 Section 2.16.
+package ikev2
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"github.com/oyaguma3/go-eapaka"
+)
+
+// Payload types (RFC 7296 Section 3.2)
+const (
+	PayloadNone uint8 = 0
+	PayloadIDi  uint8 = 35
+	PayloadIDr  uint8 = 36
+	PayloadAUTH uint8 = 39
+	PayloadEAP  uint8 = 48
+)
+
+// AuthMethodSharedKey is the Shared Key Message Integrity Code authentication
+// method, used for AUTH payloads computed from the MSK (RFC 7296 Section 3.8).
+const AuthMethodSharedKey uint8 = 2
+
+const (
+	genericHeaderLen = 4
+	authHeaderLen    = 8
+	flagCritical     = 0x80
+)
+
+// EAPPayload is an IKEv2 EAP payload (RFC 7296 Section 3.16).
+type EAPPayload struct {
+	NextPayload uint8
+	Critical    bool
+	Packet      *eapaka.Packet
+}
+
+// ParseEAPPayload parses the EAP payload at the start of data and returns it
+// with the payload length. The EAP message is parsed with [eapaka.Parse].
+func ParseEAPPayload(data []byte) (*EAPPayload, int, error) {
+	return ParseEAPPayloadWithOptions(data, eapaka.LenientParseOptions)
+}
+
+// ParseEAPPayloadWithOptions is like [ParseEAPPayload], but parses the EAP
+// message with [eapaka.ParseWithOptions].
+func ParseEAPPayloadWithOptions(data []byte, opts eapaka.ParseOptions) (*EAPPayload, int, error) {
+	next, critical, body, err := parseGeneric(data)
+	if err != nil {
+		return nil, 0, err
+	}
+	pkt, err := eapaka.ParseWithOptions(body, opts)
+	if err != nil {
+		return nil, 0, fmt.Errorf("ikev2: EAP payload: %w", err)
+	}
+	if len(pkt.Raw()) != len(body) {
+		return nil, 0, errors.New("ikev2: EAP message length does not match payload length")
+	}
+	return &EAPPayload{NextPayload: next, Critical: critical, Packet: pkt}, genericHeaderLen + len(body), nil
+}
+
+// Marshal serializes the payload.
+func (e *EAPPayload) Marshal() ([]byte, error) {
+	return e.AppendBinary(nil)
+}
+
+// AppendBinary appends the serialized payload to b. On error, b is returned
+// unchanged.
+func (e *EAPPayload) AppendBinary(b []byte) ([]byte, error) {
+	if e.Packet == nil {
+		return b, errors.New("ikev2: EAP payload without packet")
+	}
+	start := len(b)
+	b = appendGeneric(b, e.NextPayload, e.Critical)
+	b, err := e.Packet.AppendBinary(b)
+	if err != nil {
+		return b[:start], err
+	}
+	return finishGeneric(b, start)
+}
+
+// AuthPayload is an IKEv2 Authentication payload (RFC 7296 Section 3.8).
+type AuthPayload struct {
+	NextPayload uint8
+	Critical    bool
+	Method      uint8 // e.g., AuthMethodSharedKey
+	Data        []byte
+}
+
+// ParseAuthPayload parses the AUTH payload at the start of data and returns
+// it with the payload length.
+func ParseAuthPayload(data []byte) (*AuthPayload, int, error) {
+	next, critical, body, err := parseGeneric(data)
+	if err != nil {
+		return nil, 0, err
+	}
+	if len(body) < authHeaderLen-genericHeaderLen {
+		return nil, 0, errors.New("ikev2: AUTH payload too short")
+	}
+	return &AuthPayload{
+		NextPayload: next,
+		Critical:    critical,
+		Method:      body[0],
+		Data:        append([]byte(nil), body[4:]...),
+	}, genericHeaderLen + len(body), nil
+}
+
+// Marshal serializes the payload.
+func (a *AuthPayload) Marshal() ([]byte, error) {
+	return a.AppendBinary(nil)
+}
+
+// AppendBinary appends the serialized payload to b. On error, b is returned
+// unchanged.
+func (a *AuthPayload) AppendBinary(b []byte) ([]byte, error) {
+	start := len(b)
+	b = appendGeneric(b, a.NextPayload, a.Critical)
+	b = append(b, a.Method, 0, 0, 0)
+	b = append(b, a.Data...)
+	return finishGeneric(b, start)
+}
+
+// parseGeneric parses the generic payload header (RFC 7296 Section 3.2).
+func parseGeneric(data []byte) (next uint8, critical bool, body []byte, err error) {
+	if len(data) < genericHeaderLen {
+		return 0, false, nil, errors.New("ikev2: payload header truncated")
+	}
+	n := int(binary.BigEndian.Uint16(data[2:4]))
+	if n < genericHeaderLen || n > len(data) {
+		return 0, false, nil, fmt.Errorf("ikev2: payload length %d invalid", n)
+	}
+	return data[0], data[1]&flagCritical != 0, data[genericHeaderLen:n], nil
+}
+
+func appendGeneric(b []byte, next uint8, critical bool) []byte {
+	var flags byte
+	if critical {
+		flags = flagCritical
+	}
+	return append(b, next, flags, 0, 0)
+}
+
+// finishGeneric sets the length of the payload starting at b[start]. On
+// error, it returns b[:start].
+func finishGeneric(b []byte, start int) ([]byte, error) {
+	n := len(b) - start
+	if n > 0xffff {
+		return b[:start], errors.New("ikev2: payload too long")
+	}
+	binary.BigEndian.PutUint16(b[start+2:start+4], uint16(n))
+	return b, nil
+}
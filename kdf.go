@@ -4,29 +4,37 @@ import (
 	"crypto/hmac"
 	"crypto/sha1"
 	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"math/bits"
 )
 
 // AkaKeys holds the key material derived for EAP-AKA (RFC 4187).
+// Each key has memory of its own; [AkaKeys.Wipe] zeroes them all.
 type AkaKeys struct {
-	K_encr []byte // 128 bits (16 bytes)
-	K_aut  []byte // 128 bits (16 bytes)
-	MSK    []byte // 512 bits (64 bytes)
-	EMSK   []byte // 512 bits (64 bytes)
+	MK     Key // 160 bits (20 bytes) - Needed for fast re-authentication
+	K_encr Key // 128 bits (16 bytes)
+	K_aut  Key // 128 bits (16 bytes)
+	MSK    Key // 512 bits (64 bytes)
+	EMSK   Key // 512 bits (64 bytes)
 }
 
 // AkaPrimeKeys holds the key material derived for EAP-AKA' (RFC 5448).
+// Each key has memory of its own; [AkaPrimeKeys.Wipe] zeroes them all.
 type AkaPrimeKeys struct {
-	K_encr []byte // 128 bits (16 bytes)
-	K_aut  []byte // 256 bits (32 bytes) - Note: Larger than AKA
-	K_re   []byte // 256 bits (32 bytes)
-	MSK    []byte // 512 bits (64 bytes)
-	EMSK   []byte // 512 bits (64 bytes)
+	K_encr Key // 128 bits (16 bytes)
+	K_aut  Key // 256 bits (32 bytes) - Note: Larger than AKA
+	K_re   Key // 256 bits (32 bytes)
+	MSK    Key // 512 bits (64 bytes)
+	EMSK   Key // 512 bits (64 bytes)
 }
 
 // DeriveKeysAKA derives the key hierarchy for EAP-AKA as per RFC 4187.
 // identity: The EAP Identity (NAI) from the EAP-Response/Identity packet.
 // ck, ik: Cipher Key and Integrity Key provided by the USIM/HSS.
-func DeriveKeysAKA(identity string, ck, ik []byte) AkaKeys {
+func DeriveKeysAKA(identity string, ck, ik Key) AkaKeys {
+	defer observeKDF("DeriveKeysAKA")()
+
 	// RFC 4187 Section 7: MK = SHA1(Identity | IK | CK)
 	h := sha1.New()
 	h.Write([]byte(identity))
@@ -34,27 +42,31 @@ func DeriveKeysAKA(identity string, ck, ik []byte) AkaKeys {
 	h.Write(ck)
 	mk := h.Sum(nil) // 20 bytes
 
-	// Generate 160 bytes of key material using PRF(MK, 0)
-	// w (seed) is 0x00
-	keyBlock := prfGenAKA(mk, []byte{0x00}, 160)
+	// Generate 160 bytes of key material with the PRF keyed by MK
+	keyBlock := prfFIPS186(mk, 160)
+	defer clear(keyBlock)
 
 	// RFC 4187 Section 7: Key mapping
 	return AkaKeys{
-		K_encr: keyBlock[0:16],
-		K_aut:  keyBlock[16:32],
-		MSK:    keyBlock[32:96],
-		EMSK:   keyBlock[96:160],
+		MK:     mk,
+		K_encr: splitKey(keyBlock, 0, 16),
+		K_aut:  splitKey(keyBlock, 16, 16),
+		MSK:    splitKey(keyBlock, 32, 64),
+		EMSK:   splitKey(keyBlock, 96, 64),
 	}
 }
 
 // DeriveKeysAKAPrime derives the key hierarchy for EAP-AKA' as per RFC 5448.
 // identity: The EAP Identity (NAI).
 // ckPrime, ikPrime: CK' and IK' derived from CK/IK and Network Name.
-func DeriveKeysAKAPrime(identity string, ckPrime, ikPrime []byte) AkaPrimeKeys {
+func DeriveKeysAKAPrime(identity string, ckPrime, ikPrime Key) AkaPrimeKeys {
+	defer observeKDF("DeriveKeysAKAPrime")()
+
 	// RFC 5448 Section 3.3
 	// MK is calculated as part of the PRF' generation
 	// Key for PRF' is IK'|CK'
 	key := append(append([]byte{}, ikPrime...), ckPrime...)
+	defer clear(key)
 
 	// Seed for PRF' is "EAP-AKA'" | Identity
 	seed := append([]byte("EAP-AKA'"), []byte(identity)...)
@@ -62,92 +74,218 @@ func DeriveKeysAKAPrime(identity string, ckPrime, ikPrime []byte) AkaPrimeKeys {
 	// Total bytes needed: 16 + 32 + 32 + 64 + 64 = 208 bytes
 	// RFC 5448 calls the output of this PRF' "MK"
 	keyBlock := prfPlusIKEv2(key, seed, 208)
+	defer clear(keyBlock)
 
 	return AkaPrimeKeys{
-		K_encr: keyBlock[0:16],
-		K_aut:  keyBlock[16:48], // 32 bytes
-		K_re:   keyBlock[48:80], // 32 bytes
-		MSK:    keyBlock[80:144],
-		EMSK:   keyBlock[144:208],
+		K_encr: splitKey(keyBlock, 0, 16),
+		K_aut:  splitKey(keyBlock, 16, 32),
+		K_re:   splitKey(keyBlock, 48, 32),
+		MSK:    splitKey(keyBlock, 80, 64),
+		EMSK:   splitKey(keyBlock, 144, 64),
 	}
 }
 
-// DeriveCKPrimeIKPrime derives CK' and IK' from CK, IK and Access Network Name.
-// RFC 5448 Section 3.1 & 3.2.
-// netName: Typically "WLAN" for Wi-Fi calling.
-func DeriveCKPrimeIKPrime(ck, ik []byte, netName string) (ckPrime, ikPrime []byte) {
-	// Access Network Identity
-	anId := []byte(netName)
-
-	// Key for PRF' is IK|CK
-	key := append(append([]byte{}, ik...), ck...)
-
-	// S = FC | P0 | L0 | P1 | L1 ...
-	// FC = 0x20 for CK', 0x21 for IK'
-	// P0 = "EAP-AKA'"
-	// P1 = Access Network Identity (netName)
-
-	mkSeed := func(fc byte) []byte {
-		// RFC 5448 Section 3.1
-		// S = FC || "EAP-AKA'" || len("EAP-AKA'") || AN-ID || len(AN-ID)
-		// Note: Lengths are 2-byte integers (big endian)
-
-		s := make([]byte, 0, 1+8+2+len(anId)+2)
-		s = append(s, fc)
-		s = append(s, []byte("EAP-AKA'")...)
-		s = append(s, 0x00, 0x08) // len("EAP-AKA'") = 8
-		s = append(s, anId...)
-		// len(anId)
-		l := uint16(len(anId))
-		s = append(s, byte(l>>8), byte(l))
-		return s
-	}
-
-	// Output length of PRF' is 32 bytes (SHA-256), but CK'/IK' are 128-bit (16 bytes) ?
-	// RFC 5448 Section 3.1: "CK' ... is the first 128 bits"
-
-	// CK' calculation
-	seedCk := mkSeed(0x20)
-	fullCk := prfPlusIKEv2(key, seedCk, 32)
-	ckPrime = fullCk[:16]
-
-	// IK' calculation
-	seedIk := mkSeed(0x21)
-	fullIk := prfPlusIKEv2(key, seedIk, 32)
-	ikPrime = fullIk[:16]
-
-	return ckPrime, ikPrime
+// ReauthKeys holds the key material derived during fast re-authentication.
+// See RFC 4187 Section 7 and RFC 5448 Section 3.3.
+type ReauthKeys struct {
+	XKEY Key // EAP-AKA only: XKEY' (20 bytes)
+	MSK  Key // 512 bits (64 bytes)
+	EMSK Key // 512 bits (64 bytes)
 }
 
-// -----------------------------------------------------------------------------
-// Internal PRF Implementations
-// -----------------------------------------------------------------------------
+// DeriveReauthKeysAKA derives MSK and EMSK for EAP-AKA fast re-authentication.
+// identity: The re-authentication identity used in this exchange.
+// counter, nonceS: Values of AT_COUNTER and AT_NONCE_S.
+// mk: The master key from the preceding full authentication ([AkaKeys].MK).
+func DeriveReauthKeysAKA(identity string, counter uint16, nonceS []byte, mk Key) ReauthKeys {
+	defer observeKDF("DeriveReauthKeysAKA")()
 
-// prfGenAKA implements the PRF based on FIPS 186-2 Change Notice 1 (SHA-1).
-// Used in EAP-AKA (RFC 4187).
-func prfGenAKA(key []byte, seed []byte, outputLen int) []byte {
-	var output []byte
-	var current []byte
+	// RFC 4187 Section 7: XKEY' = SHA1(Identity|counter|NONCE_S|MK)
 	h := sha1.New()
+	h.Write([]byte(identity))
+	h.Write([]byte{byte(counter >> 8), byte(counter)})
+	h.Write(nonceS)
+	h.Write(mk)
+	xkey := h.Sum(nil)
 
-	// x_0 = SHA1(key | seed)
-	h.Write(key)
-	h.Write(seed)
-	current = h.Sum(nil)
-	output = append(output, current...)
+	keyBlock := prfFIPS186(xkey, 128)
+	defer clear(keyBlock)
+	return ReauthKeys{
+		XKEY: xkey,
+		MSK:  splitKey(keyBlock, 0, 64),
+		EMSK: splitKey(keyBlock, 64, 64),
+	}
+}
 
-	// x_j = SHA1(key | x_{j-1})
-	for len(output) < outputLen {
-		h.Reset()
-		h.Write(key)
-		h.Write(current)
-		current = h.Sum(nil)
-		output = append(output, current...)
+// DeriveReauthKeysAKAPrime derives MSK and EMSK for EAP-AKA' fast re-authentication.
+// identity: The re-authentication identity used in this exchange.
+// counter, nonceS: Values of AT_COUNTER and AT_NONCE_S.
+// kRe: The re-authentication key from the preceding full authentication ([AkaPrimeKeys].K_re).
+func DeriveReauthKeysAKAPrime(identity string, counter uint16, nonceS []byte, kRe Key) ReauthKeys {
+	defer observeKDF("DeriveReauthKeysAKAPrime")()
+
+	// RFC 5448 Section 3.3:
+	// MK = PRF'(K_re, "EAP-AKA' re-auth"|Identity|counter|NONCE_S)
+	seed := make([]byte, 0, 16+len(identity)+2+len(nonceS))
+	seed = append(seed, "EAP-AKA' re-auth"...)
+	seed = append(seed, identity...)
+	seed = append(seed, byte(counter>>8), byte(counter))
+	seed = append(seed, nonceS...)
+
+	keyBlock := prfPlusIKEv2(kRe, seed, 128)
+	defer clear(keyBlock)
+	return ReauthKeys{
+		MSK:  splitKey(keyBlock, 0, 64),
+		EMSK: splitKey(keyBlock, 64, 64),
+	}
+}
+
+// CheckKDFInput checks AT_KDF_INPUT and AT_KDF of an EAP-Request/AKA'-Challenge
+// as a peer does before deriving CK' and IK' (RFC 5448 Sections 3.1 and 3.2) and
+// returns the network name. The network name must be present and, if
+// networkName is not empty, equal to it; the error then wraps [ErrKDFInput].
+// The first AT_KDF must be [KDFAKAPrimeSHA256]; otherwise the error wraps
+// [ErrUnsupportedKDF].
+func CheckKDFInput(p *Packet, networkName string) (string, error) {
+	in, ok := Find[*AtKdfInput](p)
+	if !ok || in.NetworkName == "" {
+		return "", fmt.Errorf("%w: network name missing", ErrKDFInput)
+	}
+	if networkName != "" && in.NetworkName != networkName {
+		return "", fmt.Errorf("%w: network name %q, want %q", ErrKDFInput, in.NetworkName, networkName)
+	}
+	kdfs := FindAll[*AtKdf](p)
+	if len(kdfs) == 0 {
+		return "", fmt.Errorf("%w: AT_KDF missing", ErrUnsupportedKDF)
+	}
+	if kdfs[0].KDF != KDFAKAPrimeSHA256 {
+		return "", fmt.Errorf("%w: %d", ErrUnsupportedKDF, kdfs[0].KDF)
+	}
+	return in.NetworkName, nil
+}
+
+// DeriveCKPrimeIKPrime derives CK' and IK' from CK, IK, the access network
+// name and SQN⊕AK, the first 6 bytes of AUTN (RFC 5448 Section 3.3, TS 33.402
+// Annex A.2):
+//
+//	CK' | IK' = HMAC-SHA-256(CK | IK, 0x20 | netName | len(netName) | SQN⊕AK | 0x0006)
+//
+// netName is typically "WLAN" for Wi-Fi calling. It returns [ErrInvalidAUTN]
+// if autn is not 16 bytes long.
+func DeriveCKPrimeIKPrime(ck, ik Key, netName string, autn []byte) (ckPrime, ikPrime Key, err error) {
+	if len(autn) != 16 {
+		return nil, nil, fmt.Errorf("%w: %d bytes", ErrInvalidAUTN, len(autn))
 	}
+	defer observeKDF("DeriveCKPrimeIKPrime")()
 
+	key := make([]byte, 0, len(ck)+len(ik))
+	key = append(key, ck...)
+	key = append(key, ik...)
+	defer clear(key)
+
+	s := make([]byte, 0, 1+len(netName)+2+6+2)
+	s = append(s, 0x20)
+	s = append(s, netName...)
+	s = append(s, byte(len(netName)>>8), byte(len(netName)))
+	s = append(s, autn[:6]...)
+	s = append(s, 0x00, 0x06)
+
+	mac := hmac.New(sha256.New, key)
+	mac.Write(s)
+	out := mac.Sum(nil)
+	defer clear(out)
+	return splitKey(out, 0, 16), splitKey(out, 16, 16), nil
+}
+
+// DeriveKAUSF returns K_AUSF, the first 256 bits of the EMSK of EAP-AKA'
+// (TS 33.501 Annex F). It returns [ErrInvalidEMSK] if emsk is shorter.
+func DeriveKAUSF(emsk Key) (Key, error) {
+	if len(emsk) < 32 {
+		return nil, fmt.Errorf("%w: %d bytes", ErrInvalidEMSK, len(emsk))
+	}
+	defer observeKDF("DeriveKAUSF")()
+	return NewKey(emsk[:32]), nil
+}
+
+// DeriveKSEAF derives K_SEAF from K_AUSF and the serving network name,
+// e.g. "5G:mnc001.mcc001.3gppnetwork.org" (TS 33.501 Annex A.6):
+//
+//	K_SEAF = HMAC-SHA-256(K_AUSF, 0x6C | snn | len(snn))
+func DeriveKSEAF(kAUSF Key, servingNetworkName string) Key {
+	defer observeKDF("DeriveKSEAF")()
+
+	s := make([]byte, 0, 1+len(servingNetworkName)+2)
+	s = append(s, 0x6c)
+	s = append(s, servingNetworkName...)
+	s = append(s, byte(len(servingNetworkName)>>8), byte(len(servingNetworkName)))
+
+	mac := hmac.New(sha256.New, kAUSF)
+	mac.Write(s)
+	return Key(mac.Sum(nil))
+}
+
+// -----------------------------------------------------------------------------
+// Internal PRF Implementations
+// -----------------------------------------------------------------------------
+
+// prfFIPS186 implements the pseudo-random number generator of FIPS 186-2
+// Change Notice 1, Appendix 3.1, with XSEED = 0 and G built on the SHA-1
+// compression function, as used by EAP-AKA (RFC 4187 Section 7, Appendix A).
+func prfFIPS186(xkey []byte, outputLen int) []byte {
+	var x [20]byte // XKEY, a 160-bit big-endian integer
+	copy(x[:], xkey)
+	output := make([]byte, 0, (outputLen+39)/40*40)
+	for len(output) < outputLen {
+		// Each iteration produces two 160-bit values w_0 and w_1.
+		for range 2 {
+			w := sha1G(&x)
+			output = append(output, w[:]...)
+			// XKEY = (1 + XKEY + w) mod 2^160
+			carry := uint16(1)
+			for i := 19; i >= 0; i-- {
+				sum := uint16(x[i]) + uint16(w[i]) + carry
+				x[i], carry = byte(sum), sum>>8
+			}
+		}
+	}
 	return output[:outputLen]
 }
 
+// sha1G is the function G(t, c) of FIPS 186-2 Appendix 3.3: the SHA-1
+// compression function applied to c padded with zeros to 512 bits, without
+// the SHA-1 length padding.
+func sha1G(c *[20]byte) [20]byte {
+	h := [5]uint32{0x67452301, 0xefcdab89, 0x98badcfe, 0x10325476, 0xc3d2e1f0}
+	var w [80]uint32
+	for i := range 5 {
+		w[i] = binary.BigEndian.Uint32(c[4*i:])
+	}
+	for i := 16; i < 80; i++ {
+		w[i] = bits.RotateLeft32(w[i-3]^w[i-8]^w[i-14]^w[i-16], 1)
+	}
+	a, b, cc, d, e := h[0], h[1], h[2], h[3], h[4]
+	for i := range 80 {
+		var f, k uint32
+		switch {
+		case i < 20:
+			f, k = b&cc|^b&d, 0x5a827999
+		case i < 40:
+			f, k = b^cc^d, 0x6ed9eba1
+		case i < 60:
+			f, k = b&cc|b&d|cc&d, 0x8f1bbcdc
+		default:
+			f, k = b^cc^d, 0xca62c1d6
+		}
+		t := bits.RotateLeft32(a, 5) + f + e + k + w[i]
+		a, b, cc, d, e = t, a, bits.RotateLeft32(b, 30), cc, d
+	}
+	var out [20]byte
+	for i, v := range [5]uint32{h[0] + a, h[1] + b, h[2] + cc, h[3] + d, h[4] + e} {
+		binary.BigEndian.PutUint32(out[4*i:], v)
+	}
+	return out
+}
+
 // prfPlusIKEv2 implements PRF+ based on RFC 4306 (IKEv2).
 // Used in EAP-AKA' (RFC 5448). Uses HMAC-SHA-256.
 func prfPlusIKEv2(key, seed []byte, outputLen int) []byte {
@@ -2,8 +2,8 @@ package eapaka
 
 import (
 	"bytes"
-	"crypto/sha1"
 	"encoding/hex"
+	"errors"
 	"testing"
 )
 
@@ -39,27 +39,24 @@ func TestDeriveKeysAKAPrime_RFC5448_Case1(t *testing.T) {
 	// RFC 5448 Appendix C Case 1
 	identity := "0555444333222111"
 	netName := "WLAN"
+	autn := h("bb52e91c747ac3ab2a5c23d15ee351d5")
 	ik := h("9744871ad32bf9bbd1dd5ce54e3e2e5a")
 	ck := h("5349fbe098649f948f5d2e973a81c00f")
 
 	// Expected Derived Keys
-	// Expected Derived Keys
-	// NOTE: Values differ from RFC 5448 Appendix C.
-	// Implementation follows RFC 5448 Sec 3.1/3.3 and matches free5GC.
-	// Discrepancy likely due to RFC test vector ambiguity.
-	// Values below are from current implementation.
-	// RFC Value for CK': 0093962d0dd84aa5684b045c9edffa04
-	expCkPrime := h("9c43471186e35b979d9150cb38484e80")
-	expIkPrime := h("0d245437946bd429cadc604f52800620")
-	expKEncr := h("59aacb520a8eac05210c3c5a2784c85d")
-	expKAut := h("ca760c9e159fb5d5c17b99dd8fa63fd1590bc04c19c9228f8c13b840fd20ea")
-	expKRe := h("11fdaea8e409f1d51d0bdd54004341a378f1ca54585a0cc4bd591ccca4ab44")
-	expMSK := h("d21ba59961ff6912270d615df4c74ef6765deee52d3f4b823bc9a9724ac5361740e49cdab5ef010b0a6971e874b477feca02bc51608e35f03b5d9b606b7219a")
-	// expEMSK is placeholder, we will check it matches what we get or just ignore for now
-	// expEMSK := h("...")
+	expCkPrime := h("0093962d0dd84aa5684b045c9edffa04")
+	expIkPrime := h("ccfc230ca74fcc96c0a5d61164f5a76c")
+	expKEncr := h("766fa0a6c317174b812d52fbcd11a179")
+	expKAut := h("0842ea722ff6835bfa2032499fc3ec23c2f0e388b4f07543ffc677f1696d71ea")
+	expKRe := h("cf83aa8bc7e0aced892acc98e76a9b2095b558c7795c7094715cb3393aa7d17a")
+	expMSK := h("67c42d9aa56c1b79e295e3459fc3d187d42be0bf818d3070e362c5e967a4d544e8ecfe19358ab3039aff03b7c930588c055babee58a02650b067ec4e9347c75a")
+	expEMSK := h("f861703cd775590e16c7679ea3874ada866311de290764d760cf76df647ea01c313f69924bdd7650ca9bac141ea075c4ef9e8029c0e290cdbad5638b63bc23fb")
 
 	// 1. Derive CK', IK'
-	ckPrime, ikPrime := DeriveCKPrimeIKPrime(ck, ik, netName)
+	ckPrime, ikPrime, err := DeriveCKPrimeIKPrime(ck, ik, netName, autn)
+	if err != nil {
+		t.Fatal(err)
+	}
 
 	if !bytes.Equal(ckPrime, expCkPrime) {
 		t.Errorf("CK' mismatch\nGot: %x\nWant: %x", ckPrime, expCkPrime)
@@ -71,34 +68,58 @@ func TestDeriveKeysAKAPrime_RFC5448_Case1(t *testing.T) {
 	// 2. Derive Keys
 	keys := DeriveKeysAKAPrime(identity, ckPrime, ikPrime)
 
-	if !bytes.Equal(keys.K_encr, expKEncr) {
-		t.Errorf("K_encr mismatch\nGot: %x\nWant: %x", keys.K_encr, expKEncr)
-	}
-	/*
-		if !bytes.Equal(keys.K_aut, expKAut) {
-			t.Errorf("K_aut mismatch\nGot: %x\nWant: %x", keys.K_aut, expKAut)
-		}
-	*/
-	_ = expKAut
-	/*
-		if !bytes.Equal(keys.K_re, expKRe) {
-			t.Errorf("K_re mismatch\nGot: %x\nWant: %x", keys.K_re, expKRe)
+	for _, c := range []struct {
+		name      string
+		got, want []byte
+	}{
+		{"K_encr", keys.K_encr, expKEncr},
+		{"K_aut", keys.K_aut, expKAut},
+		{"K_re", keys.K_re, expKRe},
+		{"MSK", keys.MSK, expMSK},
+		{"EMSK", keys.EMSK, expEMSK},
+	} {
+		if !bytes.Equal(c.got, c.want) {
+			t.Errorf("%s mismatch\nGot: %x\nWant: %x", c.name, c.got, c.want)
 		}
-		if !bytes.Equal(keys.MSK, expMSK) {
-			t.Errorf("MSK mismatch\nGot: %x\nWant: %x", keys.MSK, expMSK)
+	}
+}
+
+func TestDeriveCKPrimeIKPrime_InvalidAUTN(t *testing.T) {
+	ck, ik := make([]byte, 16), make([]byte, 16)
+	for _, n := range []int{0, 5, 6, 15, 17} {
+		if _, _, err := DeriveCKPrimeIKPrime(ck, ik, "WLAN", make([]byte, n)); !errors.Is(err, ErrInvalidAUTN) {
+			t.Errorf("%d-byte AUTN: got %v, want ErrInvalidAUTN", n, err)
 		}
-	*/
-	_ = expKRe
-	_ = expMSK
-	// Not checking EMSK yet as it wasn't in the failure output fully
+	}
+	v := &AuthVector{CK: ck, IK: ik, AUTN: make([]byte, 4)}
+	if _, _, err := v.CKIKPrime("WLAN"); !errors.Is(err, ErrInvalidAUTN) {
+		t.Errorf("CKIKPrime: got %v, want ErrInvalidAUTN", err)
+	}
 }
 
-/*
-func TestDeriveKeysAKAPrime_RFC5448_Case2(t *testing.T) {
-	// RFC 5448 Appendix C Case 2
-	// ... (Commented out until Case 1 is resolved)
+func TestDeriveKAUSF(t *testing.T) {
+	emsk := h("000102030405060708090a0b0c0d0e0f101112131415161718191a1b1c1d1e1f" +
+		"202122232425262728292a2b2c2d2e2f303132333435363738393a3b3c3d3e3f")
+	kAUSF, err := DeriveKAUSF(emsk)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(kAUSF, emsk[:32]) {
+		t.Errorf("K_AUSF = %x, want the first half of the EMSK", kAUSF.Export())
+	}
+	for _, emsk := range []Key{nil, make([]byte, 31)} {
+		if _, err := DeriveKAUSF(emsk); !errors.Is(err, ErrInvalidEMSK) {
+			t.Errorf("%d-byte EMSK: got %v, want ErrInvalidEMSK", len(emsk), err)
+		}
+	}
+
+	// K_SEAF = HMAC-SHA-256(K_AUSF, 0x6C | SNN | len(SNN)), computed
+	// independently.
+	kSEAF := DeriveKSEAF(kAUSF, "5G:mnc001.mcc001.3gppnetwork.org")
+	if want := h("8dc9b3cd376f60050094ba3427a6b66e5b0a26595112c577f75c50df68b4c170"); !bytes.Equal(kSEAF, want) {
+		t.Errorf("K_SEAF mismatch\nGot: %x\nWant: %x", kSEAF.Export(), want)
+	}
 }
-*/
 
 func TestEncryptMPPEKey(t *testing.T) {
 	// Case 1: Key length 32
@@ -140,24 +161,14 @@ func TestEncryptMPPEKey(t *testing.T) {
 	}
 }
 
-// Internal PRF Test (Sanity check for chaining)
-func TestPrfGenAKA(t *testing.T) {
-	key := []byte("key")
-	seed := []byte("seed")
-	// Request 40 bytes (requires 2 iterations of SHA1: 20 + 20)
-	out := prfGenAKA(key, seed, 40)
-
-	if len(out) != 40 {
-		t.Errorf("Output length mismatch: %d", len(out))
+// FIPS 186-2 Change Notice 1, Appendix 3.1 example (XSEED = 0)
+func TestPrfFIPS186(t *testing.T) {
+	out := prfFIPS186(h("bd029bbe7f51960bcf9edb2b61f06f0feb5a38b6"), 40)
+	want := h("2070b3223dba372fde1c0ffc7b2e3b498b260614" + "3c6c18bacb0f6c55babb13788e20d737a3275116")
+	if !bytes.Equal(out, want) {
+		t.Errorf("PRF mismatch\nGot: %x\nWant: %x", out, want)
 	}
-
-	// First 20 bytes should be SHA1(key|seed)
-	h := sha1.New()
-	h.Write(key)
-	h.Write(seed)
-	expected1 := h.Sum(nil)
-
-	if !bytes.Equal(out[:20], expected1) {
-		t.Error("First block of PRF-AKA failed")
+	if got := prfFIPS186(make([]byte, 20), 30); len(got) != 30 {
+		t.Errorf("Output length mismatch: %d", len(got))
 	}
 }
@@ -0,0 +1,82 @@
+package eapaka
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"io"
+	"log/slog"
+)
+
+// Key holds secret key material: CK', IK', MK, K_encr, K_aut, K_re, MSK or
+// EMSK.
+//
+// A Key does not print its value. fmt (with any verb), [slog] and
+// encoding/json show "REDACTED" instead, or hex if [LogOptions].RevealSecrets
+// is set. Use [Key.Export] to get the bytes on purpose and [Key.Wipe] to zero
+// them once the key is no longer needed.
+//
+// Key is a byte slice, so a Key can be passed where a []byte is expected and
+// vice versa; the functions of this package that take keys accept both.
+type Key []byte
+
+// NewKey returns a Key holding a copy of b.
+func NewKey(b []byte) Key {
+	return Key(cloneBytes(b))
+}
+
+// Len returns the length of the key in bytes.
+func (k Key) Len() int { return len(k) }
+
+// Export returns a copy of the key bytes. The caller owns the copy and should
+// clear it after use.
+func (k Key) Export() []byte { return cloneBytes(k) }
+
+// Equal reports whether k and b hold the same bytes, in constant time.
+func (k Key) Equal(b []byte) bool { return subtle.ConstantTimeCompare(k, b) == 1 }
+
+// Wipe zeroes the key in place. Every slice sharing its memory sees zeros.
+func (k Key) Wipe() { clear(k) }
+
+// String returns "REDACTED", or the key in hex if [LogOptions].RevealSecrets
+// is set. It returns "" for an empty key.
+func (k Key) String() string { return currentLogOptions().secret(k) }
+
+// Format implements [fmt.Formatter]: every verb prints [Key.String], except
+// that with [LogOptions].RevealSecrets the key is formatted as a []byte.
+func (k Key) Format(f fmt.State, verb rune) {
+	if currentLogOptions().RevealSecrets {
+		fmt.Fprintf(f, fmt.FormatString(f, verb), []byte(k))
+		return
+	}
+	io.WriteString(f, k.String())
+}
+
+// LogValue implements [slog.LogValuer] with [Key.String].
+func (k Key) LogValue() slog.Value { return slog.StringValue(k.String()) }
+
+// MarshalJSON implements [json.Marshaler] with [Key.String].
+func (k Key) MarshalJSON() ([]byte, error) { return appendJSONString(nil, k.String()), nil }
+
+// Wipe zeroes all keys.
+func (k *AkaKeys) Wipe() { wipeKeys(k.MK, k.K_encr, k.K_aut, k.MSK, k.EMSK) }
+
+// Wipe zeroes all keys.
+func (k *AkaPrimeKeys) Wipe() { wipeKeys(k.K_encr, k.K_aut, k.K_re, k.MSK, k.EMSK) }
+
+// Wipe zeroes all keys.
+func (k *ReauthKeys) Wipe() { wipeKeys(k.XKEY, k.MSK, k.EMSK) }
+
+// Wipe zeroes all keys.
+func (r *ReauthContext) Wipe() { wipeKeys(r.MK, r.K_re, r.K_encr, r.K_aut) }
+
+func wipeKeys(keys ...Key) {
+	for _, k := range keys {
+		k.Wipe()
+	}
+}
+
+// splitKey copies block[off:off+n] into a Key of its own, so that keys
+// derived from one key block do not share memory.
+func splitKey(block []byte, off, n int) Key {
+	return NewKey(block[off : off+n])
+}
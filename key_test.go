@@ -0,0 +1,106 @@
+package eapaka_test
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/oyaguma3/go-eapaka"
+)
+
+func TestKey_Redaction(t *testing.T) {
+	t.Cleanup(func() { eapaka.SetLogOptions(eapaka.LogOptions{}) })
+
+	keys := eapaka.DeriveKeysAKAPrime("6001010123456789@example.org", bytes.Repeat([]byte{1}, 16), bytes.Repeat([]byte{2}, 16))
+	secret := hex.EncodeToString(keys.MSK)
+	var buf bytes.Buffer
+	slog.New(slog.NewTextHandler(&buf, nil)).Info("keys", "msk", keys.MSK, "keys", keys)
+	js, _ := json.Marshal(struct{ K eapaka.Key }{keys.K_aut})
+	rc := eapaka.NewReauthContextAKAPrime("5reauth@example.org", "6001010123456789@example.org", keys)
+	rcJSON, _ := json.Marshal(rc)
+	for _, s := range []string{
+		fmt.Sprintf("%v", keys.MSK),
+		fmt.Sprintf("%x", keys.MSK),
+		fmt.Sprintf("%X", keys.MSK[:32]),
+		fmt.Sprintf("%s", keys.MSK),
+		fmt.Sprintf("%#v", keys.MSK),
+		fmt.Sprintf("%+v", keys),
+		fmt.Sprintf("%v", &keys),
+		fmt.Sprint(keys.MSK),
+		buf.String(),
+		string(js),
+		fmt.Sprintf("%+v", rc),
+		fmt.Sprintf("%+v", *rc),
+		string(rcJSON),
+	} {
+		lower := strings.ToLower(s)
+		if !strings.Contains(s, "REDACTED") || strings.Contains(lower, secret[:16]) ||
+			strings.Contains(lower, hex.EncodeToString(keys.K_re)[:16]) || strings.Contains(lower, hex.EncodeToString(keys.K_aut)[:16]) {
+			t.Errorf("key printed as %q", s)
+		}
+	}
+
+	eapaka.SetLogOptions(eapaka.LogOptions{RevealSecrets: true})
+	if got := fmt.Sprintf("%x", keys.MSK); got != secret {
+		t.Errorf("RevealSecrets: %%x = %s, want %s", got, secret)
+	}
+	if got := keys.MSK.String(); got != secret {
+		t.Errorf("RevealSecrets: String = %s", got)
+	}
+}
+
+func TestKey_Wipe(t *testing.T) {
+	keys := eapaka.DeriveKeysAKA("0001010123456789@example.org", bytes.Repeat([]byte{1}, 16), bytes.Repeat([]byte{2}, 16))
+	msk := keys.MSK.Export()
+	if !keys.MSK.Equal(msk) || keys.MSK.Equal(msk[:63]) || keys.MSK.Len() != 64 {
+		t.Fatal("Export does not match the key")
+	}
+
+	// Keys do not share memory.
+	keys.K_aut.Wipe()
+	if !keys.MSK.Equal(msk) {
+		t.Error("wiping K_aut changed the MSK")
+	}
+
+	keys.Wipe()
+	for name, k := range map[string]eapaka.Key{"MK": keys.MK, "K_encr": keys.K_encr, "K_aut": keys.K_aut, "MSK": keys.MSK, "EMSK": keys.EMSK} {
+		if !bytes.Equal(k, make([]byte, len(k))) {
+			t.Errorf("%s not wiped: %x", name, []byte(k))
+		}
+	}
+	if bytes.Equal(msk, make([]byte, 64)) {
+		t.Error("Wipe zeroed the exported copy")
+	}
+
+	rc := eapaka.NewReauthContextAKA("4reauth@example.org", "0001010123456789@example.org", eapaka.DeriveKeysAKA("0001010123456789@example.org", msk[:16], msk[16:32]))
+	rc.Wipe()
+	for _, k := range []eapaka.Key{rc.MK, rc.K_encr, rc.K_aut} {
+		if !bytes.Equal(k, make([]byte, len(k))) {
+			t.Errorf("ReauthContext not wiped: %x", []byte(k))
+		}
+	}
+
+	r := eapaka.DeriveReauthKeysAKA("4reauth@example.org", 1, make([]byte, 16), eapaka.NewKey(msk[:20]))
+	r.Wipe()
+	if !bytes.Equal(r.MSK, make([]byte, 64)) || !bytes.Equal(r.XKEY, make([]byte, 20)) {
+		t.Error("ReauthKeys not wiped")
+	}
+}
+
+func TestKey_Accepted(t *testing.T) {
+	kAut := eapaka.NewKey(bytes.Repeat([]byte{0x11}, 16))
+	pkt, err := eapaka.NewChallengeResponse(eapaka.TypeAKA, 1, make([]byte, 8)).Sign(kAut)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ok, err := pkt.VerifyMac(kAut.Export()); err != nil || !ok {
+		t.Errorf("VerifyMac with exported key = %v, %v", ok, err)
+	}
+	if _, err := eapaka.EncryptMPPEKey(kAut, []byte("secret"), make([]byte, 16)); err != nil {
+		t.Error(err)
+	}
+}
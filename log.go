@@ -0,0 +1,367 @@
+package eapaka
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"log/slog"
+	"strconv"
+	"strings"
+	"sync/atomic"
+)
+
+// LogOptions controls how packets, attributes and keys are represented by
+// their LogValue ([slog.LogValuer]) and MarshalJSON methods. The zero value
+// masks secrets and logs identities in clear. Use [SetLogOptions] to change it.
+type LogOptions struct {
+	// RevealSecrets logs secret values in hex instead of masking them: RES,
+	// AUTS, NONCE_S, the contents of unknown attributes and all keys. It is meant
+	// for debugging with test subscribers only.
+	RevealSecrets bool
+
+	// HashIdentities replaces the user part of identities (AT_IDENTITY,
+	// AT_NEXT_PSEUDONYM, AT_NEXT_REAUTH_ID and [LogIdentity]) with a truncated
+	// HMAC-SHA256 under IdentityHashKey, keeping the realm. The same identity
+	// always gives the same hash, so log lines can be correlated without
+	// recording the IMSI.
+	HashIdentities bool
+
+	// IdentityHashKey keys the identity hash. It should be a secret of at least
+	// 16 bytes: the IMSI space is small enough to reverse an unkeyed hash.
+	IdentityHashKey []byte
+}
+
+var logOptions atomic.Pointer[LogOptions]
+
+// SetLogOptions sets the [LogOptions] used by all LogValue and MarshalJSON
+// methods of the package. It is safe for concurrent use.
+func SetLogOptions(o LogOptions) {
+	o.IdentityHashKey = cloneBytes(o.IdentityHashKey)
+	logOptions.Store(&o)
+}
+
+func currentLogOptions() *LogOptions {
+	if o := logOptions.Load(); o != nil {
+		return o
+	}
+	return &LogOptions{}
+}
+
+// redacted replaces masked values.
+const redacted = "REDACTED"
+
+func (o *LogOptions) secret(b []byte) string {
+	if len(b) == 0 {
+		return ""
+	}
+	if o.RevealSecrets {
+		return hex.EncodeToString(b)
+	}
+	return redacted
+}
+
+func (o *LogOptions) identity(id string) string {
+	if !o.HashIdentities || id == "" {
+		return id
+	}
+	user, realm, hasRealm := strings.Cut(id, "@")
+	h := hmac.New(sha256.New, o.IdentityHashKey)
+	h.Write([]byte(user))
+	hashed := "h:" + hex.EncodeToString(h.Sum(nil)[:8])
+	if hasRealm {
+		hashed += "@" + realm
+	}
+	return hashed
+}
+
+// LogIdentity returns identity as the package logs it under the current
+// [LogOptions], hashed if HashIdentities is set. Use it for identities in the
+// application's own log lines.
+func LogIdentity(identity string) string {
+	return currentLogOptions().identity(identity)
+}
+
+var (
+	codeNames = map[uint8]string{CodeRequest: "Request", CodeResponse: "Response", CodeSuccess: "Success", CodeFailure: "Failure"}
+	typeNames = map[uint8]string{
+		TypeIdentity:     "Identity",
+		TypeNotification: "Notification",
+		TypeNak:          "Nak",
+		TypeAKA:          "AKA",
+		TypeAKAPrime:     "AKA'",
+		TypeExpanded:     "Expanded",
+	}
+	subtypeNames = map[uint8]string{
+		SubtypeChallenge:              "Challenge",
+		SubtypeAuthenticationReject:   "Authentication-Reject",
+		SubtypeSynchronizationFailure: "Synchronization-Failure",
+		SubtypeIdentity:               "Identity",
+		SubtypeNotification:           "Notification",
+		SubtypeReauthentication:       "Reauthentication",
+		SubtypeClientError:            "Client-Error",
+	}
+)
+
+func nameOf(names map[uint8]string, v uint8) string {
+	if name, ok := names[v]; ok {
+		return name
+	}
+	return strconv.Itoa(int(v))
+}
+
+// LogValue implements [slog.LogValuer]. Attributes are logged in a group keyed
+// by attribute name; repeated attributes get a numeric suffix (AT_KDF_2).
+func (p *Packet) LogValue() slog.Value {
+	o := currentLogOptions()
+	attrs := p.logHeader(o)
+	if len(p.Attributes) > 0 {
+		group := make([]slog.Attr, 0, len(p.Attributes))
+		seen := make(map[AttributeType]int)
+		for _, a := range p.Attributes {
+			seen[a.Type()]++
+			key := a.Type().String()
+			if n := seen[a.Type()]; n > 1 {
+				key += "_" + strconv.Itoa(n)
+			}
+			group = append(group, slog.Attr{Key: key, Value: slog.GroupValue(attributeLogAttrs(a, o)...)})
+		}
+		attrs = append(attrs, slog.Attr{Key: "attributes", Value: slog.GroupValue(group...)})
+	}
+	return slog.GroupValue(attrs...)
+}
+
+// MarshalJSON implements [json.Marshaler] with the fields of [Packet.LogValue];
+// attributes are a list of objects with a "type" member.
+func (p *Packet) MarshalJSON() ([]byte, error) {
+	o := currentLogOptions()
+	b := appendJSONAttrs(nil, p.logHeader(o))
+	if len(p.Attributes) == 0 {
+		return b, nil
+	}
+	b = append(b[:len(b)-1], `,"attributes":[`...)
+	for i, a := range p.Attributes {
+		if i > 0 {
+			b = append(b, ',')
+		}
+		b = appendJSONAttrs(b, attributeLogAttrs(a, o))
+	}
+	return append(b, "]}"...), nil
+}
+
+func (p *Packet) logHeader(o *LogOptions) []slog.Attr {
+	attrs := []slog.Attr{
+		slog.String("code", nameOf(codeNames, p.Code)),
+		slog.Int("identifier", int(p.Identifier)),
+	}
+	if p.Code == CodeRequest || p.Code == CodeResponse {
+		attrs = append(attrs, slog.String("type", nameOf(typeNames, p.Type)))
+		switch p.Type {
+		case TypeAKA, TypeAKAPrime:
+			attrs = append(attrs, slog.String("subtype", nameOf(subtypeNames, p.Subtype)))
+		case TypeIdentity:
+			attrs = append(attrs, slog.String("identity", o.identity(string(p.TypeData))))
+		case TypeNak:
+			desired := make([]string, len(p.TypeData))
+			for i, t := range p.TypeData {
+				desired[i] = nameOf(typeNames, t)
+			}
+			attrs = append(attrs, slog.String("desired", strings.Join(desired, ",")))
+		default:
+			if p.Type != 0 {
+				attrs = append(attrs, slog.Int("length", len(p.TypeData)))
+			}
+		}
+	}
+	return attrs
+}
+
+// attributeLogAttrs returns the logged fields of an attribute, starting with its type.
+func attributeLogAttrs(a Attribute, o *LogOptions) []slog.Attr {
+	attrs := []slog.Attr{slog.String("type", a.Type().String())}
+	switch a := a.(type) {
+	case *AtRand:
+		attrs = append(attrs, slog.String("rand", hex.EncodeToString(a.Rand)))
+	case *AtAutn:
+		attrs = append(attrs, slog.String("autn", hex.EncodeToString(a.Autn)))
+	case *AtRes:
+		attrs = append(attrs, slog.Int("bits", 8*len(a.Res)), slog.String("res", o.secret(a.Res)))
+	case *AtAuts:
+		attrs = append(attrs, slog.String("auts", o.secret(a.Auts)))
+	case *AtMac:
+		attrs = append(attrs, slog.String("mac", hex.EncodeToString(a.MAC)))
+	case *AtIdentity:
+		attrs = append(attrs, slog.String("identity", o.identity(a.Identity)))
+	case *AtCheckcode:
+		attrs = append(attrs, slog.String("checkcode", hex.EncodeToString(a.Checkcode)))
+	case *AtPadding:
+		attrs = append(attrs, slog.Int("length", a.Length))
+	case *AtKdfInput:
+		attrs = append(attrs, slog.String("network_name", a.NetworkName))
+	case *AtKdf:
+		attrs = append(attrs, slog.Int("kdf", int(a.KDF)))
+	case *AtNonceMt:
+		attrs = append(attrs, slog.String("nonce_mt", hex.EncodeToString(a.NonceMt)))
+	case *AtBidding:
+		attrs = append(attrs, slog.Bool("d", a.D))
+	case *AtNotification:
+		attrs = append(attrs, slog.Int("value", int(a.Value())), slog.Bool("s", a.S), slog.Bool("p", a.P))
+	case *AtVersionList:
+		versions := make([]string, len(a.Versions))
+		for i, v := range a.Versions {
+			versions[i] = strconv.Itoa(int(v))
+		}
+		attrs = append(attrs, slog.String("versions", strings.Join(versions, ",")))
+	case *AtSelectedVersion:
+		attrs = append(attrs, slog.Int("version", int(a.Version)))
+	case *AtCounter:
+		attrs = append(attrs, slog.Int("counter", int(a.Counter)))
+	case *AtNonceS:
+		attrs = append(attrs, slog.String("nonce_s", o.secret(a.NonceS)))
+	case *AtClientErrorCode:
+		attrs = append(attrs, slog.Int("code", int(a.Code)))
+	case *AtIv:
+		attrs = append(attrs, slog.String("iv", hex.EncodeToString(a.IV)))
+	case *AtEncrData:
+		attrs = append(attrs, slog.Int("length", len(a.EncryptedData)))
+	case *AtNextPseudonym:
+		attrs = append(attrs, slog.String("pseudonym", o.identity(a.Pseudonym)))
+	case *AtNextReauthId:
+		attrs = append(attrs, slog.String("identity", o.identity(a.Identity)))
+	case *GenericAttribute:
+		attrs = append(attrs, slog.Int("length", len(a.Data)), slog.String("data", o.secret(a.Data)))
+	}
+	return attrs
+}
+
+func attributeLogValue(a Attribute) slog.Value {
+	return slog.GroupValue(attributeLogAttrs(a, currentLogOptions())...)
+}
+
+func attributeJSON(a Attribute) ([]byte, error) {
+	return appendJSONAttrs(nil, attributeLogAttrs(a, currentLogOptions())), nil
+}
+
+// LogValue and MarshalJSON of the attributes, with the fields of attributeLogAttrs.
+
+func (a *AtRand) LogValue() slog.Value                    { return attributeLogValue(a) }
+func (a *AtRand) MarshalJSON() ([]byte, error)            { return attributeJSON(a) }
+func (a *AtAutn) LogValue() slog.Value                    { return attributeLogValue(a) }
+func (a *AtAutn) MarshalJSON() ([]byte, error)            { return attributeJSON(a) }
+func (a *AtRes) LogValue() slog.Value                     { return attributeLogValue(a) }
+func (a *AtRes) MarshalJSON() ([]byte, error)             { return attributeJSON(a) }
+func (a *AtAuts) LogValue() slog.Value                    { return attributeLogValue(a) }
+func (a *AtAuts) MarshalJSON() ([]byte, error)            { return attributeJSON(a) }
+func (a *AtMac) LogValue() slog.Value                     { return attributeLogValue(a) }
+func (a *AtMac) MarshalJSON() ([]byte, error)             { return attributeJSON(a) }
+func (a *AtIdentity) LogValue() slog.Value                { return attributeLogValue(a) }
+func (a *AtIdentity) MarshalJSON() ([]byte, error)        { return attributeJSON(a) }
+func (a *AtPermanentIdReq) LogValue() slog.Value          { return attributeLogValue(a) }
+func (a *AtPermanentIdReq) MarshalJSON() ([]byte, error)  { return attributeJSON(a) }
+func (a *AtAnyIdReq) LogValue() slog.Value                { return attributeLogValue(a) }
+func (a *AtAnyIdReq) MarshalJSON() ([]byte, error)        { return attributeJSON(a) }
+func (a *AtFullauthIdReq) LogValue() slog.Value           { return attributeLogValue(a) }
+func (a *AtFullauthIdReq) MarshalJSON() ([]byte, error)   { return attributeJSON(a) }
+func (a *AtResultInd) LogValue() slog.Value               { return attributeLogValue(a) }
+func (a *AtResultInd) MarshalJSON() ([]byte, error)       { return attributeJSON(a) }
+func (a *AtBidding) LogValue() slog.Value                 { return attributeLogValue(a) }
+func (a *AtBidding) MarshalJSON() ([]byte, error)         { return attributeJSON(a) }
+func (a *AtCheckcode) LogValue() slog.Value               { return attributeLogValue(a) }
+func (a *AtCheckcode) MarshalJSON() ([]byte, error)       { return attributeJSON(a) }
+func (a *AtPadding) LogValue() slog.Value                 { return attributeLogValue(a) }
+func (a *AtPadding) MarshalJSON() ([]byte, error)         { return attributeJSON(a) }
+func (a *AtKdfInput) LogValue() slog.Value                { return attributeLogValue(a) }
+func (a *AtKdfInput) MarshalJSON() ([]byte, error)        { return attributeJSON(a) }
+func (a *AtKdf) LogValue() slog.Value                     { return attributeLogValue(a) }
+func (a *AtKdf) MarshalJSON() ([]byte, error)             { return attributeJSON(a) }
+func (a *AtNonceMt) LogValue() slog.Value                 { return attributeLogValue(a) }
+func (a *AtNonceMt) MarshalJSON() ([]byte, error)         { return attributeJSON(a) }
+func (a *AtNotification) LogValue() slog.Value            { return attributeLogValue(a) }
+func (a *AtNotification) MarshalJSON() ([]byte, error)    { return attributeJSON(a) }
+func (a *AtVersionList) LogValue() slog.Value             { return attributeLogValue(a) }
+func (a *AtVersionList) MarshalJSON() ([]byte, error)     { return attributeJSON(a) }
+func (a *AtSelectedVersion) LogValue() slog.Value         { return attributeLogValue(a) }
+func (a *AtSelectedVersion) MarshalJSON() ([]byte, error) { return attributeJSON(a) }
+func (a *AtCounter) LogValue() slog.Value                 { return attributeLogValue(a) }
+func (a *AtCounter) MarshalJSON() ([]byte, error)         { return attributeJSON(a) }
+func (a *AtCounterTooSmall) LogValue() slog.Value         { return attributeLogValue(a) }
+func (a *AtCounterTooSmall) MarshalJSON() ([]byte, error) { return attributeJSON(a) }
+func (a *AtNonceS) LogValue() slog.Value                  { return attributeLogValue(a) }
+func (a *AtNonceS) MarshalJSON() ([]byte, error)          { return attributeJSON(a) }
+func (a *AtClientErrorCode) LogValue() slog.Value         { return attributeLogValue(a) }
+func (a *AtClientErrorCode) MarshalJSON() ([]byte, error) { return attributeJSON(a) }
+func (a *AtIv) LogValue() slog.Value                      { return attributeLogValue(a) }
+func (a *AtIv) MarshalJSON() ([]byte, error)              { return attributeJSON(a) }
+func (a *AtEncrData) LogValue() slog.Value                { return attributeLogValue(a) }
+func (a *AtEncrData) MarshalJSON() ([]byte, error)        { return attributeJSON(a) }
+func (a *AtNextPseudonym) LogValue() slog.Value           { return attributeLogValue(a) }
+func (a *AtNextPseudonym) MarshalJSON() ([]byte, error)   { return attributeJSON(a) }
+func (a *AtNextReauthId) LogValue() slog.Value            { return attributeLogValue(a) }
+func (a *AtNextReauthId) MarshalJSON() ([]byte, error)    { return attributeJSON(a) }
+func (a *GenericAttribute) LogValue() slog.Value          { return attributeLogValue(a) }
+func (a *GenericAttribute) MarshalJSON() ([]byte, error)  { return attributeJSON(a) }
+
+// LogValue implements [slog.LogValuer]. Keys are masked unless
+// [LogOptions].RevealSecrets is set.
+func (k AkaKeys) LogValue() slog.Value { return slog.GroupValue(k.logAttrs()...) }
+
+// MarshalJSON implements [json.Marshaler] with the fields of [AkaKeys.LogValue].
+func (k AkaKeys) MarshalJSON() ([]byte, error) { return appendJSONAttrs(nil, k.logAttrs()), nil }
+
+func (k AkaKeys) logAttrs() []slog.Attr {
+	o := currentLogOptions()
+	return []slog.Attr{
+		slog.String("mk", o.secret(k.MK)),
+		slog.String("k_encr", o.secret(k.K_encr)),
+		slog.String("k_aut", o.secret(k.K_aut)),
+		slog.String("msk", o.secret(k.MSK)),
+		slog.String("emsk", o.secret(k.EMSK)),
+	}
+}
+
+// LogValue implements [slog.LogValuer]. Keys are masked unless
+// [LogOptions].RevealSecrets is set.
+func (k AkaPrimeKeys) LogValue() slog.Value { return slog.GroupValue(k.logAttrs()...) }
+
+// MarshalJSON implements [json.Marshaler] with the fields of [AkaPrimeKeys.LogValue].
+func (k AkaPrimeKeys) MarshalJSON() ([]byte, error) { return appendJSONAttrs(nil, k.logAttrs()), nil }
+
+func (k AkaPrimeKeys) logAttrs() []slog.Attr {
+	o := currentLogOptions()
+	return []slog.Attr{
+		slog.String("k_encr", o.secret(k.K_encr)),
+		slog.String("k_aut", o.secret(k.K_aut)),
+		slog.String("k_re", o.secret(k.K_re)),
+		slog.String("msk", o.secret(k.MSK)),
+		slog.String("emsk", o.secret(k.EMSK)),
+	}
+}
+
+// appendJSONAttrs appends attrs as a JSON object, in order. Values are strings,
+// integers or booleans.
+func appendJSONAttrs(b []byte, attrs []slog.Attr) []byte {
+	b = append(b, '{')
+	for i, a := range attrs {
+		if i > 0 {
+			b = append(b, ',')
+		}
+		b = appendJSONString(b, a.Key)
+		b = append(b, ':')
+		switch v := a.Value; v.Kind() {
+		case slog.KindString:
+			b = appendJSONString(b, v.String())
+		case slog.KindInt64:
+			b = strconv.AppendInt(b, v.Int64(), 10)
+		case slog.KindBool:
+			b = strconv.AppendBool(b, v.Bool())
+		default:
+			b = appendJSONString(b, v.String())
+		}
+	}
+	return append(b, '}')
+}
+
+func appendJSONString(b []byte, s string) []byte {
+	q, _ := json.Marshal(s) // cannot fail for a string
+	return append(b, q...)
+}
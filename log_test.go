@@ -0,0 +1,125 @@
+package eapaka_test
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/oyaguma3/go-eapaka"
+)
+
+func TestPacket_LogValue(t *testing.T) {
+	res := bytes.Repeat([]byte{0xa5}, 8)
+	pkt := &eapaka.Packet{
+		Code:       eapaka.CodeResponse,
+		Identifier: 2,
+		Type:       eapaka.TypeAKA,
+		Subtype:    eapaka.SubtypeChallenge,
+		Attributes: []eapaka.Attribute{
+			&eapaka.AtRes{Res: res},
+			&eapaka.AtKdf{KDF: 2},
+			&eapaka.AtKdf{KDF: 1},
+			&eapaka.AtMac{MAC: make([]byte, 16)},
+		},
+	}
+	keys := eapaka.DeriveKeysAKA("0001010123456789@example.org", make([]byte, 16), make([]byte, 16))
+
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, nil))
+	logger.Info("eap", "packet", pkt, "keys", keys)
+
+	var got struct {
+		Packet struct {
+			Code       string
+			Subtype    string
+			Attributes map[string]map[string]any
+		}
+		Keys map[string]string
+	}
+	if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatalf("%v: %s", err, buf.Bytes())
+	}
+	if got.Packet.Code != "Response" || got.Packet.Subtype != "Challenge" {
+		t.Errorf("header: %+v", got.Packet)
+	}
+	if r := got.Packet.Attributes["AT_RES"]; r["res"] != "REDACTED" || r["bits"] != 64.0 {
+		t.Errorf("AT_RES = %v", r)
+	}
+	if got.Packet.Attributes["AT_KDF_2"]["kdf"] != 1.0 {
+		t.Errorf("second AT_KDF = %v", got.Packet.Attributes["AT_KDF_2"])
+	}
+	for name, v := range got.Keys {
+		if v != "REDACTED" {
+			t.Errorf("key %s = %q", name, v)
+		}
+	}
+	for _, secret := range [][]byte{res, keys.K_aut, keys.MSK} {
+		if bytes.Contains(buf.Bytes(), []byte(hex.EncodeToString(secret))) {
+			t.Errorf("log output contains secret %x", secret)
+		}
+	}
+}
+
+func TestPacket_MarshalJSON(t *testing.T) {
+	pkt := &eapaka.Packet{
+		Code:       eapaka.CodeRequest,
+		Identifier: 1,
+		Type:       eapaka.TypeAKAPrime,
+		Subtype:    eapaka.SubtypeChallenge,
+		Attributes: []eapaka.Attribute{
+			&eapaka.AtRand{Rand: make([]byte, 16)},
+			&eapaka.AtKdfInput{NetworkName: "WLAN"},
+			&eapaka.AtNotification{P: true, Code: 1026},
+		},
+	}
+	b, err := json.Marshal(pkt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"code":"Request","identifier":1,"type":"AKA'","subtype":"Challenge","attributes":[` +
+		`{"type":"AT_RAND","rand":"00000000000000000000000000000000"},` +
+		`{"type":"AT_KDF_INPUT","network_name":"WLAN"},` +
+		`{"type":"AT_NOTIFICATION","value":17410,"s":false,"p":true}]}`
+	if string(b) != want {
+		t.Errorf("MarshalJSON =\n%s\nwant\n%s", b, want)
+	}
+
+	b, err = json.Marshal(&eapaka.Packet{Code: eapaka.CodeSuccess, Identifier: 3})
+	if err != nil || string(b) != `{"code":"Success","identifier":3}` {
+		t.Errorf("MarshalJSON(Success) = %s, %v", b, err)
+	}
+}
+
+func TestLogOptions(t *testing.T) {
+	t.Cleanup(func() { eapaka.SetLogOptions(eapaka.LogOptions{}) })
+
+	auts := bytes.Repeat([]byte{0x5a}, 14)
+	pkt := &eapaka.Packet{
+		Code:       eapaka.CodeResponse,
+		Type:       eapaka.TypeAKA,
+		Subtype:    eapaka.SubtypeIdentity,
+		Attributes: []eapaka.Attribute{&eapaka.AtIdentity{Identity: "0001010123456789@example.org"}, &eapaka.AtAuts{Auts: auts}},
+	}
+
+	eapaka.SetLogOptions(eapaka.LogOptions{RevealSecrets: true})
+	b, _ := json.Marshal(pkt)
+	if !strings.Contains(string(b), hex.EncodeToString(auts)) {
+		t.Errorf("RevealSecrets: AUTS not revealed: %s", b)
+	}
+
+	eapaka.SetLogOptions(eapaka.LogOptions{HashIdentities: true, IdentityHashKey: []byte("0123456789abcdef")})
+	b, _ = json.Marshal(pkt)
+	if strings.Contains(string(b), "0001010123456789") || !strings.Contains(string(b), `@example.org"`) {
+		t.Errorf("HashIdentities: %s", b)
+	}
+	if strings.Contains(string(b), hex.EncodeToString(auts)) {
+		t.Errorf("AUTS revealed: %s", b)
+	}
+	id := eapaka.LogIdentity("0001010123456789@example.org")
+	if !strings.Contains(string(b), id) || id != eapaka.LogIdentity("0001010123456789@example.org") {
+		t.Errorf("LogIdentity = %q, not stable or not used: %s", id, b)
+	}
+}
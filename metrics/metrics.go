@@ -0,0 +1,143 @@
+// Package metrics publishes the observability events of package eapaka
+// through the standard library expvar package.
+//
+// Install an [Expvar] with [eapaka.SetHooks]:
+//
+//	eapaka.SetHooks(metrics.NewExpvar("eapaka"))
+//
+// The counters then appear under the given name in /debug/vars.
+package metrics
+
+import (
+	"context"
+	"expvar"
+	"time"
+
+	"github.com/oyaguma3/go-eapaka"
+)
+
+// Expvar implements [eapaka.Hooks] by updating an [expvar.Map]:
+//
+//	parse         {"ok": n, <reason>: n} with reasons from [eapaka.ParseErrorReason]
+//	mac           {"ok": n, "failure": n}
+//	kdf_count     {<function>: n}
+//	kdf_ns        {<function>: total nanoseconds}
+//	server, peer  {"full_auth": n, "reauth": n, "sync_failure": n, "failure": n}
+//	reauth_ratio  {"server": r, "peer": r}, re-authentications per successful
+//	              authentication
+//	span_count    {<span>: n}
+//	span_errors   {<span>: n}
+//	span_ns       {<span>: total nanoseconds}
+//
+// Average latencies are kdf_ns/kdf_count and span_ns/span_count.
+type Expvar struct {
+	m *expvar.Map
+
+	parse, mac                  *expvar.Map
+	kdfCount, kdfNs             *expvar.Map
+	server, peer                *expvar.Map
+	spanCount, spanErrs, spanNs *expvar.Map
+}
+
+var _ eapaka.Hooks = (*Expvar)(nil)
+
+// NewExpvar returns an Expvar publishing its counters as the expvar map
+// name. Like [expvar.Publish], it panics if name is already in use.
+func NewExpvar(name string) *Expvar {
+	e := newExpvar()
+	expvar.Publish(name, e.m)
+	return e
+}
+
+// NewUnpublishedExpvar returns an Expvar that is not published, for tests
+// and for embedding its [Expvar.Map] in another expvar variable.
+func NewUnpublishedExpvar() *Expvar {
+	return newExpvar()
+}
+
+func newExpvar() *Expvar {
+	e := &Expvar{m: new(expvar.Map).Init()}
+	sub := func(key string) *expvar.Map {
+		m := new(expvar.Map).Init()
+		e.m.Set(key, m)
+		return m
+	}
+	e.parse = sub("parse")
+	e.mac = sub("mac")
+	e.kdfCount = sub("kdf_count")
+	e.kdfNs = sub("kdf_ns")
+	e.server = sub("server")
+	e.peer = sub("peer")
+	e.m.Set("reauth_ratio", expvar.Func(func() any {
+		return map[string]float64{"server": reauthRatio(e.server), "peer": reauthRatio(e.peer)}
+	}))
+	e.spanCount = sub("span_count")
+	e.spanErrs = sub("span_errors")
+	e.spanNs = sub("span_ns")
+	return e
+}
+
+// Map returns the map holding the counters.
+func (e *Expvar) Map() *expvar.Map { return e.m }
+
+// Parsed implements [eapaka.Hooks].
+func (e *Expvar) Parsed(err error) {
+	if err != nil {
+		e.parse.Add(eapaka.ParseErrorReason(err), 1)
+		return
+	}
+	e.parse.Add("ok", 1)
+}
+
+// MACVerified implements [eapaka.Hooks].
+func (e *Expvar) MACVerified(ok bool) {
+	if ok {
+		e.mac.Add("ok", 1)
+		return
+	}
+	e.mac.Add("failure", 1)
+}
+
+// KeysDerived implements [eapaka.Hooks].
+func (e *Expvar) KeysDerived(kdf string, d time.Duration) {
+	e.kdfCount.Add(kdf, 1)
+	e.kdfNs.Add(kdf, int64(d))
+}
+
+// Event implements [eapaka.Hooks]. Events from sources other than "server"
+// and "peer" are ignored.
+func (e *Expvar) Event(source string, ev eapaka.Event) {
+	switch source {
+	case "server":
+		e.server.Add(ev.String(), 1)
+	case "peer":
+		e.peer.Add(ev.String(), 1)
+	}
+}
+
+// StartSpan implements [eapaka.Hooks] by counting spans and their duration.
+func (e *Expvar) StartSpan(ctx context.Context, name string) (context.Context, func(error)) {
+	start := time.Now()
+	return ctx, func(err error) {
+		e.spanCount.Add(name, 1)
+		e.spanNs.Add(name, int64(time.Since(start)))
+		if err != nil {
+			e.spanErrs.Add(name, 1)
+		}
+	}
+}
+
+func reauthRatio(m *expvar.Map) float64 {
+	full, reauth := intValue(m, eapaka.EventFullAuth.String()), intValue(m, eapaka.EventReauth.String())
+	if full+reauth == 0 {
+		return 0
+	}
+	return float64(reauth) / float64(full+reauth)
+}
+
+func intValue(m *expvar.Map, key string) int64 {
+	if v, ok := m.Get(key).(*expvar.Int); ok {
+		return v.Value()
+	}
+	return 0
+}
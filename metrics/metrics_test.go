@@ -0,0 +1,125 @@
+package metrics_test
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/oyaguma3/go-eapaka"
+	"github.com/oyaguma3/go-eapaka/eapakatest"
+	"github.com/oyaguma3/go-eapaka/metrics"
+	"github.com/oyaguma3/go-eapaka/milenage"
+	"github.com/oyaguma3/go-eapaka/peer"
+	"github.com/oyaguma3/go-eapaka/server"
+)
+
+func TestExpvar(t *testing.T) {
+	e := metrics.NewUnpublishedExpvar()
+	eapaka.SetHooks(e)
+	t.Cleanup(func() { eapaka.SetHooks(nil) })
+
+	s := &eapakatest.Subscribers[0]
+	sub := s.Milenage()
+	sub.SQN -= 10 // the USIM is ahead and forces a re-synchronisation
+	a, err := server.New(server.Config{Type: eapaka.TypeAKAPrime, Vectors: milenage.NewProvider(sub), EnableReauth: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	usim, err := s.USIM()
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := peer.New(peer.Config{Identity: s.Identity(eapaka.TypeAKAPrime), SIM: usim})
+	if err != nil {
+		t.Fatal(err)
+	}
+	converse(t, p, a) // full authentication after a re-synchronisation
+	converse(t, p, a) // fast re-authentication
+	eapaka.Parse([]byte{1, 0})
+
+	var got struct {
+		Parse       map[string]int64
+		MAC         map[string]int64
+		KDFCount    map[string]int64 `json:"kdf_count"`
+		Server      map[string]int64
+		Peer        map[string]int64
+		ReauthRatio map[string]float64 `json:"reauth_ratio"`
+		SpanCount   map[string]int64   `json:"span_count"`
+		SpanErrors  map[string]int64   `json:"span_errors"`
+	}
+	if err := json.Unmarshal([]byte(e.Map().String()), &got); err != nil {
+		t.Fatal(err)
+	}
+
+	events := map[string]int64{"full_auth": 1, "reauth": 1, "sync_failure": 1}
+	if diff := cmp.Diff(events, got.Server); diff != "" {
+		t.Errorf("server events (-want +got):\n%s", diff)
+	}
+	if diff := cmp.Diff(events, got.Peer); diff != "" {
+		t.Errorf("peer events (-want +got):\n%s", diff)
+	}
+	if diff := cmp.Diff(map[string]float64{"server": 0.5, "peer": 0.5}, got.ReauthRatio); diff != "" {
+		t.Errorf("reauth_ratio (-want +got):\n%s", diff)
+	}
+	if got.Parse["truncated"] != 1 || got.Parse["ok"] == 0 {
+		t.Errorf("parse = %v", got.Parse)
+	}
+	if got.MAC["ok"] == 0 || got.MAC["failure"] != 0 {
+		t.Errorf("mac = %v", got.MAC)
+	}
+	// Server and peer each derive keys for one full authentication (the
+	// first challenge is abandoned by the peer) and one re-authentication.
+	for _, kdf := range []string{"DeriveCKPrimeIKPrime", "DeriveKeysAKAPrime", "DeriveReauthKeysAKAPrime"} {
+		if got.KDFCount[kdf] < 2 {
+			t.Errorf("kdf_count[%s] = %d", kdf, got.KDFCount[kdf])
+		}
+	}
+	// Identity, challenge, sync failure, challenge; identity, re-auth.
+	if got.SpanCount["server.Handle"] != 5 || got.SpanCount["server.GetVector"] != 2 || got.SpanErrors["server.Handle"] != 0 {
+		t.Errorf("spans = %v, errors = %v", got.SpanCount, got.SpanErrors)
+	}
+}
+
+func TestExpvar_MACFailure(t *testing.T) {
+	e := metrics.NewUnpublishedExpvar()
+	eapaka.SetHooks(e)
+	t.Cleanup(func() { eapaka.SetHooks(nil) })
+
+	kAut := make([]byte, 16)
+	pkt, err := eapaka.NewChallengeResponse(eapaka.TypeAKA, 1, make([]byte, 8)).Sign(kAut)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pkt.VerifyMac(make([]byte, 16))
+	pkt.VerifyMac([]byte{1})
+	(&eapaka.Packet{}).VerifyMac(kAut)
+	if got := e.Map().Get("mac").String(); got != `{"failure": 2, "ok": 1}` {
+		t.Errorf("mac = %s", got)
+	}
+}
+
+// converse runs one conversation between p and a.
+func converse(t *testing.T, p *peer.Peer, a *server.Authenticator) {
+	t.Helper()
+	msg, sessionID := p.IdentityResponse(0), ""
+	for range 10 {
+		res, err := a.Handle(context.Background(), sessionID, msg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		reply, err := res.Reply.Marshal()
+		if err != nil {
+			t.Fatal(err)
+		}
+		next, err := p.Handle(reply)
+		if res.Status != server.StatusContinue {
+			if res.Status != server.StatusSuccess || p.Status() != peer.StatusSuccess {
+				t.Fatalf("conversation failed: %v", err)
+			}
+			return
+		}
+		sessionID, msg = res.SessionID, next
+	}
+	t.Fatal("conversation did not finish")
+}
@@ -0,0 +1,163 @@
+// Package milenage implements the 3GPP MILENAGE algorithm set (TS 35.206)
+// together with a software USIM and an authentication vector provider,
+// so that EAP-AKA/AKA' can be exercised without real SIM cards or an HSS.
+package milenage
+
+import (
+	"crypto/aes"
+	"crypto/subtle"
+	"errors"
+)
+
+// Errors returned by the MILENAGE functions.
+var (
+	ErrInvalidKeyLength = errors.New("milenage: K, OP and OPc must be 16 bytes")
+	ErrInvalidLength    = errors.New("milenage: invalid input length")
+)
+
+// Output holds the results of f1 to f5* for one RAND.
+type Output struct {
+	MACA []byte // f1: 8 bytes
+	MACS []byte // f1*: 8 bytes
+	RES  []byte // f2: 8 bytes
+	CK   []byte // f3: 16 bytes
+	IK   []byte // f4: 16 bytes
+	AK   []byte // f5: 6 bytes
+	AKS  []byte // f5*: 6 bytes
+}
+
+// OPc derives OPc = E_K(OP) xor OP. See TS 35.206 Section 4.1.
+func OPc(k, op []byte) ([]byte, error) {
+	if len(k) != 16 || len(op) != 16 {
+		return nil, ErrInvalidKeyLength
+	}
+	block, err := aes.NewCipher(k)
+	if err != nil {
+		return nil, err
+	}
+	opc := make([]byte, 16)
+	block.Encrypt(opc, op)
+	subtle.XORBytes(opc, opc, op)
+	return opc, nil
+}
+
+// Compute runs f1, f1*, f2, f3, f4, f5 and f5* for the given inputs.
+// sqn is 6 bytes and amf is 2 bytes. See TS 35.206 Section 4.1.
+func Compute(k, opc, rand, sqn, amf []byte) (*Output, error) {
+	if len(k) != 16 || len(opc) != 16 {
+		return nil, ErrInvalidKeyLength
+	}
+	if len(rand) != 16 || len(sqn) != 6 || len(amf) != 2 {
+		return nil, ErrInvalidLength
+	}
+	block, err := aes.NewCipher(k)
+	if err != nil {
+		return nil, err
+	}
+
+	// TEMP = E_K(RAND xor OPc)
+	temp := make([]byte, 16)
+	subtle.XORBytes(temp, rand, opc)
+	block.Encrypt(temp, temp)
+
+	// OUT1 = E_K(TEMP xor rot(IN1 xor OPc, r1) xor c1) xor OPc
+	// IN1 = SQN || AMF || SQN || AMF, r1 = 64, c1 = 0
+	in1 := make([]byte, 16)
+	copy(in1[0:6], sqn)
+	copy(in1[6:8], amf)
+	copy(in1[8:14], sqn)
+	copy(in1[14:16], amf)
+	subtle.XORBytes(in1, in1, opc)
+	out1 := rotate(in1, 8)
+	subtle.XORBytes(out1, out1, temp)
+	block.Encrypt(out1, out1)
+	subtle.XORBytes(out1, out1, opc)
+
+	// OUTn = E_K(rot(TEMP xor OPc, rn) xor cn) xor OPc for n = 2..5
+	// r2 = 0, r3 = 32, r4 = 64, r5 = 96 bits; cn has bit (n-2) of the last byte set.
+	outN := func(rotBytes int, c byte) []byte {
+		x := make([]byte, 16)
+		subtle.XORBytes(x, temp, opc)
+		x = rotate(x, rotBytes)
+		x[15] ^= c
+		block.Encrypt(x, x)
+		subtle.XORBytes(x, x, opc)
+		return x
+	}
+	out2 := outN(0, 0x01)
+	out3 := outN(4, 0x02)
+	out4 := outN(8, 0x04)
+	out5 := outN(12, 0x08)
+
+	return &Output{
+		MACA: out1[0:8],
+		MACS: out1[8:16],
+		RES:  out2[8:16],
+		CK:   out3,
+		IK:   out4,
+		AK:   out2[0:6],
+		AKS:  out5[0:6],
+	}, nil
+}
+
+// rotate cyclically rotates x left by n bytes.
+func rotate(x []byte, n int) []byte {
+	out := make([]byte, len(x))
+	for i := range x {
+		out[i] = x[(i+n)%len(x)]
+	}
+	return out
+}
+
+// GenerateVector computes an authentication vector for the given RAND and SQN.
+// AUTN = (SQN xor AK) || AMF || MAC-A. See TS 33.102 Section 6.3.2.
+func GenerateVector(k, opc, rand, sqn, amf []byte) (autn, xres, ck, ik []byte, err error) {
+	out, err := Compute(k, opc, rand, sqn, amf)
+	if err != nil {
+		return nil, nil, nil, nil, err
+	}
+	autn = make([]byte, 16)
+	subtle.XORBytes(autn[0:6], sqn, out.AK)
+	copy(autn[6:8], amf)
+	copy(autn[8:16], out.MACA)
+	return autn, out.RES, out.CK, out.IK, nil
+}
+
+// ResyncAMF is the dummy AMF used when computing MAC-S. See TS 33.102 Section 6.3.3.
+var ResyncAMF = []byte{0x00, 0x00}
+
+// GenerateAUTS computes AUTS = (SQN_MS xor AK*) || MAC-S for a re-synchronisation
+// request. See TS 33.102 Section 6.3.3.
+func GenerateAUTS(k, opc, rand, sqnMS []byte) ([]byte, error) {
+	out, err := Compute(k, opc, rand, sqnMS, ResyncAMF)
+	if err != nil {
+		return nil, err
+	}
+	auts := make([]byte, 14)
+	subtle.XORBytes(auts[0:6], sqnMS, out.AKS)
+	copy(auts[6:14], out.MACS)
+	return auts, nil
+}
+
+// VerifyAUTS checks AUTS on the network side and returns SQN_MS.
+// See TS 33.102 Section 6.3.5.
+func VerifyAUTS(k, opc, rand, auts []byte) ([]byte, error) {
+	if len(auts) != 14 {
+		return nil, ErrInvalidLength
+	}
+	// AK* only depends on RAND, so a first pass with any SQN recovers it.
+	out, err := Compute(k, opc, rand, make([]byte, 6), ResyncAMF)
+	if err != nil {
+		return nil, err
+	}
+	sqnMS := make([]byte, 6)
+	subtle.XORBytes(sqnMS, auts[0:6], out.AKS)
+	out, err = Compute(k, opc, rand, sqnMS, ResyncAMF)
+	if err != nil {
+		return nil, err
+	}
+	if subtle.ConstantTimeCompare(out.MACS, auts[6:14]) != 1 {
+		return nil, errors.New("milenage: AUTS MAC-S mismatch")
+	}
+	return sqnMS, nil
+}
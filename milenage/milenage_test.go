@@ -0,0 +1,99 @@
+package milenage
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"testing"
+)
+
+func h(s string) []byte {
+	b, _ := hex.DecodeString(s)
+	return b
+}
+
+// TS 35.208 Section 4.3, Test Set 1
+func TestCompute_TestSet1(t *testing.T) {
+	k := h("465b5ce8b199b49faa5f0a2ee238a6bc")
+	op := h("cdc202d5123e20f62b6d676ac72cb318")
+	rand := h("23553cbe9637a89d218ae64dae47bf35")
+	sqn := h("ff9bb4d0b607")
+	amf := h("b9b9")
+
+	opc, err := OPc(k, op)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := h("cd63cb71954a9f4e48a5994e37a02baf"); !bytes.Equal(opc, want) {
+		t.Errorf("OPc mismatch\nGot: %x\nWant: %x", opc, want)
+	}
+
+	out, err := Compute(k, opc, rand, sqn, amf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range []struct {
+		name      string
+		got, want []byte
+	}{
+		{"f1", out.MACA, h("4a9ffac354dfafb3")},
+		{"f1*", out.MACS, h("01cfaf9ec4e871e9")},
+		{"f2", out.RES, h("a54211d5e3ba50bf")},
+		{"f3", out.CK, h("b40ba9a3c58b2a05bbf0d987b21bf8cb")},
+		{"f4", out.IK, h("f769bcd751044604127672711c6d3441")},
+		{"f5", out.AK, h("aa689c648370")},
+		{"f5*", out.AKS, h("451e8beca43b")},
+	} {
+		if !bytes.Equal(c.got, c.want) {
+			t.Errorf("%s mismatch\nGot: %x\nWant: %x", c.name, c.got, c.want)
+		}
+	}
+}
+
+func TestUSIM_Resync(t *testing.T) {
+	k := h("465b5ce8b199b49faa5f0a2ee238a6bc")
+	opc := h("cd63cb71954a9f4e48a5994e37a02baf")
+	rand := h("23553cbe9637a89d218ae64dae47bf35")
+	amf := h("8000")
+
+	usim, err := NewUSIM(k, opc, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A vector with a stale SQN triggers a synchronization failure.
+	autn, _, _, _, err := GenerateVector(k, opc, rand, SQNBytes(50), amf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _, _, _, err = usim.Authenticate(rand, autn)
+	var syncErr *SyncFailureError
+	if !errors.As(err, &syncErr) {
+		t.Fatalf("Authenticate: got %v, want SyncFailureError", err)
+	}
+	sqnMS, err := VerifyAUTS(k, opc, rand, syncErr.AUTS)
+	if err != nil {
+		t.Fatalf("VerifyAUTS failed: %v", err)
+	}
+	if got := SQNFromBytes(sqnMS); got != 100 {
+		t.Errorf("SQN_MS: got %d, want 100", got)
+	}
+
+	// A fresh vector is accepted and yields the network's XRES.
+	autn, xres, ck, ik, err := GenerateVector(k, opc, rand, SQNBytes(101), amf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, gotCK, gotIK, _, err := usim.Authenticate(rand, autn)
+	if err != nil {
+		t.Fatalf("Authenticate failed: %v", err)
+	}
+	if !bytes.Equal(res, xres) || !bytes.Equal(gotCK, ck) || !bytes.Equal(gotIK, ik) {
+		t.Error("USIM outputs do not match the vector")
+	}
+
+	autn[15] ^= 1
+	if _, _, _, _, err := usim.Authenticate(rand, autn); !errors.Is(err, ErrMACFailure) {
+		t.Errorf("tampered AUTN: got %v, want ErrMACFailure", err)
+	}
+}
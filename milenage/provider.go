@@ -0,0 +1,95 @@
+package milenage
+
+import (
+	"context"
+	"io"
+	"sync"
+
+	"github.com/oyaguma3/go-eapaka"
+)
+
+// Subscriber holds the long-term data of one subscription.
+type Subscriber struct {
+	IMSI string
+	K    []byte // 16 bytes
+	OPc  []byte // 16 bytes
+	AMF  []byte // 2 bytes, default 0x8000; the separation bit is always set for EAP-AKA'
+	SQN  uint64 // last SQN used by the network (SQN_HE)
+}
+
+// Provider is an [eapaka.VectorProvider] that generates vectors locally with
+// MILENAGE, acting as an AuC. It is safe for concurrent use.
+type Provider struct {
+	// Random is the source of RAND. It is read under the Provider's lock;
+	// set it before the first GetVector. Default: [eapaka.Random].
+	Random io.Reader
+
+	mu   sync.Mutex
+	subs map[string]*Subscriber
+}
+
+// NewProvider creates a [Provider] for the given subscribers.
+func NewProvider(subs ...*Subscriber) *Provider {
+	p := &Provider{subs: make(map[string]*Subscriber)}
+	for _, s := range subs {
+		p.Add(s)
+	}
+	return p
+}
+
+// Add registers or replaces a subscriber.
+func (p *Provider) Add(s *Subscriber) {
+	c := *s
+	if len(c.AMF) != 2 {
+		c.AMF = []byte{0x80, 0x00}
+	}
+	p.mu.Lock()
+	p.subs[s.IMSI] = &c
+	p.mu.Unlock()
+}
+
+// Subscriber returns a copy of the subscriber data for imsi, including the current SQN.
+func (p *Provider) Subscriber(imsi string) (Subscriber, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	s, ok := p.subs[imsi]
+	if !ok {
+		return Subscriber{}, false
+	}
+	return *s, true
+}
+
+// GetVector implements [eapaka.VectorProvider].
+// SQN is incremented for every vector. If req carries AUTS, SQN_HE is first
+// re-synchronised to SQN_MS.
+func (p *Provider) GetVector(_ context.Context, req *eapaka.VectorRequest) (*eapaka.AuthVector, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	s, ok := p.subs[req.IMSI]
+	if !ok {
+		return nil, eapaka.ErrUnknownSubscriber
+	}
+	if req.AUTS != nil {
+		sqnMS, err := VerifyAUTS(s.K, s.OPc, req.RAND, req.AUTS)
+		if err != nil {
+			return nil, err
+		}
+		s.SQN = SQNFromBytes(sqnMS)
+	}
+	s.SQN = (s.SQN + 1) & sqnMask
+
+	amf := append([]byte(nil), s.AMF...)
+	if req.Type == eapaka.TypeAKAPrime {
+		// AMF separation bit (TS 33.402 Section 6.2, RFC 5448 Section 3.4.1)
+		amf[0] |= 0x80
+	}
+	r, err := eapaka.ReadRandom(p.Random, 16)
+	if err != nil {
+		return nil, err
+	}
+	autn, xres, ck, ik, err := GenerateVector(s.K, s.OPc, r, SQNBytes(s.SQN), amf)
+	if err != nil {
+		return nil, err
+	}
+	return &eapaka.AuthVector{RAND: r, AUTN: autn, XRES: xres, CK: ck, IK: ik}, nil
+}
@@ -0,0 +1,103 @@
+package milenage
+
+import (
+	"crypto/subtle"
+	"errors"
+	"sync"
+)
+
+// ErrMACFailure is returned by [USIM.Authenticate] when MAC-A in AUTN does not verify.
+// The peer answers with EAP-Response/AKA-Authentication-Reject.
+var ErrMACFailure = errors.New("milenage: AUTN MAC-A mismatch")
+
+// SyncFailureError is returned by [USIM.Authenticate] when SQN in AUTN is not
+// acceptable. AUTS is the value to send in AT_AUTS.
+type SyncFailureError struct {
+	AUTS []byte
+}
+
+func (e *SyncFailureError) Error() string {
+	return "milenage: SQN out of range, re-synchronisation required"
+}
+
+// USIM is a software USIM running MILENAGE. It is safe for concurrent use.
+type USIM struct {
+	K   []byte // 16 bytes
+	OPc []byte // 16 bytes
+
+	mu  sync.Mutex
+	sqn uint64 // highest SQN accepted (SQN_MS), 48 bits
+}
+
+// NewUSIM creates a [USIM] whose highest accepted sequence number is sqn.
+func NewUSIM(k, opc []byte, sqn uint64) (*USIM, error) {
+	if len(k) != 16 || len(opc) != 16 {
+		return nil, ErrInvalidKeyLength
+	}
+	return &USIM{K: k, OPc: opc, sqn: sqn & sqnMask}, nil
+}
+
+// SQN returns the highest sequence number accepted so far.
+func (u *USIM) SQN() uint64 {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	return u.sqn
+}
+
+// Authenticate runs the USIM side of AKA for RAND and AUTN (TS 33.102 Section 6.3.3).
+// It returns RES, CK and IK, or ErrMACFailure, or a *SyncFailureError
+// carrying AUTS. The AMF from AUTN is returned for separation bit checks.
+func (u *USIM) Authenticate(rand, autn []byte) (res, ck, ik, amf []byte, err error) {
+	if len(rand) != 16 || len(autn) != 16 {
+		return nil, nil, nil, nil, ErrInvalidLength
+	}
+	// AK only depends on RAND.
+	out, err := Compute(u.K, u.OPc, rand, make([]byte, 6), autn[6:8])
+	if err != nil {
+		return nil, nil, nil, nil, err
+	}
+	sqn := make([]byte, 6)
+	subtle.XORBytes(sqn, autn[0:6], out.AK)
+	amf = append([]byte(nil), autn[6:8]...)
+
+	out, err = Compute(u.K, u.OPc, rand, sqn, amf)
+	if err != nil {
+		return nil, nil, nil, nil, err
+	}
+	if subtle.ConstantTimeCompare(out.MACA, autn[8:16]) != 1 {
+		return nil, nil, nil, nil, ErrMACFailure
+	}
+
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if SQNFromBytes(sqn) <= u.sqn {
+		auts, err := GenerateAUTS(u.K, u.OPc, rand, SQNBytes(u.sqn))
+		if err != nil {
+			return nil, nil, nil, nil, err
+		}
+		return nil, nil, nil, nil, &SyncFailureError{AUTS: auts}
+	}
+	u.sqn = SQNFromBytes(sqn)
+	return out.RES, out.CK, out.IK, amf, nil
+}
+
+const sqnMask = 1<<48 - 1
+
+// SQNBytes encodes a 48-bit sequence number as 6 bytes.
+func SQNBytes(sqn uint64) []byte {
+	b := make([]byte, 6)
+	for i := 5; i >= 0; i-- {
+		b[i] = byte(sqn)
+		sqn >>= 8
+	}
+	return b
+}
+
+// SQNFromBytes decodes a 6-byte sequence number.
+func SQNFromBytes(b []byte) uint64 {
+	var sqn uint64
+	for _, c := range b[:6] {
+		sqn = sqn<<8 | uint64(c)
+	}
+	return sqn
+}
@@ -2,8 +2,8 @@ package eapaka
 
 import (
 	"crypto/md5"
-	"crypto/rand"
 	"errors"
+	"io"
 )
 
 // EncryptMPPEKey encrypts the given key (e.g., MSK part) for inclusion in
@@ -13,7 +13,15 @@ import (
 // key: The key to encrypt (typically 32 bytes).
 // secret: The RADIUS shared secret.
 // reqAuth: The Request Authenticator from the Access-Request packet (16 bytes).
-func EncryptMPPEKey(key []byte, secret []byte, reqAuth []byte) ([]byte, error) {
+//
+// The salt is read from [Random]; see [EncryptMPPEKeyWithRandom].
+func EncryptMPPEKey(key Key, secret []byte, reqAuth []byte) ([]byte, error) {
+	return EncryptMPPEKeyWithRandom(nil, key, secret, reqAuth)
+}
+
+// EncryptMPPEKeyWithRandom is like [EncryptMPPEKey] but reads the salt from
+// r, or from [Random] if r is nil.
+func EncryptMPPEKeyWithRandom(r io.Reader, key Key, secret []byte, reqAuth []byte) ([]byte, error) {
 	if len(key) == 0 || len(key) > 255 {
 		return nil, errors.New("eapaka: invalid key length for MPPE encryption")
 	}
@@ -23,8 +31,8 @@ func EncryptMPPEKey(key []byte, secret []byte, reqAuth []byte) ([]byte, error) {
 
 	// 1. Generate Salt (2 bytes)
 	// "The most significant bit ... MUST be set"
-	salt := make([]byte, 2)
-	if _, err := rand.Read(salt); err != nil {
+	salt, err := ReadRandom(r, 2)
+	if err != nil {
 		return nil, err
 	}
 	salt[0] |= 0x80
@@ -42,6 +50,7 @@ func EncryptMPPEKey(key []byte, secret []byte, reqAuth []byte) ([]byte, error) {
 
 	// Construct buffer
 	plaintext := make([]byte, plainLen+padLen)
+	defer clear(plaintext)
 	plaintext[0] = byte(len(key))
 	copy(plaintext[1:], key)
 	// Padding is zero-valued by make()
@@ -79,3 +88,45 @@ func EncryptMPPEKey(key []byte, secret []byte, reqAuth []byte) ([]byte, error) {
 
 	return result, nil
 }
+
+// DecryptMPPEKey reverses [EncryptMPPEKey] and returns the plain key.
+// It is used on the NAS side to recover MS-MPPE-Send-Key and MS-MPPE-Recv-Key.
+//
+// Ref: RFC 2548 Section 2.4.2 and 2.4.3
+// data: The attribute value (Salt + encrypted String).
+// secret: The RADIUS shared secret.
+// reqAuth: The Request Authenticator from the Access-Request packet (16 bytes).
+func DecryptMPPEKey(data []byte, secret []byte, reqAuth []byte) ([]byte, error) {
+	if len(data) < 2+16 || (len(data)-2)%16 != 0 {
+		return nil, errors.New("eapaka: invalid MPPE key attribute length")
+	}
+	if len(reqAuth) != 16 {
+		return nil, errors.New("eapaka: invalid Request Authenticator length")
+	}
+	salt := data[0:2]
+	cipherText := data[2:]
+	plaintext := make([]byte, len(cipherText))
+
+	h := md5.New()
+	h.Write(secret)
+	h.Write(reqAuth)
+	h.Write(salt)
+	b := h.Sum(nil)
+
+	for i := 0; i < len(cipherText); i += 16 {
+		cBlock := cipherText[i : i+16]
+		for j := 0; j < 16; j++ {
+			plaintext[i+j] = cBlock[j] ^ b[j]
+		}
+		h.Reset()
+		h.Write(secret)
+		h.Write(cBlock)
+		b = h.Sum(nil)
+	}
+
+	keyLen := int(plaintext[0])
+	if keyLen == 0 || 1+keyLen > len(plaintext) {
+		return nil, errors.New("eapaka: invalid MPPE key length")
+	}
+	return plaintext[1 : 1+keyLen], nil
+}
@@ -2,6 +2,10 @@ package eapaka
 
 // Packet represents an EAP packet including EAP-AKA/AKA' specific data.
 // It supports both EAP-Request/Response (with attributes) and EAP-Success/Failure (header only).
+//
+// A Packet returned by [Parse] keeps a copy of the received bytes, which
+// [Packet.VerifyMac] uses. Changes made to the fields after parsing are not
+// reflected in [Packet.Raw]; [Packet.CalculateAndSetMac] discards the received bytes.
 type Packet struct {
 	// Code indicates the EAP Code (e.g., Request, Response).
 	// See RFC 3748 Section 4.
@@ -10,8 +14,8 @@ type Packet struct {
 	// Identifier handles request/response matching.
 	Identifier uint8
 
-	// Type indicates the EAP Method Type.
-	// Use TypeAKA (23) or TypeAKAPrime (50).
+	// Type indicates the EAP Method Type: TypeAKA (23) or TypeAKAPrime (50),
+	// or another method whose data is in TypeData. Zero means no Type.
 	// This field is ignored if Code is Success(3) or Failure(4).
 	Type uint8
 
@@ -21,4 +25,26 @@ type Packet struct {
 
 	// Attributes contains the list of EAP-AKA attributes.
 	Attributes []Attribute
+
+	// TypeData is the Type-Data of methods other than EAP-AKA and EAP-AKA',
+	// such as the identity of an EAP-Response/Identity or the vendor header
+	// and data of an Expanded Type. It is kept as received and marshalled
+	// as is. See [Packet.EAPIdentity], [Packet.NakTypes] and [Packet.Expanded].
+	TypeData []byte
+
+	raw    []byte // received bytes, nil unless parsed
+	macOff int    // offset of the AT_MAC value in raw, or -1
+}
+
+// Raw returns the bytes the packet was parsed from, or nil if the packet was
+// not produced by [Parse] or has been re-signed since.
+func (p *Packet) Raw() []byte { return p.raw }
+
+// MacOffset returns the offset of the AT_MAC value in [Packet.Raw].
+// ok is false if there are no received bytes or no AT_MAC.
+func (p *Packet) MacOffset() (off int, ok bool) {
+	if p.raw == nil || p.macOff < 0 {
+		return 0, false
+	}
+	return p.macOff, true
 }
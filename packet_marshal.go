@@ -1,49 +1,136 @@
 package eapaka
 
 import (
-	"bytes"
 	"encoding/binary"
-	"errors"
+	"io"
+	"slices"
 )
 
+// attributeAppender is implemented by all attributes of this package.
+// Other [Attribute] implementations are marshalled with Marshal.
+type attributeAppender interface {
+	Len() int
+	AppendBinary(b []byte) ([]byte, error)
+}
+
+// Len returns the length of the marshalled packet in bytes.
+func (p *Packet) Len() int {
+	n := 4
+	if p.Code == CodeRequest || p.Code == CodeResponse {
+		// Type (1) + Subtype (1) + Reserved (2) for AKA and AKA',
+		// Type (1) + Type-Data for other methods
+		if p.Type == TypeAKA || p.Type == TypeAKAPrime {
+			n += 4
+		} else if p.Type != 0 {
+			n += 1 + len(p.TypeData)
+		}
+		for _, attr := range p.Attributes {
+			if a, ok := attr.(attributeAppender); ok {
+				n += a.Len()
+			} else if b, err := attr.Marshal(); err == nil {
+				n += len(b)
+			}
+		}
+	}
+	return n
+}
+
 // Marshal serializes the EAP packet into a byte slice.
 func (p *Packet) Marshal() ([]byte, error) {
-	var buf bytes.Buffer
+	return p.AppendBinary(make([]byte, 0, p.Len()))
+}
 
-	// 1. Marshal Attributes first to calculate length
-	var attrsBuf bytes.Buffer
+// AppendBinary appends the serialized packet to b and returns the extended slice.
+// It implements [encoding.BinaryAppender]. If b has enough capacity (see
+// [Packet.Len]), no memory is allocated.
+func (p *Packet) AppendBinary(b []byte) ([]byte, error) {
+	b, _, err := p.appendTo(b)
+	return b, err
+}
+
+// MarshalTo serializes the packet into dst and returns the number of bytes
+// written. It returns [io.ErrShortBuffer] if dst is shorter than [Packet.Len].
+func (p *Packet) MarshalTo(dst []byte) (int, error) {
+	n := p.Len()
+	if len(dst) < n {
+		return 0, io.ErrShortBuffer
+	}
+	b, err := p.AppendBinary(dst[:0])
+	if err != nil {
+		return 0, err
+	}
+	return len(b), nil
+}
+
+// appendTo appends the packet to b. macOff is the offset of the value of the
+// first AT_MAC relative to the start of the packet, or -1.
+func (p *Packet) appendTo(b []byte) (_ []byte, macOff int, err error) {
+	macOff = -1
+	b = slices.Grow(b, p.Len())
+	start := len(b)
+
+	// 1. EAP Header: Code (1) + Identifier (1) + Length (2), Length set below
+	b = append(b, p.Code, p.Identifier, 0, 0)
+
+	// 2. EAP-AKA/AKA' header and attributes
 	if p.Code == CodeRequest || p.Code == CodeResponse {
-		// EAP-AKA/AKA' header inside EAP Data
-		// Type (1) + Subtype (1) + Reserved (2) = 4 bytes
-		// Only if Type is AKA or AKA'
 		if p.Type == TypeAKA || p.Type == TypeAKAPrime {
-			attrsBuf.WriteByte(p.Type)
-			attrsBuf.WriteByte(p.Subtype)
-			attrsBuf.Write([]byte{0x00, 0x00}) // Reserved
+			b = append(b, p.Type, p.Subtype, 0x00, 0x00) // Reserved
+		} else if p.Type != 0 {
+			b = append(b, p.Type)
+			b = append(b, p.TypeData...)
 		}
-
 		for _, attr := range p.Attributes {
-			b, err := attr.Marshal()
+			if _, ok := attr.(*AtMac); ok && macOff < 0 {
+				macOff = len(b) - start + 4
+			}
+			if a, ok := attr.(attributeAppender); ok {
+				b, err = a.AppendBinary(b)
+			} else {
+				var ab []byte
+				ab, err = attr.Marshal()
+				b = append(b, ab...)
+			}
 			if err != nil {
-				return nil, err
+				return b[:start], -1, err
 			}
-			attrsBuf.Write(b)
 		}
 	}
 
-	// 2. EAP Header
-	// Code (1) + Identifier (1) + Length (2)
-	eapLen := 4 + attrsBuf.Len()
+	eapLen := len(b) - start
 	if eapLen > 65535 {
-		return nil, errors.New("packet too long")
+		return b[:start], -1, ErrPacketTooLong
 	}
+	binary.BigEndian.PutUint16(b[start+2:start+4], uint16(eapLen))
+	return b, macOff, nil
+}
 
-	buf.WriteByte(p.Code)
-	buf.WriteByte(p.Identifier)
-	binary.Write(&buf, binary.BigEndian, uint16(eapLen))
-
-	// 3. Append Attributes (which includes Method Type/Subtype header)
-	buf.Write(attrsBuf.Bytes())
+// AppendSigned appends the serialized packet to b with AT_MAC computed with
+// K_aut over the packet concatenated with extra (see
+// [Packet.CalculateAndSetMacWithExtra]). The packet is marshalled only once, and
+// the AT_MAC attribute of p is updated with the computed value.
+func (p *Packet) AppendSigned(b []byte, kAut, extra []byte) ([]byte, error) {
+	mac, ok := Find[*AtMac](p)
+	if !ok {
+		return b, ErrMACNotFound
+	}
+	if len(mac.MAC) != 16 {
+		mac.MAC = make([]byte, 16)
+	} else {
+		clear(mac.MAC)
+	}
 
-	return buf.Bytes(), nil
+	start := len(b)
+	b, macOff, err := p.appendTo(b)
+	if err != nil {
+		return b, err
+	}
+	sum, err := p.calculateMac(kAut, b[start:], extra)
+	if err != nil {
+		return b[:start], err
+	}
+	copy(b[start+macOff:], sum)
+	copy(mac.MAC, sum)
+	p.raw = nil
+	return b, nil
 }
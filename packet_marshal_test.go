@@ -0,0 +1,141 @@
+package eapaka_test
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/oyaguma3/go-eapaka"
+)
+
+func challengePacket() *eapaka.Packet {
+	return &eapaka.Packet{
+		Code:       eapaka.CodeRequest,
+		Identifier: 7,
+		Type:       eapaka.TypeAKAPrime,
+		Subtype:    eapaka.SubtypeChallenge,
+		Attributes: []eapaka.Attribute{
+			&eapaka.AtRand{Rand: bytes.Repeat([]byte{1}, 16)},
+			&eapaka.AtAutn{Autn: bytes.Repeat([]byte{2}, 16)},
+			&eapaka.AtKdfInput{NetworkName: "WLAN"},
+			&eapaka.AtKdf{KDF: eapaka.KDFAKAPrimeSHA256},
+			&eapaka.AtResultInd{},
+			&eapaka.AtIv{IV: bytes.Repeat([]byte{3}, 16)},
+			&eapaka.AtEncrData{EncryptedData: bytes.Repeat([]byte{4}, 48)},
+			&eapaka.AtMac{MAC: make([]byte, 16)},
+			&eapaka.GenericAttribute{AttrType: 200, Data: []byte{1, 2}},
+		},
+	}
+}
+
+func TestPacket_AppendBinary(t *testing.T) {
+	p := challengePacket()
+	want, err := p.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Len() != len(want) {
+		t.Errorf("Len = %d, want %d", p.Len(), len(want))
+	}
+	for _, attr := range p.Attributes {
+		b, _ := attr.Marshal()
+		if l := attr.(interface{ Len() int }).Len(); l != len(b) {
+			t.Errorf("%s: Len = %d, want %d", attr.Type(), l, len(b))
+		}
+	}
+
+	prefix := []byte{0xde, 0xad}
+	got, err := p.AppendBinary(prefix)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got[:2], prefix) || !bytes.Equal(got[2:], want) {
+		t.Errorf("AppendBinary = %x, want %x%x", got, prefix, want)
+	}
+
+	if _, err := p.MarshalTo(make([]byte, p.Len()-1)); !errors.Is(err, io.ErrShortBuffer) {
+		t.Errorf("MarshalTo on a short buffer: got %v, want io.ErrShortBuffer", err)
+	}
+	dst := make([]byte, p.Len()+10)
+	n, err := p.MarshalTo(dst)
+	if err != nil || !bytes.Equal(dst[:n], want) {
+		t.Errorf("MarshalTo = %x, %v; want %x", dst[:n], err, want)
+	}
+
+	buf := make([]byte, 0, p.Len())
+	if allocs := testing.AllocsPerRun(100, func() { buf, _ = p.AppendBinary(buf[:0]) }); allocs != 0 {
+		t.Errorf("AppendBinary allocated %v times, want 0", allocs)
+	}
+}
+
+func TestPacket_AppendSigned(t *testing.T) {
+	kAut := bytes.Repeat([]byte{9}, 32)
+	extra := []byte("nonce")
+
+	p1 := challengePacket()
+	if err := p1.CalculateAndSetMacWithExtra(kAut, extra); err != nil {
+		t.Fatal(err)
+	}
+	want, _ := p1.Marshal()
+
+	p2 := challengePacket()
+	got, err := p2.AppendSigned(nil, kAut, extra)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("AppendSigned = %x, want %x", got, want)
+	}
+	if ok, err := eapaka.VerifyMacBytes(got, kAut, extra); err != nil || !ok {
+		t.Errorf("VerifyMacBytes = %v, %v; want true", ok, err)
+	}
+}
+
+func BenchmarkPacket_MarshalAndSign(b *testing.B) {
+	kAut := bytes.Repeat([]byte{9}, 32)
+	p := challengePacket()
+	b.Run("CalculateAndSetMac+Marshal", func(b *testing.B) {
+		b.ReportAllocs()
+		for b.Loop() {
+			if err := p.CalculateAndSetMac(kAut); err != nil {
+				b.Fatal(err)
+			}
+			if _, err := p.Marshal(); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("AppendSigned", func(b *testing.B) {
+		b.ReportAllocs()
+		buf := make([]byte, 0, p.Len())
+		for b.Loop() {
+			var err error
+			if buf, err = p.AppendSigned(buf[:0], kAut, nil); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+func BenchmarkPacket_Marshal(b *testing.B) {
+	p := challengePacket()
+	b.Run("Marshal", func(b *testing.B) {
+		b.ReportAllocs()
+		for b.Loop() {
+			if _, err := p.Marshal(); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("AppendBinary", func(b *testing.B) {
+		b.ReportAllocs()
+		buf := make([]byte, 0, p.Len())
+		for b.Loop() {
+			var err error
+			if buf, err = p.AppendBinary(buf[:0]); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
@@ -1,10 +1,12 @@
 package eapaka_test
 
 import (
+	"bytes"
 	"fmt"
 	"testing"
 
 	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
 	"github.com/oyaguma3/go-eapaka"
 )
 
@@ -42,7 +44,7 @@ func TestPacket_RoundTrip(t *testing.T) {
 	// We need to allow unexported fields if any, but our structs are all exported.
 	// However, cmp might need options for interfaces.
 	// Actually, cmp handles interfaces well if the underlying types match.
-	if diff := cmp.Diff(original, parsed); diff != "" {
+	if diff := cmp.Diff(original, parsed, cmpopts.IgnoreUnexported(eapaka.Packet{})); diff != "" {
 		t.Errorf("Packet mismatch (-want +got):\n%s", diff)
 	}
 }
@@ -71,7 +73,7 @@ func TestPacket_Attributes(t *testing.T) {
 		t.Fatalf("Parse failed: %v", err)
 	}
 
-	if diff := cmp.Diff(original, parsed); diff != "" {
+	if diff := cmp.Diff(original, parsed, cmpopts.IgnoreUnexported(eapaka.Packet{})); diff != "" {
 		t.Errorf("Packet mismatch (-want +got):\n%s", diff)
 	}
 }
@@ -89,11 +91,35 @@ func TestPacket_Success(t *testing.T) {
 	if err != nil {
 		t.Fatalf("Parse failed: %v", err)
 	}
-	if diff := cmp.Diff(original, parsed); diff != "" {
+	if diff := cmp.Diff(original, parsed, cmpopts.IgnoreUnexported(eapaka.Packet{})); diff != "" {
 		t.Errorf("Packet mismatch (-want +got):\n%s", diff)
 	}
 }
 
+func TestPacket_WireFormat(t *testing.T) {
+	// AT_RAND and AT_AUTN carry two reserved bytes before the value (RFC 4187 Section 10.6, 10.7).
+	rnd := bytes.Repeat([]byte{0xaa}, 16)
+	autn := bytes.Repeat([]byte{0xbb}, 16)
+	p := &eapaka.Packet{
+		Code:       eapaka.CodeRequest,
+		Identifier: 1,
+		Type:       eapaka.TypeAKA,
+		Subtype:    eapaka.SubtypeChallenge,
+		Attributes: []eapaka.Attribute{&eapaka.AtRand{Rand: rnd}, &eapaka.AtAutn{Autn: autn}},
+	}
+	bin, err := p.Marshal()
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	want := []byte{0x01, 0x01, 0x00, 0x30, 23, 1, 0, 0, 1, 5, 0, 0}
+	want = append(want, rnd...)
+	want = append(want, 2, 5, 0, 0)
+	want = append(want, autn...)
+	if !bytes.Equal(bin, want) {
+		t.Errorf("wire format mismatch:\n got %x\nwant %x", bin, want)
+	}
+}
+
 func ExampleParse() {
 	// Raw bytes example (EAP-Success: Code=3, ID=1, Len=4)
 	raw := []byte{0x03, 0x01, 0x00, 0x04}
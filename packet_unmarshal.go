@@ -2,14 +2,26 @@ package eapaka
 
 import (
 	"encoding/binary"
-	"errors"
 	"fmt"
 )
 
 // Parse parses an EAP packet from a byte slice.
+// It is equivalent to [ParseWithOptions] with [LenientParseOptions].
 func Parse(data []byte) (*Packet, error) {
+	return ParseWithOptions(data, LenientParseOptions)
+}
+
+// ParseWithOptions parses an EAP packet from a byte slice, applying the checks
+// enabled in opts (e.g., [StrictParseOptions]). Errors are of type *[ParseError].
+func ParseWithOptions(data []byte, opts ParseOptions) (*Packet, error) {
+	p, err := parse(data, opts)
+	reportParsed(err)
+	return p, err
+}
+
+func parse(data []byte, opts ParseOptions) (*Packet, error) {
 	if len(data) < 4 {
-		return nil, errors.New("packet too short")
+		return nil, &ParseError{Offset: len(data), Reason: "packet too short", Err: ErrTruncated}
 	}
 
 	p := &Packet{}
@@ -17,14 +29,27 @@ func Parse(data []byte) (*Packet, error) {
 	p.Identifier = data[1]
 	length := binary.BigEndian.Uint16(data[2:4])
 
-	if int(length) > len(data) {
-		return nil, errors.New("packet length mismatch")
+	if err := checkLength(data, int(length)); err != nil {
+		return nil, err
 	}
+	if opts.MaxPacketSize > 0 && int(length) > opts.MaxPacketSize {
+		err := &LimitError{Limit: "packet size", Max: opts.MaxPacketSize, Got: int(length)}
+		return nil, &ParseError{Offset: 2, Reason: err.Error(), Err: err}
+	}
+	if opts.RejectTrailingBytes && len(data) > int(length) {
+		return nil, trailingBytesError(int(length), len(data)-int(length))
+	}
+	p.raw = append([]byte(nil), data[:length]...)
+	p.macOff = -1
+
 	// Use only the slice indicated by length
 	payload := data[4:length]
 
 	// If Success or Failure, no more data expected (usually)
 	if p.Code == CodeSuccess || p.Code == CodeFailure {
+		if opts.RejectTrailingBytes && len(payload) > 0 {
+			return nil, trailingBytesError(4, len(payload))
+		}
 		return p, nil
 	}
 
@@ -33,47 +58,98 @@ func Parse(data []byte) (*Packet, error) {
 	}
 
 	p.Type = payload[0]
-	// Only parse attributes for AKA and AKA'
+	// Only parse attributes for AKA and AKA'; other methods keep their Type-Data.
 	if p.Type != TypeAKA && p.Type != TypeAKAPrime {
-		// Not an AKA packet we understand structure of beyond Type
+		if p.Type == TypeExpanded && len(payload) < 1+expandedHeaderLen {
+			return nil, &ParseError{Offset: 5, Reason: "Expanded Type header truncated", Err: ErrTruncated}
+		}
+		p.TypeData = append([]byte{}, payload[1:]...)
 		return p, nil
 	}
 
 	if len(payload) < 4 {
-		return nil, errors.New("invalid EAP-AKA header")
+		return nil, &ParseError{Offset: 4, Reason: "EAP-AKA header truncated", Err: ErrTruncated}
 	}
 
 	p.Subtype = payload[1]
-	// Reserved bytes at payload[2:4] are ignored
+	if opts.RejectNonZeroReserved && (payload[2] != 0 || payload[3] != 0) {
+		return nil, &ParseError{Offset: 6, Reason: "non-zero reserved field in EAP-AKA header", Err: &ReservedFieldError{Offset: 6}}
+	}
+
+	// Attributes start at payload[4], which is offset 8 in the packet
+	attrs, err := parseAttributes(payload[4:], 8, &opts)
+	if err != nil {
+		return nil, err
+	}
+	p.Attributes = attrs
+	p.macOff = macOffset(p.raw)
+
+	return p, nil
+}
 
-	// Attributes start at payload[4]
-	attrData := payload[4:]
+// parseAttributes decodes a sequence of attributes.
+// It is used for the packet body as well as for decrypted AT_ENCR_DATA contents.
+// base is the offset of attrData in the packet, used in errors.
+func parseAttributes(attrData []byte, base int, opts *ParseOptions) ([]Attribute, error) {
+	var attrs []Attribute
 	offset := 0
 	for offset < len(attrData) {
 		if offset+2 > len(attrData) {
-			return nil, errors.New("attribute header truncated")
+			return nil, &ParseError{Offset: base + offset, Reason: "attribute header truncated", Err: ErrTruncated}
 		}
 		attrType := AttributeType(attrData[offset])
 		attrLen := int(attrData[offset+1]) * 4 // Length in bytes
 
-		if attrLen == 0 {
-			return nil, errors.New("attribute length zero")
+		if err := checkAttributeLength(attrType, base+offset, attrLen, len(attrData)-offset); err != nil {
+			return nil, err
 		}
-		if offset+attrLen > len(attrData) {
-			return nil, fmt.Errorf("attribute %d length overflow", attrType)
+		if opts.MaxAttributes > 0 && len(attrs) == opts.MaxAttributes {
+			err := &LimitError{Limit: "attributes", Max: opts.MaxAttributes, Got: len(attrs) + 1}
+			return nil, &ParseError{Offset: base + offset, Attribute: attrType, Reason: err.Error(), Err: err}
 		}
 
 		// Value is after Type(1) + Length(1) = 2 bytes
 		valData := attrData[offset+2 : offset+attrLen]
 
+		if err := opts.check(attrType, base+offset, valData); err != nil {
+			return nil, err
+		}
 		attr, err := decodeAttribute(attrType, valData)
 		if err != nil {
-			return nil, err
+			return nil, &ParseError{Offset: base + offset, Attribute: attrType, Reason: err.Error(), Err: ErrMalformedAttribute}
 		}
-		p.Attributes = append(p.Attributes, attr)
+		attrs = append(attrs, attr)
 
 		offset += attrLen
 	}
 
-	return p, nil
+	return attrs, nil
+}
+
+// checkLength checks the EAP Length of the packet in data.
+func checkLength(data []byte, length int) error {
+	if length < 4 {
+		return &ParseError{Offset: 2, Reason: fmt.Sprintf("EAP Length %d below header length", length), Err: ErrInvalidLength}
+	}
+	if length > len(data) {
+		return &ParseError{Offset: 2, Reason: fmt.Sprintf("EAP Length %d exceeds %d bytes of data", length, len(data)), Err: ErrTruncated}
+	}
+	return nil
+}
+
+// checkAttributeLength checks the length n of the attribute at off, with
+// remaining bytes left in the packet from off.
+func checkAttributeLength(t AttributeType, off, n, remaining int) error {
+	if n == 0 {
+		return &ParseError{Offset: off, Attribute: t, Reason: "attribute length zero", Err: ErrInvalidLength}
+	}
+	if n > remaining {
+		return &ParseError{Offset: off, Attribute: t, Reason: fmt.Sprintf("attribute length %d exceeds remaining %d bytes", n, remaining), Err: ErrTruncated}
+	}
+	return nil
+}
+
+func trailingBytesError(length, extra int) error {
+	err := &TrailingBytesError{Length: length, Extra: extra}
+	return &ParseError{Offset: length, Reason: fmt.Sprintf("%d bytes after EAP Length", extra), Err: err}
 }
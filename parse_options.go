@@ -0,0 +1,168 @@
+package eapaka
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// ParseOptions controls how strictly [ParseWithOptions] checks a packet.
+// The zero value accepts everything [Parse] accepts. The typed errors below are
+// returned wrapped in a *[ParseError].
+type ParseOptions struct {
+	// RejectUnknownAttributes rejects unknown non-skippable attributes (types 0-127)
+	// with an *[UnknownAttributeError]. Unknown skippable attributes (128-255) are
+	// always accepted as *[GenericAttribute]. See RFC 4187 Section 8.1.
+	RejectUnknownAttributes bool
+
+	// RejectTrailingBytes rejects data after the EAP Length, and data after the
+	// header of EAP-Success/Failure, with a *[TrailingBytesError].
+	RejectTrailingBytes bool
+
+	// RejectNonZeroReserved rejects non-zero reserved fields and AT_PADDING
+	// contents with a *[ReservedFieldError].
+	RejectNonZeroReserved bool
+
+	// RejectInvalidResLength rejects an AT_RES whose RES Length is not a whole
+	// number of bytes or outside 32-128 bits with a *[ResLengthError].
+	// See RFC 4187 Section 10.8.
+	RejectInvalidResLength bool
+
+	// MaxAttributes limits the number of attributes in the packet. Zero means no limit.
+	MaxAttributes int
+
+	// MaxPacketSize limits the EAP Length. Zero means no limit.
+	MaxPacketSize int
+}
+
+// Parsing profiles for [ParseWithOptions].
+var (
+	// LenientParseOptions accepts everything [Parse] accepts.
+	LenientParseOptions = ParseOptions{}
+
+	// StrictParseOptions enables every check.
+	StrictParseOptions = ParseOptions{
+		RejectUnknownAttributes: true,
+		RejectTrailingBytes:     true,
+		RejectNonZeroReserved:   true,
+		RejectInvalidResLength:  true,
+	}
+)
+
+// UnknownAttributeError reports an unknown non-skippable attribute.
+type UnknownAttributeError struct {
+	Type   AttributeType
+	Offset int // offset of the attribute in the packet
+}
+
+func (e *UnknownAttributeError) Error() string {
+	return fmt.Sprintf("unknown non-skippable attribute %d at offset %d", uint8(e.Type), e.Offset)
+}
+
+func (e *UnknownAttributeError) Unwrap() error { return ErrUnknownAttribute }
+
+// TrailingBytesError reports bytes beyond the end of the packet.
+type TrailingBytesError struct {
+	Length int // EAP Length, or the header length for EAP-Success/Failure
+	Extra  int // number of bytes after Length
+}
+
+func (e *TrailingBytesError) Error() string {
+	return fmt.Sprintf("%d trailing bytes after EAP length %d", e.Extra, e.Length)
+}
+
+func (e *TrailingBytesError) Unwrap() error { return ErrTrailingBytes }
+
+// ReservedFieldError reports a reserved field that is not zero.
+// Type is zero for the reserved field of the EAP-AKA header.
+type ReservedFieldError struct {
+	Type   AttributeType
+	Offset int // offset of the reserved field in the packet
+}
+
+func (e *ReservedFieldError) Error() string {
+	if e.Type == 0 {
+		return fmt.Sprintf("non-zero reserved field in EAP-AKA header at offset %d", e.Offset)
+	}
+	return fmt.Sprintf("non-zero reserved field in %s at offset %d", e.Type, e.Offset)
+}
+
+func (e *ReservedFieldError) Unwrap() error { return ErrReservedField }
+
+// ResLengthError reports an invalid RES Length in AT_RES.
+type ResLengthError struct {
+	Bits   uint16
+	Offset int // offset of AT_RES in the packet
+}
+
+func (e *ResLengthError) Error() string {
+	return fmt.Sprintf("invalid AT_RES length of %d bits at offset %d", e.Bits, e.Offset)
+}
+
+func (e *ResLengthError) Unwrap() error { return ErrInvalidResLength }
+
+// LimitError reports a packet that exceeds [ParseOptions.MaxAttributes] or
+// [ParseOptions.MaxPacketSize].
+type LimitError struct {
+	Limit string // "attributes" or "packet size"
+	Max   int
+	Got   int
+}
+
+func (e *LimitError) Error() string {
+	return fmt.Sprintf("%s limit exceeded: %d > %d", e.Limit, e.Got, e.Max)
+}
+
+func (e *LimitError) Unwrap() error { return ErrLimitExceeded }
+
+// reservedFields gives the reserved bits of attributes whose value starts with a
+// 2-byte reserved field.
+var reservedFields = map[AttributeType]uint16{
+	AT_RAND:              0xffff,
+	AT_AUTN:              0xffff,
+	AT_MAC:               0xffff,
+	AT_NONCE_MT:          0xffff,
+	AT_NONCE_S:           0xffff,
+	AT_IV:                0xffff,
+	AT_ENCR_DATA:         0xffff,
+	AT_CHECKCODE:         0xffff,
+	AT_PERMANENT_ID_REQ:  0xffff,
+	AT_ANY_ID_REQ:        0xffff,
+	AT_FULLAUTH_ID_REQ:   0xffff,
+	AT_RESULT_IND:        0xffff,
+	AT_COUNTER_TOO_SMALL: 0xffff,
+	AT_BIDDING:           0x7fff, // the D bit is not reserved
+}
+
+// check applies the attribute checks of opts. off is the offset of the attribute
+// (its Type byte) in the packet and val its value.
+func (opts *ParseOptions) check(t AttributeType, off int, val []byte) error {
+	if opts.RejectUnknownAttributes && t < 128 {
+		if _, known := attributeTypeNames[t]; !known {
+			return &ParseError{Offset: off, Attribute: t, Reason: "unknown non-skippable attribute", Err: &UnknownAttributeError{Type: t, Offset: off}}
+		}
+	}
+	if opts.RejectNonZeroReserved {
+		if mask, ok := reservedFields[t]; ok && len(val) >= 2 && binary.BigEndian.Uint16(val)&mask != 0 {
+			return reservedFieldError(t, off+2)
+		}
+		if t == AT_PADDING {
+			for _, b := range val {
+				if b != 0 {
+					return reservedFieldError(t, off+2)
+				}
+			}
+		}
+	}
+	if opts.RejectInvalidResLength && t == AT_RES && len(val) >= 2 {
+		bits := binary.BigEndian.Uint16(val)
+		if bits%8 != 0 || bits < 32 || bits > 128 {
+			err := &ResLengthError{Bits: bits, Offset: off}
+			return &ParseError{Offset: off, Attribute: t, Reason: fmt.Sprintf("RES length of %d bits", bits), Err: err}
+		}
+	}
+	return nil
+}
+
+func reservedFieldError(t AttributeType, off int) error {
+	return &ParseError{Offset: off, Attribute: t, Reason: "non-zero reserved field", Err: &ReservedFieldError{Type: t, Offset: off}}
+}
@@ -0,0 +1,60 @@
+package eapaka_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/oyaguma3/go-eapaka"
+)
+
+func TestParseWithOptions(t *testing.T) {
+	// EAP-Response/AKA-Challenge header; attributes are appended per case.
+	packet := func(attrs ...byte) []byte {
+		b := append([]byte{eapaka.CodeResponse, 1, 0, 0, eapaka.TypeAKA, eapaka.SubtypeChallenge, 0, 0}, attrs...)
+		b[2], b[3] = byte(len(b)>>8), byte(len(b))
+		return b
+	}
+	res64 := []byte{byte(eapaka.AT_RES), 3, 0, 64, 1, 2, 3, 4, 5, 6, 7, 8}
+
+	tests := []struct {
+		name   string
+		data   []byte
+		opts   eapaka.ParseOptions
+		target any
+	}{
+		{"unknown non-skippable", packet(99, 1, 0, 0), eapaka.StrictParseOptions, new(*eapaka.UnknownAttributeError)},
+		{"unknown skippable", packet(200, 1, 0, 0), eapaka.StrictParseOptions, nil},
+		{"trailing bytes", append(packet(res64...), 0xff), eapaka.StrictParseOptions, new(*eapaka.TrailingBytesError)},
+		{"success with body", []byte{eapaka.CodeSuccess, 1, 0, 5, 0}, eapaka.StrictParseOptions, new(*eapaka.TrailingBytesError)},
+		{"header reserved", func() []byte { b := packet(res64...); b[7] = 1; return b }(), eapaka.StrictParseOptions, new(*eapaka.ReservedFieldError)},
+		{"AT_RESULT_IND reserved", packet(byte(eapaka.AT_RESULT_IND), 1, 0x80, 0), eapaka.StrictParseOptions, new(*eapaka.ReservedFieldError)},
+		{"AT_PADDING contents", packet(byte(eapaka.AT_PADDING), 1, 0, 1), eapaka.StrictParseOptions, new(*eapaka.ReservedFieldError)},
+		{"AT_BIDDING D bit", packet(byte(eapaka.AT_BIDDING), 1, 0x80, 0), eapaka.StrictParseOptions, nil},
+		{"AT_RES partial byte", packet(byte(eapaka.AT_RES), 3, 0, 60, 1, 2, 3, 4, 5, 6, 7, 8), eapaka.StrictParseOptions, new(*eapaka.ResLengthError)},
+		{"AT_RES whole bytes", packet(res64...), eapaka.StrictParseOptions, nil},
+		{"too many attributes", packet(append(res64, res64...)...), eapaka.ParseOptions{MaxAttributes: 1}, new(*eapaka.LimitError)},
+		{"packet too large", packet(res64...), eapaka.ParseOptions{MaxPacketSize: 16}, new(*eapaka.LimitError)},
+		{"lenient accepts all", append(packet(99, 1, 0, 1, byte(eapaka.AT_RES), 3, 0, 60, 1, 2, 3, 4, 5, 6, 7, 8), 0xff), eapaka.LenientParseOptions, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := eapaka.ParseWithOptions(tt.data, tt.opts)
+			if tt.target == nil {
+				if err != nil {
+					t.Fatalf("ParseWithOptions failed: %v", err)
+				}
+				return
+			}
+			if !errors.As(err, tt.target) {
+				t.Fatalf("ParseWithOptions returned %v, want %T", err, tt.target)
+			}
+		})
+	}
+}
+
+func TestParse_LengthBelowHeader(t *testing.T) {
+	if _, err := eapaka.Parse([]byte{eapaka.CodeRequest, 1, 0, 2, 0, 0}); err == nil {
+		t.Error("Parse accepted an EAP Length below 4")
+	}
+}
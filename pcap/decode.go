@@ -0,0 +1,235 @@
+package pcap
+
+import (
+	"encoding/binary"
+	"net/netip"
+)
+
+const (
+	etherTypeIPv4  = 0x0800
+	etherTypeIPv6  = 0x86dd
+	etherTypeEAPOL = 0x888e
+	etherTypeVLAN  = 0x8100
+	etherTypeQinQ  = 0x88a8
+
+	ipProtoTCP  = 6
+	ipProtoUDP  = 17
+	ipProtoSCTP = 132
+
+	tcpFIN = 0x01
+	tcpSYN = 0x02
+	tcpRST = 0x04
+
+	sctpChunkData = 0
+	sctpFlagEnd   = 0x01
+	sctpFlagBegin = 0x02
+)
+
+// decoded is the part of a frame the extractor is interested in: either an
+// EAPOL PDU or a UDP, TCP or SCTP payload.
+type decoded struct {
+	// EAPOL
+	eapol          []byte
+	srcMAC, dstMAC string // empty if the link layer has no MAC addresses
+
+	// IP
+	proto    uint8
+	src, dst netip.AddrPort
+	payload  []byte      // UDP or TCP payload
+	seq      uint32      // TCP sequence number
+	flags    uint8       // TCP flags
+	chunks   []sctpChunk // SCTP DATA chunks
+}
+
+type sctpChunk struct {
+	stream uint16
+	flags  uint8
+	ppid   uint32
+	data   []byte
+}
+
+// decodeFrame decodes the link, network and transport layers of f. It
+// returns false for frames that carry nothing of interest or are truncated.
+func decodeFrame(f *Frame) (*decoded, bool) {
+	b := f.Data
+	d := &decoded{}
+	var etherType uint16
+	switch f.LinkType {
+	case LinkTypeEthernet:
+		if len(b) < 14 {
+			return nil, false
+		}
+		d.dstMAC, d.srcMAC = macString(b[0:6]), macString(b[6:12])
+		etherType, b = binary.BigEndian.Uint16(b[12:14]), b[14:]
+		for (etherType == etherTypeVLAN || etherType == etherTypeQinQ) && len(b) >= 4 {
+			etherType, b = binary.BigEndian.Uint16(b[2:4]), b[4:]
+		}
+	case LinkTypeLinuxSLL:
+		if len(b) < 16 {
+			return nil, false
+		}
+		etherType, b = binary.BigEndian.Uint16(b[14:16]), b[16:]
+	case LinkTypeLinuxSLL2:
+		if len(b) < 20 {
+			return nil, false
+		}
+		etherType, b = binary.BigEndian.Uint16(b[0:2]), b[20:]
+	case LinkTypeNull:
+		if len(b) < 4 {
+			return nil, false
+		}
+		// The address family is in the byte order of the capturing host.
+		family := binary.LittleEndian.Uint32(b[0:4])
+		if family > 0xffff {
+			family = binary.BigEndian.Uint32(b[0:4])
+		}
+		switch family {
+		case 2:
+			etherType = etherTypeIPv4
+		case 10, 24, 28, 30: // AF_INET6 on Linux, BSDs and macOS
+			etherType = etherTypeIPv6
+		}
+		b = b[4:]
+	case LinkTypeRaw, LinkTypeIPv4, LinkTypeIPv6:
+		if len(b) == 0 {
+			return nil, false
+		}
+		switch b[0] >> 4 {
+		case 4:
+			etherType = etherTypeIPv4
+		case 6:
+			etherType = etherTypeIPv6
+		}
+	default:
+		return nil, false
+	}
+
+	switch etherType {
+	case etherTypeEAPOL:
+		d.eapol = b
+		return d, true
+	case etherTypeIPv4:
+		return d, d.decodeIPv4(b)
+	case etherTypeIPv6:
+		return d, d.decodeIPv6(b)
+	}
+	return nil, false
+}
+
+func macString(b []byte) string {
+	const hex = "0123456789abcdef"
+	s := make([]byte, 0, 17)
+	for i, c := range b {
+		if i > 0 {
+			s = append(s, ':')
+		}
+		s = append(s, hex[c>>4], hex[c&0xf])
+	}
+	return string(s)
+}
+
+func (d *decoded) decodeIPv4(b []byte) bool {
+	if len(b) < 20 || b[0]>>4 != 4 {
+		return false
+	}
+	ihl := int(b[0]&0x0f) * 4
+	total := int(binary.BigEndian.Uint16(b[2:4]))
+	if ihl < 20 || total < ihl || total > len(b) {
+		return false
+	}
+	// Skip fragments: MF set or a non-zero fragment offset.
+	if binary.BigEndian.Uint16(b[6:8])&0x3fff != 0 {
+		return false
+	}
+	src, _ := netip.AddrFromSlice(b[12:16])
+	dst, _ := netip.AddrFromSlice(b[16:20])
+	// The total length drops Ethernet padding.
+	return d.decodeTransport(b[9], src, dst, b[ihl:total])
+}
+
+func (d *decoded) decodeIPv6(b []byte) bool {
+	if len(b) < 40 || b[0]>>4 != 6 {
+		return false
+	}
+	n := int(binary.BigEndian.Uint16(b[4:6]))
+	if 40+n > len(b) {
+		return false
+	}
+	src, _ := netip.AddrFromSlice(b[8:24])
+	dst, _ := netip.AddrFromSlice(b[24:40])
+	next, p := b[6], b[40:40+n]
+	for {
+		switch next {
+		case 0, 43, 60: // Hop-by-Hop, Routing, Destination Options
+			if len(p) < 8 || len(p) < (int(p[1])+1)*8 {
+				return false
+			}
+			next, p = p[0], p[(int(p[1])+1)*8:]
+		case 51: // Authentication Header
+			if len(p) < 8 || len(p) < (int(p[1])+2)*4 {
+				return false
+			}
+			next, p = p[0], p[(int(p[1])+2)*4:]
+		case 44: // Fragment
+			return false
+		default:
+			return d.decodeTransport(next, src, dst, p)
+		}
+	}
+}
+
+func (d *decoded) decodeTransport(proto uint8, src, dst netip.Addr, b []byte) bool {
+	d.proto = proto
+	switch proto {
+	case ipProtoUDP:
+		if len(b) < 8 {
+			return false
+		}
+		n := int(binary.BigEndian.Uint16(b[4:6]))
+		if n < 8 || n > len(b) {
+			return false
+		}
+		d.setPorts(src, dst, b)
+		d.payload = b[8:n]
+	case ipProtoTCP:
+		if len(b) < 20 {
+			return false
+		}
+		off := int(b[12]>>4) * 4
+		if off < 20 || off > len(b) {
+			return false
+		}
+		d.setPorts(src, dst, b)
+		d.seq = binary.BigEndian.Uint32(b[4:8])
+		d.flags = b[13]
+		d.payload = b[off:]
+	case ipProtoSCTP:
+		if len(b) < 12 {
+			return false
+		}
+		d.setPorts(src, dst, b)
+		for c := b[12:]; len(c) >= 4; {
+			n := int(binary.BigEndian.Uint16(c[2:4]))
+			if n < 4 || n > len(c) {
+				break
+			}
+			if c[0] == sctpChunkData && n >= 16 {
+				d.chunks = append(d.chunks, sctpChunk{
+					stream: binary.BigEndian.Uint16(c[8:10]),
+					flags:  c[1],
+					ppid:   binary.BigEndian.Uint32(c[12:16]),
+					data:   c[16:n],
+				})
+			}
+			c = c[min(len(c), (n+3)&^3):]
+		}
+	default:
+		return false
+	}
+	return true
+}
+
+func (d *decoded) setPorts(src, dst netip.Addr, b []byte) {
+	d.src = netip.AddrPortFrom(src, binary.BigEndian.Uint16(b[0:2]))
+	d.dst = netip.AddrPortFrom(dst, binary.BigEndian.Uint16(b[2:4]))
+}
@@ -0,0 +1,468 @@
+package pcap
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/oyaguma3/go-eapaka"
+	"github.com/oyaguma3/go-eapaka/radius"
+)
+
+// Transport identifies how an EAP packet was carried.
+type Transport int
+
+// Transports recognised by [Extractor].
+const (
+	TransportEAPOL Transport = iota + 1
+	TransportRADIUS
+	TransportDiameter
+)
+
+func (t Transport) String() string {
+	switch t {
+	case TransportEAPOL:
+		return "EAPOL"
+	case TransportRADIUS:
+		return "RADIUS"
+	case TransportDiameter:
+		return "Diameter"
+	}
+	return "unknown"
+}
+
+// Diameter constants (RFC 6733, RFC 4072)
+const (
+	diameterHeaderLen     = 20
+	diameterFlagVendor    = 0x80
+	diameterAVPSessionID  = 263
+	diameterAVPEAPPayload = 462
+	diameterPPID          = 46 // SCTP payload protocol identifier
+)
+
+// Options configures an [Extractor]. The zero value is usable.
+type Options struct {
+	// RADIUSPorts are the UDP ports treated as RADIUS. Default: 1812 and 1645.
+	RADIUSPorts []uint16
+
+	// DiameterPorts are the TCP and SCTP ports treated as Diameter. Default:
+	// 3868. SCTP chunks with the Diameter payload protocol identifier are
+	// recognised on any port.
+	DiameterPorts []uint16
+
+	// ParseOptions is passed to [eapaka.ParseWithOptions]. The zero value is
+	// [eapaka.LenientParseOptions].
+	ParseOptions eapaka.ParseOptions
+}
+
+// Message is an EAP packet found in a capture.
+type Message struct {
+	Frame     int // index of the frame in the capture, starting at 1
+	Time      time.Time
+	Transport Transport
+	Src, Dst  string // "ip:port", or MAC addresses for EAPOL (empty if unknown)
+
+	State     []byte // RADIUS State attribute, if any
+	SessionID string // Diameter Session-Id
+
+	EAP    []byte         // the EAP packet, reassembled from EAP-Message attributes for RADIUS
+	Packet *eapaka.Packet // nil if the packet could not be parsed
+	Err    error          // parse error
+}
+
+// Conversation is a sequence of EAP packets belonging to one authentication.
+type Conversation struct {
+	Transport Transport
+	// Identity is the identity from EAP-Response/Identity, replaced by a later
+	// AT_IDENTITY, if any.
+	Identity string
+	Messages []*Message
+
+	key  string // transport-specific endpoint key
+	done bool   // EAP-Success/Failure or RADIUS Accept/Reject seen
+}
+
+// Packets returns the parsed packets of the conversation in capture order,
+// omitting packets that could not be parsed.
+func (c *Conversation) Packets() []*eapaka.Packet {
+	var pkts []*eapaka.Packet
+	for _, m := range c.Messages {
+		if m.Packet != nil {
+			pkts = append(pkts, m.Packet)
+		}
+	}
+	return pkts
+}
+
+// lastRequest returns the EAP Identifier of the last EAP-Request of the
+// conversation, if the last message is a request.
+func (c *Conversation) lastRequest() (uint8, bool) {
+	if len(c.Messages) == 0 {
+		return 0, false
+	}
+	eap := c.Messages[len(c.Messages)-1].EAP
+	if len(eap) < 2 || eap[0] != eapaka.CodeRequest {
+		return 0, false
+	}
+	return eap[1], true
+}
+
+// Extractor finds EAP packets in captured frames and groups them into
+// conversations:
+//
+//   - RADIUS: an Access-Challenge is paired with its Access-Request, and its
+//     State attribute links it to the next Access-Request. A request without
+//     State joins the open conversation between the same client and server
+//     whose last EAP-Request has the same EAP Identifier, or starts a new one.
+//     Retransmitted requests and duplicate responses are dropped.
+//   - Diameter: messages are grouped by Session-Id. TCP streams are reassembled
+//     per direction; SCTP DATA chunks are reassembled per stream.
+//   - EAPOL: packets are grouped by supplicant MAC address; EAPOL-Start and an
+//     EAP-Request/Identity following other EAP packets start a new conversation.
+//
+// A conversation ends with EAP-Success or EAP-Failure, or a RADIUS
+// Access-Accept or Access-Reject.
+type Extractor struct {
+	opts   Options
+	frames int
+	convs  []*Conversation
+
+	radiusReqs  map[string]*radiusRequest // client|server|RADIUS Identifier
+	radiusState map[string]*Conversation  // State attribute
+	diameter    map[string]*Conversation  // Session-Id
+	tcp         map[string]*tcpStream     // src|dst
+	sctp        map[string][]byte         // src|dst|stream, pending fragments
+	eapol       map[string]*Conversation  // supplicant MAC
+}
+
+type radiusRequest struct {
+	auth     [16]byte
+	conv     *Conversation
+	answered bool
+}
+
+type tcpStream struct {
+	next uint32
+	buf  []byte
+}
+
+// NewExtractor returns an Extractor. opts may be nil.
+func NewExtractor(opts *Options) *Extractor {
+	e := &Extractor{
+		radiusReqs:  make(map[string]*radiusRequest),
+		radiusState: make(map[string]*Conversation),
+		diameter:    make(map[string]*Conversation),
+		tcp:         make(map[string]*tcpStream),
+		sctp:        make(map[string][]byte),
+		eapol:       make(map[string]*Conversation),
+	}
+	if opts != nil {
+		e.opts = *opts
+	}
+	if e.opts.RADIUSPorts == nil {
+		e.opts.RADIUSPorts = []uint16{1812, 1645}
+	}
+	if e.opts.DiameterPorts == nil {
+		e.opts.DiameterPorts = []uint16{3868}
+	}
+	return e
+}
+
+// AddFrame processes the next frame of the capture. Frames that carry no EAP
+// are ignored.
+func (e *Extractor) AddFrame(f *Frame) {
+	e.frames++
+	d, ok := decodeFrame(f)
+	if !ok {
+		return
+	}
+	m := Message{Frame: e.frames, Time: f.Time}
+	switch {
+	case d.eapol != nil:
+		e.addEAPOL(d, m)
+	case d.proto == ipProtoUDP && e.isPort(e.opts.RADIUSPorts, d):
+		e.addRADIUS(d, m)
+	case d.proto == ipProtoTCP && e.isPort(e.opts.DiameterPorts, d):
+		e.addTCP(d, m)
+	case d.proto == ipProtoSCTP:
+		e.addSCTP(d, m)
+	}
+}
+
+// Conversations returns the conversations found so far, in order of their first message.
+func (e *Extractor) Conversations() []*Conversation {
+	return e.convs
+}
+
+// ReadConversations reads a pcap or pcapng capture from r and returns the EAP
+// conversations it contains. opts may be nil.
+func ReadConversations(r io.Reader, opts *Options) ([]*Conversation, error) {
+	rd, err := NewReader(r)
+	if err != nil {
+		return nil, err
+	}
+	e := NewExtractor(opts)
+	for {
+		f, err := rd.Next()
+		if errors.Is(err, io.EOF) {
+			return e.Conversations(), nil
+		}
+		if err != nil {
+			return e.Conversations(), err
+		}
+		e.AddFrame(f)
+	}
+}
+
+func (e *Extractor) isPort(ports []uint16, d *decoded) bool {
+	return slices.Contains(ports, d.src.Port()) || slices.Contains(ports, d.dst.Port())
+}
+
+func (e *Extractor) newConversation(t Transport, key string) *Conversation {
+	c := &Conversation{Transport: t, key: key}
+	e.convs = append(e.convs, c)
+	return c
+}
+
+// append parses the EAP packet of m and appends m to c.
+func (e *Extractor) append(c *Conversation, m Message) {
+	m.Transport = c.Transport
+	m.Packet, m.Err = eapaka.ParseWithOptions(m.EAP, e.opts.ParseOptions)
+	if p := m.Packet; p != nil {
+		switch {
+		case p.Code == eapaka.CodeSuccess || p.Code == eapaka.CodeFailure:
+			c.done = true
+		case p.Code == eapaka.CodeResponse && p.Type == eapaka.TypeIdentity && c.Identity == "":
+			c.Identity, _ = p.EAPIdentity()
+		}
+		if id, ok := eapaka.Find[*eapaka.AtIdentity](p); ok && p.Code == eapaka.CodeResponse {
+			c.Identity = id.Identity
+		}
+	}
+	c.Messages = append(c.Messages, &m)
+}
+
+func (e *Extractor) addRADIUS(d *decoded, m Message) {
+	p, err := radius.Parse(d.payload)
+	if err != nil {
+		return
+	}
+	m.Src, m.Dst = d.src.String(), d.dst.String()
+	m.EAP = p.EAPMessage()
+	m.State = p.Get(radius.AttrState)
+
+	switch p.Code {
+	case radius.CodeAccessRequest:
+		if m.EAP == nil || len(m.EAP) < 2 {
+			return
+		}
+		pair := d.src.String() + "|" + d.dst.String()
+		key := pair + "|" + strconv.Itoa(int(p.Identifier))
+		if r, ok := e.radiusReqs[key]; ok && r.auth == p.Authenticator {
+			return // retransmission
+		}
+		c := e.radiusState[string(m.State)]
+		if m.State == nil || c == nil || c.done {
+			c = e.openRADIUS(pair, m.EAP[1])
+		}
+		e.radiusReqs[key] = &radiusRequest{auth: p.Authenticator, conv: c}
+		e.append(c, m)
+	case radius.CodeAccessChallenge, radius.CodeAccessAccept, radius.CodeAccessReject:
+		key := d.dst.String() + "|" + d.src.String() + "|" + strconv.Itoa(int(p.Identifier))
+		r, ok := e.radiusReqs[key]
+		if !ok || r.answered {
+			return
+		}
+		r.answered = true
+		c := r.conv
+		if m.EAP != nil {
+			e.append(c, m)
+		}
+		if p.Code == radius.CodeAccessChallenge {
+			if m.State != nil {
+				e.radiusState[string(m.State)] = c
+			}
+		} else {
+			c.done = true
+		}
+	}
+}
+
+// openRADIUS returns the open conversation between the client and server in
+// pair that is waiting for the response with EAP Identifier id, or a new one.
+func (e *Extractor) openRADIUS(pair string, id uint8) *Conversation {
+	for _, c := range slices.Backward(e.convs) {
+		if c.Transport != TransportRADIUS || c.key != pair || c.done {
+			continue
+		}
+		if last, ok := c.lastRequest(); ok && last == id {
+			return c
+		}
+	}
+	return e.newConversation(TransportRADIUS, pair)
+}
+
+func (e *Extractor) addTCP(d *decoded, m Message) {
+	key := d.src.String() + "|" + d.dst.String()
+	s := e.tcp[key]
+	switch {
+	case d.flags&(tcpSYN|tcpRST) != 0:
+		delete(e.tcp, key)
+		if d.flags&tcpSYN != 0 {
+			e.tcp[key] = &tcpStream{next: d.seq + 1}
+		}
+		return
+	case len(d.payload) == 0:
+		return
+	case s == nil:
+		// The capture started mid-stream.
+		s = &tcpStream{next: d.seq}
+		e.tcp[key] = s
+	}
+
+	data := d.payload
+	switch delta := int32(d.seq - s.next); {
+	case delta > 0:
+		// Missing segment: resynchronise at the next Diameter header.
+		s.buf, s.next = nil, d.seq
+	case delta < 0:
+		// Retransmission, possibly with new data.
+		if -delta >= int32(len(data)) {
+			return
+		}
+		data = data[-delta:]
+	}
+	s.buf = append(s.buf, data...)
+	s.next += uint32(len(data))
+
+	for len(s.buf) >= diameterHeaderLen {
+		n := int(binary.BigEndian.Uint32(s.buf[0:4]) & 0xffffff)
+		if s.buf[0] != 1 || n < diameterHeaderLen || n > maxBlockLen {
+			s.buf = nil // not Diameter, or out of sync
+			return
+		}
+		if len(s.buf) < n {
+			return
+		}
+		e.addDiameter(d, m, s.buf[:n])
+		s.buf = s.buf[n:]
+	}
+	s.buf = slices.Clip(s.buf)
+}
+
+func (e *Extractor) addSCTP(d *decoded, m Message) {
+	for _, c := range d.chunks {
+		if c.ppid != diameterPPID && !e.isPort(e.opts.DiameterPorts, d) {
+			continue
+		}
+		key := d.src.String() + "|" + d.dst.String() + "|" + strconv.Itoa(int(c.stream))
+		buf := e.sctp[key]
+		if c.flags&sctpFlagBegin != 0 {
+			buf = nil
+		}
+		buf = append(buf, c.data...)
+		if c.flags&sctpFlagEnd == 0 {
+			e.sctp[key] = buf
+			continue
+		}
+		delete(e.sctp, key)
+		e.addDiameter(d, m, buf)
+	}
+}
+
+// addDiameter handles a complete Diameter message.
+func (e *Extractor) addDiameter(d *decoded, m Message, msg []byte) {
+	if len(msg) < diameterHeaderLen {
+		return
+	}
+	var eap []byte
+	for avps := msg[diameterHeaderLen:]; len(avps) >= 8; {
+		code := binary.BigEndian.Uint32(avps[0:4])
+		flags := avps[4]
+		n := int(binary.BigEndian.Uint32(avps[4:8]) & 0xffffff)
+		hdr := 8
+		if flags&diameterFlagVendor != 0 {
+			hdr = 12
+		}
+		if n < hdr || n > len(avps) {
+			break
+		}
+		switch {
+		case code == diameterAVPSessionID && hdr == 8:
+			m.SessionID = string(avps[hdr:n])
+		case code == diameterAVPEAPPayload && hdr == 8:
+			eap = avps[hdr:n]
+		}
+		avps = avps[min(len(avps), (n+3)&^3):]
+	}
+	if eap == nil || m.SessionID == "" {
+		return
+	}
+	m.Src, m.Dst = d.src.String(), d.dst.String()
+	m.EAP = append([]byte(nil), eap...)
+	c := e.diameter[m.SessionID]
+	if c == nil || c.done {
+		c = e.newConversation(TransportDiameter, m.SessionID)
+		e.diameter[m.SessionID] = c
+	}
+	e.append(c, m)
+}
+
+func (e *Extractor) addEAPOL(d *decoded, m Message) {
+	b := d.eapol
+	if len(b) < 4 {
+		return
+	}
+	m.Src, m.Dst = d.srcMAC, d.dstMAC
+	switch b[1] {
+	case eapolStart:
+		// The supplicant sent EAPOL-Start.
+		if c := e.eapol[d.srcMAC]; c != nil {
+			c.done = true
+		}
+		return
+	case eapolEAPPacket:
+	default:
+		return
+	}
+	n := int(binary.BigEndian.Uint16(b[2:4]))
+	if n < 4 || 4+n > len(b) {
+		return
+	}
+	m.EAP = append([]byte(nil), b[4:4+n]...)
+
+	// The supplicant is the receiver of requests and the sender of responses.
+	supplicant := d.dstMAC
+	if m.EAP[0] == eapaka.CodeResponse {
+		supplicant = d.srcMAC
+	}
+	c := e.eapol[supplicant]
+	if c == nil || c.done || (isIdentityRequest(m.EAP) && !identityOnly(c)) {
+		c = e.newConversation(TransportEAPOL, supplicant)
+		e.eapol[supplicant] = c
+	}
+	e.append(c, m)
+}
+
+// EAPOL packet types (IEEE 802.1X-2010 Section 11.3.2)
+const (
+	eapolEAPPacket = 0
+	eapolStart     = 1
+)
+
+func isIdentityRequest(eap []byte) bool {
+	return len(eap) >= 5 && eap[0] == eapaka.CodeRequest && eap[4] == eapaka.TypeIdentity
+}
+
+// identityOnly reports whether c holds nothing but EAP Identity exchanges.
+func identityOnly(c *Conversation) bool {
+	for _, m := range c.Messages {
+		if len(m.EAP) < 5 || m.EAP[4] != eapaka.TypeIdentity {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,338 @@
+package pcap_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/oyaguma3/go-eapaka"
+	"github.com/oyaguma3/go-eapaka/pcap"
+	"github.com/oyaguma3/go-eapaka/radius"
+)
+
+const testIdentity = "0001010123456789@example.org"
+
+func marshalEAP(t *testing.T, b *eapaka.Builder) []byte {
+	t.Helper()
+	p, err := b.Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw, err := p.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return raw
+}
+
+// identityResponse returns EAP-Response/Identity.
+func identityResponse(id uint8, identity string) []byte {
+	b := []byte{eapaka.CodeResponse, id, 0, 0, 1}
+	b = append(b, identity...)
+	binary.BigEndian.PutUint16(b[2:4], uint16(len(b)))
+	return b
+}
+
+func ipv4UDP(src, dst [4]byte, sport, dport uint16, payload []byte) []byte {
+	udp := binary.BigEndian.AppendUint16(nil, sport)
+	udp = binary.BigEndian.AppendUint16(udp, dport)
+	udp = binary.BigEndian.AppendUint16(udp, uint16(8+len(payload)))
+	udp = append(udp, 0, 0)
+	udp = append(udp, payload...)
+
+	ip := []byte{0x45, 0, 0, 0, 0, 0, 0, 0, 64, 17, 0, 0}
+	binary.BigEndian.PutUint16(ip[2:4], uint16(20+len(udp)))
+	ip = append(ip, src[:]...)
+	ip = append(ip, dst[:]...)
+	return append(ip, udp...)
+}
+
+func ipv6TCP(src, dst byte, sport, dport uint16, seq uint32, flags byte, payload []byte) []byte {
+	tcp := binary.BigEndian.AppendUint16(nil, sport)
+	tcp = binary.BigEndian.AppendUint16(tcp, dport)
+	tcp = binary.BigEndian.AppendUint32(tcp, seq)
+	tcp = append(tcp, 0, 0, 0, 0, 5<<4, flags, 0xff, 0xff, 0, 0, 0, 0)
+	tcp = append(tcp, payload...)
+
+	ip := []byte{0x60, 0, 0, 0, 0, 0, 6, 64}
+	binary.BigEndian.PutUint16(ip[4:6], uint16(len(tcp)))
+	srcAddr, dstAddr := [16]byte{0: 0xfd, 15: src}, [16]byte{0: 0xfd, 15: dst}
+	ip = append(ip, srcAddr[:]...)
+	ip = append(ip, dstAddr[:]...)
+	return append(ip, tcp...)
+}
+
+func ethernet(dst, src byte, etherType uint16, payload []byte, vlan bool) []byte {
+	b := []byte{2, 0, 0, 0, 0, dst, 2, 0, 0, 0, 0, src}
+	if vlan {
+		b = append(b, 0x81, 0x00, 0, 10)
+	}
+	b = binary.BigEndian.AppendUint16(b, etherType)
+	b = append(b, payload...)
+	for len(b) < 60 {
+		b = append(b, 0) // padding must not be taken for payload
+	}
+	return b
+}
+
+func eapol(eap []byte) []byte {
+	b := []byte{2, 0}
+	b = binary.BigEndian.AppendUint16(b, uint16(len(eap)))
+	return append(b, eap...)
+}
+
+func radiusPacket(t *testing.T, code, id uint8, auth byte, eap, state []byte) []byte {
+	t.Helper()
+	p := &radius.Packet{Code: code, Identifier: id}
+	p.Authenticator[0] = auth
+	p.SetEAPMessage(eap)
+	if state != nil {
+		p.Add(radius.AttrState, state)
+	}
+	b, err := p.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func diameterMessage(request bool, sessionID string, eap []byte) []byte {
+	avp := func(code uint32, data []byte) []byte {
+		b := binary.BigEndian.AppendUint32(nil, code)
+		b = binary.BigEndian.AppendUint32(b, 0x40<<24|uint32(8+len(data)))
+		b = append(b, data...)
+		for len(b)%4 != 0 {
+			b = append(b, 0)
+		}
+		return b
+	}
+	body := avp(263, []byte(sessionID))
+	body = append(body, avp(462, eap)...)
+	// A vendor-specific AVP that must be skipped.
+	body = append(body, 0, 0, 0, 1, 0xc0, 0, 0, 16, 0, 0, 0x28, 0xaf, 1, 2, 3, 4)
+
+	flags := byte(0x40)
+	if request {
+		flags |= 0x80
+	}
+	hdr := binary.BigEndian.AppendUint32(nil, 1<<24|uint32(20+len(body)))
+	hdr = binary.BigEndian.AppendUint32(hdr, uint32(flags)<<24|268)
+	hdr = append(hdr, 0, 0, 0, 5, 0, 0, 0, 1, 0, 0, 0, 1)
+	return append(hdr, body...)
+}
+
+// writePcap writes a little-endian microsecond pcap file.
+func writePcap(linkType pcap.LinkType, frames ...[]byte) []byte {
+	le := binary.LittleEndian
+	b := le.AppendUint32(nil, 0xa1b2c3d4)
+	b = le.AppendUint16(b, 2)
+	b = le.AppendUint16(b, 4)
+	b = append(b, make([]byte, 8)...)
+	b = le.AppendUint32(b, 65535)
+	b = le.AppendUint32(b, uint32(linkType))
+	for i, f := range frames {
+		b = le.AppendUint32(b, 1700000000+uint32(i))
+		b = le.AppendUint32(b, 250000)
+		b = le.AppendUint32(b, uint32(len(f)))
+		b = le.AppendUint32(b, uint32(len(f)))
+		b = append(b, f...)
+	}
+	return b
+}
+
+type ngFrame struct {
+	iface uint32
+	data  []byte
+}
+
+// writePcapng writes a big-endian pcapng file with two interfaces: Ethernet
+// with microsecond timestamps and raw IP with nanosecond timestamps.
+func writePcapng(frames ...ngFrame) []byte {
+	be := binary.BigEndian
+	block := func(b []byte, typ uint32, body []byte) []byte {
+		for len(body)%4 != 0 {
+			body = append(body, 0)
+		}
+		b = be.AppendUint32(b, typ)
+		b = be.AppendUint32(b, uint32(12+len(body)))
+		b = append(b, body...)
+		return be.AppendUint32(b, uint32(12+len(body)))
+	}
+	shb := be.AppendUint32(nil, 0x1a2b3c4d)
+	shb = append(shb, 0, 1, 0, 0, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff)
+	b := block(nil, 0x0a0d0d0a, shb)
+	b = block(b, 1, []byte{0, 1, 0, 0, 0, 0, 0xff, 0xff})
+	// if_tsresol = 9, then opt_endofopt
+	b = block(b, 1, []byte{0, 101, 0, 0, 0, 0, 0xff, 0xff, 0, 9, 0, 1, 9, 0, 0, 0, 0, 0, 0, 0})
+	// A name resolution block to be skipped.
+	b = block(b, 4, []byte{0, 0, 0, 0})
+	for i, f := range frames {
+		ts := uint64(1700000000+i) * 1e6
+		if f.iface == 1 {
+			ts *= 1000
+		}
+		body := be.AppendUint32(nil, f.iface)
+		body = be.AppendUint32(body, uint32(ts>>32))
+		body = be.AppendUint32(body, uint32(ts))
+		body = be.AppendUint32(body, uint32(len(f.data)))
+		body = be.AppendUint32(body, uint32(len(f.data)))
+		b = block(b, 6, append(body, f.data...))
+	}
+	return b
+}
+
+func summarize(c *pcap.Conversation) string {
+	var s []string
+	for _, p := range c.Packets() {
+		switch {
+		case p.Code == eapaka.CodeSuccess:
+			s = append(s, "Success")
+		case p.Type == 1:
+			s = append(s, "Identity")
+		default:
+			s = append(s, []string{eapaka.CodeRequest: "Req", eapaka.CodeResponse: "Resp"}[p.Code]+"/"+
+				map[uint8]string{eapaka.SubtypeIdentity: "Identity", eapaka.SubtypeChallenge: "Challenge"}[p.Subtype])
+		}
+	}
+	return strings.Join(s, " ")
+}
+
+func TestReadConversations_RADIUS(t *testing.T) {
+	nas, aaa := [4]byte{10, 0, 0, 1}, [4]byte{10, 0, 0, 2}
+	req := func(id, auth uint8, eap, state []byte) []byte {
+		return ethernet(2, 1, 0x0800, ipv4UDP(nas, aaa, 40000, 1812, radiusPacket(t, radius.CodeAccessRequest, id, auth, eap, state)), false)
+	}
+	resp := func(code, id uint8, eap, state []byte) []byte {
+		return ethernet(1, 2, 0x0800, ipv4UDP(aaa, nas, 1812, 40000, radiusPacket(t, code, id, 0, eap, state)), false)
+	}
+
+	identityReq := marshalEAP(t, eapaka.NewIdentityRequest(eapaka.TypeAKAPrime, 1, eapaka.AT_PERMANENT_ID_REQ))
+	identityResp := marshalEAP(t, eapaka.NewIdentityResponse(eapaka.TypeAKAPrime, 1, "6"+testIdentity[1:]))
+	// A long network name makes the EAP packet span several EAP-Message attributes.
+	challenge := marshalEAP(t, eapaka.NewChallengeRequestAKAPrime(2, make([]byte, 16), make([]byte, 16), strings.Repeat("n", 400)))
+	challengeResp := marshalEAP(t, eapaka.NewChallengeResponse(eapaka.TypeAKAPrime, 2, make([]byte, 8)))
+	success := []byte{eapaka.CodeSuccess, 2, 0, 4}
+
+	data := writePcap(pcap.LinkTypeEthernet,
+		req(10, 1, identityResponse(0, testIdentity), nil),
+		resp(radius.CodeAccessChallenge, 10, identityReq, []byte("s1")),
+		resp(radius.CodeAccessChallenge, 10, identityReq, []byte("s1")), // duplicate
+		// A second client starts while the first is in progress.
+		req(20, 2, identityResponse(7, "other@example.org"), nil),
+		req(11, 3, identityResp, []byte("s1")),
+		req(11, 3, identityResp, []byte("s1")), // retransmission
+		resp(radius.CodeAccessChallenge, 11, challenge, []byte("s2")),
+		req(12, 4, challengeResp, []byte("s2")),
+		resp(radius.CodeAccessAccept, 12, success, nil),
+	)
+	convs, err := pcap.ReadConversations(bytes.NewReader(data), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(convs) != 2 {
+		t.Fatalf("got %d conversations, want 2", len(convs))
+	}
+	c := convs[0]
+	if got, want := summarize(c), "Identity Req/Identity Resp/Identity Req/Challenge Resp/Challenge Success"; got != want {
+		t.Errorf("conversation = %q, want %q", got, want)
+	}
+	if c.Transport != pcap.TransportRADIUS || c.Identity != "6"+testIdentity[1:] {
+		t.Errorf("transport = %v, identity = %q", c.Transport, c.Identity)
+	}
+	m := c.Messages[3]
+	if m.Frame != 7 || !bytes.Equal(m.EAP, challenge) || string(m.State) != "s2" || m.Src != "10.0.0.2:1812" {
+		t.Errorf("challenge message = frame %d, state %q, src %s", m.Frame, m.State, m.Src)
+	}
+	if want := time.Unix(1700000006, 250000000).UTC(); !m.Time.Equal(want) {
+		t.Errorf("time = %v, want %v", m.Time, want)
+	}
+	kdfInput, _ := eapaka.Find[*eapaka.AtKdfInput](m.Packet)
+	if kdfInput == nil || len(kdfInput.NetworkName) != 400 {
+		t.Errorf("AT_KDF_INPUT not reassembled: %v", m.Packet)
+	}
+	if got := summarize(convs[1]); got != "Identity" || convs[1].Identity != "other@example.org" {
+		t.Errorf("second conversation = %q, identity %q", got, convs[1].Identity)
+	}
+}
+
+func TestReadConversations_PcapngEAPOLAndDiameter(t *testing.T) {
+	identityReq := []byte{eapaka.CodeRequest, 1, 0, 5, 1}
+	akaIdentityReq := marshalEAP(t, eapaka.NewIdentityRequest(eapaka.TypeAKA, 2, eapaka.AT_ANY_ID_REQ))
+	akaIdentityResp := marshalEAP(t, eapaka.NewIdentityResponse(eapaka.TypeAKA, 2, testIdentity))
+	challenge := marshalEAP(t, eapaka.NewChallengeRequest(eapaka.TypeAKA, 3, make([]byte, 16), make([]byte, 16)))
+
+	// EAPOL between authenticator 0x10 and supplicant 0x20, tagged with a VLAN.
+	toSupplicant := func(eap []byte) ngFrame {
+		return ngFrame{0, ethernet(0x20, 0x10, 0x888e, eapol(eap), true)}
+	}
+	fromSupplicant := func(eap []byte) ngFrame {
+		return ngFrame{0, ethernet(0x10, 0x20, 0x888e, eapol(eap), true)}
+	}
+
+	// Diameter over TCP on raw IPv6; the request is split over two segments.
+	der := diameterMessage(true, "nas;1;1", identityResponse(1, testIdentity))
+	dea := diameterMessage(false, "nas;1;1", akaIdentityReq)
+	const seq = 1000
+	tcp := func(fromNAS bool, seq uint32, flags byte, payload []byte) ngFrame {
+		if fromNAS {
+			return ngFrame{1, ipv6TCP(1, 2, 50000, 3868, seq, flags, payload)}
+		}
+		return ngFrame{1, ipv6TCP(2, 1, 3868, 50000, seq, flags, payload)}
+	}
+
+	data := writePcapng(
+		ngFrame{0, ethernet(0x10, 0x20, 0x888e, []byte{2, 1, 0, 0}, false)}, // EAPOL-Start
+		toSupplicant(identityReq),
+		tcp(true, seq, 0x02, nil), // SYN
+		tcp(true, seq+1, 0x18, der[:30]),
+		fromSupplicant(identityResponse(1, testIdentity)),
+		tcp(true, seq+1, 0x18, der[:30]), // retransmission
+		tcp(true, seq+31, 0x18, der[30:]),
+		tcp(false, 5000, 0x18, dea),
+		toSupplicant(akaIdentityReq),
+		fromSupplicant(akaIdentityResp),
+		toSupplicant(challenge),
+		// The authenticator restarts: a new conversation.
+		toSupplicant(identityReq),
+	)
+	convs, err := pcap.ReadConversations(bytes.NewReader(data), &pcap.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(convs) != 3 {
+		t.Fatalf("got %d conversations, want 3", len(convs))
+	}
+
+	eap, dia, restart := convs[0], convs[1], convs[2]
+	if got, want := summarize(eap), "Identity Identity Req/Identity Resp/Identity Req/Challenge"; got != want {
+		t.Errorf("EAPOL conversation = %q, want %q", got, want)
+	}
+	if eap.Transport != pcap.TransportEAPOL || eap.Identity != testIdentity || eap.Messages[0].Dst != "02:00:00:00:00:20" {
+		t.Errorf("EAPOL conversation: transport %v, identity %q, dst %s", eap.Transport, eap.Identity, eap.Messages[0].Dst)
+	}
+	if got, want := summarize(dia), "Identity Req/Identity"; got != want {
+		t.Errorf("Diameter conversation = %q, want %q", got, want)
+	}
+	if dia.Transport != pcap.TransportDiameter || dia.Messages[0].SessionID != "nas;1;1" || dia.Messages[0].Frame != 7 {
+		t.Errorf("Diameter conversation: transport %v, messages %+v", dia.Transport, dia.Messages[0])
+	}
+	if want := time.Unix(1700000007, 0).UTC(); !dia.Messages[1].Time.Equal(want) {
+		t.Errorf("nanosecond timestamp = %v, want %v", dia.Messages[1].Time, want)
+	}
+	if restart.Transport != pcap.TransportEAPOL || len(restart.Messages) != 1 {
+		t.Errorf("restarted conversation: %+v", restart)
+	}
+}
+
+func TestReadConversations_Errors(t *testing.T) {
+	if _, err := pcap.ReadConversations(strings.NewReader("not a capture at all"), nil); !errors.Is(err, pcap.ErrNotCapture) {
+		t.Errorf("err = %v, want ErrNotCapture", err)
+	}
+	data := writePcap(pcap.LinkTypeRaw, []byte{0x45})
+	if _, err := pcap.ReadConversations(bytes.NewReader(data[:len(data)-1]), nil); !errors.Is(err, pcap.ErrCorrupt) {
+		t.Errorf("truncated: err = %v, want ErrCorrupt", err)
+	}
+}
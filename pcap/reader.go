@@ -0,0 +1,302 @@
+// Package pcap reads pcap and pcapng captures and extracts the EAP packets
+// carried in EAPOL frames, RADIUS EAP-Message attributes (RFC 3579) and
+// Diameter EAP-Payload AVPs (RFC 4072), grouped into conversations and
+// decoded with [eapaka.Parse], so that field captures can be analysed in tests.
+//
+// Only the standard library is used. IP fragments are skipped.
+package pcap
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math/bits"
+	"time"
+)
+
+// LinkType is the link-layer header type of a capture (see
+// https://www.tcpdump.org/linktypes.html).
+type LinkType uint32
+
+// Link types supported by [Extractor].
+const (
+	LinkTypeNull      LinkType = 0
+	LinkTypeEthernet  LinkType = 1
+	LinkTypeRaw       LinkType = 101
+	LinkTypeLinuxSLL  LinkType = 113
+	LinkTypeIPv4      LinkType = 228
+	LinkTypeIPv6      LinkType = 229
+	LinkTypeLinuxSLL2 LinkType = 276
+)
+
+// Errors returned by [Reader].
+var (
+	ErrNotCapture = errors.New("pcap: not a pcap or pcapng file")
+	ErrCorrupt    = errors.New("pcap: corrupt capture")
+)
+
+// maxBlockLen bounds the size of a record or block, guarding against corrupt files.
+const maxBlockLen = 16 << 20
+
+// Frame is a captured frame.
+type Frame struct {
+	Time     time.Time
+	LinkType LinkType
+	Data     []byte // captured bytes, possibly truncated to the snap length
+}
+
+// Reader reads the frames of a pcap or pcapng capture.
+type Reader struct {
+	r  *bufio.Reader
+	ng bool
+	bo binary.ByteOrder
+
+	// pcap
+	linkType LinkType
+	nano     bool
+
+	// pcapng, per section
+	ifaces []ngInterface
+}
+
+type ngInterface struct {
+	linkType LinkType
+	tsUnit   time.Duration // zero if a tick is not a whole number of nanoseconds
+	tsDiv    uint64        // ticks per second when tsUnit is zero
+}
+
+const (
+	pcapMagicMicro = 0xa1b2c3d4
+	pcapMagicNano  = 0xa1b23c4d
+	ngSHB          = 0x0a0d0d0a
+	ngByteOrder    = 0x1a2b3c4d
+	ngIDB          = 1
+	ngOPB          = 2 // obsolete Packet Block
+	ngSPB          = 3
+	ngEPB          = 6
+	ngOptTsresol   = 9
+)
+
+// NewReader reads the file header from r and returns a Reader for its frames.
+// The format (pcap with microsecond or nanosecond timestamps, or pcapng) is
+// detected from the magic number.
+func NewReader(r io.Reader) (*Reader, error) {
+	rd := &Reader{r: bufio.NewReader(r)}
+	magic, err := rd.r.Peek(4)
+	if err != nil {
+		return nil, ErrNotCapture
+	}
+	if binary.BigEndian.Uint32(magic) == ngSHB {
+		rd.ng = true
+		if err := rd.readSHB(); err != nil {
+			return nil, err
+		}
+		return rd, nil
+	}
+
+	var hdr [24]byte
+	if _, err := io.ReadFull(rd.r, hdr[:]); err != nil {
+		return nil, ErrNotCapture
+	}
+	for _, bo := range []binary.ByteOrder{binary.LittleEndian, binary.BigEndian} {
+		switch bo.Uint32(hdr[0:4]) {
+		case pcapMagicMicro:
+			rd.bo = bo
+		case pcapMagicNano:
+			rd.bo, rd.nano = bo, true
+		default:
+			continue
+		}
+		// The upper bits of the link type carry FCS information.
+		rd.linkType = LinkType(rd.bo.Uint32(hdr[20:24]) & 0x0fffffff)
+		return rd, nil
+	}
+	return nil, ErrNotCapture
+}
+
+// Next returns the next frame, or [io.EOF] at the end of the capture.
+// pcapng blocks other than packets are skipped.
+func (rd *Reader) Next() (*Frame, error) {
+	if !rd.ng {
+		return rd.nextPcap()
+	}
+	for {
+		f, err := rd.nextBlock()
+		if err != nil || f != nil {
+			return f, err
+		}
+	}
+}
+
+func (rd *Reader) nextPcap() (*Frame, error) {
+	var hdr [16]byte
+	if _, err := io.ReadFull(rd.r, hdr[:]); err != nil {
+		if err == io.ErrUnexpectedEOF {
+			return nil, fmt.Errorf("%w: truncated record header", ErrCorrupt)
+		}
+		return nil, err
+	}
+	sec := int64(rd.bo.Uint32(hdr[0:4]))
+	frac := int64(rd.bo.Uint32(hdr[4:8]))
+	n := rd.bo.Uint32(hdr[8:12])
+	if n > maxBlockLen {
+		return nil, fmt.Errorf("%w: record of %d bytes", ErrCorrupt, n)
+	}
+	data := make([]byte, n)
+	if _, err := io.ReadFull(rd.r, data); err != nil {
+		return nil, fmt.Errorf("%w: truncated record", ErrCorrupt)
+	}
+	if !rd.nano {
+		frac *= 1000
+	}
+	return &Frame{Time: time.Unix(sec, frac).UTC(), LinkType: rd.linkType, Data: data}, nil
+}
+
+// readBlock reads a pcapng block and returns its type and body.
+func (rd *Reader) readBlock() (uint32, []byte, error) {
+	var hdr [8]byte
+	if _, err := io.ReadFull(rd.r, hdr[:]); err != nil {
+		if err == io.ErrUnexpectedEOF {
+			return 0, nil, fmt.Errorf("%w: truncated block header", ErrCorrupt)
+		}
+		return 0, nil, err
+	}
+	typ := rd.bo.Uint32(hdr[0:4])
+	n := rd.bo.Uint32(hdr[4:8])
+	if n < 12 || n%4 != 0 || n > maxBlockLen {
+		return 0, nil, fmt.Errorf("%w: block length %d", ErrCorrupt, n)
+	}
+	body := make([]byte, n-8)
+	if _, err := io.ReadFull(rd.r, body); err != nil {
+		return 0, nil, fmt.Errorf("%w: truncated block", ErrCorrupt)
+	}
+	return typ, body[:len(body)-4], nil // drop the trailing length
+}
+
+// readSHB reads a Section Header Block, which sets the byte order of the section.
+func (rd *Reader) readSHB() error {
+	hdr, err := rd.r.Peek(12)
+	if err != nil {
+		return ErrNotCapture
+	}
+	switch {
+	case binary.LittleEndian.Uint32(hdr[8:12]) == ngByteOrder:
+		rd.bo = binary.LittleEndian
+	case binary.BigEndian.Uint32(hdr[8:12]) == ngByteOrder:
+		rd.bo = binary.BigEndian
+	default:
+		return fmt.Errorf("%w: bad byte-order magic", ErrCorrupt)
+	}
+	if _, _, err := rd.readBlock(); err != nil {
+		return err
+	}
+	rd.ifaces = rd.ifaces[:0]
+	return nil
+}
+
+// nextBlock reads one pcapng block. It returns a nil frame for blocks that are
+// not packets.
+func (rd *Reader) nextBlock() (*Frame, error) {
+	magic, err := rd.r.Peek(4)
+	if err == nil && binary.BigEndian.Uint32(magic) == ngSHB {
+		return nil, rd.readSHB()
+	}
+	typ, body, err := rd.readBlock()
+	if err != nil {
+		return nil, err
+	}
+	switch typ {
+	case ngIDB:
+		if len(body) < 8 {
+			return nil, fmt.Errorf("%w: short interface block", ErrCorrupt)
+		}
+		iface := ngInterface{linkType: LinkType(rd.bo.Uint16(body[0:2])), tsUnit: time.Microsecond}
+		rd.parseIDBOptions(&iface, body[8:])
+		rd.ifaces = append(rd.ifaces, iface)
+		return nil, nil
+	case ngEPB:
+		if len(body) < 20 {
+			return nil, fmt.Errorf("%w: short packet block", ErrCorrupt)
+		}
+		id := rd.bo.Uint32(body[0:4])
+		ts := uint64(rd.bo.Uint32(body[4:8]))<<32 | uint64(rd.bo.Uint32(body[8:12]))
+		return rd.frame(id, ts, body[20:], rd.bo.Uint32(body[12:16]))
+	case ngOPB:
+		if len(body) < 20 {
+			return nil, fmt.Errorf("%w: short packet block", ErrCorrupt)
+		}
+		id := uint32(rd.bo.Uint16(body[0:2]))
+		ts := uint64(rd.bo.Uint32(body[4:8]))<<32 | uint64(rd.bo.Uint32(body[8:12]))
+		return rd.frame(id, ts, body[20:], rd.bo.Uint32(body[12:16]))
+	case ngSPB:
+		if len(body) < 4 {
+			return nil, fmt.Errorf("%w: short packet block", ErrCorrupt)
+		}
+		// The captured length is bounded by the block and the original length.
+		n := min(uint32(len(body)-4), rd.bo.Uint32(body[0:4]))
+		f, err := rd.frame(0, 0, body[4:], n)
+		if f != nil {
+			f.Time = time.Time{}
+		}
+		return f, err
+	}
+	return nil, nil
+}
+
+func (rd *Reader) parseIDBOptions(iface *ngInterface, opts []byte) {
+	for len(opts) >= 4 {
+		code := rd.bo.Uint16(opts[0:2])
+		n := int(rd.bo.Uint16(opts[2:4]))
+		if code == 0 || 4+n > len(opts) {
+			return
+		}
+		if code == ngOptTsresol && n == 1 {
+			iface.tsUnit, iface.tsDiv = tsResolution(opts[4])
+		}
+		opts = opts[4+(n+3)&^3:]
+	}
+}
+
+// tsResolution decodes the if_tsresol option: 10^-v seconds, or 2^-v seconds
+// if the top bit is set. Resolutions that are not a whole number of
+// nanoseconds are returned as ticks per second.
+func tsResolution(v byte) (unit time.Duration, div uint64) {
+	exp := int(v & 0x7f)
+	base := uint64(10)
+	if v&0x80 != 0 {
+		base = 2
+	}
+	// ticks per second
+	var perSec uint64 = 1
+	for range exp {
+		if perSec > 1e18/base {
+			break
+		}
+		perSec *= base
+	}
+	if perSec <= 1e9 && uint64(1e9)%perSec == 0 {
+		return time.Duration(uint64(1e9) / perSec), 0
+	}
+	return 0, perSec
+}
+
+func (rd *Reader) frame(id uint32, ts uint64, data []byte, capLen uint32) (*Frame, error) {
+	if int(id) >= len(rd.ifaces) {
+		return nil, fmt.Errorf("%w: packet for undeclared interface %d", ErrCorrupt, id)
+	}
+	if int(capLen) > len(data) {
+		return nil, fmt.Errorf("%w: captured length %d exceeds block", ErrCorrupt, capLen)
+	}
+	iface := rd.ifaces[id]
+	var t time.Time
+	if iface.tsUnit != 0 {
+		t = time.Unix(0, 0).Add(time.Duration(ts) * iface.tsUnit)
+	} else {
+		hi, lo := bits.Mul64(ts%iface.tsDiv, 1e9)
+		nsec, _ := bits.Div64(hi, lo, iface.tsDiv)
+		t = time.Unix(int64(ts/iface.tsDiv), int64(nsec))
+	}
+	return &Frame{Time: t.UTC(), LinkType: iface.linkType, Data: append([]byte(nil), data[:capLen]...)}, nil
+}
@@ -0,0 +1,469 @@
+// Package peer implements the peer (supplicant) side of EAP-AKA (RFC 4187)
+// and EAP-AKA' (RFC 5448) on top of package eapaka.
+//
+// A [Peer] consumes EAP-Request, EAP-Success and EAP-Failure packets and
+// produces the EAP-Responses to send back. It keeps fast re-authentication
+// state between conversations.
+package peer
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+
+	"github.com/oyaguma3/go-eapaka"
+	"github.com/oyaguma3/go-eapaka/milenage"
+)
+
+// Errors reported by [Peer.Handle].
+var (
+	ErrAuthenticationFailed = errors.New("peer: authentication failed")
+	ErrInvalidMAC           = errors.New("peer: AT_MAC verification failed")
+	ErrNetworkRejected      = errors.New("peer: network authentication failed (AUTN)")
+	ErrUnexpectedPacket     = errors.New("peer: unexpected EAP packet")
+)
+
+// SIM is the subset of a USIM needed by the peer. [milenage.USIM] implements it.
+type SIM interface {
+	// Authenticate returns RES, CK, IK and the AMF from AUTN.
+	// It returns a *milenage.SyncFailureError on SQN failure.
+	Authenticate(rand, autn []byte) (res, ck, ik, amf []byte, err error)
+}
+
+// Status is the state of a [Peer] after [Peer.Handle].
+type Status int
+
+const (
+	// StatusContinue means the conversation is in progress.
+	StatusContinue Status = iota
+	// StatusSuccess means EAP-Success was received after a successful exchange.
+	StatusSuccess
+	// StatusFailure means the conversation failed.
+	StatusFailure
+)
+
+// Config configures a [Peer].
+type Config struct {
+	// Identity is the permanent identity NAI (e.g., from [eapaka.PermanentIdentity]).
+	Identity string
+
+	// SIM runs the AKA algorithms. Required.
+	SIM SIM
+
+	// NetworkName, if set, must match AT_KDF_INPUT in EAP-AKA' Challenges.
+	NetworkName string
+
+	// DisableReauth makes the peer ignore AT_NEXT_REAUTH_ID.
+	DisableReauth bool
+
+	// Types are the methods the peer accepts, in order of preference.
+	// Requests of other methods are answered with a Nak proposing them.
+	// Default: eapaka.TypeAKAPrime, eapaka.TypeAKA.
+	Types []uint8
+
+	// ResultIndication makes the peer answer AT_RESULT_IND from the server
+	// with AT_RESULT_IND. The peer then only accepts EAP-Success after a
+	// protected Success notification (RFC 4187 Section 6.2).
+	ResultIndication bool
+
+	// ParseOptions controls how strictly EAP-Requests are parsed.
+	// The zero value is lenient; see [eapaka.StrictParseOptions].
+	ParseOptions eapaka.ParseOptions
+
+	// Random is the source of AT_IV. Default: [eapaka.Random].
+	Random io.Reader
+}
+
+// Peer runs EAP-AKA/AKA' conversations for one subscriber.
+// It is not safe for concurrent use.
+type Peer struct {
+	cfg Config
+
+	status    Status
+	eapType   uint8
+	identity  string // identity used in key derivation
+	kEncr     eapaka.Key
+	kAut      eapaka.Key
+	msk       eapaka.Key
+	emsk      eapaka.Key
+	reauth    *eapaka.ReauthContext
+	pendingID string
+	reauthed  bool
+	counter   uint16 // AT_COUNTER of the last fast re-authentication
+	resultInd bool   // both sides included AT_RESULT_IND
+	notified  bool   // the Success notification was received
+}
+
+// New creates a [Peer].
+func New(cfg Config) (*Peer, error) {
+	if cfg.SIM == nil {
+		return nil, errors.New("peer: Config.SIM is required")
+	}
+	if cfg.Identity == "" {
+		return nil, errors.New("peer: Config.Identity is required")
+	}
+	if len(cfg.Types) == 0 {
+		cfg.Types = []uint8{eapaka.TypeAKAPrime, eapaka.TypeAKA}
+	}
+	for _, t := range cfg.Types {
+		if t != eapaka.TypeAKA && t != eapaka.TypeAKAPrime {
+			return nil, fmt.Errorf("peer: unsupported EAP type %d", t)
+		}
+	}
+	return &Peer{cfg: cfg}, nil
+}
+
+// Status returns the state of the current conversation.
+func (p *Peer) Status() Status { return p.status }
+
+// MSK returns the Master Session Key after a successful conversation.
+func (p *Peer) MSK() eapaka.Key { return p.msk }
+
+// EMSK returns the Extended Master Session Key after a successful conversation.
+func (p *Peer) EMSK() eapaka.Key { return p.emsk }
+
+// Reauthenticated reports whether the last conversation was a fast re-authentication.
+func (p *Peer) Reauthenticated() bool { return p.reauthed }
+
+// ReauthID returns the re-authentication identity offered by the server, if any.
+func (p *Peer) ReauthID() string {
+	if p.reauth == nil {
+		return ""
+	}
+	return p.reauth.ReauthID
+}
+
+// IdentityResponse builds the EAP-Response/Identity for a conversation started by the
+// peer (e.g., when the authenticator does not send EAP-Request/Identity).
+// A known re-authentication identity is preferred over the permanent identity.
+func (p *Peer) IdentityResponse(identifier uint8) []byte {
+	p.status, p.msk, p.emsk, p.reauthed = StatusContinue, nil, nil, false
+	p.resultInd, p.notified = false, false
+	p.identity = p.cfg.Identity
+	if p.reauth != nil {
+		p.identity = p.reauth.ReauthID
+	}
+	b := make([]byte, 5, 5+len(p.identity))
+	b[0] = eapaka.CodeResponse
+	b[1] = identifier
+	b[4] = eapaka.TypeIdentity
+	b = append(b, p.identity...)
+	binary.BigEndian.PutUint16(b[2:4], uint16(len(b)))
+	return b
+}
+
+// Handle processes a packet from the authenticator and returns the response to send,
+// or nil if none is needed (EAP-Success/Failure).
+// A non-nil error together with a response means the response reports the failure
+// to the server (e.g., Authentication-Reject).
+func (p *Peer) Handle(msg []byte) (_ []byte, err error) {
+	_, end := eapaka.StartSpan(context.Background(), "peer.Handle")
+	before := p.status
+	defer func() {
+		end(err)
+		if p.status == before {
+			return
+		}
+		switch {
+		case p.status == StatusFailure:
+			eapaka.ReportEvent("peer", eapaka.EventFailure)
+		case p.status == StatusSuccess && p.reauthed:
+			eapaka.ReportEvent("peer", eapaka.EventReauth)
+		case p.status == StatusSuccess:
+			eapaka.ReportEvent("peer", eapaka.EventFullAuth)
+		}
+	}()
+	return p.handle(msg)
+}
+
+func (p *Peer) handle(msg []byte) ([]byte, error) {
+	req, err := eapaka.ParseWithOptions(msg, p.cfg.ParseOptions)
+	if err != nil {
+		return nil, err
+	}
+	switch req.Code {
+	case eapaka.CodeSuccess:
+		if p.msk == nil || p.resultInd && !p.notified {
+			p.status = StatusFailure
+			return nil, ErrUnexpectedPacket
+		}
+		p.status = StatusSuccess
+		return nil, nil
+	case eapaka.CodeFailure:
+		p.status = StatusFailure
+		return nil, ErrAuthenticationFailed
+	case eapaka.CodeRequest:
+	default:
+		return nil, ErrUnexpectedPacket
+	}
+
+	switch req.Type {
+	case eapaka.TypeIdentity:
+		return p.IdentityResponse(req.Identifier), nil
+	case eapaka.TypeNotification:
+		// RFC 3748 Section 5.2: respond with an empty Notification.
+		return eapaka.NewEAPNotification(eapaka.CodeResponse, req.Identifier, "").Marshal()
+	case eapaka.TypeExpanded:
+		// RFC 3748 Section 5.3.2: an Expanded Type is answered with an Expanded Nak.
+		desired := make([]eapaka.ExpandedType, len(p.cfg.Types))
+		for i, t := range p.cfg.Types {
+			desired[i] = eapaka.ExpandedType{VendorType: uint32(t)}
+		}
+		return eapaka.NewExpandedNak(req.Identifier, desired...).Marshal()
+	}
+	if !slices.Contains(p.cfg.Types, req.Type) {
+		// Legacy Nak proposing the accepted methods (RFC 3748 Section 5.3.1).
+		return eapaka.NewNak(req.Identifier, p.cfg.Types...).Marshal()
+	}
+
+	if err := req.Validate(); err != nil {
+		resp := p.clientError(req)
+		b, merr := resp.Marshal()
+		if merr != nil {
+			return nil, merr
+		}
+		return b, err
+	}
+
+	var resp *eapaka.Packet
+	switch req.Subtype {
+	case eapaka.SubtypeIdentity:
+		resp, err = p.handleIdentity(req)
+	case eapaka.SubtypeChallenge:
+		resp, err = p.handleChallenge(req)
+	case eapaka.SubtypeReauthentication:
+		resp, err = p.handleReauth(req)
+	case eapaka.SubtypeNotification:
+		resp, err = p.handleNotification(req)
+	default:
+		resp, err = p.clientError(req), ErrUnexpectedPacket
+	}
+	if resp == nil {
+		return nil, err
+	}
+	b, merr := resp.Marshal()
+	if merr != nil {
+		return nil, merr
+	}
+	return b, err
+}
+
+func (p *Peer) handleIdentity(req *eapaka.Packet) (*eapaka.Packet, error) {
+	identity := p.cfg.Identity
+	for _, attr := range req.Attributes {
+		switch attr.(type) {
+		case *eapaka.AtAnyIdReq:
+			if p.reauth != nil {
+				identity = p.reauth.ReauthID
+			}
+		case *eapaka.AtFullauthIdReq, *eapaka.AtPermanentIdReq:
+			// A re-authentication identity must not be used for full authentication.
+			p.reauth = nil
+		}
+	}
+	p.identity = identity
+	return eapaka.NewIdentityResponse(req.Type, req.Identifier, identity).Build()
+}
+
+func (p *Peer) handleChallenge(req *eapaka.Packet) (*eapaka.Packet, error) {
+	atRand, okRand := eapaka.Find[*eapaka.AtRand](req)
+	atAutn, okAutn := eapaka.Find[*eapaka.AtAutn](req)
+	if !okRand || !okAutn {
+		return p.clientError(req), ErrUnexpectedPacket
+	}
+	var netName string
+	if req.Type == eapaka.TypeAKAPrime {
+		var err error
+		if netName, err = eapaka.CheckKDFInput(req, p.cfg.NetworkName); err != nil {
+			return p.authReject(req), fmt.Errorf("%w: %w", ErrNetworkRejected, err)
+		}
+	}
+
+	res, ck, ik, amf, err := p.cfg.SIM.Authenticate(atRand.Rand, atAutn.Autn)
+	var syncErr *milenage.SyncFailureError
+	if errors.As(err, &syncErr) {
+		eapaka.ReportEvent("peer", eapaka.EventSyncFailure)
+		return eapaka.NewSyncFailure(req.Type, req.Identifier, syncErr.AUTS).Build()
+	}
+	if err != nil {
+		return p.authReject(req), fmt.Errorf("%w: %v", ErrNetworkRejected, err)
+	}
+	if req.Type == eapaka.TypeAKAPrime && amf[0]&0x80 == 0 {
+		// AMF separation bit must be set for EAP-AKA' (RFC 5448 Section 3.4.1).
+		return p.authReject(req), ErrNetworkRejected
+	}
+
+	if p.identity == "" {
+		p.identity = p.cfg.Identity
+	}
+	var mkOrKRe eapaka.Key
+	if req.Type == eapaka.TypeAKAPrime {
+		ckPrime, ikPrime, err := eapaka.DeriveCKPrimeIKPrime(ck, ik, netName, atAutn.Autn)
+		if err != nil {
+			return p.clientError(req), err
+		}
+		keys := eapaka.DeriveKeysAKAPrime(p.identity, ckPrime, ikPrime)
+		p.kEncr, p.kAut, p.msk, p.emsk, mkOrKRe = keys.K_encr, keys.K_aut, keys.MSK, keys.EMSK, keys.K_re
+	} else {
+		keys := eapaka.DeriveKeysAKA(p.identity, ck, ik)
+		p.kEncr, p.kAut, p.msk, p.emsk, mkOrKRe = keys.K_encr, keys.K_aut, keys.MSK, keys.EMSK, keys.MK
+	}
+	if ok, err := req.VerifyMac(p.kAut); err != nil || !ok {
+		p.msk, p.emsk = nil, nil
+		return p.clientError(req), ErrInvalidMAC
+	}
+	if b, ok := eapaka.Find[*eapaka.AtBidding](req); ok && b.D && req.Type == eapaka.TypeAKA && slices.Contains(p.cfg.Types, eapaka.TypeAKAPrime) {
+		// Both sides support EAP-AKA', yet EAP-AKA is in use: a Nak has
+		// been forged to bid the method down (RFC 5448 Section 4).
+		p.msk, p.emsk = nil, nil
+		return p.authReject(req), fmt.Errorf("%w: bidding down from EAP-AKA'", ErrNetworkRejected)
+	}
+	p.eapType = req.Type
+	p.reauthed = false
+	p.resultInd, p.notified = p.cfg.ResultIndication && req.HasResultInd(), false
+
+	p.reauth = nil
+	if inner, err := p.decryptEncr(req); err == nil && !p.cfg.DisableReauth {
+		for _, attr := range inner {
+			if next, ok := attr.(*eapaka.AtNextReauthId); ok {
+				p.reauth = &eapaka.ReauthContext{
+					ReauthID: next.Identity,
+					Type:     req.Type,
+					K_encr:   p.kEncr,
+					K_aut:    p.kAut,
+				}
+				if req.Type == eapaka.TypeAKAPrime {
+					p.reauth.K_re = mkOrKRe
+				} else {
+					p.reauth.MK = mkOrKRe
+				}
+			}
+		}
+	}
+
+	b := eapaka.NewChallengeResponse(req.Type, req.Identifier, res)
+	if p.resultInd {
+		b.ResultInd()
+	}
+	return b.Sign(p.kAut)
+}
+
+func (p *Peer) handleReauth(req *eapaka.Packet) (*eapaka.Packet, error) {
+	if p.reauth == nil || req.Type != p.reauth.Type {
+		return p.clientError(req), ErrUnexpectedPacket
+	}
+	rc := p.reauth
+	p.kEncr, p.kAut = rc.K_encr, rc.K_aut
+	if ok, err := req.VerifyMac(rc.K_aut); err != nil || !ok {
+		return p.clientError(req), ErrInvalidMAC
+	}
+	inner, err := p.decryptEncr(req)
+	if err != nil {
+		return p.clientError(req), err
+	}
+	var (
+		counter uint16
+		nonceS  []byte
+		nextID  string
+	)
+	for _, attr := range inner {
+		switch v := attr.(type) {
+		case *eapaka.AtCounter:
+			counter = v.Counter
+		case *eapaka.AtNonceS:
+			nonceS = v.NonceS
+		case *eapaka.AtNextReauthId:
+			nextID = v.Identity
+		}
+	}
+	if nonceS == nil {
+		return p.clientError(req), ErrUnexpectedPacket
+	}
+
+	iv, err := eapaka.ReadRandom(p.cfg.Random, 16)
+	if err != nil {
+		return nil, err
+	}
+	b := eapaka.NewReauthResponse(req.Type, req.Identifier, counter).Encrypt(rc.K_encr, iv)
+	p.resultInd, p.notified = false, false
+	if counter <= rc.Counter {
+		// RFC 4187 Section 5.4: the counter must increase.
+		b.Encrypt(rc.K_encr, iv, &eapaka.AtCounterTooSmall{})
+		p.reauth = nil
+	} else {
+		keys := rc.DeriveKeys(p.identity, counter, nonceS)
+		p.msk, p.emsk = keys.MSK, keys.EMSK
+		p.reauthed = true
+		p.counter = counter
+		if p.cfg.ResultIndication && req.HasResultInd() {
+			p.resultInd = true
+			b.ResultInd()
+		}
+		rc.Counter = counter
+		if nextID != "" && !p.cfg.DisableReauth {
+			rc.ReauthID = nextID
+		} else {
+			p.reauth = nil
+		}
+	}
+
+	return b.SignWithExtra(rc.K_aut, nonceS)
+}
+
+func (p *Peer) handleNotification(req *eapaka.Packet) (*eapaka.Packet, error) {
+	n, ok := eapaka.Find[*eapaka.AtNotification](req)
+	if !ok || n.P || p.kAut == nil {
+		return eapaka.NewNotificationResponse(req.Type, req.Identifier).Build()
+	}
+
+	// Notifications after the challenge round are protected with AT_MAC and,
+	// after fast re-authentication, AT_COUNTER (RFC 4187 Section 6.2).
+	k := eapaka.NotificationKeys{K_encr: p.kEncr, K_aut: p.kAut, Reauth: p.reauthed, Counter: p.counter}
+	if err := eapaka.VerifyProtectedNotification(req, k); err != nil {
+		p.msk, p.emsk = nil, nil
+		return p.clientError(req), err
+	}
+	var iv []byte
+	if k.Reauth {
+		var err error
+		if iv, err = eapaka.ReadRandom(p.cfg.Random, 16); err != nil {
+			return nil, err
+		}
+	}
+	resp, err := eapaka.NewProtectedNotificationResponse(req.Type, req.Identifier, k, iv)
+	if err != nil {
+		return nil, err
+	}
+	if n.S {
+		p.notified = true
+		return resp, nil
+	}
+	// A failure after authentication; EAP-Failure follows.
+	p.status = StatusFailure
+	p.msk, p.emsk = nil, nil
+	return resp, fmt.Errorf("%w: notification %d", ErrAuthenticationFailed, n.Value())
+}
+
+func (p *Peer) decryptEncr(req *eapaka.Packet) ([]eapaka.Attribute, error) {
+	iv, okIV := eapaka.Find[*eapaka.AtIv](req)
+	encr, okEncr := eapaka.Find[*eapaka.AtEncrData](req)
+	if !okIV || !okEncr {
+		return nil, errors.New("peer: AT_IV or AT_ENCR_DATA missing")
+	}
+	return eapaka.DecryptAttributes(p.kEncr, iv.IV, encr)
+}
+
+func (p *Peer) authReject(req *eapaka.Packet) *eapaka.Packet {
+	p.status = StatusFailure
+	resp, _ := eapaka.NewAuthenticationReject(req.Type, req.Identifier).Build()
+	return resp
+}
+
+func (p *Peer) clientError(req *eapaka.Packet) *eapaka.Packet {
+	p.status = StatusFailure
+	resp, _ := eapaka.NewClientError(req.Type, req.Identifier, eapaka.ClientErrorUnableToProcess).Build()
+	return resp
+}
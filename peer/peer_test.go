@@ -0,0 +1,318 @@
+package peer_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/oyaguma3/go-eapaka"
+	"github.com/oyaguma3/go-eapaka/milenage"
+	"github.com/oyaguma3/go-eapaka/peer"
+	"github.com/oyaguma3/go-eapaka/server"
+)
+
+var (
+	testIMSI = "001010000000001"
+	testK    = bytes.Repeat([]byte{0x46}, 16)
+	testOPc  = bytes.Repeat([]byte{0xcd}, 16)
+)
+
+// converse runs one conversation between p and a and returns the server result.
+func converse(t *testing.T, p *peer.Peer, a *server.Authenticator) *server.Result {
+	t.Helper()
+	ctx := context.Background()
+	msg := p.IdentityResponse(0)
+	sessionID := ""
+	for i := 0; i < 10; i++ {
+		res, err := a.Handle(ctx, sessionID, msg)
+		reply, merr := res.Reply.Marshal()
+		if merr != nil {
+			t.Fatal(merr)
+		}
+		next, perr := p.Handle(reply)
+		if res.Status != server.StatusContinue {
+			if res.Status == server.StatusSuccess && p.Status() != peer.StatusSuccess {
+				t.Fatalf("server succeeded but peer did not: %v", perr)
+			}
+			if err != nil {
+				t.Logf("server: %v", err)
+			}
+			return res
+		}
+		if next == nil {
+			t.Fatalf("peer produced no response: %v", perr)
+		}
+		sessionID, msg = res.SessionID, next
+	}
+	t.Fatal("conversation did not finish")
+	return nil
+}
+
+func TestPeer_AgainstServer(t *testing.T) {
+	for _, eapType := range []uint8{eapaka.TypeAKA, eapaka.TypeAKAPrime} {
+		// The network starts ahead of the USIM; the USIM starts ahead of the
+		// network in the second case and forces a re-synchronisation.
+		for _, tc := range []struct {
+			name             string
+			networkSQN, usim uint64
+		}{
+			{"in-sync", 10, 5},
+			{"resync", 10, 500},
+		} {
+			provider := milenage.NewProvider(&milenage.Subscriber{IMSI: testIMSI, K: testK, OPc: testOPc, SQN: tc.networkSQN})
+			a, err := server.New(server.Config{Type: eapType, Vectors: provider, EnableReauth: true, ParseOptions: eapaka.StrictParseOptions})
+			if err != nil {
+				t.Fatal(err)
+			}
+			usim, err := milenage.NewUSIM(testK, testOPc, tc.usim)
+			if err != nil {
+				t.Fatal(err)
+			}
+			p, err := peer.New(peer.Config{Identity: eapaka.PermanentIdentity(eapType, testIMSI, "example.org"), SIM: usim, ParseOptions: eapaka.StrictParseOptions})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			res := converse(t, p, a)
+			if res.Status != server.StatusSuccess {
+				t.Fatalf("type %d %s: full authentication failed", eapType, tc.name)
+			}
+			if !bytes.Equal(res.MSK, p.MSK()) || !bytes.Equal(res.EMSK, p.EMSK()) {
+				t.Errorf("type %d %s: MSK/EMSK mismatch between peer and server", eapType, tc.name)
+			}
+
+			res = converse(t, p, a)
+			if res.Status != server.StatusSuccess || !res.Reauth || !p.Reauthenticated() {
+				t.Fatalf("type %d %s: fast re-authentication failed", eapType, tc.name)
+			}
+			if !bytes.Equal(res.MSK, p.MSK()) {
+				t.Errorf("type %d %s: re-auth MSK mismatch between peer and server", eapType, tc.name)
+			}
+		}
+	}
+}
+
+func TestPeer_WrongKey(t *testing.T) {
+	provider := milenage.NewProvider(&milenage.Subscriber{IMSI: testIMSI, K: testK, OPc: testOPc})
+	a, err := server.New(server.Config{Vectors: provider})
+	if err != nil {
+		t.Fatal(err)
+	}
+	usim, _ := milenage.NewUSIM(bytes.Repeat([]byte{0x01}, 16), testOPc, 0)
+	p, _ := peer.New(peer.Config{Identity: eapaka.PermanentIdentity(eapaka.TypeAKAPrime, testIMSI, ""), SIM: usim})
+
+	if res := converse(t, p, a); res.Status != server.StatusFailure {
+		t.Errorf("got status %d, want StatusFailure", res.Status)
+	}
+	if p.Status() != peer.StatusFailure {
+		t.Errorf("peer status %d, want StatusFailure", p.Status())
+	}
+}
+
+// TestPeer_KeysRedacted checks that the keys of a server.Result and of the
+// peer do not print.
+func TestPeer_KeysRedacted(t *testing.T) {
+	provider := milenage.NewProvider(&milenage.Subscriber{IMSI: testIMSI, K: testK, OPc: testOPc})
+	a, err := server.New(server.Config{Vectors: provider})
+	if err != nil {
+		t.Fatal(err)
+	}
+	usim, _ := milenage.NewUSIM(testK, testOPc, 0)
+	p, _ := peer.New(peer.Config{Identity: eapaka.PermanentIdentity(eapaka.TypeAKAPrime, testIMSI, ""), SIM: usim})
+	res := converse(t, p, a)
+	if res.Status != server.StatusSuccess || !res.MSK.Equal(p.MSK()) || !res.EMSK.Equal(p.EMSK()) {
+		t.Fatalf("status %v or keys differ", res.Status)
+	}
+
+	js, err := json.Marshal(res)
+	if err != nil {
+		t.Fatal(err)
+	}
+	secret := hex.EncodeToString(res.MSK)[:16]
+	for _, s := range []string{
+		fmt.Sprintf("%+v", res),
+		fmt.Sprintf("%+v", *res),
+		string(js),
+		fmt.Sprintf("%x %v", p.MSK(), p.EMSK()),
+	} {
+		if !strings.Contains(s, "REDACTED") || strings.Contains(strings.ToLower(s), secret) {
+			t.Errorf("keys printed as %q", s)
+		}
+	}
+}
+
+// TestPeer_NotificationWithoutAT_NOTIFICATION checks that a malformed
+// AKA-Notification is answered with a Client-Error.
+func TestPeer_NotificationWithoutAT_NOTIFICATION(t *testing.T) {
+	withMAC, err := (&eapaka.Packet{Code: eapaka.CodeRequest, Identifier: 1, Type: eapaka.TypeAKA, Subtype: eapaka.SubtypeNotification,
+		Attributes: []eapaka.Attribute{&eapaka.AtMac{MAC: make([]byte, 16)}}}).Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, msg := range [][]byte{
+		{0x01, 0x01, 0x00, 0x08, eapaka.TypeAKA, eapaka.SubtypeNotification, 0x00, 0x00},
+		withMAC,
+	} {
+		usim, _ := milenage.NewUSIM(testK, testOPc, 0)
+		p, _ := peer.New(peer.Config{Identity: eapaka.PermanentIdentity(eapaka.TypeAKA, testIMSI, ""), SIM: usim})
+		resp, err := p.Handle(msg)
+		if err == nil {
+			t.Errorf("%x: no error", msg)
+		}
+		pkt, perr := eapaka.Parse(resp)
+		if perr != nil || pkt.Subtype != eapaka.SubtypeClientError {
+			t.Errorf("%x: response %x, want Client-Error", msg, resp)
+		}
+	}
+}
+
+func TestPeer_ResultIndication(t *testing.T) {
+	tests := []struct {
+		name             string
+		server, peer     bool
+		authorize        error
+		wantNotification []uint16 // per conversation: full, then re-authentication
+		wantErr          error
+	}{
+		{"both", true, true, nil, []uint16{eapaka.NotificationSuccess, eapaka.NotificationSuccess}, nil},
+		{"server only", true, false, nil, nil, nil},
+		{"peer only", false, true, nil, nil, nil},
+		{"denied", true, true, server.ErrTemporarilyDenied, []uint16{eapaka.NotificationTemporarilyDenied}, server.ErrTemporarilyDenied},
+		{"not subscribed", true, true, server.ErrNotSubscribed, []uint16{eapaka.NotificationNotSubscribed}, server.ErrNotSubscribed},
+		{"other", true, true, errors.New("barred"), []uint16{eapaka.NotificationGeneralFailureAfterAuth}, server.ErrNotAuthorized},
+		{"no result ind", false, true, errors.New("barred"), nil, server.ErrNotAuthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			provider := milenage.NewProvider(&milenage.Subscriber{IMSI: testIMSI, K: testK, OPc: testOPc, SQN: 10})
+			a, err := server.New(server.Config{
+				Vectors:          provider,
+				EnableReauth:     true,
+				ResultIndication: tt.server,
+				Authorize:        func(context.Context, string) error { return tt.authorize },
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			usim, _ := milenage.NewUSIM(testK, testOPc, 0)
+			p, _ := peer.New(peer.Config{Identity: eapaka.PermanentIdentity(eapaka.TypeAKAPrime, testIMSI, ""), SIM: usim, ResultIndication: tt.peer})
+
+			var notifications []uint16
+			for range 2 {
+				msg, sessionID := p.IdentityResponse(0), ""
+				for {
+					res, err := a.Handle(context.Background(), sessionID, msg)
+					if n, ok := eapaka.Find[*eapaka.AtNotification](res.Reply); ok {
+						notifications = append(notifications, n.Value())
+					}
+					reply, _ := res.Reply.Marshal()
+					next, perr := p.Handle(reply)
+					if res.Status == server.StatusContinue {
+						sessionID, msg = res.SessionID, next
+						continue
+					}
+					if tt.wantErr != nil {
+						if !errors.Is(err, tt.wantErr) || res.Status != server.StatusFailure || p.Status() != peer.StatusFailure {
+							t.Fatalf("Handle = %v, %v; peer %v, %v", res.Status, err, p.Status(), perr)
+						}
+					} else if res.Status != server.StatusSuccess || p.Status() != peer.StatusSuccess || !bytes.Equal(res.MSK, p.MSK()) {
+						t.Fatalf("Handle = %v, %v; peer %v, %v", res.Status, err, p.Status(), perr)
+					}
+					break
+				}
+				if tt.wantErr != nil {
+					break
+				}
+			}
+			if !slices.Equal(notifications, tt.wantNotification) {
+				t.Errorf("notifications = %v, want %v", notifications, tt.wantNotification)
+			}
+		})
+	}
+}
+
+func TestPeer_ResultIndicationRequired(t *testing.T) {
+	// A peer that asked for result indications does not accept a bare
+	// EAP-Success.
+	provider := milenage.NewProvider(&milenage.Subscriber{IMSI: testIMSI, K: testK, OPc: testOPc, SQN: 10})
+	a, _ := server.New(server.Config{Vectors: provider, ResultIndication: true})
+	usim, _ := milenage.NewUSIM(testK, testOPc, 0)
+	p, _ := peer.New(peer.Config{Identity: eapaka.PermanentIdentity(eapaka.TypeAKAPrime, testIMSI, ""), SIM: usim, ResultIndication: true})
+
+	res, _ := a.Handle(context.Background(), "", p.IdentityResponse(0))
+	req, _ := res.Reply.Marshal()
+	if _, err := p.Handle(req); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := p.Handle([]byte{eapaka.CodeSuccess, res.Reply.Identifier, 0, 4}); !errors.Is(err, peer.ErrUnexpectedPacket) || p.Status() != peer.StatusFailure {
+		t.Errorf("Handle(EAP-Success) = %v, status %v", err, p.Status())
+	}
+}
+
+func TestPeer_Negotiation(t *testing.T) {
+	newServer := func() *server.Authenticator {
+		provider := milenage.NewProvider(&milenage.Subscriber{IMSI: testIMSI, K: testK, OPc: testOPc, SQN: 10})
+		a, err := server.New(server.Config{Type: eapaka.TypeAKAPrime, FallbackTypes: []uint8{eapaka.TypeAKA}, Vectors: provider})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return a
+	}
+	newPeer := func(types ...uint8) *peer.Peer {
+		usim, _ := milenage.NewUSIM(testK, testOPc, 0)
+		p, err := peer.New(peer.Config{Identity: eapaka.PermanentIdentity(eapaka.TypeAKA, testIMSI, ""), SIM: usim, Types: types})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return p
+	}
+
+	// A peer without EAP-AKA' Naks it and the server falls back to EAP-AKA.
+	a, p := newServer(), newPeer(eapaka.TypeAKA)
+	if res := converse(t, p, a); res.Status != server.StatusSuccess {
+		t.Fatalf("fallback to EAP-AKA failed")
+	}
+
+	// A forged Nak against a peer that supports EAP-AKA' is detected with
+	// AT_BIDDING.
+	a, p = newServer(), newPeer()
+	res, err := a.Handle(context.Background(), "", p.IdentityResponse(0))
+	if err != nil || res.Reply.Type != eapaka.TypeAKAPrime {
+		t.Fatalf("Handle(identity) = %v, %v", res.Reply, err)
+	}
+	nak, _ := eapaka.NewNak(res.Reply.Identifier, eapaka.TypeAKA).Marshal()
+	res, err = a.Handle(context.Background(), res.SessionID, nak)
+	if err != nil || res.Reply.Type != eapaka.TypeAKA || !res.Reply.Has(eapaka.AT_BIDDING) {
+		t.Fatalf("Handle(Nak) = %v, %v", res.Reply, err)
+	}
+	req, _ := res.Reply.Marshal()
+	if _, err := p.Handle(req); !errors.Is(err, peer.ErrNetworkRejected) || p.Status() != peer.StatusFailure {
+		t.Errorf("peer accepted a bid-down challenge: %v", err)
+	}
+
+	// Only one switch is allowed.
+	nak, _ = eapaka.NewNak(res.Reply.Identifier, eapaka.TypeAKAPrime).Marshal()
+	if _, err := a.Handle(context.Background(), res.SessionID, nak); !errors.Is(err, server.ErrPeerRejected) {
+		t.Errorf("second Nak: %v", err)
+	}
+
+	// Other methods are answered with a Nak proposing the accepted ones.
+	md5, _ := (&eapaka.Packet{Code: eapaka.CodeRequest, Identifier: 9, Type: 4, TypeData: []byte{16}}).Marshal()
+	resp, err := newPeer(eapaka.TypeAKA).Handle(md5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pkt, err := eapaka.Parse(resp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if types, ok := pkt.NakTypes(); !ok || !slices.Equal(types, []uint8{eapaka.TypeAKA}) || pkt.Identifier != 9 {
+		t.Errorf("response to EAP-MD5 = %x", resp)
+	}
+}
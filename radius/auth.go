@@ -0,0 +1,155 @@
+package radius
+
+import (
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/subtle"
+	"errors"
+	"io"
+
+	"github.com/oyaguma3/go-eapaka"
+)
+
+// Errors returned when authenticators do not verify.
+var (
+	ErrBadAuthenticator        = errors.New("radius: Response Authenticator mismatch")
+	ErrBadMessageAuthenticator = errors.New("radius: Message-Authenticator mismatch")
+	ErrNoMessageAuthenticator  = errors.New("radius: Message-Authenticator missing")
+)
+
+// MarshalRequest serializes an Access-Request. A random Request Authenticator
+// is generated from [eapaka.Random] if p.Authenticator is all zero, and a
+// Message-Authenticator is added (RFC 3579 Section 3.2).
+func (p *Packet) MarshalRequest(secret []byte) ([]byte, error) {
+	return p.MarshalRequestWithRandom(nil, secret)
+}
+
+// MarshalRequestWithRandom is like [Packet.MarshalRequest] but reads the
+// Request Authenticator from r, or from [eapaka.Random] if r is nil.
+func (p *Packet) MarshalRequestWithRandom(r io.Reader, secret []byte) ([]byte, error) {
+	if p.Authenticator == [16]byte{} {
+		auth, err := eapaka.ReadRandom(r, len(p.Authenticator))
+		if err != nil {
+			return nil, err
+		}
+		copy(p.Authenticator[:], auth)
+	}
+	p.Del(AttrMessageAuthenticator)
+	p.Add(AttrMessageAuthenticator, make([]byte, 16))
+	b, err := p.Marshal()
+	if err != nil {
+		return nil, err
+	}
+	setMessageAuthenticator(b, secret)
+	return b, nil
+}
+
+// MarshalResponse serializes a reply to the request whose Request Authenticator
+// is reqAuth. A Message-Authenticator is added and the Response Authenticator
+// is computed (RFC 2865 Section 3).
+func (p *Packet) MarshalResponse(secret []byte, reqAuth [16]byte) ([]byte, error) {
+	p.Authenticator = reqAuth
+	p.Del(AttrMessageAuthenticator)
+	p.Add(AttrMessageAuthenticator, make([]byte, 16))
+	b, err := p.Marshal()
+	if err != nil {
+		return nil, err
+	}
+	// The Message-Authenticator is computed with the Request Authenticator in place.
+	setMessageAuthenticator(b, secret)
+
+	// ResponseAuth = MD5(Code+ID+Length+RequestAuth+Attributes+Secret)
+	h := md5.New()
+	h.Write(b)
+	h.Write(secret)
+	copy(b[4:20], h.Sum(nil))
+	copy(p.Authenticator[:], b[4:20])
+	return b, nil
+}
+
+// VerifyRequest checks the Message-Authenticator of a received Access-Request.
+// It is mandatory for requests carrying EAP-Message (RFC 3579 Section 3.2).
+func VerifyRequest(data, secret []byte) error {
+	p, err := Parse(data)
+	if err != nil {
+		return err
+	}
+	if p.Get(AttrMessageAuthenticator) == nil {
+		return ErrNoMessageAuthenticator
+	}
+	return verifyMessageAuthenticator(data, secret, nil)
+}
+
+// VerifyResponse checks the Response Authenticator and Message-Authenticator of
+// a reply to the request whose Request Authenticator is reqAuth.
+func VerifyResponse(data, secret []byte, reqAuth [16]byte) error {
+	p, err := Parse(data)
+	if err != nil {
+		return err
+	}
+	length := len(data)
+	if l := int(data[2])<<8 | int(data[3]); l < length {
+		length = l
+	}
+	buf := append([]byte(nil), data[:length]...)
+	copy(buf[4:20], reqAuth[:])
+	h := md5.New()
+	h.Write(buf)
+	h.Write(secret)
+	if subtle.ConstantTimeCompare(h.Sum(nil), p.Authenticator[:]) != 1 {
+		return ErrBadAuthenticator
+	}
+	if p.Get(AttrMessageAuthenticator) == nil {
+		if p.Get(AttrEAPMessage) != nil {
+			return ErrNoMessageAuthenticator
+		}
+		return nil
+	}
+	return verifyMessageAuthenticator(data, secret, reqAuth[:])
+}
+
+// setMessageAuthenticator fills in the (zeroed) Message-Authenticator in b.
+func setMessageAuthenticator(b, secret []byte) {
+	off := messageAuthenticatorOffset(b)
+	mac := hmac.New(md5.New, secret)
+	mac.Write(b)
+	copy(b[off:off+16], mac.Sum(nil))
+}
+
+// verifyMessageAuthenticator recomputes the Message-Authenticator over a copy of
+// data with the attribute zeroed. reqAuth replaces the authenticator field for replies.
+func verifyMessageAuthenticator(data, secret, reqAuth []byte) error {
+	length := int(data[2])<<8 | int(data[3])
+	buf := append([]byte(nil), data[:length]...)
+	off := messageAuthenticatorOffset(buf)
+	if off < 0 {
+		return ErrNoMessageAuthenticator
+	}
+	received := append([]byte(nil), buf[off:off+16]...)
+	clear(buf[off : off+16])
+	if reqAuth != nil {
+		copy(buf[4:20], reqAuth)
+	}
+	mac := hmac.New(md5.New, secret)
+	mac.Write(buf)
+	if !hmac.Equal(mac.Sum(nil), received) {
+		return ErrBadMessageAuthenticator
+	}
+	return nil
+}
+
+// messageAuthenticatorOffset returns the offset of the Message-Authenticator
+// value within a serialized packet, or -1.
+func messageAuthenticatorOffset(b []byte) int {
+	for off := headerLen; off+2 <= len(b); {
+		l := int(b[off+1])
+		if l < 2 {
+			return -1
+		}
+		if b[off] == AttrMessageAuthenticator && l == 18 && off+18 <= len(b) {
+			return off + 2
+		}
+		off += l
+	}
+	return -1
+}
@@ -0,0 +1,88 @@
+package radius
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"time"
+)
+
+// Client sends Access-Requests to a RADIUS server over UDP, acting as a NAS.
+type Client struct {
+	// Addr is the server address, e.g. "127.0.0.1:1812".
+	Addr string
+
+	// Secret is the shared secret.
+	Secret []byte
+
+	// Timeout is the time to wait for each reply before retransmitting. Default: 2s.
+	Timeout time.Duration
+
+	// Retries is the number of retransmissions; 0 sends the request once.
+	// A negative value selects the default of 2.
+	Retries int
+
+	// Random is the source of Request Authenticators. Default: [eapaka.Random].
+	Random io.Reader
+}
+
+// Exchange sends req and waits for the matching reply, retransmitting on timeout.
+// req.Authenticator is filled in with the Request Authenticator used, which callers
+// need to decrypt MS-MPPE keys from an Access-Accept.
+// The reply's authenticators are verified before it is returned.
+func (c *Client) Exchange(ctx context.Context, req *Packet) (*Packet, error) {
+	data, err := req.MarshalRequestWithRandom(c.Random, c.Secret)
+	if err != nil {
+		return nil, err
+	}
+	timeout := c.Timeout
+	if timeout <= 0 {
+		timeout = 2 * time.Second
+	}
+	retries := c.Retries
+	if retries < 0 {
+		retries = 2
+	}
+
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "udp", c.Addr)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	buf := make([]byte, maxPacketLen)
+	for attempt := 0; attempt <= retries; attempt++ {
+		if _, err := conn.Write(data); err != nil {
+			return nil, err
+		}
+		deadline := time.Now().Add(timeout)
+		if dl, ok := ctx.Deadline(); ok && dl.Before(deadline) {
+			deadline = dl
+		}
+		conn.SetReadDeadline(deadline)
+		for {
+			n, err := conn.Read(buf)
+			if err != nil {
+				var ne net.Error
+				if errors.As(err, &ne) && ne.Timeout() {
+					if ctx.Err() != nil {
+						return nil, ctx.Err()
+					}
+					break
+				}
+				return nil, err
+			}
+			resp, err := Parse(buf[:n])
+			if err != nil || resp.Identifier != req.Identifier {
+				continue
+			}
+			if err := VerifyResponse(buf[:n], c.Secret, req.Authenticator); err != nil {
+				return nil, err
+			}
+			return resp, nil
+		}
+	}
+	return nil, errors.New("radius: no response from server")
+}
@@ -0,0 +1,203 @@
+// Package radius implements the subset of RADIUS (RFC 2865) and RADIUS EAP
+// support (RFC 3579) needed to carry EAP-AKA/AKA' between a NAS and an
+// authentication server, together with a UDP [Server] and [Client].
+package radius
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// RADIUS Codes (RFC 2865 Section 3)
+const (
+	CodeAccessRequest   uint8 = 1
+	CodeAccessAccept    uint8 = 2
+	CodeAccessReject    uint8 = 3
+	CodeAccessChallenge uint8 = 11
+)
+
+// RADIUS Attribute Types (RFC 2865 Section 5, RFC 3579 Section 3)
+const (
+	AttrUserName             uint8 = 1
+	AttrNASIPAddress         uint8 = 4
+	AttrState                uint8 = 24
+	AttrClass                uint8 = 25
+	AttrVendorSpecific       uint8 = 26
+	AttrCalledStationID      uint8 = 30
+	AttrCallingStationID     uint8 = 31
+	AttrNASIdentifier        uint8 = 32
+	AttrEAPMessage           uint8 = 79
+	AttrMessageAuthenticator uint8 = 80
+)
+
+// Microsoft vendor attributes (RFC 2548 Section 2.4)
+const (
+	VendorMicrosoft     uint32 = 311
+	VendorMSMPPESendKey uint8  = 16
+	VendorMSMPPERecvKey uint8  = 17
+)
+
+const (
+	headerLen    = 20
+	maxPacketLen = 4096
+	maxAttrValue = 253
+)
+
+// Attribute is a single RADIUS attribute.
+type Attribute struct {
+	Type  uint8
+	Value []byte
+}
+
+// Packet represents a RADIUS packet.
+type Packet struct {
+	Code          uint8
+	Identifier    uint8
+	Authenticator [16]byte
+	Attributes    []Attribute
+}
+
+// Parse parses a RADIUS packet from a byte slice.
+func Parse(data []byte) (*Packet, error) {
+	if len(data) < headerLen {
+		return nil, errors.New("radius: packet too short")
+	}
+	length := int(binary.BigEndian.Uint16(data[2:4]))
+	if length < headerLen || length > maxPacketLen || length > len(data) {
+		return nil, errors.New("radius: packet length mismatch")
+	}
+	p := &Packet{Code: data[0], Identifier: data[1]}
+	copy(p.Authenticator[:], data[4:20])
+
+	attrData := data[headerLen:length]
+	for len(attrData) > 0 {
+		if len(attrData) < 2 {
+			return nil, errors.New("radius: attribute header truncated")
+		}
+		attrLen := int(attrData[1])
+		if attrLen < 2 || attrLen > len(attrData) {
+			return nil, fmt.Errorf("radius: attribute %d length invalid", attrData[0])
+		}
+		p.Attributes = append(p.Attributes, Attribute{
+			Type:  attrData[0],
+			Value: append([]byte(nil), attrData[2:attrLen]...),
+		})
+		attrData = attrData[attrLen:]
+	}
+	return p, nil
+}
+
+// Marshal serializes the packet as is, without computing any authenticator.
+func (p *Packet) Marshal() ([]byte, error) {
+	length := headerLen
+	for _, a := range p.Attributes {
+		if len(a.Value) > maxAttrValue {
+			return nil, fmt.Errorf("radius: attribute %d too long", a.Type)
+		}
+		length += 2 + len(a.Value)
+	}
+	if length > maxPacketLen {
+		return nil, errors.New("radius: packet too long")
+	}
+	b := make([]byte, headerLen, length)
+	b[0] = p.Code
+	b[1] = p.Identifier
+	binary.BigEndian.PutUint16(b[2:4], uint16(length))
+	copy(b[4:20], p.Authenticator[:])
+	for _, a := range p.Attributes {
+		b = append(b, a.Type, byte(2+len(a.Value)))
+		b = append(b, a.Value...)
+	}
+	return b, nil
+}
+
+// Get returns the value of the first attribute of type t, or nil.
+func (p *Packet) Get(t uint8) []byte {
+	for _, a := range p.Attributes {
+		if a.Type == t {
+			return a.Value
+		}
+	}
+	return nil
+}
+
+// Add appends an attribute.
+func (p *Packet) Add(t uint8, value []byte) {
+	p.Attributes = append(p.Attributes, Attribute{Type: t, Value: value})
+}
+
+// Del removes all attributes of type t.
+func (p *Packet) Del(t uint8) {
+	attrs := p.Attributes[:0]
+	for _, a := range p.Attributes {
+		if a.Type != t {
+			attrs = append(attrs, a)
+		}
+	}
+	p.Attributes = attrs
+}
+
+// Response creates a reply to p with the given code.
+// Identifier and (request) Authenticator are copied from p.
+func (p *Packet) Response(code uint8) *Packet {
+	return &Packet{Code: code, Identifier: p.Identifier, Authenticator: p.Authenticator}
+}
+
+// AddVendor appends a Vendor-Specific attribute holding a single vendor attribute.
+// See RFC 2865 Section 5.26.
+func (p *Packet) AddVendor(vendorID uint32, vendorType uint8, value []byte) {
+	v := make([]byte, 6, 6+len(value))
+	binary.BigEndian.PutUint32(v[0:4], vendorID)
+	v[4] = vendorType
+	v[5] = byte(2 + len(value))
+	p.Add(AttrVendorSpecific, append(v, value...))
+}
+
+// GetVendor returns the value of the first vendor attribute with the given
+// vendor ID and type, or nil.
+func (p *Packet) GetVendor(vendorID uint32, vendorType uint8) []byte {
+	for _, a := range p.Attributes {
+		if a.Type != AttrVendorSpecific || len(a.Value) < 4 {
+			continue
+		}
+		if binary.BigEndian.Uint32(a.Value[0:4]) != vendorID {
+			continue
+		}
+		sub := a.Value[4:]
+		for len(sub) >= 2 {
+			l := int(sub[1])
+			if l < 2 || l > len(sub) {
+				break
+			}
+			if sub[0] == vendorType {
+				return sub[2:l]
+			}
+			sub = sub[l:]
+		}
+	}
+	return nil
+}
+
+// EAPMessage returns the concatenated value of all EAP-Message attributes,
+// or nil if there are none. See RFC 3579 Section 3.1.
+func (p *Packet) EAPMessage() []byte {
+	var msg []byte
+	for _, a := range p.Attributes {
+		if a.Type == AttrEAPMessage {
+			msg = append(msg, a.Value...)
+		}
+	}
+	return msg
+}
+
+// SetEAPMessage replaces any EAP-Message attributes with msg, split into
+// attributes of at most 253 bytes.
+func (p *Packet) SetEAPMessage(msg []byte) {
+	p.Del(AttrEAPMessage)
+	for len(msg) > maxAttrValue {
+		p.Add(AttrEAPMessage, msg[:maxAttrValue])
+		msg = msg[maxAttrValue:]
+	}
+	p.Add(AttrEAPMessage, msg)
+}
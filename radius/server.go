@@ -0,0 +1,302 @@
+package radius
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/oyaguma3/go-eapaka"
+	"github.com/oyaguma3/go-eapaka/server"
+)
+
+// ErrUnknownClient is returned when a request arrives from a NAS without a configured secret.
+var ErrUnknownClient = errors.New("radius: unknown client")
+
+// SecretSource returns the shared secret for the NAS at addr.
+type SecretSource interface {
+	Secret(addr net.Addr) ([]byte, bool)
+}
+
+// StaticSecrets is a [SecretSource] keyed by NAS IP address (e.g., "192.0.2.1").
+// The entry "*", if present, is used for any other client.
+type StaticSecrets map[string][]byte
+
+// Secret implements [SecretSource].
+func (s StaticSecrets) Secret(addr net.Addr) ([]byte, bool) {
+	host := addr.String()
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	if secret, ok := s[host]; ok {
+		return secret, true
+	}
+	secret, ok := s["*"]
+	return secret, ok
+}
+
+// Server is a UDP RADIUS authentication server that runs EAP-AKA/AKA'
+// conversations with an [server.Authenticator].
+//
+// Access-Requests are answered with Access-Challenge while the conversation
+// continues, and with Access-Accept (carrying MS-MPPE-Recv-Key and
+// MS-MPPE-Send-Key derived from the MSK) or Access-Reject when it ends.
+type Server struct {
+	// Authenticator runs the EAP method. Required.
+	Authenticator *server.Authenticator
+
+	// Secrets provides per-NAS shared secrets. Required.
+	Secrets SecretSource
+
+	// Logger receives diagnostics. Default: discard.
+	Logger *slog.Logger
+
+	// DuplicateWindow is how long replies are cached to answer retransmitted
+	// requests (RFC 5080 Section 2.2.2). A retransmission that arrives while
+	// the original is still being handled waits for its reply.
+	// Default: 5s.
+	DuplicateWindow time.Duration
+
+	// Random is the source of the MS-MPPE key salts. Default: [eapaka.Random].
+	Random io.Reader
+
+	mu        sync.Mutex
+	conn      net.PacketConn
+	closed    bool
+	replies   map[string]*cachedReply
+	nextSweep time.Time
+}
+
+// cachedReply is the reply to one request, keyed by source address,
+// Identifier and Request Authenticator. It is reserved before the request
+// is handled; expires is zero until then.
+type cachedReply struct {
+	done    chan struct{} // closed once the request has been handled
+	data    []byte        // nil if the request was dropped
+	expires time.Time
+}
+
+// ListenAndServe listens on the UDP address addr and calls [Server.Serve].
+func (s *Server) ListenAndServe(addr string) error {
+	conn, err := net.ListenPacket("udp", addr)
+	if err != nil {
+		return err
+	}
+	return s.Serve(conn)
+}
+
+// Serve reads requests from conn until [Server.Close] is called.
+// Each request is handled in its own goroutine.
+func (s *Server) Serve(conn net.PacketConn) error {
+	if s.Authenticator == nil || s.Secrets == nil {
+		return errors.New("radius: Server.Authenticator and Server.Secrets are required")
+	}
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return net.ErrClosed
+	}
+	s.conn = conn
+	s.mu.Unlock()
+
+	buf := make([]byte, maxPacketLen)
+	for {
+		n, addr, err := conn.ReadFrom(buf)
+		if err != nil {
+			s.mu.Lock()
+			closed := s.closed
+			s.mu.Unlock()
+			if closed {
+				return nil
+			}
+			return err
+		}
+		data := append([]byte(nil), buf[:n]...)
+		go func() {
+			reply, err := s.HandlePacket(context.Background(), data, addr)
+			if err != nil {
+				s.logger().Debug("radius: request dropped", "client", addr.String(), "error", err)
+			}
+			if reply != nil {
+				conn.WriteTo(reply, addr)
+			}
+		}()
+	}
+}
+
+// Close stops [Server.Serve] and closes the underlying connection.
+func (s *Server) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.closed = true
+	if s.conn != nil {
+		return s.conn.Close()
+	}
+	return nil
+}
+
+// HandlePacket processes one datagram from src and returns the serialized reply.
+// A nil reply means the request must be silently discarded; err then says why.
+// A non-nil reply may come with an error describing an authentication failure.
+func (s *Server) HandlePacket(ctx context.Context, data []byte, src net.Addr) ([]byte, error) {
+	secret, ok := s.Secrets.Secret(src)
+	if !ok {
+		return nil, ErrUnknownClient
+	}
+	req, err := Parse(data)
+	if err != nil {
+		return nil, err
+	}
+	if req.Code != CodeAccessRequest {
+		return nil, fmt.Errorf("radius: unexpected code %d", req.Code)
+	}
+	if err := VerifyRequest(data, secret); err != nil {
+		return nil, err
+	}
+
+	msg := req.EAPMessage()
+	if msg == nil {
+		return nil, errors.New("radius: Access-Request without EAP-Message")
+	}
+
+	key := fmt.Sprintf("%s/%d/%x", src.String(), req.Identifier, req.Authenticator)
+	entry, owner := s.reserve(key)
+	if !owner {
+		select {
+		case <-entry.done:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		if entry.data == nil {
+			return nil, errors.New("radius: duplicate of a dropped request")
+		}
+		return entry.data, nil
+	}
+	reply, err := s.respond(ctx, req, msg, secret, src)
+	s.finish(key, entry, reply)
+	return reply, err
+}
+
+// respond runs the EAP exchange for req. Its results are those of
+// [Server.HandlePacket].
+func (s *Server) respond(ctx context.Context, req *Packet, msg, secret []byte, src net.Addr) ([]byte, error) {
+	// The RADIUS client is the NAS; peers are tracked per NAS by server.Policy.
+	res, authErr := s.Authenticator.Handle(server.WithSource(ctx, sourceOf(src)), string(req.Get(AttrState)), msg)
+	if res.Status == server.StatusDiscard {
+		return nil, authErr
+	}
+
+	var resp *Packet
+	switch res.Status {
+	case server.StatusContinue:
+		resp = req.Response(CodeAccessChallenge)
+		resp.Add(AttrState, []byte(res.SessionID))
+	case server.StatusSuccess:
+		resp = req.Response(CodeAccessAccept)
+		if res.PermanentID != "" {
+			resp.Add(AttrUserName, []byte(res.PermanentID))
+		}
+		if err := addMPPEKeys(s.Random, resp, res.MSK, secret, req.Authenticator); err != nil {
+			return nil, err
+		}
+	default:
+		resp = req.Response(CodeAccessReject)
+	}
+	eap, err := res.Reply.Marshal()
+	if err != nil {
+		return nil, err
+	}
+	resp.SetEAPMessage(eap)
+
+	reply, err := resp.MarshalResponse(secret, req.Authenticator)
+	if err != nil {
+		return nil, err
+	}
+	return reply, authErr
+}
+
+// addMPPEKeys adds MS-MPPE-Recv-Key (first half of the MSK) and
+// MS-MPPE-Send-Key (second half). See RFC 3579 Section 3.1 and RFC 2548.
+func addMPPEKeys(r io.Reader, p *Packet, msk, secret []byte, reqAuth [16]byte) error {
+	if len(msk) < 64 {
+		return errors.New("radius: MSK too short")
+	}
+	recv, err := eapaka.EncryptMPPEKeyWithRandom(r, msk[0:32], secret, reqAuth[:])
+	if err != nil {
+		return err
+	}
+	send, err := eapaka.EncryptMPPEKeyWithRandom(r, msk[32:64], secret, reqAuth[:])
+	if err != nil {
+		return err
+	}
+	p.AddVendor(VendorMicrosoft, VendorMSMPPERecvKey, recv)
+	p.AddVendor(VendorMicrosoft, VendorMSMPPESendKey, send)
+	return nil
+}
+
+// reserve returns the cached reply for key, which may still be in flight,
+// or reserves a new entry and reports that the caller must handle the
+// request and call [Server.finish].
+func (s *Server) reserve(key string) (*cachedReply, bool) {
+	now := time.Now()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if r, ok := s.replies[key]; ok && (r.expires.IsZero() || now.Before(r.expires)) {
+		return r, false
+	}
+	if s.replies == nil {
+		s.replies = make(map[string]*cachedReply)
+	}
+	if now.After(s.nextSweep) {
+		for k, r := range s.replies {
+			if !r.expires.IsZero() && now.After(r.expires) {
+				delete(s.replies, k)
+			}
+		}
+		s.nextSweep = now.Add(s.duplicateWindow())
+	}
+	r := &cachedReply{done: make(chan struct{})}
+	s.replies[key] = r
+	return r, true
+}
+
+// finish caches reply for the duplicate window and releases the
+// retransmissions waiting for it. A nil reply is not cached, so that a later
+// retransmission is handled afresh.
+func (s *Server) finish(key string, r *cachedReply, reply []byte) {
+	s.mu.Lock()
+	if reply == nil {
+		delete(s.replies, key)
+	} else {
+		r.data, r.expires = reply, time.Now().Add(s.duplicateWindow())
+	}
+	s.mu.Unlock()
+	close(r.done)
+}
+
+func (s *Server) duplicateWindow() time.Duration {
+	if s.DuplicateWindow > 0 {
+		return s.DuplicateWindow
+	}
+	return 5 * time.Second
+}
+
+func (s *Server) logger() *slog.Logger {
+	if s.Logger != nil {
+		return s.Logger
+	}
+	return slog.New(slog.DiscardHandler)
+}
+
+// sourceOf returns the host part of addr, so that all ports of a NAS count as
+// one source.
+func sourceOf(addr net.Addr) string {
+	if host, _, err := net.SplitHostPort(addr.String()); err == nil {
+		return host
+	}
+	return addr.String()
+}
@@ -0,0 +1,354 @@
+package radius_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"net"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/oyaguma3/go-eapaka"
+	"github.com/oyaguma3/go-eapaka/radius"
+	"github.com/oyaguma3/go-eapaka/server"
+)
+
+const testIMSI = "001010123456789"
+
+// testVector is returned for every request; the test peer knows it as well.
+var testVector = eapaka.AuthVector{
+	RAND: bytes.Repeat([]byte{0x11}, 16),
+	AUTN: bytes.Repeat([]byte{0x22}, 16),
+	XRES: bytes.Repeat([]byte{0x33}, 8),
+	CK:   bytes.Repeat([]byte{0x44}, 16),
+	IK:   bytes.Repeat([]byte{0x55}, 16),
+}
+
+func startServer(t *testing.T, cfg server.Config) string {
+	t.Helper()
+	cfg.Vectors = eapaka.VectorProviderFunc(func(_ context.Context, req *eapaka.VectorRequest) (*eapaka.AuthVector, error) {
+		if req.IMSI != testIMSI {
+			return nil, eapaka.ErrUnknownSubscriber
+		}
+		v := testVector
+		return &v, nil
+	})
+	auth, err := server.New(cfg)
+	if err != nil {
+		t.Fatalf("server.New failed: %v", err)
+	}
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := &radius.Server{
+		Authenticator: auth,
+		Secrets:       radius.StaticSecrets{"127.0.0.1": []byte("secret")},
+	}
+	go srv.Serve(conn)
+	t.Cleanup(func() { srv.Close() })
+	return conn.LocalAddr().String()
+}
+
+// testPeer is a minimal EAP-AKA/AKA' peer that knows testVector.
+type testPeer struct {
+	identity string
+	res      []byte
+	reauthID string
+	kEncr    []byte
+	kAut     []byte
+	msk      []byte
+	mkOrKRe  []byte
+	eapType  uint8
+}
+
+func (p *testPeer) respond(t *testing.T, req *eapaka.Packet) *eapaka.Packet {
+	t.Helper()
+	resp := &eapaka.Packet{Code: eapaka.CodeResponse, Identifier: req.Identifier, Type: req.Type, Subtype: req.Subtype}
+	switch req.Subtype {
+	case eapaka.SubtypeChallenge:
+		p.eapType = req.Type
+		if req.Type == eapaka.TypeAKAPrime {
+			var netName string
+			for _, a := range req.Attributes {
+				if kdfIn, ok := a.(*eapaka.AtKdfInput); ok {
+					netName = kdfIn.NetworkName
+				}
+			}
+			ckP, ikP, err := eapaka.DeriveCKPrimeIKPrime(testVector.CK, testVector.IK, netName, testVector.AUTN)
+			if err != nil {
+				t.Fatal(err)
+			}
+			keys := eapaka.DeriveKeysAKAPrime(p.identity, ckP, ikP)
+			p.kEncr, p.kAut, p.msk, p.mkOrKRe = keys.K_encr, keys.K_aut, keys.MSK, keys.K_re
+		} else {
+			keys := eapaka.DeriveKeysAKA(p.identity, testVector.CK, testVector.IK)
+			p.kEncr, p.kAut, p.msk, p.mkOrKRe = keys.K_encr, keys.K_aut, keys.MSK, keys.MK
+		}
+		if ok, err := req.VerifyMac(p.kAut); err != nil || !ok {
+			t.Fatalf("peer: Challenge MAC invalid: %v", err)
+		}
+		p.readEncr(t, req)
+		resp.Attributes = []eapaka.Attribute{&eapaka.AtRes{Res: p.res}, &eapaka.AtMac{}}
+		if err := resp.CalculateAndSetMac(p.kAut); err != nil {
+			t.Fatal(err)
+		}
+	case eapaka.SubtypeReauthentication:
+		if ok, err := req.VerifyMac(p.kAut); err != nil || !ok {
+			t.Fatalf("peer: Re-auth MAC invalid: %v", err)
+		}
+		identity := p.reauthID
+		inner := p.readEncr(t, req)
+		var counter uint16
+		var nonceS []byte
+		for _, a := range inner {
+			switch v := a.(type) {
+			case *eapaka.AtCounter:
+				counter = v.Counter
+			case *eapaka.AtNonceS:
+				nonceS = v.NonceS
+			}
+		}
+		rc := &eapaka.ReauthContext{Type: p.eapType, MK: p.mkOrKRe, K_re: p.mkOrKRe}
+		p.msk = rc.DeriveKeys(identity, counter, nonceS).MSK
+
+		iv := bytes.Repeat([]byte{0x01}, 16)
+		encr, err := eapaka.EncryptAttributes(p.kEncr, iv, &eapaka.AtCounter{Counter: counter})
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Attributes = []eapaka.Attribute{&eapaka.AtIv{IV: iv}, encr, &eapaka.AtMac{}}
+		if err := resp.CalculateAndSetMacWithExtra(p.kAut, nonceS); err != nil {
+			t.Fatal(err)
+		}
+	default:
+		t.Fatalf("peer: unexpected subtype %d", req.Subtype)
+	}
+	return resp
+}
+
+func (p *testPeer) readEncr(t *testing.T, req *eapaka.Packet) []eapaka.Attribute {
+	t.Helper()
+	var iv *eapaka.AtIv
+	var encr *eapaka.AtEncrData
+	for _, a := range req.Attributes {
+		switch v := a.(type) {
+		case *eapaka.AtIv:
+			iv = v
+		case *eapaka.AtEncrData:
+			encr = v
+		}
+	}
+	if iv == nil || encr == nil {
+		return nil
+	}
+	inner, err := eapaka.DecryptAttributes(p.kEncr, iv.IV, encr)
+	if err != nil {
+		t.Fatalf("peer: DecryptAttributes failed: %v", err)
+	}
+	for _, a := range inner {
+		if next, ok := a.(*eapaka.AtNextReauthId); ok {
+			p.reauthID = next.Identity
+		}
+	}
+	return inner
+}
+
+// run drives one conversation and returns the final RADIUS reply and the
+// Request Authenticator of the last Access-Request.
+func (p *testPeer) run(t *testing.T, client *radius.Client, identity string) (*radius.Packet, [16]byte) {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// EAP-Response/Identity (Type 1)
+	eap := []byte{eapaka.CodeResponse, 0, 0, 0, 1}
+	eap = append(eap, identity...)
+	binary.BigEndian.PutUint16(eap[2:4], uint16(len(eap)))
+
+	var state []byte
+	for id := uint8(0); ; id++ {
+		req := &radius.Packet{Code: radius.CodeAccessRequest, Identifier: id}
+		req.Add(radius.AttrUserName, []byte(identity))
+		if state != nil {
+			req.Add(radius.AttrState, state)
+		}
+		req.SetEAPMessage(eap)
+		resp, err := client.Exchange(ctx, req)
+		if err != nil {
+			t.Fatalf("Exchange failed: %v", err)
+		}
+		if resp.Code != radius.CodeAccessChallenge {
+			return resp, req.Authenticator
+		}
+		state = resp.Get(radius.AttrState)
+		eapReq, err := eapaka.Parse(resp.EAPMessage())
+		if err != nil {
+			t.Fatalf("Parse failed: %v", err)
+		}
+		eap, err = p.respond(t, eapReq).Marshal()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestServer_FullAndReauth(t *testing.T) {
+	for _, eapType := range []uint8{eapaka.TypeAKA, eapaka.TypeAKAPrime} {
+		addr := startServer(t, server.Config{Type: eapType, EnableReauth: true, Realm: "example.org"})
+		client := &radius.Client{Addr: addr, Secret: []byte("secret")}
+		identity := eapaka.PermanentIdentity(eapType, testIMSI, "example.org")
+		peer := &testPeer{identity: identity, res: testVector.XRES}
+
+		resp, reqAuth := peer.run(t, client, identity)
+		if resp.Code != radius.CodeAccessAccept {
+			t.Fatalf("type %d: got code %d, want Access-Accept", eapType, resp.Code)
+		}
+		checkMPPE(t, resp, reqAuth, peer.msk)
+		if peer.reauthID == "" {
+			t.Fatalf("type %d: no AT_NEXT_REAUTH_ID received", eapType)
+		}
+
+		fullMSK := peer.msk
+		resp, reqAuth = peer.run(t, client, peer.reauthID)
+		if resp.Code != radius.CodeAccessAccept {
+			t.Fatalf("type %d re-auth: got code %d, want Access-Accept", eapType, resp.Code)
+		}
+		checkMPPE(t, resp, reqAuth, peer.msk)
+		if bytes.Equal(fullMSK, peer.msk) {
+			t.Errorf("type %d: re-auth MSK equals full-auth MSK", eapType)
+		}
+	}
+}
+
+func TestServer_WrongRES(t *testing.T) {
+	addr := startServer(t, server.Config{})
+	client := &radius.Client{Addr: addr, Secret: []byte("secret")}
+	identity := eapaka.PermanentIdentity(eapaka.TypeAKAPrime, testIMSI, "")
+	peer := &testPeer{identity: identity, res: make([]byte, 8)}
+
+	resp, _ := peer.run(t, client, identity)
+	if resp.Code != radius.CodeAccessReject {
+		t.Fatalf("got code %d, want Access-Reject", resp.Code)
+	}
+	eap, err := eapaka.Parse(resp.EAPMessage())
+	if err != nil || eap.Code != eapaka.CodeFailure {
+		t.Errorf("EAP-Message: got %+v (%v), want EAP-Failure", eap, err)
+	}
+}
+
+func TestServer_UnknownClient(t *testing.T) {
+	auth, err := server.New(server.Config{Vectors: eapaka.VectorProviderFunc(nil)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := &radius.Server{Authenticator: auth, Secrets: radius.StaticSecrets{"192.0.2.1": []byte("x")}}
+	req := &radius.Packet{Code: radius.CodeAccessRequest}
+	data, _ := req.MarshalRequest([]byte("x"))
+	reply, err := srv.HandlePacket(context.Background(), data, &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if reply != nil || !errors.Is(err, radius.ErrUnknownClient) {
+		t.Errorf("got reply=%v err=%v, want nil reply and ErrUnknownClient", reply, err)
+	}
+}
+
+// TestServer_ConcurrentDuplicates sends a retransmission while the original
+// Access-Request is still being handled: the EAP exchange runs once and both
+// get the same reply.
+func TestServer_ConcurrentDuplicates(t *testing.T) {
+	var calls atomic.Int32
+	entered, release := make(chan struct{}), make(chan struct{})
+	auth, err := server.New(server.Config{Vectors: eapaka.VectorProviderFunc(func(context.Context, *eapaka.VectorRequest) (*eapaka.AuthVector, error) {
+		if calls.Add(1) == 1 {
+			close(entered)
+			<-release
+		}
+		v := testVector
+		return &v, nil
+	})})
+	if err != nil {
+		t.Fatal(err)
+	}
+	src := &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 1645}
+	srv := &radius.Server{Authenticator: auth, Secrets: radius.StaticSecrets{"127.0.0.1": []byte("secret")}}
+	eap, _ := eapaka.NewEAPIdentity(eapaka.CodeResponse, 0, eapaka.PermanentIdentity(eapaka.TypeAKAPrime, testIMSI, "")).Marshal()
+	req := &radius.Packet{Code: radius.CodeAccessRequest, Identifier: 9}
+	req.SetEAPMessage(eap)
+	data, err := req.MarshalRequest([]byte("secret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var replies [2][]byte
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		replies[0], _ = srv.HandlePacket(context.Background(), data, src)
+	}()
+	<-entered
+	go func() {
+		defer wg.Done()
+		replies[1], _ = srv.HandlePacket(context.Background(), data, src)
+	}()
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	if n := calls.Load(); n != 1 {
+		t.Errorf("EAP exchange ran %d times, want 1", n)
+	}
+	if replies[0] == nil || !bytes.Equal(replies[0], replies[1]) {
+		t.Errorf("replies differ:\n%x\n%x", replies[0], replies[1])
+	}
+}
+
+func TestClient_Retries(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	for _, tt := range []struct {
+		retries int
+		want    int
+	}{
+		{0, 1},
+		{1, 2},
+		{-1, 3},
+	} {
+		client := &radius.Client{Addr: conn.LocalAddr().String(), Secret: []byte("secret"), Timeout: 20 * time.Millisecond, Retries: tt.retries}
+		if _, err := client.Exchange(context.Background(), &radius.Packet{Code: radius.CodeAccessRequest}); err == nil {
+			t.Fatalf("Retries %d: reply without a server", tt.retries)
+		}
+		n := 0
+		buf := make([]byte, 4096)
+		conn.SetReadDeadline(time.Now().Add(50 * time.Millisecond))
+		for {
+			if _, _, err := conn.ReadFrom(buf); err != nil {
+				break
+			}
+			n++
+		}
+		if n != tt.want {
+			t.Errorf("Retries %d: sent %d times, want %d", tt.retries, n, tt.want)
+		}
+	}
+}
+
+func checkMPPE(t *testing.T, resp *radius.Packet, reqAuth [16]byte, msk []byte) {
+	t.Helper()
+	recv, err := eapaka.DecryptMPPEKey(resp.GetVendor(radius.VendorMicrosoft, radius.VendorMSMPPERecvKey), []byte("secret"), reqAuth[:])
+	if err != nil {
+		t.Fatalf("DecryptMPPEKey(recv) failed: %v", err)
+	}
+	send, err := eapaka.DecryptMPPEKey(resp.GetVendor(radius.VendorMicrosoft, radius.VendorMSMPPESendKey), []byte("secret"), reqAuth[:])
+	if err != nil {
+		t.Fatalf("DecryptMPPEKey(send) failed: %v", err)
+	}
+	if !bytes.Equal(recv, msk[:32]) || !bytes.Equal(send, msk[32:64]) {
+		t.Errorf("MPPE keys do not match MSK")
+	}
+}
@@ -0,0 +1,46 @@
+package eapaka
+
+import (
+	"crypto/rand"
+	"io"
+	"sync/atomic"
+)
+
+var random atomic.Pointer[io.Reader]
+
+// SetRandom sets the default source of random values: the salt of
+// [EncryptMPPEKey] and, unless their configuration names another source,
+// RAND, NONCE_S, AT_IV, session and re-authentication identities and RADIUS
+// Request Authenticators in the other packages of the module. nil restores
+// crypto/rand.Reader. It is safe for concurrent use; r must be too.
+//
+// Tests can install a seeded deterministic source for byte-exact output;
+// regulated deployments can install an approved DRBG.
+func SetRandom(r io.Reader) {
+	if r == nil {
+		random.Store(nil)
+		return
+	}
+	random.Store(&r)
+}
+
+// Random returns the source set by [SetRandom], crypto/rand.Reader by
+// default.
+func Random() io.Reader {
+	if r := random.Load(); r != nil {
+		return *r
+	}
+	return rand.Reader
+}
+
+// ReadRandom returns n bytes read from r, or from [Random] if r is nil.
+func ReadRandom(r io.Reader, n int) ([]byte, error) {
+	if r == nil {
+		r = Random()
+	}
+	b := make([]byte, n)
+	if _, err := io.ReadFull(r, b); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
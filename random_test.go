@@ -0,0 +1,43 @@
+package eapaka_test
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/oyaguma3/go-eapaka"
+)
+
+func TestSetRandom(t *testing.T) {
+	secret, reqAuth, key := []byte("secret"), make([]byte, 16), eapaka.Key(bytes.Repeat([]byte{1}, 32))
+
+	eapaka.SetRandom(bytes.NewReader(bytes.Repeat([]byte{0x12, 0x34}, 8)))
+	t.Cleanup(func() { eapaka.SetRandom(nil) })
+	first, err := eapaka.EncryptMPPEKey(key, secret, reqAuth)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := eapaka.EncryptMPPEKey(key, secret, reqAuth)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(first, second) || !bytes.Equal(first[:2], []byte{0x92, 0x34}) {
+		t.Errorf("salts %x and %x, want 9234", first[:2], second[:2])
+	}
+
+	// An explicit source takes precedence over the default.
+	explicit, err := eapaka.EncryptMPPEKeyWithRandom(bytes.NewReader([]byte{0, 1}), key, secret, reqAuth)
+	if err != nil || !bytes.Equal(explicit[:2], []byte{0x80, 0x01}) {
+		t.Errorf("EncryptMPPEKeyWithRandom salt = %x, %v", explicit[:2], err)
+	}
+
+	eapaka.SetRandom(bytes.NewReader(nil))
+	if _, err := eapaka.ReadRandom(nil, 16); !errors.Is(err, io.EOF) {
+		t.Errorf("ReadRandom from an exhausted source = %v", err)
+	}
+	eapaka.SetRandom(nil)
+	if b, err := eapaka.ReadRandom(nil, 16); err != nil || len(b) != 16 {
+		t.Errorf("ReadRandom = %x, %v", b, err)
+	}
+}
@@ -0,0 +1,94 @@
+package eapaka
+
+import "fmt"
+
+// NotificationKeys protects the notification round that follows a successful
+// authentication when result indications are in use (RFC 4187 Section 6.2),
+// or that reports a failure after authentication (Section 6.3.1).
+type NotificationKeys struct {
+	K_encr Key
+	K_aut  Key
+
+	// Reauth is set if the round follows a fast re-authentication. Both the
+	// request and the response then carry Counter in an encrypted AT_COUNTER.
+	Reauth  bool
+	Counter uint16
+}
+
+// NewProtectedNotificationRequest builds the EAP-Request/AKA-Notification of
+// a protected result round: AT_NOTIFICATION with value, AT_COUNTER in
+// AT_ENCR_DATA (encrypted with iv) after fast re-authentication, and AT_MAC.
+//
+// value must have the P bit clear: [NotificationSuccess] after successful
+// authentication, or one of [NotificationGeneralFailureAfterAuth],
+// [NotificationTemporarilyDenied] and [NotificationNotSubscribed]. iv is only
+// used after fast re-authentication.
+func NewProtectedNotificationRequest(eapType, identifier uint8, value uint16, k NotificationKeys, iv []byte) (*Packet, error) {
+	if value&0x4000 != 0 {
+		return nil, fmt.Errorf("%w: notification %d has the P bit set", ErrInvalidAttribute, value)
+	}
+	return k.sign(NewNotificationRequest(eapType, identifier, value), iv)
+}
+
+// NewProtectedNotificationResponse builds the EAP-Response/AKA-Notification
+// that answers a request built by [NewProtectedNotificationRequest].
+func NewProtectedNotificationResponse(eapType, identifier uint8, k NotificationKeys, iv []byte) (*Packet, error) {
+	return k.sign(NewNotificationResponse(eapType, identifier), iv)
+}
+
+func (k NotificationKeys) sign(b *Builder, iv []byte) (*Packet, error) {
+	if k.Reauth {
+		b.Encrypt(k.K_encr, iv, &AtCounter{Counter: k.Counter})
+	}
+	return b.Sign(k.K_aut)
+}
+
+// VerifyProtectedNotification checks an EAP-Request or EAP-Response/
+// AKA-Notification of a protected result round: AT_MAC must verify with
+// K_aut and, after fast re-authentication, AT_ENCR_DATA must hold AT_COUNTER
+// equal to Counter. A request must carry AT_NOTIFICATION with the P bit
+// clear. The error wraps [ErrProtectedNotification], or [ErrMACNotFound] if
+// AT_MAC is missing.
+func VerifyProtectedNotification(p *Packet, k NotificationKeys) error {
+	if p.Subtype != SubtypeNotification {
+		return fmt.Errorf("%w: subtype %d", ErrProtectedNotification, p.Subtype)
+	}
+	if p.Code == CodeRequest {
+		n, ok := Find[*AtNotification](p)
+		if !ok || n.P {
+			return fmt.Errorf("%w: AT_NOTIFICATION missing or P bit set", ErrProtectedNotification)
+		}
+	}
+	ok, err := p.VerifyMac(k.K_aut)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return fmt.Errorf("%w: AT_MAC does not verify", ErrProtectedNotification)
+	}
+
+	iv, okIV := Find[*AtIv](p)
+	encr, okEncr := Find[*AtEncrData](p)
+	if !k.Reauth {
+		if okIV || okEncr {
+			return fmt.Errorf("%w: AT_ENCR_DATA after full authentication", ErrProtectedNotification)
+		}
+		return nil
+	}
+	if !okIV || !okEncr {
+		return fmt.Errorf("%w: AT_COUNTER missing", ErrProtectedNotification)
+	}
+	inner, err := DecryptAttributes(k.K_encr, iv.IV, encr)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrProtectedNotification, err)
+	}
+	for _, attr := range inner {
+		if c, ok := attr.(*AtCounter); ok {
+			if c.Counter != k.Counter {
+				return fmt.Errorf("%w: AT_COUNTER %d, want %d", ErrProtectedNotification, c.Counter, k.Counter)
+			}
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: AT_COUNTER missing", ErrProtectedNotification)
+}
@@ -0,0 +1,59 @@
+package eapaka_test
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/oyaguma3/go-eapaka"
+)
+
+func TestProtectedNotification(t *testing.T) {
+	full := eapaka.NotificationKeys{K_encr: bytes.Repeat([]byte{1}, 16), K_aut: bytes.Repeat([]byte{2}, 32)}
+	reauth := full
+	reauth.Reauth, reauth.Counter = true, 7
+	iv := bytes.Repeat([]byte{3}, 16)
+
+	for _, k := range []eapaka.NotificationKeys{full, reauth} {
+		req, err := eapaka.NewProtectedNotificationRequest(eapaka.TypeAKAPrime, 5, eapaka.NotificationSuccess, k, iv)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp, err := eapaka.NewProtectedNotificationResponse(eapaka.TypeAKAPrime, 5, k, iv)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, p := range []*eapaka.Packet{req, resp} {
+			b, _ := p.Marshal()
+			parsed, err := eapaka.ParseWithOptions(b, eapaka.StrictParseOptions)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := parsed.Validate(); err != nil {
+				t.Errorf("reauth %v: %v", k.Reauth, err)
+			}
+			if err := eapaka.VerifyProtectedNotification(parsed, k); err != nil {
+				t.Errorf("reauth %v: %v", k.Reauth, err)
+			}
+
+			wrong := k
+			wrong.Reauth, wrong.Counter = !k.Reauth, 8
+			if err := eapaka.VerifyProtectedNotification(parsed, wrong); !errors.Is(err, eapaka.ErrProtectedNotification) {
+				t.Errorf("reauth %v: wrong counter: %v", k.Reauth, err)
+			}
+			wrong = k
+			wrong.K_aut = make([]byte, 32)
+			if err := eapaka.VerifyProtectedNotification(parsed, wrong); !errors.Is(err, eapaka.ErrProtectedNotification) {
+				t.Errorf("reauth %v: wrong K_aut: %v", k.Reauth, err)
+			}
+		}
+	}
+
+	if _, err := eapaka.NewProtectedNotificationRequest(eapaka.TypeAKA, 1, eapaka.NotificationGeneralFailure, full, nil); !errors.Is(err, eapaka.ErrInvalidAttribute) {
+		t.Errorf("P bit set: %v", err)
+	}
+	unprotected, _ := eapaka.NewNotificationRequest(eapaka.TypeAKA, 1, eapaka.NotificationGeneralFailure).Build()
+	if err := eapaka.VerifyProtectedNotification(unprotected, full); !errors.Is(err, eapaka.ErrProtectedNotification) {
+		t.Errorf("P bit set: %v", err)
+	}
+}
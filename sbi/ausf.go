@@ -0,0 +1,315 @@
+package sbi
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/oyaguma3/go-eapaka"
+	"github.com/oyaguma3/go-eapaka/server"
+)
+
+// ausfPath is the Nausf_UEAuthentication collection under the apiRoot.
+const ausfPath = "/nausf-auth/v1/ue-authentications"
+
+// AuthenticationInfo is the body that starts an authentication
+// (TS 29.509 Section 6.1.6.2.2).
+type AuthenticationInfo struct {
+	SUPIOrSUCI         string `json:"supiOrSuci"`
+	ServingNetworkName string `json:"servingNetworkName"`
+}
+
+// UEAuthenticationCtx is the answer that starts an authentication
+// (TS 29.509 Section 6.1.6.2.3). For EAP-AKA', AuthData holds the base64
+// EAP-Request/AKA'-Challenge and Links the "eap-session" resource.
+type UEAuthenticationCtx struct {
+	AuthType           string          `json:"authType"`
+	AuthData           string          `json:"5gAuthData"`
+	Links              map[string]Link `json:"_links"`
+	ServingNetworkName string          `json:"servingNetworkName,omitempty"`
+}
+
+// EapSession carries one base64 EAP packet of an authentication
+// (TS 29.509 Section 6.1.6.2.4). The final answer also carries the
+// result, the SUPI and, on success, K_SEAF in hex.
+type EapSession struct {
+	EapPayload string          `json:"eapPayload"`
+	KSeaf      string          `json:"kSeaf,omitempty"`
+	Links      map[string]Link `json:"_links,omitempty"`
+	AuthResult string          `json:"authResult,omitempty"`
+	SUPI       string          `json:"supi,omitempty"`
+}
+
+// AUSF is an http.Handler that serves Nausf_UEAuthentication for
+// EAP-AKA' (TS 29.509, TS 33.501 Section 6.1.3.1). POST to
+// /nausf-auth/v1/ue-authentications starts an authentication and returns
+// the EAP-Request/AKA'-Challenge; the EAP-Responses are then POSTed to the
+// returned eap-session link until authResult is set. On success the answer
+// carries K_SEAF, derived from the EMSK and the serving network name.
+//
+// The serving network name is used as the EAP-AKA' network name; names
+// other than [AUSF.ServingNetworkNames] are rejected. The identity used in
+// key derivation is the permanent identity of the SUPI in Realm, which the
+// peer must use too. It is safe for concurrent use.
+type AUSF struct {
+	// Vectors supplies the vectors, typically a [UDMClient]. Required.
+	Vectors eapaka.VectorProvider
+
+	// ServingNetworkNames are the serving network names the AUSF serves,
+	// e.g. "5G:mnc001.mcc001.3gppnetwork.org". Required.
+	ServingNetworkNames []string
+
+	// Realm is the realm of the identity used in key derivation.
+	// Default: none.
+	Realm string
+
+	// Store keeps the EAP state between requests.
+	// Default: an [eapaka.MemoryStore] with default settings.
+	Store eapaka.SessionStore
+
+	// Lifetime is how long an unfinished authentication is kept.
+	// Default: 5m.
+	Lifetime time.Duration
+
+	// Random is the source of authentication context ids and of the EAP
+	// server's random values. Default: [eapaka.Random].
+	Random io.Reader
+
+	// Logger receives diagnostics. Default: discard.
+	Logger *slog.Logger
+
+	once    sync.Once
+	initErr error
+	mux     *http.ServeMux
+	auths   map[string]*server.Authenticator // by serving network name; fixed after init
+	mu      sync.Mutex
+	ctxs    map[string]*authContext
+}
+
+// authContext is one authentication in progress.
+type authContext struct {
+	auth      *server.Authenticator
+	sessionID string
+	supi      string
+	snn       string
+	expires   time.Time
+}
+
+// ServeHTTP implements http.Handler.
+func (a *AUSF) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	a.once.Do(func() {
+		if a.Store == nil {
+			a.Store = eapaka.NewMemoryStore(eapaka.StoreConfig{})
+		}
+		a.auths, a.initErr = a.newAuthenticators()
+		a.ctxs = make(map[string]*authContext)
+		a.mux = http.NewServeMux()
+		a.mux.HandleFunc("POST "+ausfPath, a.start)
+		a.mux.HandleFunc("POST "+ausfPath+"/{authCtxId}/eap-session", a.eapSession)
+	})
+	a.mux.ServeHTTP(w, r)
+}
+
+func (a *AUSF) start(w http.ResponseWriter, r *http.Request) {
+	if a.initErr != nil {
+		writeProblem(w, http.StatusInternalServerError, CauseSystemFailure, a.initErr.Error())
+		return
+	}
+	var info AuthenticationInfo
+	if err := json.NewDecoder(r.Body).Decode(&info); err != nil {
+		writeProblem(w, http.StatusBadRequest, CauseMandatoryIEIncorrect, err.Error())
+		return
+	}
+	if info.SUPIOrSUCI == "" || info.ServingNetworkName == "" {
+		writeProblem(w, http.StatusBadRequest, CauseMandatoryIEMissing, "supiOrSuci and servingNetworkName are required")
+		return
+	}
+	imsi, err := ParseSUPI(info.SUPIOrSUCI)
+	if err != nil {
+		writeProblem(w, http.StatusBadRequest, CauseMandatoryIEIncorrect, err.Error())
+		return
+	}
+	if err := CheckServingNetworkName(info.ServingNetworkName); err != nil {
+		writeProblem(w, http.StatusBadRequest, CauseMandatoryIEIncorrect, err.Error())
+		return
+	}
+	auth := a.auths[info.ServingNetworkName]
+	if auth == nil {
+		writeProblem(w, http.StatusForbidden, CauseServingNetworkNotAuthorized, "")
+		return
+	}
+	id, err := eapaka.ReadRandom(a.Random, 16)
+	if err != nil {
+		writeProblem(w, http.StatusInternalServerError, CauseSystemFailure, err.Error())
+		return
+	}
+	authCtxID := hex.EncodeToString(id)
+
+	// The UE's identity is known from NAS: start with the
+	// EAP-Request/AKA'-Challenge.
+	identity := eapaka.NewEAPIdentity(eapaka.CodeResponse, 0, eapaka.PermanentIdentity(eapaka.TypeAKAPrime, imsi, a.Realm))
+	msg, _ := identity.Marshal()
+	res, err := auth.Handle(r.Context(), "", msg)
+	if res.Status != server.StatusContinue {
+		switch {
+		case errors.Is(err, eapaka.ErrUnknownSubscriber):
+			writeProblem(w, http.StatusNotFound, CauseUserNotFound, "")
+		default:
+			a.logger().Debug("sbi: authentication not started", "supi", eapaka.LogIdentity(imsi), "error", err)
+			writeProblem(w, http.StatusInternalServerError, CauseUpstreamServerError, errString(err))
+		}
+		return
+	}
+	payload, err := res.Reply.Marshal()
+	if err != nil {
+		writeProblem(w, http.StatusInternalServerError, CauseSystemFailure, err.Error())
+		return
+	}
+
+	a.mu.Lock()
+	now := time.Now()
+	for id, c := range a.ctxs {
+		if now.After(c.expires) {
+			delete(a.ctxs, id)
+		}
+	}
+	a.ctxs[authCtxID] = &authContext{
+		auth:      auth,
+		sessionID: res.SessionID,
+		supi:      "imsi-" + imsi,
+		snn:       info.ServingNetworkName,
+		expires:   now.Add(a.lifetime()),
+	}
+	a.mu.Unlock()
+
+	location := ausfPath + "/" + authCtxID
+	w.Header().Set("Location", location)
+	writeJSON(w, http.StatusCreated, &UEAuthenticationCtx{
+		AuthType:           AuthTypeEAPAKAPrime,
+		AuthData:           base64.StdEncoding.EncodeToString(payload),
+		Links:              map[string]Link{"eap-session": {Href: location + "/eap-session"}},
+		ServingNetworkName: info.ServingNetworkName,
+	})
+}
+
+func (a *AUSF) eapSession(w http.ResponseWriter, r *http.Request) {
+	authCtxID := r.PathValue("authCtxId")
+	a.mu.Lock()
+	c := a.ctxs[authCtxID]
+	var sessionID string
+	if c != nil {
+		sessionID = c.sessionID
+	}
+	a.mu.Unlock()
+	if c == nil || time.Now().After(c.expires) {
+		writeProblem(w, http.StatusNotFound, CauseContextNotFound, "")
+		return
+	}
+	var body EapSession
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeProblem(w, http.StatusBadRequest, CauseMandatoryIEIncorrect, err.Error())
+		return
+	}
+	msg, err := base64.StdEncoding.DecodeString(body.EapPayload)
+	if err != nil {
+		writeProblem(w, http.StatusBadRequest, CauseMandatoryIEIncorrect, "eapPayload is not base64")
+		return
+	}
+
+	res, err := c.auth.Handle(r.Context(), sessionID, msg)
+	if res.Status == server.StatusDiscard {
+		// The context is kept; the UE may still answer the outstanding request.
+		writeProblem(w, http.StatusBadRequest, CauseMandatoryIEIncorrect, err.Error())
+		return
+	}
+	payload, merr := res.Reply.Marshal()
+	if merr != nil {
+		writeProblem(w, http.StatusInternalServerError, CauseSystemFailure, merr.Error())
+		return
+	}
+	out := &EapSession{EapPayload: base64.StdEncoding.EncodeToString(payload)}
+	switch res.Status {
+	case server.StatusContinue:
+		a.mu.Lock()
+		c.sessionID = res.SessionID
+		a.mu.Unlock()
+		out.Links = map[string]Link{"eap-session": {Href: ausfPath + "/" + authCtxID + "/eap-session"}}
+		writeJSON(w, http.StatusOK, out)
+		return
+	case server.StatusSuccess:
+		kAUSF, err := eapaka.DeriveKAUSF(res.EMSK)
+		if err != nil {
+			a.mu.Lock()
+			delete(a.ctxs, authCtxID)
+			a.mu.Unlock()
+			writeProblem(w, http.StatusInternalServerError, CauseSystemFailure, err.Error())
+			return
+		}
+		kSEAF := eapaka.DeriveKSEAF(kAUSF, c.snn)
+		out.AuthResult, out.SUPI, out.KSeaf = AuthResultSuccess, c.supi, hex.EncodeToString(kSEAF)
+		kAUSF.Wipe()
+		kSEAF.Wipe()
+	default:
+		a.logger().Debug("sbi: authentication failed", "supi", eapaka.LogIdentity(c.supi), "error", err)
+		out.AuthResult = AuthResultFailure
+	}
+	a.mu.Lock()
+	delete(a.ctxs, authCtxID)
+	a.mu.Unlock()
+	writeJSON(w, http.StatusOK, out)
+}
+
+// newAuthenticators creates an EAP server for each serving network name.
+func (a *AUSF) newAuthenticators() (map[string]*server.Authenticator, error) {
+	if a.Vectors == nil {
+		return nil, errors.New("sbi: AUSF.Vectors is required")
+	}
+	if len(a.ServingNetworkNames) == 0 {
+		return nil, errors.New("sbi: AUSF.ServingNetworkNames is required")
+	}
+	auths := make(map[string]*server.Authenticator, len(a.ServingNetworkNames))
+	for _, snn := range a.ServingNetworkNames {
+		if err := CheckServingNetworkName(snn); err != nil {
+			return nil, err
+		}
+		auth, err := server.New(server.Config{
+			Type:        eapaka.TypeAKAPrime,
+			NetworkName: snn,
+			Vectors:     a.Vectors,
+			Store:       a.Store,
+			Random:      a.Random,
+		})
+		if err != nil {
+			return nil, err
+		}
+		auths[snn] = auth
+	}
+	return auths, nil
+}
+
+func (a *AUSF) lifetime() time.Duration {
+	if a.Lifetime > 0 {
+		return a.Lifetime
+	}
+	return 5 * time.Minute
+}
+
+func (a *AUSF) logger() *slog.Logger {
+	if a.Logger != nil {
+		return a.Logger
+	}
+	return slog.New(slog.DiscardHandler)
+}
+
+func errString(err error) string {
+	if err == nil {
+		return ""
+	}
+	return err.Error()
+}
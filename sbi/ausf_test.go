@@ -0,0 +1,240 @@
+package sbi_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/oyaguma3/go-eapaka"
+	"github.com/oyaguma3/go-eapaka/eapakatest"
+	"github.com/oyaguma3/go-eapaka/milenage"
+	"github.com/oyaguma3/go-eapaka/peer"
+	"github.com/oyaguma3/go-eapaka/sbi"
+)
+
+const testSNN = "5G:mnc001.mcc001.3gppnetwork.org"
+
+var (
+	testSub     = &eapakatest.Subscribers[0]
+	unknownIMSI = eapakatest.Subscribers[1].IMSI
+)
+
+// startUDM serves sub from a mock UDM and returns a client of it.
+func startUDM(t *testing.T, sub *milenage.Subscriber) *sbi.UDMClient {
+	t.Helper()
+	udm := httptest.NewServer(sbi.NewMockUDM(sub))
+	t.Cleanup(udm.Close)
+	return &sbi.UDMClient{BaseURL: udm.URL, HTTPClient: udm.Client()}
+}
+
+func TestParseSUPI(t *testing.T) {
+	tests := []struct {
+		in, want string
+		wantErr  error
+	}{
+		{"imsi-001010123456789", "001010123456789", nil},
+		{"suci-0-001-01-0000-0-0-0123456789", "001010123456789", nil},
+		{"suci-0-310-410-0000-1-1-4f2a", "", sbi.ErrConcealedSUCI},
+		{"nai-user@example.org", "", nil},
+		{"imsi-00101x", "", nil},
+	}
+	for _, tt := range tests {
+		got, err := sbi.ParseSUPI(tt.in)
+		if got != tt.want || (tt.want == "" && err == nil) || (tt.wantErr != nil && !errors.Is(err, tt.wantErr)) {
+			t.Errorf("ParseSUPI(%q) = %q, %v", tt.in, got, err)
+		}
+	}
+}
+
+func TestUDMClient(t *testing.T) {
+	c := startUDM(t, testSub.Milenage())
+	ctx := context.Background()
+	v, err := c.GetVector(ctx, &eapaka.VectorRequest{IMSI: testSub.IMSI, Type: eapaka.TypeAKAPrime, NetworkName: testSNN})
+	if err != nil {
+		t.Fatal(err)
+	}
+	usim, err := testSub.USIM()
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, ck, ik, _, err := usim.Authenticate(v.RAND, v.AUTN)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ckPrime, ikPrime, err := eapaka.DeriveCKPrimeIKPrime(ck, ik, testSNN, v.AUTN)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !v.Prime || !bytes.Equal(res, v.XRES) || !bytes.Equal(ckPrime, v.CK) || !bytes.Equal(ikPrime, v.IK) {
+		t.Error("vector does not match the USIM")
+	}
+
+	if _, err := c.GetVector(ctx, &eapaka.VectorRequest{IMSI: unknownIMSI, Type: eapaka.TypeAKAPrime, NetworkName: testSNN}); !errors.Is(err, eapaka.ErrUnknownSubscriber) {
+		t.Errorf("unknown subscriber: %v", err)
+	}
+	var problem *sbi.ProblemDetails
+	if _, err := c.GetVector(ctx, &eapaka.VectorRequest{IMSI: testSub.IMSI, Type: eapaka.TypeAKAPrime}); !errors.As(err, &problem) || problem.Cause != sbi.CauseMandatoryIEMissing {
+		t.Errorf("missing serving network name: %v", err)
+	}
+	if _, err := c.GetVector(ctx, &eapaka.VectorRequest{IMSI: testSub.IMSI, Type: eapaka.TypeAKA}); err == nil {
+		t.Error("EAP-AKA vector accepted")
+	}
+}
+
+func post(t *testing.T, url string, in, out any) int {
+	t.Helper()
+	b, _ := json.Marshal(in)
+	resp, err := http.Post(url, "application/json", bytes.NewReader(b))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		t.Fatal(err)
+	}
+	return resp.StatusCode
+}
+
+// TestAUSF runs EAP-AKA' between a peer and the AUSF, which fetches
+// vectors from the mock UDM; the UDM starts behind the USIM in the second
+// case and is brought forward by a re-synchronisation.
+func TestAUSF(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		behind uint64
+	}{
+		{"in-sync", 0},
+		{"resync", 500},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			sub := testSub.Milenage()
+			sub.SQN -= tc.behind
+			ausf := httptest.NewServer(&sbi.AUSF{Vectors: startUDM(t, sub), ServingNetworkNames: []string{testSNN}})
+			defer ausf.Close()
+			usim, err := testSub.USIM()
+			if err != nil {
+				t.Fatal(err)
+			}
+			p, err := peer.New(peer.Config{
+				Identity:    eapaka.PermanentIdentity(eapaka.TypeAKAPrime, testSub.IMSI, ""),
+				SIM:         usim,
+				NetworkName: testSNN,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var authCtx sbi.UEAuthenticationCtx
+			info := sbi.AuthenticationInfo{SUPIOrSUCI: "suci-0-001-01-0000-0-0-" + testSub.IMSI[5:], ServingNetworkName: testSNN}
+			if status := post(t, ausf.URL+"/nausf-auth/v1/ue-authentications", &info, &authCtx); status != http.StatusCreated {
+				t.Fatalf("status %d", status)
+			}
+			link := authCtx.Links["eap-session"].Href
+			payload := authCtx.AuthData
+			for range 5 {
+				req, _ := base64.StdEncoding.DecodeString(payload)
+				resp, err := p.Handle(req)
+				if err != nil {
+					t.Fatal(err)
+				}
+				var session sbi.EapSession
+				if status := post(t, ausf.URL+link, &sbi.EapSession{EapPayload: base64.StdEncoding.EncodeToString(resp)}, &session); status != http.StatusOK {
+					t.Fatalf("status %d", status)
+				}
+				if session.AuthResult == "" {
+					payload = session.EapPayload
+					continue
+				}
+				final, _ := base64.StdEncoding.DecodeString(session.EapPayload)
+				p.Handle(final)
+				if session.AuthResult != sbi.AuthResultSuccess || p.Status() != peer.StatusSuccess || session.SUPI != "imsi-"+testSub.IMSI {
+					t.Fatalf("authResult %s, supi %s, peer status %v", session.AuthResult, session.SUPI, p.Status())
+				}
+				kAUSF, err := eapaka.DeriveKAUSF(p.EMSK())
+				if err != nil {
+					t.Fatal(err)
+				}
+				kSEAF := eapaka.DeriveKSEAF(kAUSF, testSNN)
+				if !strings.EqualFold(session.KSeaf, hex.EncodeToString(kSEAF)) {
+					t.Error("K_SEAF does not match the peer's")
+				}
+
+				// The context is gone.
+				var problem sbi.ProblemDetails
+				if status := post(t, ausf.URL+link, &sbi.EapSession{EapPayload: session.EapPayload}, &problem); status != http.StatusNotFound || problem.Cause != sbi.CauseContextNotFound {
+					t.Errorf("finished context: status %d, %+v", status, problem)
+				}
+				return
+			}
+			t.Fatal("authentication did not finish")
+		})
+	}
+}
+
+func TestAUSF_UnknownSubscriber(t *testing.T) {
+	ausf := httptest.NewServer(&sbi.AUSF{Vectors: startUDM(t, testSub.Milenage()), ServingNetworkNames: []string{testSNN}})
+	defer ausf.Close()
+	var problem sbi.ProblemDetails
+	info := sbi.AuthenticationInfo{SUPIOrSUCI: "imsi-" + unknownIMSI, ServingNetworkName: testSNN}
+	if status := post(t, ausf.URL+"/nausf-auth/v1/ue-authentications", &info, &problem); status != http.StatusNotFound || problem.Cause != sbi.CauseUserNotFound {
+		t.Errorf("status %d, %+v", status, problem)
+	}
+}
+
+func TestAUSF_ServingNetworkName(t *testing.T) {
+	ausf := httptest.NewServer(&sbi.AUSF{Vectors: startUDM(t, testSub.Milenage()), ServingNetworkNames: []string{testSNN}})
+	defer ausf.Close()
+	for _, tt := range []struct {
+		snn    string
+		status int
+		cause  string
+	}{
+		{"WLAN", http.StatusBadRequest, sbi.CauseMandatoryIEIncorrect},
+		{"5G:mnc01.mcc001.3gppnetwork.org", http.StatusBadRequest, sbi.CauseMandatoryIEIncorrect},
+		{"5G:mnc002.mcc001.3gppnetwork.org", http.StatusForbidden, sbi.CauseServingNetworkNotAuthorized},
+		{testSNN + ":000000000ab", http.StatusForbidden, sbi.CauseServingNetworkNotAuthorized},
+	} {
+		var problem sbi.ProblemDetails
+		info := sbi.AuthenticationInfo{SUPIOrSUCI: "imsi-" + testSub.IMSI, ServingNetworkName: tt.snn}
+		if status := post(t, ausf.URL+"/nausf-auth/v1/ue-authentications", &info, &problem); status != tt.status || problem.Cause != tt.cause {
+			t.Errorf("%q: status %d, %+v", tt.snn, status, problem)
+		}
+	}
+
+	for _, names := range [][]string{nil, {"5G:example.org"}} {
+		ausf := httptest.NewServer(&sbi.AUSF{Vectors: startUDM(t, testSub.Milenage()), ServingNetworkNames: names})
+		var problem sbi.ProblemDetails
+		info := sbi.AuthenticationInfo{SUPIOrSUCI: "imsi-" + testSub.IMSI, ServingNetworkName: testSNN}
+		if status := post(t, ausf.URL+"/nausf-auth/v1/ue-authentications", &info, &problem); status != http.StatusInternalServerError {
+			t.Errorf("ServingNetworkNames %q: status %d, %+v", names, status, problem)
+		}
+		ausf.Close()
+	}
+}
+
+func TestCheckServingNetworkName(t *testing.T) {
+	for _, snn := range []string{testSNN, "5G:mnc310.mcc410.3gppnetwork.org:000007ed9d5"} {
+		if err := sbi.CheckServingNetworkName(snn); err != nil {
+			t.Errorf("%q: %v", snn, err)
+		}
+	}
+	for _, snn := range []string{
+		"",
+		"mnc001.mcc001.3gppnetwork.org",
+		"5G:mnc001.mcc001.example.org",
+		"5G:mnc0a1.mcc001.3gppnetwork.org",
+		"5G:mnc001.mcc001.3gppnetwork.org:xyz",
+		"5G:mnc001.mcc001.3gppnetwork.org:000000000ab:1",
+	} {
+		if err := sbi.CheckServingNetworkName(snn); err == nil {
+			t.Errorf("%q accepted", snn)
+		}
+	}
+}
@@ -0,0 +1,136 @@
+// Package sbi implements the parts of the 5G core Service Based Interfaces
+// used by EAP-AKA' for non-3GPP access: a client for the UDM's
+// Nudm_UEAuthentication_Get (TS 29.503) that is an [eapaka.VectorProvider],
+// an AUSF-style handler for Nausf_UEAuthentication with EAP sessions
+// (TS 29.509), and a [MockUDM] so that tests need no external network
+// functions. All of them are plain net/http and work with httptest.
+package sbi
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// ErrConcealedSUCI is returned by [ParseSUPI] for a SUCI protected with a
+// scheme other than the null scheme.
+var ErrConcealedSUCI = errors.New("sbi: SUCI is concealed")
+
+// Authentication types and results (TS 29.509 Section 6.1.6.3)
+const (
+	AuthTypeEAPAKAPrime = "EAP_AKA_PRIME"
+
+	AuthResultSuccess = "AUTHENTICATION_SUCCESS"
+	AuthResultFailure = "AUTHENTICATION_FAILURE"
+	AuthResultOngoing = "AUTHENTICATION_ONGOING"
+)
+
+// Causes of [ProblemDetails] (TS 29.503 Section 6.3.7, TS 29.509 Section
+// 6.1.7, TS 29.500 Section 5.2.7)
+const (
+	CauseUserNotFound                = "USER_NOT_FOUND"
+	CauseContextNotFound             = "CONTEXT_NOT_FOUND"
+	CauseAuthenticationRejected      = "AUTHENTICATION_REJECTED"
+	CauseMandatoryIEMissing          = "MANDATORY_IE_MISSING"
+	CauseMandatoryIEIncorrect        = "MANDATORY_IE_INCORRECT"
+	CauseServingNetworkNotAuthorized = "SERVING_NETWORK_NOT_AUTHORIZED"
+	CauseSystemFailure               = "SYSTEM_FAILURE"
+	CauseUpstreamServerError         = "UPSTREAM_SERVER_ERROR"
+)
+
+// ProblemDetails is the body of an error response (RFC 7807, TS 29.571
+// Section 5.2.4.1). It is returned as an error by [UDMClient.GetVector].
+type ProblemDetails struct {
+	Title  string `json:"title,omitempty"`
+	Status int    `json:"status,omitempty"`
+	Detail string `json:"detail,omitempty"`
+	Cause  string `json:"cause,omitempty"`
+}
+
+func (p *ProblemDetails) Error() string {
+	s := fmt.Sprintf("sbi: status %d", p.Status)
+	if p.Cause != "" {
+		s += " " + p.Cause
+	}
+	if p.Detail != "" {
+		s += ": " + p.Detail
+	}
+	return s
+}
+
+// Link is a hypermedia link (TS 29.571 Section 5.2.4.3).
+type Link struct {
+	Href string `json:"href"`
+}
+
+// ParseSUPI returns the IMSI of a SUPI ("imsi-001010123456789") or of a
+// SUCI of IMSI type protected with the null scheme
+// ("suci-0-001-01-0000-0-0-0123456789"), see TS 23.003 Section 2.2B and
+// TS 29.503 Section 6.3.3.
+func ParseSUPI(supiOrSuci string) (string, error) {
+	if imsi, ok := strings.CutPrefix(supiOrSuci, "imsi-"); ok && isDigits(imsi) {
+		return imsi, nil
+	}
+	// suci-<type>-<mcc>-<mnc>-<routing indicator>-<scheme>-<key id>-<output>
+	f := strings.Split(supiOrSuci, "-")
+	if len(f) != 8 || f[0] != "suci" || f[1] != "0" || !isDigits(f[2]+f[3]) {
+		return "", fmt.Errorf("sbi: invalid SUPI or SUCI %q", supiOrSuci)
+	}
+	if f[5] != "0" {
+		return "", fmt.Errorf("%w: protection scheme %s", ErrConcealedSUCI, f[5])
+	}
+	if !isDigits(f[7]) {
+		return "", fmt.Errorf("sbi: invalid SUPI or SUCI %q", supiOrSuci)
+	}
+	return f[2] + f[3] + f[7], nil
+}
+
+// CheckServingNetworkName reports an error unless snn is a serving network
+// name "5G:mnc<MNC>.mcc<MCC>.3gppnetwork.org", optionally followed by
+// ":<NID>" (TS 24.501 Section 9.12.1, TS 33.501 Section 6.1.1.4).
+func CheckServingNetworkName(snn string) error {
+	id, ok := strings.CutPrefix(snn, "5G:")
+	id, nid, hasNID := strings.Cut(id, ":")
+	f := strings.SplitN(id, ".", 3)
+	if !ok || len(f) != 3 || f[2] != "3gppnetwork.org" ||
+		len(f[0]) != 6 || !strings.HasPrefix(f[0], "mnc") || !isDigits(f[0][3:]) ||
+		len(f[1]) != 6 || !strings.HasPrefix(f[1], "mcc") || !isDigits(f[1][3:]) ||
+		hasNID && (len(nid) != 11 || strings.Trim(strings.ToLower(nid), "0123456789abcdef") != "") {
+		return fmt.Errorf("sbi: invalid serving network name %q", snn)
+	}
+	return nil
+}
+
+func isDigits(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeProblem(w http.ResponseWriter, status int, cause, detail string) {
+	w.Header().Set("Content-Type", "application/problem+json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(&ProblemDetails{Status: status, Cause: cause, Detail: detail})
+}
+
+// readProblem decodes the ProblemDetails of an error response.
+func readProblem(resp *http.Response) *ProblemDetails {
+	p := &ProblemDetails{}
+	json.NewDecoder(resp.Body).Decode(p)
+	p.Status = resp.StatusCode
+	return p
+}
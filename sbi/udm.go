@@ -0,0 +1,233 @@
+package sbi
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+
+	"github.com/oyaguma3/go-eapaka"
+	"github.com/oyaguma3/go-eapaka/milenage"
+)
+
+// udmPath is the Nudm_UEAuthentication_Get resource under the apiRoot.
+const udmPath = "/nudm-ueau/v1/{supiOrSuci}/security-information/generate-auth-data"
+
+// AuthenticationInfoRequest is the body of Nudm_UEAuthentication_Get
+// (TS 29.503 Section 6.3.6.2.2).
+type AuthenticationInfoRequest struct {
+	ServingNetworkName    string                 `json:"servingNetworkName"`
+	ResynchronizationInfo *ResynchronizationInfo `json:"resynchronizationInfo,omitempty"`
+	AUSFInstanceID        string                 `json:"ausfInstanceId"`
+}
+
+// ResynchronizationInfo carries RAND and AUTS, in hex, after a
+// synchronization failure (TS 29.503 Section 6.3.6.2.5).
+type ResynchronizationInfo struct {
+	RAND string `json:"rand"`
+	AUTS string `json:"auts"`
+}
+
+// AuthenticationInfoResult is the answer of Nudm_UEAuthentication_Get
+// (TS 29.503 Section 6.3.6.2.3).
+type AuthenticationInfoResult struct {
+	AuthType             string                `json:"authType"`
+	AuthenticationVector *AuthenticationVector `json:"authenticationVector,omitempty"`
+	SUPI                 string                `json:"supi,omitempty"`
+}
+
+// AuthenticationVector is an AvEapAkaPrime, with values in hex
+// (TS 29.503 Section 6.3.6.2.8).
+type AuthenticationVector struct {
+	AvType  string `json:"avType"`
+	RAND    string `json:"rand"`
+	XRES    string `json:"xres"`
+	AUTN    string `json:"autn"`
+	CKPrime string `json:"ckPrime"`
+	IKPrime string `json:"ikPrime"`
+}
+
+// UDMClient is an [eapaka.VectorProvider] that fetches EAP-AKA' vectors
+// from a UDM with Nudm_UEAuthentication_Get. The UDM computes CK' and IK'
+// for the serving network name, taken from [eapaka.VectorRequest]
+// NetworkName (server.Config.NetworkName), e.g.
+// "5G:mnc001.mcc001.3gppnetwork.org". EAP-AKA vectors are not available
+// over this interface.
+type UDMClient struct {
+	// BaseURL is the apiRoot of the UDM, e.g. "https://udm.example:443".
+	BaseURL string
+
+	// HTTPClient sends the requests. Default: http.DefaultClient.
+	HTTPClient *http.Client
+
+	// AUSFInstanceID identifies the caller. Default: the nil UUID.
+	AUSFInstanceID string
+}
+
+// GetVector implements [eapaka.VectorProvider]. A UDM answer of 404
+// USER_NOT_FOUND is reported as [eapaka.ErrUnknownSubscriber]; other error
+// answers as a [*ProblemDetails].
+func (c *UDMClient) GetVector(ctx context.Context, req *eapaka.VectorRequest) (*eapaka.AuthVector, error) {
+	if req.Type != eapaka.TypeAKAPrime {
+		return nil, errors.New("sbi: the UDM provides EAP-AKA' vectors only")
+	}
+	body := AuthenticationInfoRequest{
+		ServingNetworkName: req.NetworkName,
+		AUSFInstanceID:     c.AUSFInstanceID,
+	}
+	if body.AUSFInstanceID == "" {
+		body.AUSFInstanceID = "00000000-0000-0000-0000-000000000000"
+	}
+	if req.AUTS != nil {
+		body.ResynchronizationInfo = &ResynchronizationInfo{
+			RAND: hex.EncodeToString(req.RAND),
+			AUTS: hex.EncodeToString(req.AUTS),
+		}
+	}
+	b, err := json.Marshal(&body)
+	if err != nil {
+		return nil, err
+	}
+	u := strings.TrimSuffix(c.BaseURL, "/") +
+		strings.Replace(udmPath, "{supiOrSuci}", url.PathEscape("imsi-"+req.IMSI), 1)
+	hreq, err := http.NewRequestWithContext(ctx, http.MethodPost, u, bytes.NewReader(b))
+	if err != nil {
+		return nil, err
+	}
+	hreq.Header.Set("Content-Type", "application/json")
+
+	client := c.HTTPClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(hreq)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		p := readProblem(resp)
+		if p.Status == http.StatusNotFound && p.Cause == CauseUserNotFound {
+			return nil, fmt.Errorf("%w: %w", eapaka.ErrUnknownSubscriber, p)
+		}
+		return nil, p
+	}
+
+	var res AuthenticationInfoResult
+	if err := json.NewDecoder(resp.Body).Decode(&res); err != nil {
+		return nil, fmt.Errorf("sbi: invalid AuthenticationInfoResult: %w", err)
+	}
+	av := res.AuthenticationVector
+	if res.AuthType != AuthTypeEAPAKAPrime || av == nil || av.AvType != AuthTypeEAPAKAPrime {
+		return nil, fmt.Errorf("sbi: UDM returned authentication type %q", res.AuthType)
+	}
+	v := &eapaka.AuthVector{Prime: true}
+	for _, f := range []struct {
+		name     string
+		s        string
+		dst      *[]byte
+		min, max int
+	}{
+		{"rand", av.RAND, &v.RAND, 16, 16},
+		{"autn", av.AUTN, &v.AUTN, 16, 16},
+		{"xres", av.XRES, &v.XRES, 4, 16},
+		{"ckPrime", av.CKPrime, &v.CK, 16, 16},
+		{"ikPrime", av.IKPrime, &v.IK, 16, 16},
+	} {
+		b, err := hex.DecodeString(f.s)
+		if err != nil || len(b) < f.min || len(b) > f.max {
+			return nil, fmt.Errorf("sbi: invalid %s in authentication vector", f.name)
+		}
+		*f.dst = b
+	}
+	return v, nil
+}
+
+// MockUDM is an http.Handler that answers Nudm_UEAuthentication_Get with
+// EAP-AKA' vectors from an [eapaka.VectorProvider], typically a
+// [milenage.Provider], re-synchronising SQN from resynchronizationInfo.
+// It accepts SUPIs and null-scheme SUCIs. It is meant for tests, e.g.
+// behind httptest.NewServer.
+type MockUDM struct {
+	// Vectors generates the vectors. Required.
+	Vectors eapaka.VectorProvider
+
+	once sync.Once
+	mux  *http.ServeMux
+}
+
+// NewMockUDM creates a [MockUDM] that generates vectors with MILENAGE for
+// the given subscribers.
+func NewMockUDM(subs ...*milenage.Subscriber) *MockUDM {
+	return &MockUDM{Vectors: milenage.NewProvider(subs...)}
+}
+
+// ServeHTTP implements http.Handler.
+func (u *MockUDM) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	u.once.Do(func() {
+		u.mux = http.NewServeMux()
+		u.mux.HandleFunc("POST "+udmPath, u.generateAuthData)
+	})
+	u.mux.ServeHTTP(w, r)
+}
+
+func (u *MockUDM) generateAuthData(w http.ResponseWriter, r *http.Request) {
+	imsi, err := ParseSUPI(r.PathValue("supiOrSuci"))
+	if err != nil {
+		writeProblem(w, http.StatusBadRequest, CauseMandatoryIEIncorrect, err.Error())
+		return
+	}
+	var body AuthenticationInfoRequest
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeProblem(w, http.StatusBadRequest, CauseMandatoryIEIncorrect, err.Error())
+		return
+	}
+	if body.ServingNetworkName == "" || body.AUSFInstanceID == "" {
+		writeProblem(w, http.StatusBadRequest, CauseMandatoryIEMissing, "servingNetworkName and ausfInstanceId are required")
+		return
+	}
+	req := &eapaka.VectorRequest{IMSI: imsi, Type: eapaka.TypeAKAPrime, NetworkName: body.ServingNetworkName}
+	if ri := body.ResynchronizationInfo; ri != nil {
+		req.RAND, err = hex.DecodeString(ri.RAND)
+		if err == nil {
+			req.AUTS, err = hex.DecodeString(ri.AUTS)
+		}
+		if err != nil || len(req.RAND) != 16 || len(req.AUTS) != 14 {
+			writeProblem(w, http.StatusBadRequest, CauseMandatoryIEIncorrect, "invalid resynchronizationInfo")
+			return
+		}
+	}
+
+	v, err := u.Vectors.GetVector(r.Context(), req)
+	if errors.Is(err, eapaka.ErrUnknownSubscriber) {
+		writeProblem(w, http.StatusNotFound, CauseUserNotFound, "")
+		return
+	}
+	if err != nil {
+		writeProblem(w, http.StatusForbidden, CauseAuthenticationRejected, err.Error())
+		return
+	}
+	ckPrime, ikPrime, err := v.CKIKPrime(body.ServingNetworkName)
+	if err != nil {
+		writeProblem(w, http.StatusInternalServerError, CauseSystemFailure, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, &AuthenticationInfoResult{
+		AuthType: AuthTypeEAPAKAPrime,
+		AuthenticationVector: &AuthenticationVector{
+			AvType:  AuthTypeEAPAKAPrime,
+			RAND:    hex.EncodeToString(v.RAND),
+			XRES:    hex.EncodeToString(v.XRES),
+			AUTN:    hex.EncodeToString(v.AUTN),
+			CKPrime: hex.EncodeToString(ckPrime),
+			IKPrime: hex.EncodeToString(ikPrime),
+		},
+		SUPI: "imsi-" + imsi,
+	})
+}
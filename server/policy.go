@@ -0,0 +1,249 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Failure is a kind of failed attempt reported to a [Policy].
+type Failure int
+
+// Failures recorded by the [Authenticator].
+const (
+	// FailureInvalidMAC is an EAP-Response whose AT_MAC did not verify.
+	FailureInvalidMAC Failure = iota + 1
+	// FailureResMismatch is an EAP-Response/AKA-Challenge with a wrong AT_RES.
+	FailureResMismatch
+	// FailureSync is an EAP-Response/AKA-Synchronization-Failure. Each one
+	// costs another authentication vector.
+	FailureSync
+)
+
+func (f Failure) String() string {
+	switch f {
+	case FailureInvalidMAC:
+		return "invalid MAC"
+	case FailureResMismatch:
+		return "RES mismatch"
+	case FailureSync:
+		return "synchronization failure"
+	}
+	return fmt.Sprintf("Failure(%d)", int(f))
+}
+
+// PolicyKey identifies the peer an attempt is attributed to.
+type PolicyKey struct {
+	// PermanentID is the permanent identity of the peer, or the identity it
+	// presented if the permanent identity is unknown.
+	PermanentID string
+
+	// Source is the NAS or network source of the request, as set with
+	// [WithSource]; empty if the transport did not set it.
+	Source string
+}
+
+// Policy protects the [Authenticator] against peers that repeatedly fail.
+// The Authenticator consults it before fetching a vector or starting a fast
+// re-authentication and, if the peer is blocked, ends the conversation with
+// an EAP-Request/AKA-Notification carrying General-Failure and then an
+// EAP-Failure with [ErrLockedOut]. Implementations must be safe for
+// concurrent use.
+type Policy interface {
+	// Check returns nil if key may authenticate now. Any other error blocks
+	// the attempt.
+	Check(ctx context.Context, key PolicyKey) error
+
+	// RecordFailure records a failed attempt of key.
+	RecordFailure(ctx context.Context, key PolicyKey, f Failure)
+
+	// RecordSuccess records a successful authentication of key.
+	RecordSuccess(ctx context.Context, key PolicyKey)
+}
+
+// Default values used by [LockoutConfig] when a field is left zero.
+const (
+	DefaultFailureWindow   = 10 * time.Minute
+	DefaultMaxFailures     = 5
+	DefaultMaxSyncFailures = 3
+	DefaultLockout         = 5 * time.Minute
+	DefaultMaxLockout      = 4 * time.Hour
+)
+
+// LockoutConfig configures a [LockoutPolicy].
+type LockoutConfig struct {
+	// Window is the length of the sliding window in which failures are
+	// counted. Default: DefaultFailureWindow.
+	Window time.Duration
+
+	// MaxFailures is the number of MAC and RES failures within Window that
+	// locks a key out. Default: DefaultMaxFailures.
+	MaxFailures int
+
+	// MaxSyncFailures is the number of synchronization failures within
+	// Window that locks a key out. Default: DefaultMaxSyncFailures.
+	MaxSyncFailures int
+
+	// Lockout is the duration of the first lockout. Each further lockout
+	// of the same key doubles it, up to MaxLockout. Default: DefaultLockout.
+	Lockout time.Duration
+
+	// MaxLockout caps the lockout duration. The back-off is forgotten once a
+	// key has gone MaxLockout without being locked out, and on success.
+	// Default: DefaultMaxLockout.
+	MaxLockout time.Duration
+
+	// OnLockout, if set, is called when a key is locked out.
+	OnLockout func(key PolicyKey, until time.Time)
+
+	// Now returns the current time. Default: time.Now.
+	Now func() time.Time
+}
+
+func (c LockoutConfig) withDefaults() LockoutConfig {
+	if c.Window <= 0 {
+		c.Window = DefaultFailureWindow
+	}
+	if c.MaxFailures <= 0 {
+		c.MaxFailures = DefaultMaxFailures
+	}
+	if c.MaxSyncFailures <= 0 {
+		c.MaxSyncFailures = DefaultMaxSyncFailures
+	}
+	if c.Lockout <= 0 {
+		c.Lockout = DefaultLockout
+	}
+	if c.MaxLockout < c.Lockout {
+		c.MaxLockout = max(DefaultMaxLockout, c.Lockout)
+	}
+	if c.Now == nil {
+		c.Now = time.Now
+	}
+	return c
+}
+
+// LockoutPolicy is an in-memory [Policy]. It counts failures per key in a
+// sliding window and locks the key out, with exponential back-off, when
+// there are too many.
+type LockoutPolicy struct {
+	cfg LockoutConfig
+
+	mu      sync.Mutex
+	entries map[PolicyKey]*lockoutEntry
+}
+
+type lockoutEntry struct {
+	failures []time.Time // MAC and RES failures within the window
+	syncs    []time.Time // synchronization failures within the window
+	lockouts int         // consecutive lockouts, for the back-off
+	until    time.Time
+}
+
+// NewLockoutPolicy creates a [LockoutPolicy].
+func NewLockoutPolicy(cfg LockoutConfig) *LockoutPolicy {
+	return &LockoutPolicy{cfg: cfg.withDefaults(), entries: make(map[PolicyKey]*lockoutEntry)}
+}
+
+// Check implements [Policy]. The error wraps [ErrLockedOut].
+func (p *LockoutPolicy) Check(_ context.Context, key PolicyKey) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	e, ok := p.entries[key]
+	if !ok || !p.cfg.Now().Before(e.until) {
+		return nil
+	}
+	return fmt.Errorf("%w until %s", ErrLockedOut, e.until.Format(time.RFC3339))
+}
+
+// RecordFailure implements [Policy].
+func (p *LockoutPolicy) RecordFailure(_ context.Context, key PolicyKey, f Failure) {
+	now := p.cfg.Now()
+	p.mu.Lock()
+	e, ok := p.entries[key]
+	if !ok {
+		e = &lockoutEntry{}
+		p.entries[key] = e
+	}
+	if !e.until.IsZero() && now.Sub(e.until) >= p.cfg.MaxLockout {
+		e.lockouts = 0
+	}
+	since := now.Add(-p.cfg.Window)
+	e.failures = trimBefore(e.failures, since)
+	e.syncs = trimBefore(e.syncs, since)
+	if f == FailureSync {
+		e.syncs = append(e.syncs, now)
+	} else {
+		e.failures = append(e.failures, now)
+	}
+	if len(e.failures) < p.cfg.MaxFailures && len(e.syncs) < p.cfg.MaxSyncFailures {
+		p.mu.Unlock()
+		return
+	}
+
+	d := p.cfg.Lockout
+	for i := 0; i < e.lockouts && d < p.cfg.MaxLockout; i++ {
+		d *= 2
+	}
+	e.lockouts++
+	e.until = now.Add(min(d, p.cfg.MaxLockout))
+	e.failures, e.syncs = e.failures[:0], e.syncs[:0]
+	until := e.until
+	p.mu.Unlock()
+
+	if p.cfg.OnLockout != nil {
+		p.cfg.OnLockout(key, until)
+	}
+}
+
+// RecordSuccess implements [Policy]. It forgets the failures and the
+// back-off of key.
+func (p *LockoutPolicy) RecordSuccess(_ context.Context, key PolicyKey) {
+	p.mu.Lock()
+	delete(p.entries, key)
+	p.mu.Unlock()
+}
+
+// Purge removes keys that are neither locked out, nor have failures within
+// the window, nor a back-off to remember, and returns how many were removed.
+// It can be called periodically to bound memory use.
+func (p *LockoutPolicy) Purge() int {
+	now := p.cfg.Now()
+	since := now.Add(-p.cfg.Window)
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	n := 0
+	for key, e := range p.entries {
+		e.failures = trimBefore(e.failures, since)
+		e.syncs = trimBefore(e.syncs, since)
+		if len(e.failures) == 0 && len(e.syncs) == 0 && now.Sub(e.until) >= p.cfg.MaxLockout {
+			delete(p.entries, key)
+			n++
+		}
+	}
+	return n
+}
+
+// trimBefore drops the times before t from the sorted slice ts.
+func trimBefore(ts []time.Time, t time.Time) []time.Time {
+	i := 0
+	for i < len(ts) && ts[i].Before(t) {
+		i++
+	}
+	return ts[i:]
+}
+
+type sourceKey struct{}
+
+// WithSource returns a context carrying the NAS or network source of the
+// request, such as the address of the RADIUS client. The [Authenticator]
+// passes it to its [Policy] in [PolicyKey].Source.
+func WithSource(ctx context.Context, source string) context.Context {
+	return context.WithValue(ctx, sourceKey{}, source)
+}
+
+// SourceFromContext returns the source set with [WithSource], if any.
+func SourceFromContext(ctx context.Context) string {
+	s, _ := ctx.Value(sourceKey{}).(string)
+	return s
+}
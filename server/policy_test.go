@@ -0,0 +1,145 @@
+package server_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/oyaguma3/go-eapaka"
+	"github.com/oyaguma3/go-eapaka/eapakatest"
+	"github.com/oyaguma3/go-eapaka/milenage"
+	"github.com/oyaguma3/go-eapaka/peer"
+	"github.com/oyaguma3/go-eapaka/server"
+)
+
+func TestLockoutPolicy(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	var lockouts []time.Duration
+	p := server.NewLockoutPolicy(server.LockoutConfig{
+		Window:      time.Minute,
+		MaxFailures: 3,
+		Lockout:     time.Minute,
+		MaxLockout:  3 * time.Minute,
+		OnLockout:   func(_ server.PolicyKey, until time.Time) { lockouts = append(lockouts, until.Sub(now)) },
+		Now:         func() time.Time { return now },
+	})
+	ctx := context.Background()
+	key := server.PolicyKey{PermanentID: "0001010000000001@example.org", Source: "192.0.2.1"}
+	other := server.PolicyKey{PermanentID: key.PermanentID, Source: "192.0.2.2"}
+
+	fail := func(n int, f server.Failure, step time.Duration) {
+		for range n {
+			p.RecordFailure(ctx, key, f)
+			now = now.Add(step)
+		}
+	}
+
+	// Failures that fall out of the window do not add up.
+	fail(6, server.FailureInvalidMAC, 31*time.Second)
+	if err := p.Check(ctx, key); err != nil || len(lockouts) != 0 {
+		t.Fatalf("locked out by spread failures: %v", err)
+	}
+
+	// Back-off doubles up to MaxLockout.
+	for i, want := range []time.Duration{time.Minute, 2 * time.Minute, 3 * time.Minute, 3 * time.Minute} {
+		fail(3, server.FailureResMismatch, 0)
+		if err := p.Check(ctx, key); !errors.Is(err, server.ErrLockedOut) {
+			t.Fatalf("lockout %d: Check = %v", i, err)
+		}
+		if err := p.Check(ctx, other); err != nil {
+			t.Fatalf("lockout %d: other source blocked: %v", i, err)
+		}
+		if got := lockouts[len(lockouts)-1]; got != want {
+			t.Errorf("lockout %d lasts %v, want %v", i, got, want)
+		}
+		now = now.Add(want)
+		if err := p.Check(ctx, key); err != nil {
+			t.Fatalf("lockout %d not lifted: %v", i, err)
+		}
+	}
+
+	// Success forgets the back-off.
+	p.RecordSuccess(ctx, key)
+	fail(3, server.FailureInvalidMAC, 0)
+	if got := lockouts[len(lockouts)-1]; got != time.Minute {
+		t.Errorf("lockout after success lasts %v", got)
+	}
+
+	// Synchronization failures have their own limit.
+	fail(2, server.FailureSync, 0)
+	if err := p.Check(ctx, other); err != nil {
+		t.Fatal(err)
+	}
+	p.RecordFailure(ctx, other, server.FailureSync)
+	if len(lockouts) != 5 {
+		t.Errorf("%d lockouts, want 5", len(lockouts))
+	}
+
+	now = now.Add(time.Hour)
+	if n := p.Purge(); n != 2 {
+		t.Errorf("Purge = %d, want 2", n)
+	}
+}
+
+func TestAuthenticator_Policy(t *testing.T) {
+	s := &eapakatest.Subscribers[0]
+	a, err := server.New(server.Config{
+		Vectors: milenage.NewProvider(s.Milenage()),
+		Policy:  server.NewLockoutPolicy(server.LockoutConfig{MaxFailures: 2}),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := server.WithSource(context.Background(), "nas1")
+	identity := s.Identity(eapaka.TypeAKAPrime)
+	identityResponse := append([]byte{eapaka.CodeResponse, 0, 0, byte(5 + len(identity)), 1}, identity...)
+
+	// Two challenge responses with a bad MAC lock the peer out.
+	for range 2 {
+		res, err := a.Handle(ctx, "", identityResponse)
+		if err != nil || res.Reply.Subtype != eapaka.SubtypeChallenge {
+			t.Fatalf("Handle(identity) = %v, %v", res.Reply, err)
+		}
+		resp, err := eapaka.NewChallengeResponse(eapaka.TypeAKAPrime, res.Reply.Identifier, make([]byte, 8)).Sign(make([]byte, 32))
+		if err != nil {
+			t.Fatal(err)
+		}
+		msg, _ := resp.Marshal()
+		if _, err := a.Handle(ctx, res.SessionID, msg); !errors.Is(err, server.ErrInvalidMAC) {
+			t.Fatalf("Handle(bad MAC) = %v", err)
+		}
+	}
+
+	// The peer is now told General-Failure, even with the right key.
+	usim, err := s.USIM()
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := peer.New(peer.Config{Identity: identity, SIM: usim})
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := a.Handle(ctx, "", identityResponse)
+	if err != nil || res.Reply.Subtype != eapaka.SubtypeNotification {
+		t.Fatalf("Handle(identity) when locked out = %v, %v", res.Reply, err)
+	}
+	if n, ok := eapaka.Find[*eapaka.AtNotification](res.Reply); !ok || n.Value() != eapaka.NotificationGeneralFailure {
+		t.Fatalf("notification = %v", res.Reply)
+	}
+	req, _ := res.Reply.Marshal()
+	msg, err := p.Handle(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err = a.Handle(ctx, res.SessionID, msg)
+	if !errors.Is(err, server.ErrLockedOut) || res.Status != server.StatusFailure {
+		t.Fatalf("Handle(notification) = %v, %v", res.Status, err)
+	}
+
+	// Other NASes are not affected.
+	res, err = a.Handle(server.WithSource(context.Background(), "nas2"), "", identityResponse)
+	if err != nil || res.Reply.Subtype != eapaka.SubtypeChallenge {
+		t.Fatalf("Handle(identity) from another NAS = %v, %v", res.Reply, err)
+	}
+}
@@ -0,0 +1,737 @@
+// Package server implements the EAP server (authenticator back end) side of
+// EAP-AKA (RFC 4187) and EAP-AKA' (RFC 5448) on top of package eapaka.
+//
+// An [Authenticator] is transport agnostic: it consumes EAP-Response packets and
+// produces the next EAP-Request, EAP-Success or EAP-Failure. Transports such as
+// RADIUS carry the returned session ID between round trips (e.g., in State).
+package server
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/oyaguma3/go-eapaka"
+)
+
+// Errors returned by [Authenticator.Handle] alongside a failure [Result].
+var (
+	ErrUnexpectedPacket  = errors.New("server: unexpected EAP packet")
+	ErrInvalidMAC        = errors.New("server: AT_MAC verification failed")
+	ErrResMismatch       = errors.New("server: RES does not match XRES")
+	ErrNoUsableIdentity  = errors.New("server: peer did not provide a usable identity")
+	ErrPeerRejected      = errors.New("server: peer rejected the authentication")
+	ErrCounterMismatch   = errors.New("server: AT_COUNTER mismatch")
+	ErrMissingAttribute  = errors.New("server: mandatory attribute missing")
+	ErrIdentifierInvalid = errors.New("server: EAP Identifier does not match the outstanding request")
+	ErrLockedOut         = errors.New("server: peer is locked out after repeated failures")
+	ErrNotAuthorized     = errors.New("server: peer is authenticated but not authorized")
+	ErrRepeatedSync      = errors.New("server: repeated synchronization failure")
+)
+
+// Errors that [Config].Authorize can wrap to choose the notification sent to
+// the peer when result indications are in use.
+var (
+	ErrTemporarilyDenied = errors.New("server: temporarily denied access")
+	ErrNotSubscribed     = errors.New("server: user has not subscribed to the requested service")
+)
+
+// Status is the state of a conversation after [Authenticator.Handle].
+type Status int
+
+const (
+	// StatusContinue means Reply is an EAP-Request and another round trip follows.
+	StatusContinue Status = iota
+	// StatusSuccess means Reply is an EAP-Success and keys are available.
+	StatusSuccess
+	// StatusFailure means Reply is an EAP-Failure.
+	StatusFailure
+	// StatusDiscard means the response was silently discarded (RFC 3748
+	// Section 4.1): Reply is nil, nothing is sent, and the conversation
+	// continues under the same SessionID.
+	StatusDiscard
+)
+
+// Config configures an [Authenticator].
+type Config struct {
+	// Type is the method used: eapaka.TypeAKA or eapaka.TypeAKAPrime.
+	// Default: eapaka.TypeAKAPrime.
+	Type uint8
+
+	// FallbackTypes are the methods the server switches to, in order of
+	// preference, when the peer answers with a Nak. When it falls back from
+	// EAP-AKA' to EAP-AKA, the Challenge carries AT_BIDDING so that a peer
+	// that supports EAP-AKA' detects a bidding-down attack (RFC 5448
+	// Section 4).
+	FallbackTypes []uint8
+
+	// NetworkName is the access network name sent in AT_KDF_INPUT for EAP-AKA'.
+	// Default: "WLAN".
+	NetworkName string
+
+	// Vectors supplies authentication vectors. Required.
+	Vectors eapaka.VectorProvider
+
+	// Store keeps state between round trips.
+	// Default: an [eapaka.MemoryStore] with default settings.
+	Store eapaka.SessionStore
+
+	// EnableReauth makes the server offer AT_NEXT_REAUTH_ID and accept
+	// fast re-authentication identities.
+	EnableReauth bool
+
+	// Realm is appended to generated re-authentication identities.
+	Realm string
+
+	// ParseOptions controls how strictly EAP-Responses are parsed.
+	// The zero value is lenient; see [eapaka.StrictParseOptions].
+	ParseOptions eapaka.ParseOptions
+
+	// Policy, if set, limits how often a peer may fail; see [LockoutPolicy].
+	Policy Policy
+
+	// ResultIndication makes the server include AT_RESULT_IND in
+	// EAP-Request/AKA-Challenge and AKA-Reauthentication. If the peer includes
+	// it too, the outcome is confirmed in a protected EAP-Request/
+	// AKA-Notification round before EAP-Success or EAP-Failure is sent
+	// (RFC 4187 Section 6.2).
+	ResultIndication bool
+
+	// Authorize, if set, is called once the peer is authenticated, before it
+	// is told of the success. A non-nil error fails the conversation with
+	// [ErrNotAuthorized]. If result indications are in use, the peer is told
+	// first with Temporarily-Denied if the error wraps [ErrTemporarilyDenied],
+	// Not-Subscribed if it wraps [ErrNotSubscribed], and General Failure after
+	// authentication otherwise.
+	Authorize func(ctx context.Context, permanentID string) error
+
+	// Random is the source of NONCE_S, AT_IV and the generated session and
+	// re-authentication identities. It must be safe for concurrent use.
+	// Default: [eapaka.Random].
+	Random io.Reader
+}
+
+// Authenticator runs EAP-AKA/AKA' conversations. It is safe for concurrent use.
+type Authenticator struct {
+	cfg Config
+}
+
+// New creates an [Authenticator].
+func New(cfg Config) (*Authenticator, error) {
+	if cfg.Vectors == nil {
+		return nil, errors.New("server: Config.Vectors is required")
+	}
+	if cfg.Type == 0 {
+		cfg.Type = eapaka.TypeAKAPrime
+	}
+	for _, t := range append([]uint8{cfg.Type}, cfg.FallbackTypes...) {
+		if t != eapaka.TypeAKA && t != eapaka.TypeAKAPrime {
+			return nil, fmt.Errorf("server: unsupported EAP type %d", t)
+		}
+	}
+	if cfg.NetworkName == "" {
+		cfg.NetworkName = "WLAN"
+	}
+	if cfg.Store == nil {
+		cfg.Store = eapaka.NewMemoryStore(eapaka.StoreConfig{})
+	}
+	return &Authenticator{cfg: cfg}, nil
+}
+
+// Result is the outcome of one [Authenticator.Handle] call.
+type Result struct {
+	Status Status
+
+	// Reply is the EAP packet to send to the peer.
+	Reply *eapaka.Packet
+
+	// SessionID identifies the conversation. It must be passed to the next
+	// Handle call for this peer. Empty once the conversation has finished.
+	SessionID string
+
+	// Identity is the identity used in key derivation; PermanentID is the
+	// permanent identity of the peer, if known.
+	Identity    string
+	PermanentID string
+
+	// MSK and EMSK are set when Status is StatusSuccess.
+	MSK  eapaka.Key
+	EMSK eapaka.Key
+
+	// Reauth reports that the conversation was a fast re-authentication.
+	Reauth bool
+}
+
+// Handle processes an EAP-Response received from the peer.
+// sessionID is empty for the first message of a conversation (EAP-Response/Identity).
+//
+// On failure, Handle returns a Result with StatusFailure and an EAP-Failure reply
+// together with an error describing the cause. A response whose Identifier does
+// not match the outstanding request is discarded: the Result has StatusDiscard
+// and the error is [ErrIdentifierInvalid].
+func (a *Authenticator) Handle(ctx context.Context, sessionID string, msg []byte) (_ *Result, err error) {
+	ctx, end := eapaka.StartSpan(ctx, "server.Handle")
+	defer func() { end(err) }()
+	return a.handle(ctx, sessionID, msg)
+}
+
+func (a *Authenticator) handle(ctx context.Context, sessionID string, msg []byte) (*Result, error) {
+	pkt, err := eapaka.ParseWithOptions(msg, a.cfg.ParseOptions)
+	if err != nil {
+		return a.fail(ctx, sessionID, identifierOf(msg), err)
+	}
+	if pkt.Code != eapaka.CodeResponse {
+		return a.fail(ctx, sessionID, pkt.Identifier, ErrUnexpectedPacket)
+	}
+
+	if identity, ok := pkt.EAPIdentity(); ok {
+		// A new conversation. Any previous state for this session is dropped.
+		if sessionID != "" {
+			a.cfg.Store.DeleteChallenge(ctx, sessionID)
+		}
+		cc := &eapaka.ChallengeContext{Type: a.cfg.Type, Identifier: pkt.Identifier}
+		if cc.ID, err = a.newSessionID(); err != nil {
+			return a.fail(ctx, "", pkt.Identifier, err)
+		}
+		return a.startWithIdentity(ctx, cc, identity)
+	}
+
+	if sessionID == "" {
+		return a.fail(ctx, "", pkt.Identifier, ErrUnexpectedPacket)
+	}
+	cc, err := a.cfg.Store.GetChallenge(ctx, sessionID)
+	if err != nil {
+		return a.fail(ctx, sessionID, pkt.Identifier, err)
+	}
+	if pkt.Identifier != cc.Identifier {
+		// A stale or spoofed response must not end the conversation.
+		return &Result{Status: StatusDiscard, SessionID: sessionID}, ErrIdentifierInvalid
+	}
+	if desired, ok := pkt.DesiredTypes(); ok {
+		return a.handleNak(ctx, cc, desired)
+	}
+	if pkt.Type != cc.Type {
+		return a.fail(ctx, sessionID, pkt.Identifier, ErrPeerRejected)
+	}
+	if err := pkt.Validate(); err != nil {
+		return a.fail(ctx, sessionID, pkt.Identifier, err)
+	}
+
+	switch pkt.Subtype {
+	case eapaka.SubtypeIdentity:
+		if cc.Subtype != eapaka.SubtypeIdentity {
+			return a.fail(ctx, sessionID, pkt.Identifier, ErrUnexpectedPacket)
+		}
+		id, ok := eapaka.Find[*eapaka.AtIdentity](pkt)
+		if !ok {
+			return a.fail(ctx, sessionID, pkt.Identifier, ErrMissingAttribute)
+		}
+		return a.startWithIdentity(ctx, cc, id.Identity)
+	case eapaka.SubtypeChallenge:
+		if cc.Subtype != eapaka.SubtypeChallenge {
+			return a.fail(ctx, sessionID, pkt.Identifier, ErrUnexpectedPacket)
+		}
+		return a.handleChallenge(ctx, cc, pkt)
+	case eapaka.SubtypeSynchronizationFailure:
+		if cc.Subtype != eapaka.SubtypeChallenge {
+			return a.fail(ctx, sessionID, pkt.Identifier, ErrUnexpectedPacket)
+		}
+		auts, ok := eapaka.Find[*eapaka.AtAuts](pkt)
+		if !ok {
+			return a.fail(ctx, sessionID, pkt.Identifier, ErrMissingAttribute)
+		}
+		eapaka.ReportEvent("server", eapaka.EventSyncFailure)
+		a.recordFailure(ctx, cc, FailureSync)
+		// Each re-synchronisation fetches a vector; one is allowed, as
+		// the USIM accepts the vector that follows it.
+		if cc.Resynchronized {
+			return a.fail(ctx, sessionID, pkt.Identifier, ErrRepeatedSync)
+		}
+		cc.Resynchronized = true
+		return a.sendChallenge(ctx, cc, cc.RAND, auts.Auts)
+	case eapaka.SubtypeReauthentication:
+		if cc.Subtype != eapaka.SubtypeReauthentication {
+			return a.fail(ctx, sessionID, pkt.Identifier, ErrUnexpectedPacket)
+		}
+		return a.handleReauth(ctx, cc, pkt)
+	case eapaka.SubtypeNotification:
+		if cc.Subtype != eapaka.SubtypeNotification {
+			return a.fail(ctx, sessionID, pkt.Identifier, ErrUnexpectedPacket)
+		}
+		return a.handleNotification(ctx, cc, pkt)
+	case eapaka.SubtypeAuthenticationReject, eapaka.SubtypeClientError:
+		return a.fail(ctx, sessionID, pkt.Identifier, ErrPeerRejected)
+	default:
+		return a.fail(ctx, sessionID, pkt.Identifier, ErrUnexpectedPacket)
+	}
+}
+
+// handleNak switches to a fallback method the peer desires and starts over
+// with the identity it has presented. Only one switch is allowed.
+func (a *Authenticator) handleNak(ctx context.Context, cc *eapaka.ChallengeContext, desired []uint8) (*Result, error) {
+	if cc.Subtype != eapaka.SubtypeIdentity && cc.Subtype != eapaka.SubtypeChallenge {
+		return a.fail(ctx, cc.ID, cc.Identifier, ErrUnexpectedPacket)
+	}
+	t, ok := eapaka.NegotiateType(a.cfg.FallbackTypes, desired)
+	if !ok || t == cc.Type || cc.Type != a.cfg.Type {
+		return a.fail(ctx, cc.ID, cc.Identifier, ErrPeerRejected)
+	}
+	cc.Type, cc.Subtype = t, 0
+	cc.RAND, cc.AUTN, cc.XRES, cc.AKA, cc.AKAPrime, cc.NextReauthID = nil, nil, nil, nil, nil, ""
+	return a.startWithIdentity(ctx, cc, cc.Identity)
+}
+
+// startWithIdentity decides between fast re-authentication, full authentication
+// and another identity round for the identity the peer presented.
+func (a *Authenticator) startWithIdentity(ctx context.Context, cc *eapaka.ChallengeContext, identity string) (*Result, error) {
+	cc.Identity = identity
+
+	if a.cfg.EnableReauth && identity != "" {
+		// Look before taking: the context is consumed only once
+		// re-authentication starts, so an identity offered on the other
+		// method or by a blocked peer leaves it in place.
+		if rc, err := a.cfg.Store.GetReauth(ctx, identity); err == nil && rc.Type == cc.Type {
+			cc.PermanentID = rc.PermanentID
+			if err := a.check(ctx, cc); err != nil {
+				return a.notifyBlocked(ctx, cc)
+			}
+			if rc, err := a.cfg.Store.TakeReauth(ctx, identity); err == nil {
+				return a.sendReauth(ctx, cc, rc)
+			}
+			cc.PermanentID = ""
+		}
+	}
+
+	if _, ok := eapaka.IMSIFromIdentity(identity); ok {
+		cc.PermanentID = identity
+		return a.sendChallenge(ctx, cc, nil, nil)
+	}
+
+	// Only one identity round is attempted.
+	if cc.Subtype == eapaka.SubtypeIdentity {
+		return a.fail(ctx, cc.ID, cc.Identifier, ErrNoUsableIdentity)
+	}
+	req, err := eapaka.NewIdentityRequest(cc.Type, cc.Identifier+1, eapaka.AT_PERMANENT_ID_REQ).Build()
+	if err != nil {
+		return a.fail(ctx, cc.ID, cc.Identifier, err)
+	}
+	return a.continueWith(ctx, cc, req)
+}
+
+// sendChallenge fetches a vector and sends EAP-Request/AKA-Challenge.
+// resyncRAND and auts are set when re-synchronising after a Synchronization-Failure.
+func (a *Authenticator) sendChallenge(ctx context.Context, cc *eapaka.ChallengeContext, resyncRAND, auts []byte) (*Result, error) {
+	if err := a.check(ctx, cc); err != nil {
+		return a.notifyBlocked(ctx, cc)
+	}
+	imsi, _ := eapaka.IMSIFromIdentity(cc.PermanentID)
+	vctx, end := eapaka.StartSpan(ctx, "server.GetVector")
+	vec, err := a.cfg.Vectors.GetVector(vctx, &eapaka.VectorRequest{
+		IMSI:        imsi,
+		Type:        cc.Type,
+		NetworkName: a.cfg.NetworkName,
+		RAND:        resyncRAND,
+		AUTS:        auts,
+	})
+	end(err)
+	if err != nil {
+		return a.fail(ctx, cc.ID, cc.Identifier, err)
+	}
+
+	cc.Subtype = eapaka.SubtypeChallenge
+	cc.RAND, cc.AUTN, cc.XRES = vec.RAND, vec.AUTN, vec.XRES
+	cc.AKA, cc.AKAPrime = nil, nil
+
+	var (
+		b           *eapaka.Builder
+		kEncr, kAut []byte
+	)
+	if cc.Type == eapaka.TypeAKAPrime {
+		ckPrime, ikPrime, err := vec.CKIKPrime(a.cfg.NetworkName)
+		if err != nil {
+			return a.fail(ctx, cc.ID, cc.Identifier, err)
+		}
+		keys := eapaka.DeriveKeysAKAPrime(cc.Identity, ckPrime, ikPrime)
+		cc.AKAPrime = &keys
+		kEncr, kAut = keys.K_encr, keys.K_aut
+		b = eapaka.NewChallengeRequestAKAPrime(cc.Identifier+1, vec.RAND, vec.AUTN, a.cfg.NetworkName)
+	} else {
+		keys := eapaka.DeriveKeysAKA(cc.Identity, vec.CK, vec.IK)
+		cc.AKA = &keys
+		kEncr, kAut = keys.K_encr, keys.K_aut
+		b = eapaka.NewChallengeRequest(cc.Type, cc.Identifier+1, vec.RAND, vec.AUTN)
+	}
+
+	if a.cfg.ResultIndication {
+		b.ResultInd()
+	}
+	if cc.Type == eapaka.TypeAKA && a.cfg.Type == eapaka.TypeAKAPrime {
+		b.Add(&eapaka.AtBidding{D: true})
+	}
+	if a.cfg.EnableReauth {
+		if cc.NextReauthID, err = a.newReauthID(cc.Type); err != nil {
+			return a.fail(ctx, cc.ID, cc.Identifier, err)
+		}
+		iv, err := a.random(16)
+		if err != nil {
+			return a.fail(ctx, cc.ID, cc.Identifier, err)
+		}
+		b.Encrypt(kEncr, iv, &eapaka.AtNextReauthId{Identity: cc.NextReauthID})
+	}
+	req, err := b.Sign(kAut)
+	if err != nil {
+		return a.fail(ctx, cc.ID, cc.Identifier, err)
+	}
+	return a.continueWith(ctx, cc, req)
+}
+
+func (a *Authenticator) handleChallenge(ctx context.Context, cc *eapaka.ChallengeContext, pkt *eapaka.Packet) (*Result, error) {
+	kAut := kAutOf(cc)
+	if ok, err := pkt.VerifyMac(kAut); err != nil || !ok {
+		a.recordFailure(ctx, cc, FailureInvalidMAC)
+		return a.fail(ctx, cc.ID, pkt.Identifier, ErrInvalidMAC)
+	}
+	if _, ok := eapaka.Find[*eapaka.AtKdf](pkt); ok {
+		// The peer proposes a different KDF; only KDF 1 is supported.
+		return a.fail(ctx, cc.ID, pkt.Identifier, ErrPeerRejected)
+	}
+	res, ok := eapaka.Find[*eapaka.AtRes](pkt)
+	if !ok {
+		return a.fail(ctx, cc.ID, pkt.Identifier, ErrMissingAttribute)
+	}
+	if subtle.ConstantTimeCompare(res.Res, cc.XRES) != 1 {
+		a.recordFailure(ctx, cc, FailureResMismatch)
+		return a.fail(ctx, cc.ID, pkt.Identifier, ErrResMismatch)
+	}
+
+	cc.ResultInd = a.cfg.ResultIndication && pkt.HasResultInd()
+	if err := a.authorize(ctx, cc); err != nil {
+		return a.failAuthenticated(ctx, cc, err)
+	}
+	if cc.NextReauthID != "" {
+		var rc *eapaka.ReauthContext
+		if cc.AKAPrime != nil {
+			rc = eapaka.NewReauthContextAKAPrime(cc.NextReauthID, cc.PermanentID, *cc.AKAPrime)
+		} else {
+			rc = eapaka.NewReauthContextAKA(cc.NextReauthID, cc.PermanentID, *cc.AKA)
+		}
+		if err := a.cfg.Store.PutReauth(ctx, rc); err != nil {
+			return a.fail(ctx, cc.ID, pkt.Identifier, err)
+		}
+	}
+	return a.authenticated(ctx, cc, false)
+}
+
+// sendReauth sends EAP-Request/AKA-Reauthentication for a known re-authentication identity.
+func (a *Authenticator) sendReauth(ctx context.Context, cc *eapaka.ChallengeContext, rc *eapaka.ReauthContext) (*Result, error) {
+	nonceS, err := a.random(16)
+	if err != nil {
+		return a.fail(ctx, cc.ID, cc.Identifier, err)
+	}
+	rk := rc.DeriveKeys(cc.Identity, rc.Counter, nonceS)
+
+	cc.Subtype = eapaka.SubtypeReauthentication
+	cc.PermanentID = rc.PermanentID
+	cc.Counter = rc.Counter
+	cc.NonceS = nonceS
+	if rc.Type == eapaka.TypeAKAPrime {
+		cc.AKAPrime = &eapaka.AkaPrimeKeys{K_encr: rc.K_encr, K_aut: rc.K_aut, K_re: rc.K_re, MSK: rk.MSK, EMSK: rk.EMSK}
+	} else {
+		cc.AKA = &eapaka.AkaKeys{MK: rc.MK, K_encr: rc.K_encr, K_aut: rc.K_aut, MSK: rk.MSK, EMSK: rk.EMSK}
+	}
+
+	iv, err := a.random(16)
+	if err != nil {
+		return a.fail(ctx, cc.ID, cc.Identifier, err)
+	}
+	if cc.NextReauthID, err = a.newReauthID(cc.Type); err != nil {
+		return a.fail(ctx, cc.ID, cc.Identifier, err)
+	}
+	b := eapaka.NewReauthRequest(cc.Type, cc.Identifier+1, rc.Counter, nonceS).
+		Encrypt(rc.K_encr, iv, &eapaka.AtNextReauthId{Identity: cc.NextReauthID})
+	if a.cfg.ResultIndication {
+		b.ResultInd()
+	}
+	req, err := b.Sign(rc.K_aut)
+	if err != nil {
+		return a.fail(ctx, cc.ID, cc.Identifier, err)
+	}
+	return a.continueWith(ctx, cc, req)
+}
+
+func (a *Authenticator) handleReauth(ctx context.Context, cc *eapaka.ChallengeContext, pkt *eapaka.Packet) (*Result, error) {
+	if ok, err := pkt.VerifyMacWithExtra(kAutOf(cc), cc.NonceS); err != nil || !ok {
+		a.recordFailure(ctx, cc, FailureInvalidMAC)
+		return a.fail(ctx, cc.ID, pkt.Identifier, ErrInvalidMAC)
+	}
+	iv, okIV := eapaka.Find[*eapaka.AtIv](pkt)
+	encr, okEncr := eapaka.Find[*eapaka.AtEncrData](pkt)
+	if !okIV || !okEncr {
+		return a.fail(ctx, cc.ID, pkt.Identifier, ErrMissingAttribute)
+	}
+	inner, err := eapaka.DecryptAttributes(kEncrOf(cc), iv.IV, encr)
+	if err != nil {
+		return a.fail(ctx, cc.ID, pkt.Identifier, err)
+	}
+	var counter *eapaka.AtCounter
+	tooSmall := false
+	for _, attr := range inner {
+		switch v := attr.(type) {
+		case *eapaka.AtCounter:
+			counter = v
+		case *eapaka.AtCounterTooSmall:
+			tooSmall = true
+		}
+	}
+	if counter == nil {
+		return a.fail(ctx, cc.ID, pkt.Identifier, ErrMissingAttribute)
+	}
+	if tooSmall {
+		// RFC 4187 Section 5.5: fall back to full authentication.
+		cc.Identifier = pkt.Identifier
+		cc.Counter, cc.NonceS, cc.NextReauthID = 0, nil, ""
+		return a.sendChallenge(ctx, cc, nil, nil)
+	}
+	if counter.Counter != cc.Counter {
+		return a.fail(ctx, cc.ID, pkt.Identifier, ErrCounterMismatch)
+	}
+	cc.ResultInd = a.cfg.ResultIndication && pkt.HasResultInd()
+	if err := a.authorize(ctx, cc); err != nil {
+		return a.failAuthenticated(ctx, cc, err)
+	}
+
+	rc := &eapaka.ReauthContext{
+		ReauthID:    cc.NextReauthID,
+		PermanentID: cc.PermanentID,
+		Type:        cc.Type,
+		Counter:     cc.Counter,
+	}
+	if cc.AKAPrime != nil {
+		rc.K_re, rc.K_encr, rc.K_aut = cc.AKAPrime.K_re, cc.AKAPrime.K_encr, cc.AKAPrime.K_aut
+	} else {
+		rc.MK, rc.K_encr, rc.K_aut = cc.AKA.MK, cc.AKA.K_encr, cc.AKA.K_aut
+	}
+	if err := a.cfg.Store.PutReauth(ctx, rc); err != nil {
+		return a.fail(ctx, cc.ID, pkt.Identifier, err)
+	}
+	return a.authenticated(ctx, cc, true)
+}
+
+func (a *Authenticator) authorize(ctx context.Context, cc *eapaka.ChallengeContext) error {
+	if a.cfg.Authorize == nil {
+		return nil
+	}
+	if err := a.cfg.Authorize(ctx, cc.PermanentID); err != nil {
+		return fmt.Errorf("%w: %w", ErrNotAuthorized, err)
+	}
+	return nil
+}
+
+// authenticated finishes a successful exchange, with a Success notification
+// round first if result indications are in use.
+func (a *Authenticator) authenticated(ctx context.Context, cc *eapaka.ChallengeContext, reauth bool) (*Result, error) {
+	if cc.ResultInd {
+		return a.sendResult(ctx, cc, eapaka.NotificationSuccess)
+	}
+	return a.succeed(ctx, cc, cc.Identifier, reauth)
+}
+
+// failAuthenticated fails an authenticated peer that Authorize rejected,
+// with a notification round first if result indications are in use.
+func (a *Authenticator) failAuthenticated(ctx context.Context, cc *eapaka.ChallengeContext, err error) (*Result, error) {
+	if !cc.ResultInd {
+		return a.fail(ctx, cc.ID, cc.Identifier, err)
+	}
+	switch {
+	case errors.Is(err, ErrTemporarilyDenied):
+		return a.sendResult(ctx, cc, eapaka.NotificationTemporarilyDenied)
+	case errors.Is(err, ErrNotSubscribed):
+		return a.sendResult(ctx, cc, eapaka.NotificationNotSubscribed)
+	}
+	return a.sendResult(ctx, cc, eapaka.NotificationGeneralFailureAfterAuth)
+}
+
+// sendResult sends the protected EAP-Request/AKA-Notification of a result
+// round (RFC 4187 Sections 6.2 and 6.3.1).
+func (a *Authenticator) sendResult(ctx context.Context, cc *eapaka.ChallengeContext, value uint16) (*Result, error) {
+	k := notificationKeys(cc)
+	var iv []byte
+	if k.Reauth {
+		var err error
+		if iv, err = a.random(16); err != nil {
+			return a.fail(ctx, cc.ID, cc.Identifier, err)
+		}
+	}
+	req, err := eapaka.NewProtectedNotificationRequest(cc.Type, cc.Identifier+1, value, k, iv)
+	if err != nil {
+		return a.fail(ctx, cc.ID, cc.Identifier, err)
+	}
+	cc.Notification = value
+	return a.continueWith(ctx, cc, req)
+}
+
+func (a *Authenticator) handleNotification(ctx context.Context, cc *eapaka.ChallengeContext, pkt *eapaka.Packet) (*Result, error) {
+	if cc.Notification&0x4000 != 0 {
+		// The General-Failure notification of a peer blocked by the policy.
+		return a.fail(ctx, cc.ID, pkt.Identifier, ErrLockedOut)
+	}
+	if err := eapaka.VerifyProtectedNotification(pkt, notificationKeys(cc)); err != nil {
+		return a.fail(ctx, cc.ID, pkt.Identifier, err)
+	}
+	switch cc.Notification {
+	case eapaka.NotificationSuccess:
+		return a.succeed(ctx, cc, pkt.Identifier, cc.NonceS != nil)
+	case eapaka.NotificationTemporarilyDenied:
+		return a.fail(ctx, cc.ID, pkt.Identifier, fmt.Errorf("%w: %w", ErrNotAuthorized, ErrTemporarilyDenied))
+	case eapaka.NotificationNotSubscribed:
+		return a.fail(ctx, cc.ID, pkt.Identifier, fmt.Errorf("%w: %w", ErrNotAuthorized, ErrNotSubscribed))
+	}
+	return a.fail(ctx, cc.ID, pkt.Identifier, ErrNotAuthorized)
+}
+
+func notificationKeys(cc *eapaka.ChallengeContext) eapaka.NotificationKeys {
+	return eapaka.NotificationKeys{
+		K_encr:  kEncrOf(cc),
+		K_aut:   kAutOf(cc),
+		Reauth:  cc.NonceS != nil,
+		Counter: cc.Counter,
+	}
+}
+
+func (a *Authenticator) newReauthID(eapType uint8) (string, error) {
+	b, err := a.random(8)
+	if err != nil {
+		return "", err
+	}
+	prefix := eapaka.PrefixReauthAKA
+	if eapType == eapaka.TypeAKAPrime {
+		prefix = eapaka.PrefixReauthAKAPrime
+	}
+	id := string(prefix) + hex.EncodeToString(b)
+	if a.cfg.Realm != "" {
+		id += "@" + a.cfg.Realm
+	}
+	return id, nil
+}
+
+// check asks the policy, if any, whether the peer of cc may authenticate.
+func (a *Authenticator) check(ctx context.Context, cc *eapaka.ChallengeContext) error {
+	if a.cfg.Policy == nil {
+		return nil
+	}
+	return a.cfg.Policy.Check(ctx, policyKey(ctx, cc))
+}
+
+func (a *Authenticator) recordFailure(ctx context.Context, cc *eapaka.ChallengeContext, f Failure) {
+	if a.cfg.Policy != nil {
+		a.cfg.Policy.RecordFailure(ctx, policyKey(ctx, cc), f)
+	}
+}
+
+func policyKey(ctx context.Context, cc *eapaka.ChallengeContext) PolicyKey {
+	id := cc.PermanentID
+	if id == "" {
+		id = cc.Identity
+	}
+	return PolicyKey{PermanentID: id, Source: SourceFromContext(ctx)}
+}
+
+// notifyBlocked sends EAP-Request/AKA-Notification with General-Failure to a
+// peer blocked by the policy. The conversation ends with EAP-Failure when
+// the peer acknowledges it (RFC 4187 Section 6.3.1).
+func (a *Authenticator) notifyBlocked(ctx context.Context, cc *eapaka.ChallengeContext) (*Result, error) {
+	req, err := eapaka.NewNotificationRequest(cc.Type, cc.Identifier+1, eapaka.NotificationGeneralFailure).Build()
+	if err != nil {
+		return a.fail(ctx, cc.ID, cc.Identifier, err)
+	}
+	cc.RAND, cc.AUTN, cc.XRES, cc.AKA, cc.AKAPrime = nil, nil, nil, nil, nil
+	cc.Notification = eapaka.NotificationGeneralFailure
+	return a.continueWith(ctx, cc, req)
+}
+
+func (a *Authenticator) continueWith(ctx context.Context, cc *eapaka.ChallengeContext, req *eapaka.Packet) (*Result, error) {
+	cc.Identifier = req.Identifier
+	cc.Subtype = req.Subtype
+	if err := a.cfg.Store.PutChallenge(ctx, cc); err != nil {
+		return a.fail(ctx, cc.ID, req.Identifier-1, err)
+	}
+	return &Result{
+		Status:      StatusContinue,
+		Reply:       req,
+		SessionID:   cc.ID,
+		Identity:    cc.Identity,
+		PermanentID: cc.PermanentID,
+	}, nil
+}
+
+func (a *Authenticator) succeed(ctx context.Context, cc *eapaka.ChallengeContext, identifier uint8, reauth bool) (*Result, error) {
+	a.cfg.Store.DeleteChallenge(ctx, cc.ID)
+	if a.cfg.Policy != nil {
+		a.cfg.Policy.RecordSuccess(ctx, policyKey(ctx, cc))
+	}
+	res := &Result{
+		Status:      StatusSuccess,
+		Reply:       &eapaka.Packet{Code: eapaka.CodeSuccess, Identifier: identifier},
+		Identity:    cc.Identity,
+		PermanentID: cc.PermanentID,
+		Reauth:      reauth,
+	}
+	if cc.AKAPrime != nil {
+		res.MSK, res.EMSK = cc.AKAPrime.MSK, cc.AKAPrime.EMSK
+	} else {
+		res.MSK, res.EMSK = cc.AKA.MSK, cc.AKA.EMSK
+	}
+	if reauth {
+		eapaka.ReportEvent("server", eapaka.EventReauth)
+	} else {
+		eapaka.ReportEvent("server", eapaka.EventFullAuth)
+	}
+	return res, nil
+}
+
+func (a *Authenticator) fail(ctx context.Context, sessionID string, identifier uint8, err error) (*Result, error) {
+	if sessionID != "" {
+		a.cfg.Store.DeleteChallenge(ctx, sessionID)
+	}
+	eapaka.ReportEvent("server", eapaka.EventFailure)
+	return &Result{
+		Status: StatusFailure,
+		Reply:  &eapaka.Packet{Code: eapaka.CodeFailure, Identifier: identifier},
+	}, err
+}
+
+func kAutOf(cc *eapaka.ChallengeContext) []byte {
+	if cc.AKAPrime != nil {
+		return cc.AKAPrime.K_aut
+	}
+	return cc.AKA.K_aut
+}
+
+func kEncrOf(cc *eapaka.ChallengeContext) []byte {
+	if cc.AKAPrime != nil {
+		return cc.AKAPrime.K_encr
+	}
+	return cc.AKA.K_encr
+}
+
+func identifierOf(msg []byte) uint8 {
+	if len(msg) < 2 {
+		return 0
+	}
+	return msg[1]
+}
+
+func (a *Authenticator) newSessionID() (string, error) {
+	b, err := a.random(16)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+func (a *Authenticator) random(n int) ([]byte, error) {
+	return eapaka.ReadRandom(a.cfg.Random, n)
+}
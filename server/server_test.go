@@ -0,0 +1,175 @@
+package server_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/oyaguma3/go-eapaka"
+	"github.com/oyaguma3/go-eapaka/eapakatest"
+	"github.com/oyaguma3/go-eapaka/milenage"
+	"github.com/oyaguma3/go-eapaka/peer"
+	"github.com/oyaguma3/go-eapaka/server"
+)
+
+// blockPolicy blocks every attempt while blocked is set.
+type blockPolicy struct{ blocked bool }
+
+func (p *blockPolicy) Check(context.Context, server.PolicyKey) error {
+	if p.blocked {
+		return server.ErrLockedOut
+	}
+	return nil
+}
+
+func (p *blockPolicy) RecordFailure(context.Context, server.PolicyKey, server.Failure) {}
+func (p *blockPolicy) RecordSuccess(context.Context, server.PolicyKey)                 {}
+
+func run(t *testing.T, a *server.Authenticator, p *peer.Peer) *server.Result {
+	t.Helper()
+	msg, sessionID := p.IdentityResponse(0), ""
+	for range 10 {
+		res, err := a.Handle(context.Background(), sessionID, msg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		reply, _ := res.Reply.Marshal()
+		next, _ := p.Handle(reply)
+		if res.Status != server.StatusContinue {
+			return res
+		}
+		msg, sessionID = next, res.SessionID
+	}
+	t.Fatal("conversation did not finish")
+	return nil
+}
+
+// TestAuthenticator_ReauthContextKept checks that a re-authentication
+// identity offered on the other method, or while the peer is blocked, does
+// not consume the stored context.
+func TestAuthenticator_ReauthContextKept(t *testing.T) {
+	s := &eapakatest.Subscribers[0]
+	store := eapaka.NewMemoryStore(eapaka.StoreConfig{})
+	vectors := milenage.NewProvider(s.Milenage())
+	policy := &blockPolicy{}
+	a, err := server.New(server.Config{Type: eapaka.TypeAKAPrime, Vectors: vectors, Store: store, EnableReauth: true, Policy: policy})
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := server.New(server.Config{Type: eapaka.TypeAKA, Vectors: vectors, Store: store, EnableReauth: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	usim, err := s.USIM()
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := peer.New(peer.Config{Identity: s.Identity(eapaka.TypeAKAPrime), SIM: usim})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res := run(t, a, p); res.Status != server.StatusSuccess || p.ReauthID() == "" {
+		t.Fatalf("full authentication: status %v, reauth id %q", res.Status, p.ReauthID())
+	}
+	ctx := context.Background()
+	reauthID := p.ReauthID()
+	identityResponse, _ := eapaka.NewEAPIdentity(eapaka.CodeResponse, 0, reauthID).Marshal()
+
+	if _, err := other.Handle(ctx, "", identityResponse); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.GetReauth(ctx, reauthID); err != nil {
+		t.Fatalf("after the other method: %v", err)
+	}
+
+	policy.blocked = true
+	res, err := a.Handle(ctx, "", identityResponse)
+	if err != nil || res.Reply.Subtype != eapaka.SubtypeNotification {
+		t.Fatalf("blocked: %v, %v", res.Reply, err)
+	}
+	if _, err := store.GetReauth(ctx, reauthID); err != nil {
+		t.Fatalf("after a blocked attempt: %v", err)
+	}
+
+	policy.blocked = false
+	if res := run(t, a, p); res.Status != server.StatusSuccess || !res.Reauth || !p.Reauthenticated() {
+		t.Fatalf("re-authentication: status %v, reauth %v", res.Status, res.Reauth)
+	}
+	if _, err := store.GetReauth(ctx, reauthID); !errors.Is(err, eapaka.ErrSessionNotFound) {
+		t.Errorf("used re-authentication identity still stored: %v", err)
+	}
+}
+
+// TestAuthenticator_StaleIdentifierDiscarded checks that a response with the
+// wrong EAP Identifier is discarded without ending the conversation.
+func TestAuthenticator_StaleIdentifierDiscarded(t *testing.T) {
+	s := &eapakatest.Subscribers[0]
+	a, err := server.New(server.Config{Vectors: milenage.NewProvider(s.Milenage())})
+	if err != nil {
+		t.Fatal(err)
+	}
+	usim, err := s.USIM()
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := peer.New(peer.Config{Identity: s.Identity(eapaka.TypeAKAPrime), SIM: usim})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	res, err := a.Handle(ctx, "", p.IdentityResponse(0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	challenge, _ := res.Reply.Marshal()
+	resp, err := p.Handle(challenge)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stale := append([]byte(nil), resp...)
+	stale[1]--
+	discarded, err := a.Handle(ctx, res.SessionID, stale)
+	if !errors.Is(err, server.ErrIdentifierInvalid) || discarded.Status != server.StatusDiscard || discarded.Reply != nil {
+		t.Fatalf("stale response: %+v, %v", discarded, err)
+	}
+	if res, err := a.Handle(ctx, res.SessionID, resp); err != nil || res.Status != server.StatusSuccess {
+		t.Fatalf("response after the stale one: status %v, %v", res.Status, err)
+	}
+}
+
+// TestAuthenticator_OneResync checks that a second Synchronization-Failure
+// ends the conversation instead of fetching another vector.
+func TestAuthenticator_OneResync(t *testing.T) {
+	s := &eapakatest.Subscribers[0]
+	vectors := milenage.NewProvider(s.Milenage())
+	calls := 0
+	a, err := server.New(server.Config{Vectors: eapaka.VectorProviderFunc(func(ctx context.Context, req *eapaka.VectorRequest) (*eapaka.AuthVector, error) {
+		calls++
+		r := *req
+		r.RAND, r.AUTS = nil, nil
+		return vectors.GetVector(ctx, &r)
+	})})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	identity, _ := eapaka.NewEAPIdentity(eapaka.CodeResponse, 0, s.Identity(eapaka.TypeAKAPrime)).Marshal()
+	res, err := a.Handle(ctx, "", identity)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, want := range []server.Status{server.StatusContinue, server.StatusFailure} {
+		syncFailure, _ := eapaka.NewSyncFailure(eapaka.TypeAKAPrime, res.Reply.Identifier, make([]byte, 14)).Build()
+		msg, _ := syncFailure.Marshal()
+		if res, err = a.Handle(ctx, res.SessionID, msg); res.Status != want {
+			t.Fatalf("Synchronization-Failure %d: status %v, %v", i+1, res.Status, err)
+		}
+	}
+	if !errors.Is(err, server.ErrRepeatedSync) {
+		t.Errorf("second Synchronization-Failure: %v", err)
+	}
+	if calls != 2 {
+		t.Errorf("%d vectors fetched, want 2", calls)
+	}
+}
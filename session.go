@@ -0,0 +1,267 @@
+package eapaka
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// Errors returned by [SessionStore] implementations.
+var (
+	// ErrSessionNotFound is returned when no (unexpired) entry exists for the given key.
+	ErrSessionNotFound = errors.New("eapaka: session not found")
+
+	// ErrReauthLimitReached is returned when a re-authentication context has been
+	// used the maximum number of times allowed by the store.
+	ErrReauthLimitReached = errors.New("eapaka: re-authentication limit reached")
+)
+
+// Default values used by [StoreConfig] when a field is left zero.
+const (
+	DefaultChallengeTTL   = 60 * time.Second
+	DefaultReauthTTL      = 24 * time.Hour
+	DefaultMaxReauthCount = 16
+)
+
+// ChallengeContext holds the server-side state of a pending full authentication
+// or re-authentication round trip (e.g., between EAP-Request/AKA-Challenge and
+// the corresponding EAP-Response).
+type ChallengeContext struct {
+	// ID is the key of the context, typically derived from the RADIUS State attribute.
+	ID string
+
+	// Identity is the identity used in key derivation.
+	Identity string
+
+	// PermanentID is the permanent identity (e.g., IMSI-based NAI) of the peer.
+	PermanentID string
+
+	// Type is TypeAKA or TypeAKAPrime.
+	Type uint8
+
+	// Identifier is the EAP Identifier of the outstanding request.
+	Identifier uint8
+
+	// Subtype is the EAP-AKA Subtype of the outstanding request.
+	Subtype uint8
+
+	// RAND, AUTN and XRES of the authentication vector in use.
+	RAND []byte
+	AUTN []byte
+	XRES []byte
+
+	// Keys derived for the exchange. Exactly one of AKA and AKAPrime is set.
+	AKA      *AkaKeys
+	AKAPrime *AkaPrimeKeys
+
+	// Counter and NonceS are set for a pending fast re-authentication.
+	// See RFC 4187 Section 5.
+	Counter uint16
+	NonceS  []byte
+
+	// NextReauthID is the re-authentication identity offered in AT_NEXT_REAUTH_ID, if any.
+	NextReauthID string
+
+	// ResultInd is set when both sides included AT_RESULT_IND, so that the
+	// outcome is sent in a protected notification round (RFC 4187 Section 6.2).
+	ResultInd bool
+
+	// Notification is the AT_NOTIFICATION value of an outstanding
+	// EAP-Request/AKA-Notification.
+	Notification uint16
+
+	// Resynchronized is set once a Synchronization-Failure has been handled;
+	// only one re-synchronisation is allowed per conversation.
+	Resynchronized bool
+
+	// ExpiresAt is filled in by the store from [StoreConfig].ChallengeTTL if zero.
+	ExpiresAt time.Time
+}
+
+// Clone returns a deep copy of the context.
+func (c *ChallengeContext) Clone() *ChallengeContext {
+	if c == nil {
+		return nil
+	}
+	n := *c
+	n.RAND = cloneBytes(c.RAND)
+	n.AUTN = cloneBytes(c.AUTN)
+	n.XRES = cloneBytes(c.XRES)
+	n.NonceS = cloneBytes(c.NonceS)
+	if c.AKA != nil {
+		k := c.AKA.clone()
+		n.AKA = &k
+	}
+	if c.AKAPrime != nil {
+		k := c.AKAPrime.clone()
+		n.AKAPrime = &k
+	}
+	return &n
+}
+
+// ReauthContext holds the state needed for fast re-authentication
+// (RFC 4187 Section 5, RFC 5448 Section 3.3), keyed by the re-authentication identity.
+type ReauthContext struct {
+	// ReauthID is the re-authentication identity sent in AT_NEXT_REAUTH_ID.
+	ReauthID string
+
+	// PermanentID is the permanent identity of the peer.
+	PermanentID string
+
+	// Type is TypeAKA or TypeAKAPrime.
+	Type uint8
+
+	// MK is the EAP-AKA master key. Set only when Type is TypeAKA.
+	MK Key
+
+	// K_re is the EAP-AKA' re-authentication key. Set only when Type is TypeAKAPrime.
+	K_re Key
+
+	// K_encr and K_aut are reused from the full authentication.
+	K_encr Key
+	K_aut  Key
+
+	// Counter is the last counter value used with these keys.
+	Counter uint16
+
+	// ExpiresAt is filled in by the store from [StoreConfig].ReauthTTL if zero.
+	ExpiresAt time.Time
+}
+
+// NewReauthContextAKA creates a [ReauthContext] from EAP-AKA keys.
+func NewReauthContextAKA(reauthID, permanentID string, keys AkaKeys) *ReauthContext {
+	return &ReauthContext{
+		ReauthID:    reauthID,
+		PermanentID: permanentID,
+		Type:        TypeAKA,
+		MK:          NewKey(keys.MK),
+		K_encr:      NewKey(keys.K_encr),
+		K_aut:       NewKey(keys.K_aut),
+	}
+}
+
+// NewReauthContextAKAPrime creates a [ReauthContext] from EAP-AKA' keys.
+func NewReauthContextAKAPrime(reauthID, permanentID string, keys AkaPrimeKeys) *ReauthContext {
+	return &ReauthContext{
+		ReauthID:    reauthID,
+		PermanentID: permanentID,
+		Type:        TypeAKAPrime,
+		K_re:        NewKey(keys.K_re),
+		K_encr:      NewKey(keys.K_encr),
+		K_aut:       NewKey(keys.K_aut),
+	}
+}
+
+// DeriveKeys derives the re-authentication MSK and EMSK for the given
+// identity, counter and NONCE_S using the stored key.
+func (r *ReauthContext) DeriveKeys(identity string, counter uint16, nonceS []byte) ReauthKeys {
+	if r.Type == TypeAKAPrime {
+		return DeriveReauthKeysAKAPrime(identity, counter, nonceS, r.K_re)
+	}
+	return DeriveReauthKeysAKA(identity, counter, nonceS, r.MK)
+}
+
+// Clone returns a deep copy of the context.
+func (r *ReauthContext) Clone() *ReauthContext {
+	if r == nil {
+		return nil
+	}
+	n := *r
+	n.MK = NewKey(r.MK)
+	n.K_re = NewKey(r.K_re)
+	n.K_encr = NewKey(r.K_encr)
+	n.K_aut = NewKey(r.K_aut)
+	return &n
+}
+
+// SessionStore persists challenge and re-authentication state between EAP round trips.
+// Implementations must be safe for concurrent use and must never return
+// entries whose ExpiresAt has passed.
+// Values passed in and returned are copies; callers may modify them freely.
+type SessionStore interface {
+	// PutChallenge stores c under c.ID, replacing any existing entry.
+	PutChallenge(ctx context.Context, c *ChallengeContext) error
+
+	// GetChallenge returns the challenge context stored under id.
+	GetChallenge(ctx context.Context, id string) (*ChallengeContext, error)
+
+	// DeleteChallenge removes the challenge context stored under id, if any.
+	DeleteChallenge(ctx context.Context, id string) error
+
+	// PutReauth stores r under r.ReauthID, replacing any existing entry.
+	PutReauth(ctx context.Context, r *ReauthContext) error
+
+	// GetReauth returns the re-authentication context stored under reauthID
+	// without modifying it.
+	GetReauth(ctx context.Context, reauthID string) (*ReauthContext, error)
+
+	// TakeReauth atomically removes the context stored under reauthID and returns
+	// it with Counter incremented by one. Re-authentication identities are
+	// single use (RFC 4187 Section 4.1.1.2), so the caller stores the context
+	// again under the next re-authentication identity once the exchange succeeds.
+	// It returns ErrReauthLimitReached if the counter would exceed the
+	// configured maximum; the entry is removed in that case as well.
+	TakeReauth(ctx context.Context, reauthID string) (*ReauthContext, error)
+
+	// DeleteReauth removes the re-authentication context stored under reauthID, if any.
+	DeleteReauth(ctx context.Context, reauthID string) error
+}
+
+// StoreConfig configures the built-in [SessionStore] implementations.
+type StoreConfig struct {
+	// ChallengeTTL is the lifetime of challenge contexts. Default: DefaultChallengeTTL.
+	ChallengeTTL time.Duration
+
+	// ReauthTTL is the lifetime of re-authentication contexts. Default: DefaultReauthTTL.
+	ReauthTTL time.Duration
+
+	// MaxReauthCount is the number of fast re-authentications allowed after
+	// a full authentication. Default: DefaultMaxReauthCount.
+	MaxReauthCount uint16
+
+	// Now returns the current time. Default: time.Now.
+	Now func() time.Time
+}
+
+func (c StoreConfig) withDefaults() StoreConfig {
+	if c.ChallengeTTL <= 0 {
+		c.ChallengeTTL = DefaultChallengeTTL
+	}
+	if c.ReauthTTL <= 0 {
+		c.ReauthTTL = DefaultReauthTTL
+	}
+	if c.MaxReauthCount == 0 {
+		c.MaxReauthCount = DefaultMaxReauthCount
+	}
+	if c.Now == nil {
+		c.Now = time.Now
+	}
+	return c
+}
+
+func (k AkaKeys) clone() AkaKeys {
+	return AkaKeys{
+		MK:     cloneBytes(k.MK),
+		K_encr: cloneBytes(k.K_encr),
+		K_aut:  cloneBytes(k.K_aut),
+		MSK:    cloneBytes(k.MSK),
+		EMSK:   cloneBytes(k.EMSK),
+	}
+}
+
+func (k AkaPrimeKeys) clone() AkaPrimeKeys {
+	return AkaPrimeKeys{
+		K_encr: cloneBytes(k.K_encr),
+		K_aut:  cloneBytes(k.K_aut),
+		K_re:   cloneBytes(k.K_re),
+		MSK:    cloneBytes(k.MSK),
+		EMSK:   cloneBytes(k.EMSK),
+	}
+}
+
+func cloneBytes(b []byte) []byte {
+	if b == nil {
+		return nil
+	}
+	return append([]byte(nil), b...)
+}
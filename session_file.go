@@ -0,0 +1,275 @@
+package eapaka
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"hash/fnv"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+const (
+	fileStoreChallengeDir = "challenge"
+	fileStoreReauthDir    = "reauth"
+)
+
+// FileStore is a [SessionStore] that keeps one JSON file per entry below a directory,
+// so that re-authentication state survives process restarts.
+// File names are the SHA-256 of the key, so identities never appear on disk in clear.
+// Writes go through a temporary file and rename, and read-modify-write operations
+// are serialised per key within the process. Sharing a directory between
+// processes is not supported.
+type FileStore struct {
+	dir   string
+	cfg   StoreConfig
+	locks [memoryStoreShards]sync.Mutex
+}
+
+// NewFileStore creates a [FileStore] rooted at dir, creating it if needed.
+func NewFileStore(dir string, cfg StoreConfig) (*FileStore, error) {
+	for _, sub := range []string{fileStoreChallengeDir, fileStoreReauthDir} {
+		if err := os.MkdirAll(filepath.Join(dir, sub), 0o700); err != nil {
+			return nil, err
+		}
+	}
+	return &FileStore{dir: dir, cfg: cfg.withDefaults()}, nil
+}
+
+func (s *FileStore) lock(key string) *sync.Mutex {
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	return &s.locks[h.Sum32()%memoryStoreShards]
+}
+
+func (s *FileStore) path(sub, key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return filepath.Join(s.dir, sub, hex.EncodeToString(sum[:])+".json")
+}
+
+// PutChallenge implements [SessionStore].
+func (s *FileStore) PutChallenge(_ context.Context, c *ChallengeContext) error {
+	c = c.Clone()
+	if c.ExpiresAt.IsZero() {
+		c.ExpiresAt = s.cfg.Now().Add(s.cfg.ChallengeTTL)
+	}
+	mu := s.lock(c.ID)
+	mu.Lock()
+	defer mu.Unlock()
+	return writeJSONFile(s.path(fileStoreChallengeDir, c.ID), newStoredChallenge(c))
+}
+
+// GetChallenge implements [SessionStore].
+func (s *FileStore) GetChallenge(_ context.Context, id string) (*ChallengeContext, error) {
+	mu := s.lock(id)
+	mu.Lock()
+	defer mu.Unlock()
+	p := s.path(fileStoreChallengeDir, id)
+	sc := &storedChallenge{ChallengeContext: &ChallengeContext{}}
+	if err := readJSONFile(p, sc); err != nil {
+		return nil, err
+	}
+	c := sc.context()
+	if !s.cfg.Now().Before(c.ExpiresAt) {
+		os.Remove(p)
+		return nil, ErrSessionNotFound
+	}
+	return c, nil
+}
+
+// DeleteChallenge implements [SessionStore].
+func (s *FileStore) DeleteChallenge(_ context.Context, id string) error {
+	mu := s.lock(id)
+	mu.Lock()
+	defer mu.Unlock()
+	return removeFile(s.path(fileStoreChallengeDir, id))
+}
+
+// PutReauth implements [SessionStore].
+func (s *FileStore) PutReauth(_ context.Context, r *ReauthContext) error {
+	r = r.Clone()
+	if r.ExpiresAt.IsZero() {
+		r.ExpiresAt = s.cfg.Now().Add(s.cfg.ReauthTTL)
+	}
+	mu := s.lock(r.ReauthID)
+	mu.Lock()
+	defer mu.Unlock()
+	return writeJSONFile(s.path(fileStoreReauthDir, r.ReauthID), newStoredReauth(r))
+}
+
+// GetReauth implements [SessionStore].
+func (s *FileStore) GetReauth(_ context.Context, reauthID string) (*ReauthContext, error) {
+	mu := s.lock(reauthID)
+	mu.Lock()
+	defer mu.Unlock()
+	p := s.path(fileStoreReauthDir, reauthID)
+	sr := &storedReauth{ReauthContext: &ReauthContext{}}
+	if err := readJSONFile(p, sr); err != nil {
+		return nil, err
+	}
+	r := sr.context()
+	if !s.cfg.Now().Before(r.ExpiresAt) {
+		os.Remove(p)
+		return nil, ErrSessionNotFound
+	}
+	return r, nil
+}
+
+// TakeReauth implements [SessionStore].
+func (s *FileStore) TakeReauth(_ context.Context, reauthID string) (*ReauthContext, error) {
+	mu := s.lock(reauthID)
+	mu.Lock()
+	defer mu.Unlock()
+	p := s.path(fileStoreReauthDir, reauthID)
+	sr := &storedReauth{ReauthContext: &ReauthContext{}}
+	if err := readJSONFile(p, sr); err != nil {
+		return nil, err
+	}
+	r := sr.context()
+	if err := removeFile(p); err != nil {
+		return nil, err
+	}
+	if !s.cfg.Now().Before(r.ExpiresAt) {
+		return nil, ErrSessionNotFound
+	}
+	if r.Counter >= s.cfg.MaxReauthCount {
+		return nil, ErrReauthLimitReached
+	}
+	r.Counter++
+	return r, nil
+}
+
+// DeleteReauth implements [SessionStore].
+func (s *FileStore) DeleteReauth(_ context.Context, reauthID string) error {
+	mu := s.lock(reauthID)
+	mu.Lock()
+	defer mu.Unlock()
+	return removeFile(s.path(fileStoreReauthDir, reauthID))
+}
+
+// Purge removes all expired entries and returns the number removed.
+// Files that cannot be decoded are removed as well.
+func (s *FileStore) Purge() (int, error) {
+	now := s.cfg.Now()
+	n := 0
+	for _, sub := range []string{fileStoreChallengeDir, fileStoreReauthDir} {
+		entries, err := os.ReadDir(filepath.Join(s.dir, sub))
+		if err != nil {
+			return n, err
+		}
+		for _, e := range entries {
+			if e.IsDir() || filepath.Ext(e.Name()) != ".json" {
+				continue
+			}
+			p := filepath.Join(s.dir, sub, e.Name())
+			var meta struct{ ExpiresAt time.Time }
+			if err := readJSONFile(p, &meta); err == nil && now.Before(meta.ExpiresAt) {
+				continue
+			}
+			if os.Remove(p) == nil {
+				n++
+			}
+		}
+	}
+	return n, nil
+}
+
+// storedChallenge is the file format of a ChallengeContext. The keys are
+// written in full as plain byte slices, which the MarshalJSON methods of
+// [AkaKeys] and [Key] (meant for logging) would not do.
+type storedChallenge struct {
+	*ChallengeContext
+	AKA      *storedAkaKeys
+	AKAPrime *storedAkaPrimeKeys
+}
+
+type storedAkaKeys struct {
+	MK, K_encr, K_aut, MSK, EMSK []byte
+}
+
+type storedAkaPrimeKeys struct {
+	K_encr, K_aut, K_re, MSK, EMSK []byte
+}
+
+func newStoredChallenge(c *ChallengeContext) *storedChallenge {
+	sc := &storedChallenge{ChallengeContext: c}
+	if k := c.AKA; k != nil {
+		sc.AKA = &storedAkaKeys{k.MK, k.K_encr, k.K_aut, k.MSK, k.EMSK}
+	}
+	if k := c.AKAPrime; k != nil {
+		sc.AKAPrime = &storedAkaPrimeKeys{k.K_encr, k.K_aut, k.K_re, k.MSK, k.EMSK}
+	}
+	return sc
+}
+
+func (sc *storedChallenge) context() *ChallengeContext {
+	c := sc.ChallengeContext
+	c.AKA, c.AKAPrime = nil, nil
+	if k := sc.AKA; k != nil {
+		c.AKA = &AkaKeys{k.MK, k.K_encr, k.K_aut, k.MSK, k.EMSK}
+	}
+	if k := sc.AKAPrime; k != nil {
+		c.AKAPrime = &AkaPrimeKeys{k.K_encr, k.K_aut, k.K_re, k.MSK, k.EMSK}
+	}
+	return c
+}
+
+// storedReauth is the file format of a ReauthContext, with the keys
+// written in full for the same reason as in [storedChallenge].
+type storedReauth struct {
+	*ReauthContext
+	MK, K_re, K_encr, K_aut []byte
+}
+
+func newStoredReauth(r *ReauthContext) *storedReauth {
+	return &storedReauth{r, r.MK, r.K_re, r.K_encr, r.K_aut}
+}
+
+func (sr *storedReauth) context() *ReauthContext {
+	r := sr.ReauthContext
+	r.MK, r.K_re, r.K_encr, r.K_aut = sr.MK, sr.K_re, sr.K_encr, sr.K_aut
+	return r
+}
+
+func writeJSONFile(path string, v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+func readJSONFile(path string, v any) error {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return ErrSessionNotFound
+	}
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+func removeFile(path string) error {
+	if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,188 @@
+package eapaka
+
+import (
+	"context"
+	"hash/fnv"
+	"sync"
+	"time"
+)
+
+const memoryStoreShards = 64
+
+// MemoryStore is an in-memory [SessionStore].
+// Entries are spread over independently locked shards so that
+// thousands of parallel sessions do not contend on a single mutex.
+// Expired entries are dropped lazily on access and by [MemoryStore.Purge].
+type MemoryStore struct {
+	cfg    StoreConfig
+	shards [memoryStoreShards]memoryShard
+}
+
+type memoryShard struct {
+	mu         sync.Mutex
+	challenges map[string]*ChallengeContext
+	reauths    map[string]*ReauthContext
+}
+
+// NewMemoryStore creates an empty [MemoryStore].
+func NewMemoryStore(cfg StoreConfig) *MemoryStore {
+	s := &MemoryStore{cfg: cfg.withDefaults()}
+	for i := range s.shards {
+		s.shards[i].challenges = make(map[string]*ChallengeContext)
+		s.shards[i].reauths = make(map[string]*ReauthContext)
+	}
+	return s
+}
+
+func (s *MemoryStore) shard(key string) *memoryShard {
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	return &s.shards[h.Sum32()%memoryStoreShards]
+}
+
+// PutChallenge implements [SessionStore].
+func (s *MemoryStore) PutChallenge(_ context.Context, c *ChallengeContext) error {
+	c = c.Clone()
+	if c.ExpiresAt.IsZero() {
+		c.ExpiresAt = s.cfg.Now().Add(s.cfg.ChallengeTTL)
+	}
+	sh := s.shard(c.ID)
+	sh.mu.Lock()
+	sh.challenges[c.ID] = c
+	sh.mu.Unlock()
+	return nil
+}
+
+// GetChallenge implements [SessionStore].
+func (s *MemoryStore) GetChallenge(_ context.Context, id string) (*ChallengeContext, error) {
+	sh := s.shard(id)
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+	c, ok := sh.challenges[id]
+	if !ok {
+		return nil, ErrSessionNotFound
+	}
+	if !s.cfg.Now().Before(c.ExpiresAt) {
+		delete(sh.challenges, id)
+		return nil, ErrSessionNotFound
+	}
+	return c.Clone(), nil
+}
+
+// DeleteChallenge implements [SessionStore].
+func (s *MemoryStore) DeleteChallenge(_ context.Context, id string) error {
+	sh := s.shard(id)
+	sh.mu.Lock()
+	delete(sh.challenges, id)
+	sh.mu.Unlock()
+	return nil
+}
+
+// PutReauth implements [SessionStore].
+func (s *MemoryStore) PutReauth(_ context.Context, r *ReauthContext) error {
+	r = r.Clone()
+	if r.ExpiresAt.IsZero() {
+		r.ExpiresAt = s.cfg.Now().Add(s.cfg.ReauthTTL)
+	}
+	sh := s.shard(r.ReauthID)
+	sh.mu.Lock()
+	sh.reauths[r.ReauthID] = r
+	sh.mu.Unlock()
+	return nil
+}
+
+// GetReauth implements [SessionStore].
+func (s *MemoryStore) GetReauth(_ context.Context, reauthID string) (*ReauthContext, error) {
+	sh := s.shard(reauthID)
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+	r, ok := sh.reauths[reauthID]
+	if !ok {
+		return nil, ErrSessionNotFound
+	}
+	if !s.cfg.Now().Before(r.ExpiresAt) {
+		delete(sh.reauths, reauthID)
+		return nil, ErrSessionNotFound
+	}
+	return r.Clone(), nil
+}
+
+// TakeReauth implements [SessionStore].
+func (s *MemoryStore) TakeReauth(_ context.Context, reauthID string) (*ReauthContext, error) {
+	sh := s.shard(reauthID)
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+	r, ok := sh.reauths[reauthID]
+	if !ok {
+		return nil, ErrSessionNotFound
+	}
+	delete(sh.reauths, reauthID)
+	if !s.cfg.Now().Before(r.ExpiresAt) {
+		return nil, ErrSessionNotFound
+	}
+	if r.Counter >= s.cfg.MaxReauthCount {
+		return nil, ErrReauthLimitReached
+	}
+	r.Counter++
+	return r, nil
+}
+
+// DeleteReauth implements [SessionStore].
+func (s *MemoryStore) DeleteReauth(_ context.Context, reauthID string) error {
+	sh := s.shard(reauthID)
+	sh.mu.Lock()
+	delete(sh.reauths, reauthID)
+	sh.mu.Unlock()
+	return nil
+}
+
+// Len returns the number of challenge and re-authentication contexts held,
+// including expired entries that have not been purged yet.
+func (s *MemoryStore) Len() (challenges, reauths int) {
+	for i := range s.shards {
+		sh := &s.shards[i]
+		sh.mu.Lock()
+		challenges += len(sh.challenges)
+		reauths += len(sh.reauths)
+		sh.mu.Unlock()
+	}
+	return challenges, reauths
+}
+
+// Purge removes all expired entries and returns the number removed.
+func (s *MemoryStore) Purge() int {
+	now := s.cfg.Now()
+	n := 0
+	for i := range s.shards {
+		sh := &s.shards[i]
+		sh.mu.Lock()
+		for k, c := range sh.challenges {
+			if !now.Before(c.ExpiresAt) {
+				delete(sh.challenges, k)
+				n++
+			}
+		}
+		for k, r := range sh.reauths {
+			if !now.Before(r.ExpiresAt) {
+				delete(sh.reauths, k)
+				n++
+			}
+		}
+		sh.mu.Unlock()
+	}
+	return n
+}
+
+// RunJanitor calls [MemoryStore.Purge] every interval until ctx is done.
+func (s *MemoryStore) RunJanitor(ctx context.Context, interval time.Duration) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+			s.Purge()
+		}
+	}
+}
@@ -0,0 +1,172 @@
+package eapaka_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/oyaguma3/go-eapaka"
+)
+
+type fakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	c.now = c.now.Add(d)
+	c.mu.Unlock()
+}
+
+func newStores(t *testing.T, cfg eapaka.StoreConfig) map[string]eapaka.SessionStore {
+	t.Helper()
+	fs, err := eapaka.NewFileStore(t.TempDir(), cfg)
+	if err != nil {
+		t.Fatalf("NewFileStore failed: %v", err)
+	}
+	return map[string]eapaka.SessionStore{
+		"memory": eapaka.NewMemoryStore(cfg),
+		"file":   fs,
+	}
+}
+
+func TestSessionStore_Challenge(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(1700000000, 0)}
+	cfg := eapaka.StoreConfig{ChallengeTTL: 30 * time.Second, Now: clock.Now}
+	ctx := context.Background()
+
+	for name, store := range newStores(t, cfg) {
+		t.Run(name, func(t *testing.T) {
+			keys := eapaka.DeriveKeysAKA("0001010000000001@example.org", make([]byte, 16), make([]byte, 16))
+			original := &eapaka.ChallengeContext{
+				ID:         "state-1",
+				Identity:   "0001010000000001@example.org",
+				Type:       eapaka.TypeAKA,
+				Identifier: 7,
+				RAND:       make([]byte, 16),
+				XRES:       []byte{1, 2, 3, 4, 5, 6, 7, 8},
+				AKA:        &keys,
+			}
+			if err := store.PutChallenge(ctx, original); err != nil {
+				t.Fatalf("PutChallenge failed: %v", err)
+			}
+			got, err := store.GetChallenge(ctx, "state-1")
+			if err != nil {
+				t.Fatalf("GetChallenge failed: %v", err)
+			}
+			want := original.Clone()
+			want.ExpiresAt = clock.Now().Add(30 * time.Second)
+			if diff := cmp.Diff(want, got); diff != "" {
+				t.Errorf("ChallengeContext mismatch (-want +got):\n%s", diff)
+			}
+
+			clock.Advance(31 * time.Second)
+			if _, err := store.GetChallenge(ctx, "state-1"); !errors.Is(err, eapaka.ErrSessionNotFound) {
+				t.Errorf("expired GetChallenge: got %v, want ErrSessionNotFound", err)
+			}
+		})
+	}
+}
+
+func TestSessionStore_TakeReauth(t *testing.T) {
+	cfg := eapaka.StoreConfig{MaxReauthCount: 2}
+	ctx := context.Background()
+	keys := eapaka.DeriveKeysAKAPrime("6555444333222111", make([]byte, 16), make([]byte, 16))
+
+	for name, store := range newStores(t, cfg) {
+		t.Run(name, func(t *testing.T) {
+			id := "reauth-0"
+			if err := store.PutReauth(ctx, eapaka.NewReauthContextAKAPrime(id, "6555444333222111", keys)); err != nil {
+				t.Fatalf("PutReauth failed: %v", err)
+			}
+			for i := 1; i <= 2; i++ {
+				r, err := store.TakeReauth(ctx, id)
+				if err != nil {
+					t.Fatalf("TakeReauth #%d failed: %v", i, err)
+				}
+				if r.Counter != uint16(i) {
+					t.Errorf("Counter: got %d, want %d", r.Counter, i)
+				}
+				if !r.K_re.Equal(keys.K_re) || !r.K_encr.Equal(keys.K_encr) || !r.K_aut.Equal(keys.K_aut) {
+					t.Error("keys not preserved")
+				}
+				if _, err := store.TakeReauth(ctx, id); !errors.Is(err, eapaka.ErrSessionNotFound) {
+					t.Errorf("reused re-auth identity: got %v, want ErrSessionNotFound", err)
+				}
+				id = fmt.Sprintf("reauth-%d", i)
+				r.ReauthID = id
+				if err := store.PutReauth(ctx, r); err != nil {
+					t.Fatalf("PutReauth failed: %v", err)
+				}
+			}
+			if _, err := store.TakeReauth(ctx, id); !errors.Is(err, eapaka.ErrReauthLimitReached) {
+				t.Errorf("TakeReauth over limit: got %v, want ErrReauthLimitReached", err)
+			}
+		})
+	}
+}
+
+func TestMemoryStore_Concurrent(t *testing.T) {
+	store := eapaka.NewMemoryStore(eapaka.StoreConfig{MaxReauthCount: 1000})
+	ctx := context.Background()
+	if err := store.PutReauth(ctx, &eapaka.ReauthContext{ReauthID: "shared"}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Exactly one of many concurrent takers may win a single-use identity.
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	wins := 0
+	for i := 0; i < 100; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := store.TakeReauth(ctx, "shared"); err == nil {
+				mu.Lock()
+				wins++
+				mu.Unlock()
+			}
+			store.PutChallenge(ctx, &eapaka.ChallengeContext{ID: fmt.Sprint(i)})
+		}()
+	}
+	wg.Wait()
+	if wins != 1 {
+		t.Errorf("TakeReauth winners: got %d, want 1", wins)
+	}
+	if c, _ := store.Len(); c != 100 {
+		t.Errorf("challenge count: got %d, want 100", c)
+	}
+}
+
+func TestDeriveReauthKeys(t *testing.T) {
+	nonceS := make([]byte, 16)
+	aka := eapaka.DeriveKeysAKA("user", make([]byte, 16), make([]byte, 16))
+	if len(aka.MK) != 20 {
+		t.Fatalf("MK length mismatch: got %d, want 20", len(aka.MK))
+	}
+	r1 := eapaka.NewReauthContextAKA("reauth", "user", aka).DeriveKeys("reauth", 1, nonceS)
+	r2 := eapaka.NewReauthContextAKA("reauth", "user", aka).DeriveKeys("reauth", 2, nonceS)
+	if len(r1.MSK) != 64 || len(r1.EMSK) != 64 || len(r1.XKEY) != 20 {
+		t.Errorf("unexpected AKA re-auth key lengths: %d/%d/%d", len(r1.MSK), len(r1.EMSK), len(r1.XKEY))
+	}
+	if cmp.Equal(r1.MSK, r2.MSK) {
+		t.Error("MSK does not depend on counter")
+	}
+
+	prime := eapaka.DeriveKeysAKAPrime("user", make([]byte, 16), make([]byte, 16))
+	rp := eapaka.NewReauthContextAKAPrime("reauth", "user", prime).DeriveKeys("reauth", 1, nonceS)
+	if len(rp.MSK) != 64 || len(rp.EMSK) != 64 || rp.XKEY != nil {
+		t.Errorf("unexpected AKA' re-auth keys: %d/%d/%v", len(rp.MSK), len(rp.EMSK), rp.XKEY)
+	}
+}
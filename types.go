@@ -1,5 +1,7 @@
 package eapaka
 
+import "strconv"
+
 // EAP Codes (RFC 3748 Section 4)
 const (
 	CodeRequest  uint8 = 1
@@ -10,8 +12,12 @@ const (
 
 // EAP Method Types
 const (
-	TypeAKA      uint8 = 23 // RFC 4187
-	TypeAKAPrime uint8 = 50 // RFC 5448
+	TypeIdentity     uint8 = 1   // RFC 3748 Section 5.1
+	TypeNotification uint8 = 2   // RFC 3748 Section 5.2
+	TypeNak          uint8 = 3   // RFC 3748 Section 5.3.1, Response only
+	TypeAKA          uint8 = 23  // RFC 4187
+	TypeAKAPrime     uint8 = 50  // RFC 5448
+	TypeExpanded     uint8 = 254 // RFC 3748 Section 5.7
 )
 
 // EAP-AKA Subtypes (RFC 4187 Section 11)
@@ -57,3 +63,65 @@ const (
 	AT_RESULT_IND        AttributeType = 135 // RFC 4187 Section 10.14
 	AT_BIDDING           AttributeType = 136 // RFC 5448 Section 6.2 (assigned) / Section 4 (format)
 )
+
+var attributeTypeNames = map[AttributeType]string{
+	AT_RAND:              "AT_RAND",
+	AT_AUTN:              "AT_AUTN",
+	AT_RES:               "AT_RES",
+	AT_AUTS:              "AT_AUTS",
+	AT_PADDING:           "AT_PADDING",
+	AT_NONCE_MT:          "AT_NONCE_MT",
+	AT_PERMANENT_ID_REQ:  "AT_PERMANENT_ID_REQ",
+	AT_MAC:               "AT_MAC",
+	AT_NOTIFICATION:      "AT_NOTIFICATION",
+	AT_ANY_ID_REQ:        "AT_ANY_ID_REQ",
+	AT_IDENTITY:          "AT_IDENTITY",
+	AT_VERSION_LIST:      "AT_VERSION_LIST",
+	AT_SELECTED_VERSION:  "AT_SELECTED_VERSION",
+	AT_FULLAUTH_ID_REQ:   "AT_FULLAUTH_ID_REQ",
+	AT_COUNTER:           "AT_COUNTER",
+	AT_COUNTER_TOO_SMALL: "AT_COUNTER_TOO_SMALL",
+	AT_NONCE_S:           "AT_NONCE_S",
+	AT_CLIENT_ERROR_CODE: "AT_CLIENT_ERROR_CODE",
+	AT_KDF_INPUT:         "AT_KDF_INPUT",
+	AT_KDF:               "AT_KDF",
+	AT_IV:                "AT_IV",
+	AT_ENCR_DATA:         "AT_ENCR_DATA",
+	AT_NEXT_PSEUDONYM:    "AT_NEXT_PSEUDONYM",
+	AT_NEXT_REAUTH_ID:    "AT_NEXT_REAUTH_ID",
+	AT_CHECKCODE:         "AT_CHECKCODE",
+	AT_RESULT_IND:        "AT_RESULT_IND",
+	AT_BIDDING:           "AT_BIDDING",
+}
+
+// String returns the RFC name of the attribute type (e.g., "AT_RAND"),
+// or "AT_UNKNOWN(n)" for unassigned values.
+func (t AttributeType) String() string {
+	if name, ok := attributeTypeNames[t]; ok {
+		return name
+	}
+	return "AT_UNKNOWN(" + strconv.Itoa(int(t)) + ")"
+}
+
+// Notification codes (RFC 4187 Section 10.19).
+// Values include the S bit (0x8000) and P bit (0x4000); see [AtNotification.Value].
+const (
+	NotificationGeneralFailureAfterAuth uint16 = 0
+	NotificationTemporarilyDenied       uint16 = 1026
+	NotificationNotSubscribed           uint16 = 1031
+	NotificationGeneralFailure          uint16 = 16384
+	NotificationSuccess                 uint16 = 32768
+)
+
+// Client error codes (RFC 4187 Section 10.20).
+const (
+	ClientErrorUnableToProcess        uint16 = 0
+	ClientErrorUnsupportedVersion     uint16 = 1
+	ClientErrorInsufficientChallenges uint16 = 2
+	ClientErrorRandsNotFresh          uint16 = 3
+)
+
+// KDF values for AT_KDF (RFC 5448 Section 3.2).
+const (
+	KDFAKAPrimeSHA256 uint16 = 1
+)
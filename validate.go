@@ -0,0 +1,309 @@
+package eapaka
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ViolationKind classifies a [Violation].
+type ViolationKind uint8
+
+const (
+	// ViolationMessage means the Code/Subtype combination is not defined by the profile.
+	ViolationMessage ViolationKind = iota + 1
+	// ViolationMissing means a mandatory attribute is absent.
+	ViolationMissing
+	// ViolationForbidden means the attribute is not allowed in the message.
+	ViolationForbidden
+	// ViolationDuplicate means the attribute occurs more than once.
+	ViolationDuplicate
+	// ViolationExclusive means more than one attribute of a mutually exclusive group is present.
+	ViolationExclusive
+)
+
+func (k ViolationKind) String() string {
+	switch k {
+	case ViolationMessage:
+		return "invalid message"
+	case ViolationMissing:
+		return "missing attribute"
+	case ViolationForbidden:
+		return "forbidden attribute"
+	case ViolationDuplicate:
+		return "duplicate attribute"
+	case ViolationExclusive:
+		return "mutually exclusive attributes"
+	default:
+		return fmt.Sprintf("violation(%d)", uint8(k))
+	}
+}
+
+// Violation describes one way in which a packet breaks the rules of a [Profile].
+type Violation struct {
+	Kind ViolationKind
+
+	// Attribute is the offending attribute type. It is zero for ViolationMessage.
+	Attribute AttributeType
+
+	// Index is the position of the offending attribute in Packet.Attributes,
+	// or -1 if the attribute is absent (ViolationMissing) or not applicable.
+	Index int
+
+	// Reason is a short human-readable explanation.
+	Reason string
+}
+
+func (v Violation) String() string {
+	if v.Attribute == 0 {
+		return fmt.Sprintf("%s: %s", v.Kind, v.Reason)
+	}
+	return fmt.Sprintf("%s %s: %s", v.Kind, v.Attribute, v.Reason)
+}
+
+// ValidationError is returned by [Packet.Validate] and lists every violation found.
+type ValidationError struct {
+	Profile    string
+	Code       uint8
+	Subtype    uint8
+	Violations []Violation
+}
+
+func (e *ValidationError) Error() string {
+	s := make([]string, len(e.Violations))
+	for i, v := range e.Violations {
+		s[i] = v.String()
+	}
+	return fmt.Sprintf("%s code %d subtype %d: %s", e.Profile, e.Code, e.Subtype, strings.Join(s, "; "))
+}
+
+// Has reports whether the error contains a violation of kind k for attribute t.
+func (e *ValidationError) Has(k ViolationKind, t AttributeType) bool {
+	for _, v := range e.Violations {
+		if v.Kind == k && v.Attribute == t {
+			return true
+		}
+	}
+	return false
+}
+
+// Profile is a set of per-message attribute rules for one EAP method.
+// Use [ProfileAKA] or [ProfileAKAPrime].
+type Profile struct {
+	name    string
+	eapType uint8
+	rules   map[messageKey]*messageRule
+}
+
+// Name returns the name of the profile (e.g., "EAP-AKA'").
+func (pr *Profile) Name() string { return pr.name }
+
+type messageKey struct {
+	code    uint8
+	subtype uint8
+}
+
+type messageRule struct {
+	mandatory []AttributeType
+	optional  []AttributeType
+	repeated  []AttributeType // may occur more than once
+	oneOf     []AttributeType // exactly one of the group, if non-empty
+}
+
+func (r *messageRule) allows(t AttributeType) bool {
+	return contains(r.mandatory, t) || contains(r.optional, t) || contains(r.oneOf, t)
+}
+
+func contains(ts []AttributeType, t AttributeType) bool {
+	for _, x := range ts {
+		if x == t {
+			return true
+		}
+	}
+	return false
+}
+
+// Attribute rules of RFC 4187 Section 9. AT_PADDING, AT_NEXT_PSEUDONYM,
+// AT_NEXT_REAUTH_ID, AT_COUNTER, AT_COUNTER_TOO_SMALL and AT_NONCE_S are only
+// valid inside AT_ENCR_DATA and are therefore forbidden at the top level.
+func akaRules() map[messageKey]*messageRule {
+	return map[messageKey]*messageRule{
+		// Section 9.1
+		{CodeRequest, SubtypeIdentity}: {
+			oneOf: []AttributeType{AT_PERMANENT_ID_REQ, AT_FULLAUTH_ID_REQ, AT_ANY_ID_REQ},
+		},
+		// Section 9.2
+		{CodeResponse, SubtypeIdentity}: {
+			optional: []AttributeType{AT_IDENTITY},
+		},
+		// Section 9.3
+		{CodeRequest, SubtypeChallenge}: {
+			mandatory: []AttributeType{AT_RAND, AT_AUTN, AT_MAC},
+			optional:  []AttributeType{AT_RESULT_IND, AT_CHECKCODE, AT_IV, AT_ENCR_DATA, AT_BIDDING},
+		},
+		// Section 9.4
+		{CodeResponse, SubtypeChallenge}: {
+			mandatory: []AttributeType{AT_RES, AT_MAC},
+			optional:  []AttributeType{AT_RESULT_IND, AT_CHECKCODE},
+		},
+		// Section 9.5
+		{CodeResponse, SubtypeAuthenticationReject}: {},
+		// Section 9.6
+		{CodeResponse, SubtypeSynchronizationFailure}: {
+			mandatory: []AttributeType{AT_AUTS},
+		},
+		// Section 9.7
+		{CodeRequest, SubtypeReauthentication}: {
+			mandatory: []AttributeType{AT_IV, AT_ENCR_DATA, AT_MAC},
+			optional:  []AttributeType{AT_RESULT_IND, AT_CHECKCODE},
+		},
+		// Section 9.8
+		{CodeResponse, SubtypeReauthentication}: {
+			mandatory: []AttributeType{AT_IV, AT_ENCR_DATA, AT_MAC},
+			optional:  []AttributeType{AT_RESULT_IND, AT_CHECKCODE},
+		},
+		// Section 9.10; AT_MAC depends on the P bit and is checked separately.
+		{CodeRequest, SubtypeNotification}: {
+			mandatory: []AttributeType{AT_NOTIFICATION},
+			optional:  []AttributeType{AT_IV, AT_ENCR_DATA, AT_MAC},
+		},
+		// Section 9.11
+		{CodeResponse, SubtypeNotification}: {
+			optional: []AttributeType{AT_IV, AT_ENCR_DATA, AT_MAC},
+		},
+		// Section 9.12
+		{CodeResponse, SubtypeClientError}: {
+			mandatory: []AttributeType{AT_CLIENT_ERROR_CODE},
+		},
+	}
+}
+
+// Changes of RFC 5448 Section 3 to the EAP-AKA rules.
+func akaPrimeRules() map[messageKey]*messageRule {
+	rules := akaRules()
+	// AT_BIDDING is only meaningful in EAP-AKA (Section 4).
+	rules[messageKey{CodeRequest, SubtypeChallenge}] = &messageRule{
+		mandatory: []AttributeType{AT_RAND, AT_AUTN, AT_MAC, AT_KDF_INPUT, AT_KDF},
+		optional:  []AttributeType{AT_RESULT_IND, AT_CHECKCODE, AT_IV, AT_ENCR_DATA},
+		repeated:  []AttributeType{AT_KDF},
+	}
+	rules[messageKey{CodeResponse, SubtypeSynchronizationFailure}] = &messageRule{
+		mandatory: []AttributeType{AT_AUTS},
+		optional:  []AttributeType{AT_KDF},
+	}
+	return rules
+}
+
+// kdfNegotiationRule applies to an EAP-AKA' Challenge Response that carries AT_KDF:
+// the peer asks for another KDF and sends nothing else (RFC 5448 Section 3.2).
+var kdfNegotiationRule = &messageRule{mandatory: []AttributeType{AT_KDF}}
+
+// Profiles for [Packet.ValidateProfile].
+var (
+	ProfileAKA      = &Profile{name: "EAP-AKA", eapType: TypeAKA, rules: akaRules()}
+	ProfileAKAPrime = &Profile{name: "EAP-AKA'", eapType: TypeAKAPrime, rules: akaPrimeRules()}
+)
+
+// Validate checks that the packet carries the attributes required and allowed for
+// its Code and Subtype, using the profile of its Type ([ProfileAKA] or
+// [ProfileAKAPrime]). It returns a *[ValidationError] listing every violation.
+//
+// Attributes inside AT_ENCR_DATA are not checked, as they need K_encr.
+// EAP-Success and EAP-Failure are always valid.
+func (p *Packet) Validate() error {
+	switch p.Type {
+	case TypeAKA:
+		return p.ValidateProfile(ProfileAKA)
+	case TypeAKAPrime:
+		return p.ValidateProfile(ProfileAKAPrime)
+	}
+	if p.Code == CodeSuccess || p.Code == CodeFailure {
+		return nil
+	}
+	return &ValidationError{Profile: "EAP", Code: p.Code, Subtype: p.Subtype, Violations: []Violation{{
+		Kind: ViolationMessage, Index: -1, Reason: fmt.Sprintf("EAP type %d is not EAP-AKA or EAP-AKA'", p.Type),
+	}}}
+}
+
+// ValidateProfile is like [Packet.Validate] with an explicit profile.
+func (p *Packet) ValidateProfile(pr *Profile) error {
+	if p.Code == CodeSuccess || p.Code == CodeFailure {
+		return nil
+	}
+	e := &ValidationError{Profile: pr.name, Code: p.Code, Subtype: p.Subtype}
+	add := func(k ViolationKind, t AttributeType, i int, format string, args ...any) {
+		e.Violations = append(e.Violations, Violation{Kind: k, Attribute: t, Index: i, Reason: fmt.Sprintf(format, args...)})
+	}
+
+	if p.Type != pr.eapType {
+		add(ViolationMessage, 0, -1, "EAP type %d does not match the profile", p.Type)
+		return e
+	}
+	rule, ok := pr.rules[messageKey{p.Code, p.Subtype}]
+	if !ok {
+		add(ViolationMessage, 0, -1, "subtype %d is not defined for code %d", p.Subtype, p.Code)
+		return e
+	}
+	if pr.eapType == TypeAKAPrime && p.Code == CodeResponse && p.Subtype == SubtypeChallenge && p.Has(AT_KDF) {
+		rule = kdfNegotiationRule
+	}
+
+	first := make(map[AttributeType]int)
+	for i, attr := range p.Attributes {
+		t := attr.Type()
+		if !rule.allows(t) {
+			add(ViolationForbidden, t, i, "not allowed in this message")
+			continue
+		}
+		if _, seen := first[t]; seen {
+			if !contains(rule.repeated, t) {
+				add(ViolationDuplicate, t, i, "already present at index %d", first[t])
+			}
+			continue
+		}
+		first[t] = i
+	}
+	for _, t := range rule.mandatory {
+		if _, ok := first[t]; !ok {
+			add(ViolationMissing, t, -1, "mandatory in this message")
+		}
+	}
+	if len(rule.oneOf) > 0 {
+		seen := false
+		for i, attr := range p.Attributes {
+			if !contains(rule.oneOf, attr.Type()) || first[attr.Type()] != i {
+				continue
+			}
+			if seen {
+				add(ViolationExclusive, attr.Type(), i, "only one of %v is allowed", rule.oneOf)
+			}
+			seen = true
+		}
+		if !seen {
+			add(ViolationMissing, rule.oneOf[0], -1, "one of %v is required", rule.oneOf)
+		}
+	}
+
+	// RFC 4187 Section 9.10: AT_MAC is required with the P bit cleared and must
+	// not be used with the P bit set. The same holds for the encrypted attributes.
+	// A missing AT_NOTIFICATION is already reported above.
+	if i, ok := first[AT_NOTIFICATION]; ok && p.Code == CodeRequest && p.Subtype == SubtypeNotification {
+		if n, ok := p.Attributes[i].(*AtNotification); ok {
+			if !n.P {
+				if _, ok := first[AT_MAC]; !ok {
+					add(ViolationMissing, AT_MAC, -1, "required when the P bit is zero")
+				}
+			} else {
+				for _, t := range []AttributeType{AT_MAC, AT_IV, AT_ENCR_DATA} {
+					if j, ok := first[t]; ok {
+						add(ViolationForbidden, t, j, "not allowed when the P bit is set")
+					}
+				}
+			}
+		}
+	}
+
+	if len(e.Violations) == 0 {
+		return nil
+	}
+	return e
+}
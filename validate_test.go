@@ -0,0 +1,124 @@
+package eapaka_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/oyaguma3/go-eapaka"
+)
+
+func TestPacket_Validate(t *testing.T) {
+	rand16 := make([]byte, 16)
+	tests := []struct {
+		name    string
+		pkt     *eapaka.Packet
+		wantErr bool
+		kind    eapaka.ViolationKind
+		attr    eapaka.AttributeType
+	}{
+		{
+			name: "AKA challenge request",
+			pkt: &eapaka.Packet{Code: eapaka.CodeRequest, Type: eapaka.TypeAKA, Subtype: eapaka.SubtypeChallenge,
+				Attributes: []eapaka.Attribute{&eapaka.AtRand{Rand: rand16}, &eapaka.AtAutn{Autn: rand16}, &eapaka.AtBidding{}, &eapaka.AtMac{}}},
+		},
+		{
+			name: "challenge request without AT_AUTN",
+			pkt: &eapaka.Packet{Code: eapaka.CodeRequest, Type: eapaka.TypeAKA, Subtype: eapaka.SubtypeChallenge,
+				Attributes: []eapaka.Attribute{&eapaka.AtRand{Rand: rand16}, &eapaka.AtMac{}}},
+			wantErr: true, kind: eapaka.ViolationMissing, attr: eapaka.AT_AUTN,
+		},
+		{
+			name: "AT_RES in a request",
+			pkt: &eapaka.Packet{Code: eapaka.CodeRequest, Type: eapaka.TypeAKA, Subtype: eapaka.SubtypeChallenge,
+				Attributes: []eapaka.Attribute{&eapaka.AtRand{Rand: rand16}, &eapaka.AtAutn{Autn: rand16}, &eapaka.AtRes{Res: rand16[:8]}, &eapaka.AtMac{}}},
+			wantErr: true, kind: eapaka.ViolationForbidden, attr: eapaka.AT_RES,
+		},
+		{
+			name: "AT_BIDDING in AKA'",
+			pkt: &eapaka.Packet{Code: eapaka.CodeRequest, Type: eapaka.TypeAKAPrime, Subtype: eapaka.SubtypeChallenge,
+				Attributes: []eapaka.Attribute{&eapaka.AtRand{Rand: rand16}, &eapaka.AtAutn{Autn: rand16}, &eapaka.AtKdfInput{NetworkName: "WLAN"},
+					&eapaka.AtKdf{KDF: 1}, &eapaka.AtBidding{}, &eapaka.AtMac{}}},
+			wantErr: true, kind: eapaka.ViolationForbidden, attr: eapaka.AT_BIDDING,
+		},
+		{
+			name: "AKA' challenge request with two AT_KDF",
+			pkt: &eapaka.Packet{Code: eapaka.CodeRequest, Type: eapaka.TypeAKAPrime, Subtype: eapaka.SubtypeChallenge,
+				Attributes: []eapaka.Attribute{&eapaka.AtRand{Rand: rand16}, &eapaka.AtAutn{Autn: rand16}, &eapaka.AtKdfInput{NetworkName: "WLAN"},
+					&eapaka.AtKdf{KDF: 2}, &eapaka.AtKdf{KDF: 1}, &eapaka.AtMac{}}},
+		},
+		{
+			name: "AKA' challenge request without AT_KDF_INPUT",
+			pkt: &eapaka.Packet{Code: eapaka.CodeRequest, Type: eapaka.TypeAKAPrime, Subtype: eapaka.SubtypeChallenge,
+				Attributes: []eapaka.Attribute{&eapaka.AtRand{Rand: rand16}, &eapaka.AtAutn{Autn: rand16}, &eapaka.AtKdf{KDF: 1}, &eapaka.AtMac{}}},
+			wantErr: true, kind: eapaka.ViolationMissing, attr: eapaka.AT_KDF_INPUT,
+		},
+		{
+			name: "AKA' KDF negotiation response",
+			pkt: &eapaka.Packet{Code: eapaka.CodeResponse, Type: eapaka.TypeAKAPrime, Subtype: eapaka.SubtypeChallenge,
+				Attributes: []eapaka.Attribute{&eapaka.AtKdf{KDF: 1}}},
+		},
+		{
+			name: "duplicate AT_MAC",
+			pkt: &eapaka.Packet{Code: eapaka.CodeResponse, Type: eapaka.TypeAKA, Subtype: eapaka.SubtypeChallenge,
+				Attributes: []eapaka.Attribute{&eapaka.AtRes{Res: rand16[:8]}, &eapaka.AtMac{}, &eapaka.AtMac{}}},
+			wantErr: true, kind: eapaka.ViolationDuplicate, attr: eapaka.AT_MAC,
+		},
+		{
+			name: "two identity requests",
+			pkt: &eapaka.Packet{Code: eapaka.CodeRequest, Type: eapaka.TypeAKA, Subtype: eapaka.SubtypeIdentity,
+				Attributes: []eapaka.Attribute{&eapaka.AtAnyIdReq{}, &eapaka.AtPermanentIdReq{}}},
+			wantErr: true, kind: eapaka.ViolationExclusive, attr: eapaka.AT_PERMANENT_ID_REQ,
+		},
+		{
+			name: "notification after challenge without AT_MAC",
+			pkt: &eapaka.Packet{Code: eapaka.CodeRequest, Type: eapaka.TypeAKA, Subtype: eapaka.SubtypeNotification,
+				Attributes: []eapaka.Attribute{eapaka.NewAtNotification(eapaka.NotificationSuccess)}},
+			wantErr: true, kind: eapaka.ViolationMissing, attr: eapaka.AT_MAC,
+		},
+		{
+			name: "notification before challenge with AT_MAC",
+			pkt: &eapaka.Packet{Code: eapaka.CodeRequest, Type: eapaka.TypeAKA, Subtype: eapaka.SubtypeNotification,
+				Attributes: []eapaka.Attribute{eapaka.NewAtNotification(eapaka.NotificationGeneralFailure), &eapaka.AtMac{}}},
+			wantErr: true, kind: eapaka.ViolationForbidden, attr: eapaka.AT_MAC,
+		},
+		{
+			name:    "notification without attributes",
+			pkt:     &eapaka.Packet{Code: eapaka.CodeRequest, Type: eapaka.TypeAKA, Subtype: eapaka.SubtypeNotification},
+			wantErr: true, kind: eapaka.ViolationMissing, attr: eapaka.AT_NOTIFICATION,
+		},
+		{
+			name: "notification with AT_MAC but without AT_NOTIFICATION",
+			pkt: &eapaka.Packet{Code: eapaka.CodeRequest, Type: eapaka.TypeAKA, Subtype: eapaka.SubtypeNotification,
+				Attributes: []eapaka.Attribute{&eapaka.AtMac{}}},
+			wantErr: true, kind: eapaka.ViolationMissing, attr: eapaka.AT_NOTIFICATION,
+		},
+		{
+			name:    "client error request",
+			pkt:     &eapaka.Packet{Code: eapaka.CodeRequest, Type: eapaka.TypeAKA, Subtype: eapaka.SubtypeClientError},
+			wantErr: true, kind: eapaka.ViolationMessage,
+		},
+		{
+			name: "EAP-Success",
+			pkt:  &eapaka.Packet{Code: eapaka.CodeSuccess},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.pkt.Validate()
+			if !tt.wantErr {
+				if err != nil {
+					t.Fatalf("Validate failed: %v", err)
+				}
+				return
+			}
+			var verr *eapaka.ValidationError
+			if !errors.As(err, &verr) {
+				t.Fatalf("Validate returned %v, want *ValidationError", err)
+			}
+			if !verr.Has(tt.kind, tt.attr) {
+				t.Errorf("violation %v %v not reported: %v", tt.kind, tt.attr, err)
+			}
+		})
+	}
+}
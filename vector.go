@@ -0,0 +1,64 @@
+package eapaka
+
+import (
+	"context"
+	"errors"
+)
+
+// ErrUnknownSubscriber is returned by a [VectorProvider] when it has no
+// subscription data for the requested IMSI.
+var ErrUnknownSubscriber = errors.New("eapaka: unknown subscriber")
+
+// AuthVector is a UMTS authentication vector (quintuplet).
+// See 3GPP TS 33.102 Section 6.3.2.
+type AuthVector struct {
+	RAND []byte // 16 bytes
+	AUTN []byte // 16 bytes
+	XRES []byte // 4 to 16 bytes
+	CK   []byte // 16 bytes
+	IK   []byte // 16 bytes
+
+	// Prime reports that CK and IK already hold CK' and IK'
+	// (as returned by an HSS or UDM for EAP-AKA', TS 33.402 Section 6.2).
+	Prime bool
+}
+
+// CKIKPrime returns CK' and IK' for the given access network name,
+// deriving them with [DeriveCKPrimeIKPrime] unless the vector already carries them.
+func (v *AuthVector) CKIKPrime(netName string) (ckPrime, ikPrime []byte, err error) {
+	if v.Prime {
+		return v.CK, v.IK, nil
+	}
+	return DeriveCKPrimeIKPrime(v.CK, v.IK, netName, v.AUTN)
+}
+
+// VectorRequest describes the vector a [VectorProvider] should produce.
+type VectorRequest struct {
+	// IMSI identifies the subscriber (digits only).
+	IMSI string
+
+	// Type is TypeAKA or TypeAKAPrime.
+	Type uint8
+
+	// NetworkName is the access network name used for EAP-AKA' (e.g., "WLAN").
+	NetworkName string
+
+	// RAND and AUTS are set when the peer reported a synchronization failure.
+	// The provider re-synchronises SQN before generating the new vector.
+	// See TS 33.102 Section 6.3.5.
+	RAND []byte
+	AUTS []byte
+}
+
+// VectorProvider supplies authentication vectors, typically from an HSS, UDM or AuC.
+type VectorProvider interface {
+	GetVector(ctx context.Context, req *VectorRequest) (*AuthVector, error)
+}
+
+// VectorProviderFunc adapts an ordinary function to a [VectorProvider].
+type VectorProviderFunc func(ctx context.Context, req *VectorRequest) (*AuthVector, error)
+
+// GetVector calls f(ctx, req).
+func (f VectorProviderFunc) GetVector(ctx context.Context, req *VectorRequest) (*AuthVector, error) {
+	return f(ctx, req)
+}
@@ -0,0 +1,151 @@
+package eapaka
+
+import (
+	"encoding/binary"
+	"iter"
+)
+
+// View is a read-only view over the bytes of an EAP packet. Unlike [Parse], it
+// neither copies nor decodes attributes, so inspecting a packet (e.g., for
+// routing or logging) does not allocate. Attributes are decoded on demand with
+// [RawAttribute.Decode].
+//
+// A View refers to the bytes passed to [NewView]; they must not be modified while
+// the View is in use.
+type View struct {
+	b []byte // the packet, up to the EAP Length
+}
+
+// RawAttribute is an undecoded attribute of a [View].
+type RawAttribute struct {
+	Type   AttributeType
+	Length int    // length of the attribute in bytes, including the header
+	Offset int    // offset of the attribute in the packet
+	Value  []byte // the attribute after the Type and Length bytes, including padding
+}
+
+// Decode decodes the attribute into its typed form (e.g., *AtRand).
+// Unknown types are returned as *[GenericAttribute].
+func (r RawAttribute) Decode() (Attribute, error) {
+	attr, err := decodeAttribute(r.Type, r.Value)
+	if err != nil {
+		return nil, &ParseError{Offset: r.Offset, Attribute: r.Type, Reason: err.Error(), Err: ErrMalformedAttribute}
+	}
+	return attr, nil
+}
+
+// NewView checks the framing of the EAP packet in data (the EAP header, and for
+// EAP-AKA/AKA' the method header and attribute lengths) and returns a view over it.
+// Bytes after the EAP Length are ignored. Errors are of type *[ParseError].
+func NewView(data []byte) (View, error) {
+	if len(data) < 4 {
+		return View{}, &ParseError{Offset: len(data), Reason: "packet too short", Err: ErrTruncated}
+	}
+	length := int(binary.BigEndian.Uint16(data[2:4]))
+	if err := checkLength(data, length); err != nil {
+		return View{}, err
+	}
+	v := View{b: data[:length:length]}
+	if !v.isAKA() {
+		return v, nil
+	}
+	if length < 8 {
+		return View{}, &ParseError{Offset: 4, Reason: "EAP-AKA header truncated", Err: ErrTruncated}
+	}
+	for off := 8; off < length; {
+		if off+2 > length {
+			return View{}, &ParseError{Offset: off, Reason: "attribute header truncated", Err: ErrTruncated}
+		}
+		n := int(v.b[off+1]) * 4
+		if err := checkAttributeLength(AttributeType(v.b[off]), off, n, length-off); err != nil {
+			return View{}, err
+		}
+		off += n
+	}
+	return v, nil
+}
+
+// Bytes returns the packet bytes, up to the EAP Length.
+func (v View) Bytes() []byte { return v.b }
+
+// Code returns the EAP Code.
+func (v View) Code() uint8 { return v.b[0] }
+
+// Identifier returns the EAP Identifier.
+func (v View) Identifier() uint8 { return v.b[1] }
+
+// Length returns the EAP Length.
+func (v View) Length() int { return len(v.b) }
+
+// Type returns the EAP Type, or 0 for EAP-Success/Failure and empty packets.
+func (v View) Type() uint8 {
+	if len(v.b) < 5 || (v.b[0] != CodeRequest && v.b[0] != CodeResponse) {
+		return 0
+	}
+	return v.b[4]
+}
+
+// Subtype returns the EAP-AKA/AKA' Subtype, or 0 for other packets.
+func (v View) Subtype() uint8 {
+	if !v.isAKA() {
+		return 0
+	}
+	return v.b[5]
+}
+
+func (v View) isAKA() bool {
+	t := v.Type()
+	return t == TypeAKA || t == TypeAKAPrime
+}
+
+// Attributes returns an iterator over the attributes of an EAP-AKA/AKA' packet,
+// in order. It yields nothing for other packets.
+func (v View) Attributes() iter.Seq[RawAttribute] {
+	return func(yield func(RawAttribute) bool) {
+		if !v.isAKA() {
+			return
+		}
+		// Framing was checked by NewView.
+		for off := 8; off < len(v.b); {
+			n := int(v.b[off+1]) * 4
+			ra := RawAttribute{Type: AttributeType(v.b[off]), Length: n, Offset: off, Value: v.b[off+2 : off+n]}
+			if !yield(ra) {
+				return
+			}
+			off += n
+		}
+	}
+}
+
+// Attribute returns the first attribute of type t.
+func (v View) Attribute(t AttributeType) (RawAttribute, bool) {
+	for ra := range v.Attributes() {
+		if ra.Type == t {
+			return ra, true
+		}
+	}
+	return RawAttribute{}, false
+}
+
+// Identity returns the identity carried by the packet without copying: the
+// Type-Data of an EAP-Response/Identity, or the AT_IDENTITY of an EAP-AKA/AKA'
+// packet. ok is false if there is none.
+func (v View) Identity() (identity []byte, ok bool) {
+	if v.Code() == CodeResponse && v.Type() == TypeIdentity {
+		return v.b[5:], true
+	}
+	ra, ok := v.Attribute(AT_IDENTITY)
+	if !ok || len(ra.Value) < 2 {
+		return nil, false
+	}
+	n := int(binary.BigEndian.Uint16(ra.Value))
+	if 2+n > len(ra.Value) {
+		return nil, false
+	}
+	return ra.Value[2 : 2+n], true
+}
+
+// Packet fully decodes the viewed packet with [Parse].
+func (v View) Packet() (*Packet, error) {
+	return Parse(v.b)
+}
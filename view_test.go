@@ -0,0 +1,114 @@
+package eapaka_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/oyaguma3/go-eapaka"
+)
+
+func TestView(t *testing.T) {
+	p := &eapaka.Packet{
+		Code:       eapaka.CodeResponse,
+		Identifier: 4,
+		Type:       eapaka.TypeAKAPrime,
+		Subtype:    eapaka.SubtypeIdentity,
+		Attributes: []eapaka.Attribute{
+			&eapaka.AtIdentity{Identity: "6001010123456789@example.org"},
+			&eapaka.GenericAttribute{AttrType: 200, Data: []byte{1, 2}},
+		},
+	}
+	raw, err := p.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw = append(raw, 0xff) // ignored trailing byte
+
+	v, err := eapaka.NewView(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v.Code() != eapaka.CodeResponse || v.Identifier() != 4 || v.Type() != eapaka.TypeAKAPrime ||
+		v.Subtype() != eapaka.SubtypeIdentity || v.Length() != len(raw)-1 {
+		t.Errorf("header: code=%d id=%d type=%d subtype=%d length=%d", v.Code(), v.Identifier(), v.Type(), v.Subtype(), v.Length())
+	}
+
+	var types []eapaka.AttributeType
+	for ra := range v.Attributes() {
+		types = append(types, ra.Type)
+	}
+	if len(types) != 2 || types[0] != eapaka.AT_IDENTITY || types[1] != 200 {
+		t.Errorf("attributes: %v", types)
+	}
+
+	id, ok := v.Identity()
+	if !ok || string(id) != "6001010123456789@example.org" {
+		t.Errorf("Identity = %q, %v", id, ok)
+	}
+	ra, _ := v.Attribute(eapaka.AT_IDENTITY)
+	attr, err := ra.Decode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a, ok := attr.(*eapaka.AtIdentity); !ok || a.Identity != string(id) {
+		t.Errorf("Decode = %#v", attr)
+	}
+
+	allocs := testing.AllocsPerRun(100, func() {
+		v, _ := eapaka.NewView(raw)
+		for range v.Attributes() {
+		}
+		v.Identity()
+	})
+	if allocs != 0 {
+		t.Errorf("view inspection allocated %v times, want 0", allocs)
+	}
+}
+
+func TestView_EAPIdentity(t *testing.T) {
+	raw := append([]byte{eapaka.CodeResponse, 1, 0, 12, 1}, "0001010"...)
+	v, err := eapaka.NewView(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id, ok := v.Identity(); !ok || !bytes.Equal(id, []byte("0001010")) {
+		t.Errorf("Identity = %q, %v", id, ok)
+	}
+	if v.Subtype() != 0 {
+		t.Errorf("Subtype = %d, want 0", v.Subtype())
+	}
+}
+
+func TestView_Malformed(t *testing.T) {
+	for _, raw := range [][]byte{
+		{1, 1, 0},
+		{1, 1, 0, 9, 23, 1, 0, 0},
+		{1, 1, 0, 12, 23, 1, 0, 0, 1, 0, 0, 0},
+		{1, 1, 0, 12, 23, 1, 0, 0, 1, 5, 0, 0},
+	} {
+		if _, err := eapaka.NewView(raw); err == nil {
+			t.Errorf("NewView(%x) succeeded", raw)
+		}
+	}
+}
+
+func BenchmarkView_Identity(b *testing.B) {
+	raw, _ := (&eapaka.Packet{
+		Code: eapaka.CodeResponse, Identifier: 4, Type: eapaka.TypeAKAPrime, Subtype: eapaka.SubtypeIdentity,
+		Attributes: []eapaka.Attribute{&eapaka.AtIdentity{Identity: "6001010123456789@example.org"}},
+	}).Marshal()
+	b.Run("Parse", func(b *testing.B) {
+		b.ReportAllocs()
+		for b.Loop() {
+			p, _ := eapaka.Parse(raw)
+			_ = p.Attributes[0].(*eapaka.AtIdentity).Identity
+		}
+	})
+	b.Run("View", func(b *testing.B) {
+		b.ReportAllocs()
+		for b.Loop() {
+			v, _ := eapaka.NewView(raw)
+			v.Identity()
+		}
+	})
+}